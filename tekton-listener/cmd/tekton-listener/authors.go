@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"path"
+	"strings"
+
+	"github.com/knative/pkg/logging"
+	"github.com/pkg/errors"
+)
+
+// authorFilter holds login patterns, which may use path.Match wildcards, of
+// the authors, such as bots, whose events are not run.
+type authorFilter []string
+
+// parseAuthorFilter parses comma separated login patterns. Patterns are
+// matched case insensitively.
+func parseAuthorFilter(s string) (authorFilter, error) {
+	var filter authorFilter
+	for _, pattern := range strings.Split(s, ",") {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if pattern == "" {
+			continue
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, errors.Wrapf(err, "Invalid author pattern %q", pattern)
+		}
+		filter = append(filter, pattern)
+	}
+	return filter, nil
+}
+
+// matches reports whether login matches any of the patterns. An empty filter
+// matches no author.
+func (f authorFilter) matches(login string) bool {
+	if login == "" {
+		return false
+	}
+	login = strings.ToLower(login)
+	for _, pattern := range f {
+		if ok, _ := path.Match(pattern, login); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// skipAuthor reports whether events by login should be skipped because it
+// matches the configured ignored authors.
+func (e *EventListener) skipAuthor(ctx context.Context, login string) bool {
+	if !e.ignoreAuthors.matches(login) {
+		return false
+	}
+	logging.FromContext(ctx).Infof("Author %q is ignored, skipping", login)
+	return true
+}
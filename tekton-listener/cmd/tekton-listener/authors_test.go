@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestAuthorFilter(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns string
+		login    string
+		want     bool
+	}{
+		{name: "empty", patterns: "", login: "dependabot[bot]", want: false},
+		{name: "match", patterns: "renovate", login: "renovate", want: true},
+		{name: "case insensitive", patterns: "Renovate", login: "RENOVATE", want: true},
+		{name: "wildcard", patterns: "*bot]", login: "dependabot[bot]", want: true},
+		{name: "list", patterns: "renovate, dependabot*", login: "dependabot-preview[bot]", want: true},
+		{name: "no match", patterns: "dependabot*", login: "octocat", want: false},
+		{name: "unknown author", patterns: "*", login: "", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := parseAuthorFilter(tt.patterns)
+			if err != nil {
+				t.Fatalf("Unexpected error parsing %q: %s", tt.patterns, err)
+			}
+			if got := filter.matches(tt.login); got != tt.want {
+				t.Errorf("Expected %q to match %q: %t, got %t", tt.login, tt.patterns, tt.want, got)
+			}
+		})
+	}
+
+	if _, err := parseAuthorFilter("bot[s"); err == nil {
+		t.Error("Expected an error parsing an invalid pattern")
+	}
+}
+
+func TestHandleRequestIgnoreAuthors(t *testing.T) {
+	pushPayload := func(login string) []byte {
+		payload, err := json.Marshal(map[string]interface{}{
+			"ref":        "refs/heads/master",
+			"after":      "def456",
+			"repository": map[string]interface{}{"full_name": "foo/bar"},
+			"sender":     map[string]interface{}{"login": login},
+		})
+		if err != nil {
+			t.Fatalf("Error marshalling payload: %s", err)
+		}
+		return payload
+	}
+	pullRequestPayload := func(name string) []byte {
+		payload, err := json.Marshal(map[string]interface{}{
+			"eventKey": "pr:opened",
+			"pullRequest": map[string]interface{}{
+				"id":      1,
+				"state":   "OPEN",
+				"fromRef": map[string]interface{}{"latestCommit": "abc123"},
+				"author":  map[string]interface{}{"user": map[string]interface{}{"name": name}},
+			},
+		})
+		if err != nil {
+			t.Fatalf("Error marshalling payload: %s", err)
+		}
+		return payload
+	}
+
+	tests := []struct {
+		name      string
+		eventType string
+		payload   []byte
+		wantRuns  int
+	}{
+		{name: "github bot", eventType: githubPushEventType, payload: pushPayload("dependabot[bot]")},
+		{name: "github human", eventType: githubPushEventType, payload: pushPayload("octocat"), wantRuns: 1},
+		{name: "bitbucket bot", eventType: bitbucketPullRequestEventType, payload: pullRequestPayload("renovate")},
+		{name: "bitbucket human", eventType: bitbucketPullRequestEventType, payload: pullRequestPayload("jdoe"), wantRuns: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, logs := newTestListener()
+			e.eventType = tt.eventType
+			filter, err := parseAuthorFilter("dependabot*, renovate")
+			if err != nil {
+				t.Fatalf("Error parsing ignored authors: %s", err)
+			}
+			e.ignoreAuthors = filter
+			if err := e.HandleRequest(context.Background(), newEvent(t, "delivery-1234", tt.eventType, tt.payload)); err != nil {
+				t.Fatalf("Error handling request: %s", err)
+			}
+
+			runs, err := e.pipelineClientset.Tekton().PipelineRuns(e.namespace).List(metav1.ListOptions{})
+			if err != nil {
+				t.Fatalf("Error listing pipelineruns: %s", err)
+			}
+			if len(runs.Items) != tt.wantRuns {
+				t.Errorf("Expected %d pipelineruns, got %d", tt.wantRuns, len(runs.Items))
+			}
+			skipped := 0
+			for _, entry := range logs.All() {
+				if strings.HasPrefix(entry.Message, "Author ") {
+					skipped++
+				}
+			}
+			if wantSkipped := 1 - tt.wantRuns; skipped != wantSkipped {
+				t.Errorf("Expected %d skip log lines, got %d: %+v", wantSkipped, skipped, logs.All())
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	"github.com/cloudevents/sdk-go/pkg/cloudevents"
+	"github.com/knative/pkg/logging"
+	"github.com/pkg/errors"
+)
+
+const (
+	bitbucketPushEventType        = "com.bitbucket.push"
+	bitbucketPullRequestEventType = "com.bitbucket.pullrequest"
+
+	// bitbucketNullHash is the hash Bitbucket Server reports for the new
+	// side of a deleted ref.
+	bitbucketNullHash = "0000000000000000000000000000000000000000"
+)
+
+// The bitbucket package of the webhooks library models Bitbucket Cloud
+// payloads, which differ from Bitbucket Server's, so the fields used from the
+// Server payloads are declared here.
+
+// bitbucketPushPayload is the repo:refs_changed payload sent by Bitbucket Server.
+type bitbucketPushPayload struct {
+	EventKey string                  `json:"eventKey"`
+	Changes  []bitbucketRefChange    `json:"changes"`
+	Repo     bitbucketRepositoryInfo `json:"repository"`
+	Actor    bitbucketUser           `json:"actor"`
+}
+
+type bitbucketRefChange struct {
+	RefID    string `json:"refId"`
+	FromHash string `json:"fromHash"`
+	ToHash   string `json:"toHash"`
+	Type     string `json:"type"`
+}
+
+// bitbucketUser is the user that pushed or authored a pull request.
+type bitbucketUser struct {
+	Name string `json:"name"`
+}
+
+type bitbucketRepositoryInfo struct {
+	Slug    string `json:"slug"`
+	Project struct {
+		Key string `json:"key"`
+	} `json:"project"`
+}
+
+// fullName returns the repository as project/slug, the form matched by the
+// repository filter.
+func (r bitbucketRepositoryInfo) fullName() string {
+	return r.Project.Key + "/" + r.Slug
+}
+
+// bitbucketPullRequestPayload is the pr:* payload sent by Bitbucket Server.
+type bitbucketPullRequestPayload struct {
+	EventKey    string `json:"eventKey"`
+	PullRequest struct {
+		ID      int    `json:"id"`
+		State   string `json:"state"`
+		FromRef struct {
+			ID           string `json:"id"`
+			LatestCommit string `json:"latestCommit"`
+		} `json:"fromRef"`
+		ToRef struct {
+			ID   string                  `json:"id"`
+			Repo bitbucketRepositoryInfo `json:"repository"`
+		} `json:"toRef"`
+		Author struct {
+			User bitbucketUser `json:"user"`
+		} `json:"author"`
+	} `json:"pullRequest"`
+}
+
+// pushChange returns the change of the first ref a push moved, ignoring
+// deleted refs.
+func (p *bitbucketPushPayload) pushChange() (bitbucketRefChange, bool) {
+	for _, change := range p.Changes {
+		if strings.EqualFold(change.Type, "DELETE") || change.ToHash == "" || change.ToHash == bitbucketNullHash {
+			continue
+		}
+		return change, true
+	}
+	return bitbucketRefChange{}, false
+}
+
+func (e *EventListener) handleBitbucketPush(ctx context.Context, event cloudevents.Event, payload interface{}) error {
+	logger := logging.FromContext(ctx)
+	push := &bitbucketPushPayload{}
+	if err := decodePayload(ctx, event, push, "Error handling bitbucket push payload"); err != nil {
+		return err
+	}
+	if e.skipRepository(ctx, push.Repo.fullName()) || e.skipAuthor(ctx, push.Actor.Name) {
+		return nil
+	}
+	change, ok := push.pushChange()
+	if !ok {
+		logger.Info("Bitbucket push has no updated refs, skipping")
+		return nil
+	}
+
+	if err := e.trigger(withEventBranch(ctx, change.RefID), push.Repo.fullName(), change.ToHash, payload); err != nil {
+		return errors.Wrapf(err, "Error creating pipeline run for bitbucket push event: %q", event.Type())
+	}
+	return nil
+}
+
+func (e *EventListener) handleBitbucketPullRequest(ctx context.Context, event cloudevents.Event, payload interface{}) error {
+	logger := logging.FromContext(ctx)
+	pr := &bitbucketPullRequestPayload{}
+	if err := decodePayload(ctx, event, pr, "Error handling bitbucket pull request payload"); err != nil {
+		return err
+	}
+	if e.skipRepository(ctx, pr.PullRequest.ToRef.Repo.fullName()) || e.skipAuthor(ctx, pr.PullRequest.Author.User.Name) {
+		return nil
+	}
+	if pr.PullRequest.State != "" && pr.PullRequest.State != "OPEN" {
+		logger.Infof("Bitbucket pull request %d is %s, skipping", pr.PullRequest.ID, pr.PullRequest.State)
+		return nil
+	}
+	sha := pr.PullRequest.FromRef.LatestCommit
+	if sha == "" {
+		return errors.New("Bitbucket pull request payload has no latest commit")
+	}
+
+	ctx = withPullRequest(withEventBranch(ctx, pr.PullRequest.ToRef.ID), pr.PullRequest.ID)
+	if err := e.trigger(ctx, pr.PullRequest.ToRef.Repo.fullName(), sha, payload); err != nil {
+		return errors.Wrapf(err, "Error creating pipeline run for bitbucket pull request event: %q", event.Type())
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	pipelinev1alpha1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const bitbucketPushPayloadJSON = `{
+	"eventKey": "repo:refs_changed",
+	"date": "2019-06-01T10:00:00+0000",
+	"actor": {"name": "admin", "displayName": "Administrator"},
+	"repository": {"slug": "repo", "name": "repo", "project": {"key": "PRJ"}},
+	"changes": [
+		{
+			"ref": {"id": "refs/heads/old", "displayId": "old", "type": "BRANCH"},
+			"refId": "refs/heads/old",
+			"fromHash": "1111111111111111111111111111111111111111",
+			"toHash": "0000000000000000000000000000000000000000",
+			"type": "DELETE"
+		},
+		{
+			"ref": {"id": "refs/heads/master", "displayId": "master", "type": "BRANCH"},
+			"refId": "refs/heads/master",
+			"fromHash": "2222222222222222222222222222222222222222",
+			"toHash": "3333333333333333333333333333333333333333",
+			"type": "UPDATE"
+		}
+	]
+}`
+
+const bitbucketPullRequestPayloadJSON = `{
+	"eventKey": "pr:opened",
+	"date": "2019-06-01T10:00:00+0000",
+	"actor": {"name": "admin", "displayName": "Administrator"},
+	"pullRequest": {
+		"id": 7,
+		"title": "Add feature",
+		"state": "OPEN",
+		"open": true,
+		"fromRef": {
+			"id": "refs/heads/feature",
+			"displayId": "feature",
+			"latestCommit": "4444444444444444444444444444444444444444",
+			"repository": {"slug": "repo", "project": {"key": "PRJ"}}
+		},
+		"toRef": {
+			"id": "refs/heads/master",
+			"displayId": "master",
+			"latestCommit": "2222222222222222222222222222222222222222",
+			"repository": {"slug": "repo", "project": {"key": "PRJ"}}
+		}
+	}
+}`
+
+func TestBitbucketEvents(t *testing.T) {
+	tests := []struct {
+		name      string
+		eventType string
+		payload   string
+		wantSha   string
+	}{
+		{name: "push", eventType: bitbucketPushEventType, payload: bitbucketPushPayloadJSON, wantSha: "3333333333333333333333333333333333333333"},
+		{name: "pull request", eventType: bitbucketPullRequestEventType, payload: bitbucketPullRequestPayloadJSON, wantSha: "4444444444444444444444444444444444444444"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, _ := newTestListener()
+			e.eventType = tt.eventType
+			e.setBuildSha = true
+			e.runSpec.Params = []pipelinev1alpha1.Param{{Name: "revision"}}
+
+			event := newEvent(t, "delivery-1234", tt.eventType, []byte(tt.payload))
+			if err := e.HandleRequest(context.Background(), event); err != nil {
+				t.Fatalf("Unexpected error handling request: %s", err)
+			}
+
+			runs, err := e.pipelineClientset.Tekton().PipelineRuns(e.namespace).List(metav1.ListOptions{})
+			if err != nil {
+				t.Fatalf("Error listing pipelineruns: %s", err)
+			}
+			if len(runs.Items) != 1 {
+				t.Fatalf("Expected one pipelinerun, got %d", len(runs.Items))
+			}
+			if got := runs.Items[0].Spec.Params[0].Value; got != tt.wantSha {
+				t.Errorf("Expected revision %q, got %q", tt.wantSha, got)
+			}
+		})
+	}
+}
+
+func TestBitbucketSkippedEvents(t *testing.T) {
+	tests := []struct {
+		name      string
+		eventType string
+		payload   string
+	}{
+		{name: "deleted branch", eventType: bitbucketPushEventType, payload: `{"eventKey": "repo:refs_changed", "changes": [{"refId": "refs/heads/old", "toHash": "0000000000000000000000000000000000000000", "type": "DELETE"}]}`},
+		{name: "merged pull request", eventType: bitbucketPullRequestEventType, payload: `{"eventKey": "pr:merged", "pullRequest": {"id": 7, "state": "MERGED", "fromRef": {"latestCommit": "4444444444444444444444444444444444444444"}}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, _ := newTestListener()
+			e.eventType = tt.eventType
+
+			event := newEvent(t, "delivery-1234", tt.eventType, []byte(tt.payload))
+			if err := e.HandleRequest(context.Background(), event); err != nil {
+				t.Fatalf("Unexpected error handling request: %s", err)
+			}
+
+			runs, err := e.pipelineClientset.Tekton().PipelineRuns(e.namespace).List(metav1.ListOptions{})
+			if err != nil {
+				t.Fatalf("Error listing pipelineruns: %s", err)
+			}
+			if len(runs.Items) != 0 {
+				t.Errorf("Expected no pipelineruns, got %d", len(runs.Items))
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/knative/pkg/logging"
+	"github.com/pkg/errors"
+	pipelinev1alpha1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1alpha1"
+)
+
+// eventBranchKey is the context key of the branch an event is for: the
+// branch pushed to, or the target branch of a pull request.
+type eventBranchKey struct{}
+
+// withEventBranch returns a context carrying the branch of ref, which may be
+// a full refs/heads/ reference. Other references, such as tags, carry no
+// branch.
+func withEventBranch(ctx context.Context, ref string) context.Context {
+	if strings.HasPrefix(ref, "refs/") && !strings.HasPrefix(ref, "refs/heads/") {
+		return ctx
+	}
+	branch := strings.TrimPrefix(ref, "refs/heads/")
+	if branch == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, eventBranchKey{}, branch)
+}
+
+// eventBranch returns the branch of the event being handled, or "" when it
+// is not known.
+func eventBranch(ctx context.Context) string {
+	branch, _ := ctx.Value(eventBranchKey{}).(string)
+	return branch
+}
+
+// parsePipelineByBranch parses either a JSON object of branch name to
+// pipeline name, or a comma separated list of branch=pipeline pairs.
+func parsePipelineByBranch(s string) (map[string]string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+
+	pipelines := map[string]string{}
+	if strings.HasPrefix(s, "{") {
+		if err := json.Unmarshal([]byte(s), &pipelines); err != nil {
+			return nil, errors.Wrap(err, "Error parsing pipelines by branch as JSON")
+		}
+		for branch, pipeline := range pipelines {
+			if branch == "" || pipeline == "" {
+				return nil, errors.Errorf("Invalid pipeline %q for branch %q, neither can be empty", pipeline, branch)
+			}
+		}
+	} else {
+		for _, pair := range strings.Split(s, ",") {
+			parts := strings.SplitN(pair, "=", 2)
+			if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || strings.TrimSpace(parts[1]) == "" {
+				return nil, errors.Errorf("Invalid pipeline by branch %q, expected branch=pipeline", pair)
+			}
+			pipelines[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+		}
+	}
+	return pipelines, nil
+}
+
+// applyPipelineByBranch sets the pipeline of spec to the one configured for
+// the event's branch. The runspec's pipeline is kept when the branch is not
+// known or has no pipeline configured.
+func (e *EventListener) applyPipelineByBranch(ctx context.Context, spec *pipelinev1alpha1.PipelineRunSpec) {
+	branch := eventBranch(ctx)
+	if branch == "" {
+		return
+	}
+	if pipeline, ok := e.pipelineByBranch[branch]; ok {
+		logging.FromContext(ctx).Infof("Running pipeline %q for branch %q", pipeline, branch)
+		spec.PipelineRef.Name = pipeline
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestParsePipelineByBranch(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want map[string]string
+	}{
+		{name: "empty", in: "", want: nil},
+		{name: "pairs", in: "main=deploy-prod, staging=deploy-staging", want: map[string]string{"main": "deploy-prod", "staging": "deploy-staging"}},
+		{name: "json", in: `{"main": "deploy-prod"}`, want: map[string]string{"main": "deploy-prod"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parsePipelineByBranch(tt.in)
+			if err != nil {
+				t.Fatalf("Unexpected error parsing %q: %s", tt.in, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+
+	for _, in := range []string{"main", "=deploy-prod", "main=", `{"main": ""}`, `{"main": `} {
+		if _, err := parsePipelineByBranch(in); err == nil {
+			t.Errorf("Expected an error parsing %q", in)
+		}
+	}
+}
+
+func TestHandleRequestPipelineByBranch(t *testing.T) {
+	tests := []struct {
+		name         string
+		eventType    string
+		payload      map[string]interface{}
+		wantPipeline string
+	}{
+		{name: "push to matching branch", eventType: githubPushEventType, payload: map[string]interface{}{"ref": "refs/heads/main", "after": "abc123"}, wantPipeline: "deploy-prod"},
+		{name: "push to other matching branch", eventType: githubPushEventType, payload: map[string]interface{}{"ref": "refs/heads/staging", "after": "abc123"}, wantPipeline: "deploy-staging"},
+		{name: "push to non matching branch", eventType: githubPushEventType, payload: map[string]interface{}{"ref": "refs/heads/feature", "after": "abc123"}, wantPipeline: "test-pipeline"},
+		{name: "push of tag", eventType: githubPushEventType, payload: map[string]interface{}{"ref": "refs/tags/main", "after": "abc123"}, wantPipeline: "test-pipeline"},
+		{
+			name:      "check suite of matching branch",
+			eventType: checkSuiteEventType,
+			payload: map[string]interface{}{
+				"check_suite": map[string]interface{}{"conclusion": "success", "head_sha": "abc123", "head_branch": "staging"},
+			},
+			wantPipeline: "deploy-staging",
+		},
+		{
+			name:      "pull request targeting matching branch",
+			eventType: githubPullRequestEventType,
+			payload: map[string]interface{}{
+				"action": "opened",
+				"pull_request": map[string]interface{}{
+					"head": map[string]interface{}{"ref": "feature", "sha": "abc123", "repo": map[string]interface{}{"full_name": "foo/bar"}},
+					"base": map[string]interface{}{"ref": "main", "repo": map[string]interface{}{"full_name": "foo/bar"}},
+				},
+				"repository": map[string]interface{}{"full_name": "foo/bar"},
+			},
+			wantPipeline: "deploy-prod",
+		},
+		{
+			name:      "gitea pull request targeting matching branch",
+			eventType: giteaPullRequestEventType,
+			payload: map[string]interface{}{
+				"action": "opened",
+				"number": 1,
+				"pull_request": map[string]interface{}{
+					"head": map[string]interface{}{"ref": "feature", "sha": "abc123"},
+					"base": map[string]interface{}{"ref": "staging"},
+				},
+				"repository": map[string]interface{}{"full_name": "foo/bar"},
+			},
+			wantPipeline: "deploy-staging",
+		},
+		{
+			name:      "bitbucket push to matching branch",
+			eventType: bitbucketPushEventType,
+			payload: map[string]interface{}{
+				"eventKey": "repo:refs_changed",
+				"changes": []interface{}{
+					map[string]interface{}{"refId": "refs/heads/main", "toHash": "abc123", "type": "UPDATE"},
+				},
+				"repository": map[string]interface{}{"slug": "bar", "project": map[string]interface{}{"key": "FOO"}},
+			},
+			wantPipeline: "deploy-prod",
+		},
+		{
+			name:      "bitbucket pull request targeting matching branch",
+			eventType: bitbucketPullRequestEventType,
+			payload: map[string]interface{}{
+				"eventKey": "pr:opened",
+				"pullRequest": map[string]interface{}{
+					"id":      1,
+					"state":   "OPEN",
+					"fromRef": map[string]interface{}{"id": "refs/heads/feature", "latestCommit": "abc123"},
+					"toRef":   map[string]interface{}{"id": "refs/heads/staging", "repository": map[string]interface{}{"slug": "bar", "project": map[string]interface{}{"key": "FOO"}}},
+				},
+			},
+			wantPipeline: "deploy-staging",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, _ := newTestListener()
+			e.eventType = tt.eventType
+			e.pipelineByBranch = map[string]string{"main": "deploy-prod", "staging": "deploy-staging"}
+			payload, err := json.Marshal(tt.payload)
+			if err != nil {
+				t.Fatalf("Error marshalling payload: %s", err)
+			}
+			if err := e.HandleRequest(context.Background(), newEvent(t, "delivery-1234", tt.eventType, payload)); err != nil {
+				t.Fatalf("Error handling request: %s", err)
+			}
+
+			runs, err := e.pipelineClientset.Tekton().PipelineRuns(e.namespace).List(metav1.ListOptions{})
+			if err != nil {
+				t.Fatalf("Error listing pipelineruns: %s", err)
+			}
+			if len(runs.Items) != 1 {
+				t.Fatalf("Expected one pipelinerun, got %d", len(runs.Items))
+			}
+			if got := runs.Items[0].Spec.PipelineRef.Name; got != tt.wantPipeline {
+				t.Errorf("Expected pipeline %q, got %q", tt.wantPipeline, got)
+			}
+			if e.runSpec.PipelineRef.Name != "test-pipeline" {
+				t.Errorf("Expected the runspec's pipeline to be left alone, got %q", e.runSpec.PipelineRef.Name)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"strings"
+
+	pipelinev1alpha1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1alpha1"
+)
+
+// maxCommitMessageLength is the number of characters of a commit message
+// kept in the COMMIT_MESSAGE_PARAM.
+const maxCommitMessageLength = 512
+
+// commitMessageReplacer puts a commit message on a single line.
+var commitMessageReplacer = strings.NewReplacer("\r\n", " ", "\n", " ", "\r", " ")
+
+// applyCommitParams sets the authorParam and messageParam params to the
+// author email and message of the payload's head_commit, as sent for GitHub
+// pushes. A param is left alone when its name is empty or the payload has no
+// such value, as for a push deleting a branch.
+func applyCommitParams(authorParam, messageParam string, payload interface{}, params []pipelinev1alpha1.Param) []pipelinev1alpha1.Param {
+	data, _ := payload.(map[string]interface{})
+	commit, _ := data["head_commit"].(map[string]interface{})
+	if commit == nil {
+		return params
+	}
+	if authorParam != "" {
+		author, _ := commit["author"].(map[string]interface{})
+		if email, ok := author["email"].(string); ok {
+			params = setParam(params, authorParam, email)
+		}
+	}
+	if messageParam != "" {
+		if message, ok := commit["message"].(string); ok {
+			params = setParam(params, messageParam, sanitizeCommitMessage(message))
+		}
+	}
+	return params
+}
+
+// sanitizeCommitMessage puts a commit message on a single line and truncates
+// it to maxCommitMessageLength characters.
+func sanitizeCommitMessage(message string) string {
+	message = strings.TrimSpace(commitMessageReplacer.Replace(message))
+	if runes := []rune(message); len(runes) > maxCommitMessageLength {
+		message = string(runes[:maxCommitMessageLength])
+	}
+	return message
+}
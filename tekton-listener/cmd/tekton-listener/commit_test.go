@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	pipelinev1alpha1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestHandleRequestCommitParams(t *testing.T) {
+	longMessage := strings.Repeat("x", maxCommitMessageLength+10)
+	tests := []struct {
+		name        string
+		headCommit  interface{}
+		wantAuthor  string
+		wantMessage string
+	}{
+		{
+			name: "head commit",
+			headCommit: map[string]interface{}{
+				"id":      "def456",
+				"message": "Fix the build\r\n\nSigned-off-by: Octo Cat <octocat@example.com>\n",
+				"author":  map[string]interface{}{"name": "Octo Cat", "email": "octocat@example.com"},
+			},
+			wantAuthor:  "octocat@example.com",
+			wantMessage: "Fix the build  Signed-off-by: Octo Cat <octocat@example.com>",
+		},
+		{
+			name:        "long message",
+			headCommit:  map[string]interface{}{"id": "def456", "message": longMessage, "author": map[string]interface{}{"email": "octocat@example.com"}},
+			wantAuthor:  "octocat@example.com",
+			wantMessage: longMessage[:maxCommitMessageLength],
+		},
+		{name: "no head commit", headCommit: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, _ := newTestListener()
+			e.eventType = githubPushEventType
+			e.commitAuthorParam = "author"
+			e.commitMessageParam = "message"
+			e.runSpec.Params = []pipelinev1alpha1.Param{{Name: "message", Value: "unset"}}
+			payload, err := json.Marshal(map[string]interface{}{
+				"ref":         "refs/heads/master",
+				"after":       "def456",
+				"head_commit": tt.headCommit,
+			})
+			if err != nil {
+				t.Fatalf("Error marshalling payload: %s", err)
+			}
+
+			if err := e.HandleRequest(context.Background(), newEvent(t, "delivery-1234", githubPushEventType, payload)); err != nil {
+				t.Fatalf("Unexpected error handling request: %s", err)
+			}
+			runs, err := e.pipelineClientset.Tekton().PipelineRuns(e.namespace).List(metav1.ListOptions{})
+			if err != nil {
+				t.Fatalf("Error listing pipelineruns: %s", err)
+			}
+			if len(runs.Items) != 1 {
+				t.Fatalf("Expected one pipelinerun, got %d", len(runs.Items))
+			}
+			params := map[string]string{}
+			for _, p := range runs.Items[0].Spec.Params {
+				params[p.Name] = p.Value
+			}
+			if tt.headCommit == nil {
+				if _, ok := params["author"]; ok || params["message"] != "unset" {
+					t.Errorf("Expected the params to be left alone without a head commit, got %v", params)
+				}
+				return
+			}
+			if params["author"] != tt.wantAuthor {
+				t.Errorf("Expected author %q, got %q", tt.wantAuthor, params["author"])
+			}
+			if params["message"] != tt.wantMessage {
+				t.Errorf("Expected message %q, got %q", tt.wantMessage, params["message"])
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	duckv1alpha1 "github.com/knative/pkg/apis/duck/v1alpha1"
+	"github.com/knative/pkg/logging"
+	pipelinev1alpha1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// concurrencyKeyAnnotation is set, when cancelling in-flight runs, on each
+// run to the concurrency key of the event it was created for.
+const concurrencyKeyAnnotation = "webhooks.tekton.dev/concurrency-key"
+
+// pullRequestKey is the context key of the pull request an event is for.
+type pullRequestKey struct{}
+
+// withPullRequest returns a context carrying the number of the pull request
+// an event is for.
+func withPullRequest(ctx context.Context, number int) context.Context {
+	return context.WithValue(ctx, pullRequestKey{}, number)
+}
+
+// concurrencyKey returns the key of the runs that supersede each other:
+// those for the same pull request or, for other events, the same branch of
+// the event's repository. "" is returned when the event has neither.
+func concurrencyKey(ctx context.Context) string {
+	repo := eventRepository(ctx)
+	if number, ok := ctx.Value(pullRequestKey{}).(int); ok {
+		return fmt.Sprintf("%s#%d", repo, number)
+	}
+	if branch := eventBranch(ctx); branch != "" {
+		return fmt.Sprintf("%s@%s", repo, branch)
+	}
+	return ""
+}
+
+// cancelInFlightRuns cancels this listener's runs with the concurrency key
+// that have not finished, so that only the newest run for a pull request or
+// branch is active. Runs that can't be listed or cancelled are logged and
+// left running.
+func (e *EventListener) cancelInFlightRuns(ctx context.Context, key string) {
+	logger := logging.FromContext(ctx)
+	opts := metav1.ListOptions{}
+	if e.runName != "" {
+		opts.LabelSelector = fmt.Sprintf("%s=%s", instanceLabel, e.runName)
+	}
+	runs, err := e.pipelineClientset.Tekton().PipelineRuns(e.runNamespace).List(opts)
+	if err != nil {
+		logger.Errorf("Error listing pipelineruns to cancel for %q: %s", key, err)
+		return
+	}
+	for i := range runs.Items {
+		run := &runs.Items[i]
+		if run.Annotations[concurrencyKeyAnnotation] != key || !inFlight(run) {
+			continue
+		}
+		run.Spec.Status = pipelinev1alpha1.PipelineRunSpecStatusCancelled
+		if _, err := e.pipelineClientset.Tekton().PipelineRuns(e.runNamespace).Update(run); err != nil {
+			logger.Errorf("Error cancelling pipelinerun %q superseded for %q: %s", run.Name, key, err)
+			continue
+		}
+		logger.Infof("Cancelled pipelinerun %q superseded for %q", run.Name, key)
+	}
+}
+
+// inFlight returns whether run has neither finished nor been cancelled.
+func inFlight(run *pipelinev1alpha1.PipelineRun) bool {
+	if run.Spec.Status == pipelinev1alpha1.PipelineRunSpecStatusCancelled {
+		return false
+	}
+	cond := run.Status.GetCondition(duckv1alpha1.ConditionSucceeded)
+	return cond == nil || cond.IsUnknown()
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	duckv1alpha1 "github.com/knative/pkg/apis/duck/v1alpha1"
+	pipelinev1alpha1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestConcurrencyKey(t *testing.T) {
+	ctx := context.WithValue(context.Background(), repositoryKey{}, "foo/bar")
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want string
+	}{
+		{name: "pull request", ctx: withPullRequest(withEventBranch(ctx, "main"), 12), want: "foo/bar#12"},
+		{name: "branch", ctx: withEventBranch(ctx, "refs/heads/main"), want: "foo/bar@main"},
+		{name: "neither", ctx: withEventBranch(ctx, "refs/tags/v1.0"), want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := concurrencyKey(tt.ctx); got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+// pullRequestPayload returns a pull_request payload for pull request number
+// of foo/bar at sha.
+func pullRequestPayload(t *testing.T, number int, sha string) []byte {
+	t.Helper()
+	payload, err := json.Marshal(map[string]interface{}{
+		"action": "synchronize",
+		"number": number,
+		"pull_request": map[string]interface{}{
+			"number": number,
+			"head":   map[string]interface{}{"ref": "feature", "sha": sha, "repo": map[string]interface{}{"full_name": "foo/bar"}},
+			"base":   map[string]interface{}{"ref": "main", "repo": map[string]interface{}{"full_name": "foo/bar"}},
+		},
+		"repository": map[string]interface{}{"full_name": "foo/bar"},
+	})
+	if err != nil {
+		t.Fatalf("Error marshalling payload: %s", err)
+	}
+	return payload
+}
+
+// runsBySha returns the listener's pipelineruns keyed by their revision.
+func runsBySha(t *testing.T, e *EventListener) map[string]pipelinev1alpha1.PipelineRun {
+	t.Helper()
+	runs, err := e.pipelineClientset.Tekton().PipelineRuns(e.runNamespace).List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("Error listing pipelineruns: %s", err)
+	}
+	bySha := map[string]pipelinev1alpha1.PipelineRun{}
+	for _, run := range runs.Items {
+		bySha[run.Labels[revisionLabel]] = run
+	}
+	return bySha
+}
+
+func TestHandleRequestCancelInFlight(t *testing.T) {
+	e, _ := newTestListener()
+	e.eventType = githubPullRequestEventType
+	e.cancelInFlight = true
+
+	if err := e.HandleRequest(context.Background(), newEvent(t, "delivery-1", githubPullRequestEventType, pullRequestPayload(t, 1, "abc123"))); err != nil {
+		t.Fatalf("Error handling request: %s", err)
+	}
+	if err := e.HandleRequest(context.Background(), newEvent(t, "delivery-2", githubPullRequestEventType, pullRequestPayload(t, 2, "def456"))); err != nil {
+		t.Fatalf("Error handling request: %s", err)
+	}
+	runs := runsBySha(t, e)
+	if key := runs["abc123"].Annotations[concurrencyKeyAnnotation]; key != "foo/bar#1" {
+		t.Errorf("Expected the run to be annotated with the concurrency key foo/bar#1, got %q", key)
+	}
+
+	// a new push to pull request 1 supersedes its run only
+	if err := e.HandleRequest(context.Background(), newEvent(t, "delivery-3", githubPullRequestEventType, pullRequestPayload(t, 1, "fed789"))); err != nil {
+		t.Fatalf("Error handling request: %s", err)
+	}
+	runs = runsBySha(t, e)
+	if len(runs) != 3 {
+		t.Fatalf("Expected three pipelineruns, got %d", len(runs))
+	}
+	want := map[string]pipelinev1alpha1.PipelineRunSpecStatus{
+		"abc123": pipelinev1alpha1.PipelineRunSpecStatusCancelled,
+		"def456": "",
+		"fed789": "",
+	}
+	for sha, status := range want {
+		if got := runs[sha].Spec.Status; got != status {
+			t.Errorf("Expected the run for %s to have status %q, got %q", sha, status, got)
+		}
+	}
+}
+
+func TestHandleRequestCancelInFlightProviders(t *testing.T) {
+	tests := []struct {
+		name      string
+		eventType string
+		payload   func(sha string) map[string]interface{}
+		wantKey   string
+	}{
+		{
+			name:      "gitea",
+			eventType: giteaPullRequestEventType,
+			payload: func(sha string) map[string]interface{} {
+				return map[string]interface{}{
+					"action": "synchronized",
+					"number": 1,
+					"pull_request": map[string]interface{}{
+						"head": map[string]interface{}{"ref": "feature", "sha": sha},
+						"base": map[string]interface{}{"ref": "main"},
+					},
+					"repository": map[string]interface{}{"full_name": "foo/bar"},
+				}
+			},
+			wantKey: "foo/bar#1",
+		},
+		{
+			name:      "bitbucket",
+			eventType: bitbucketPullRequestEventType,
+			payload: func(sha string) map[string]interface{} {
+				return map[string]interface{}{
+					"eventKey": "pr:from_ref_updated",
+					"pullRequest": map[string]interface{}{
+						"id":      1,
+						"state":   "OPEN",
+						"fromRef": map[string]interface{}{"id": "refs/heads/feature", "latestCommit": sha},
+						"toRef":   map[string]interface{}{"id": "refs/heads/main", "repository": map[string]interface{}{"slug": "bar", "project": map[string]interface{}{"key": "FOO"}}},
+					},
+				}
+			},
+			wantKey: "FOO/bar#1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, _ := newTestListener()
+			e.eventType = tt.eventType
+			e.cancelInFlight = true
+
+			for i, sha := range []string{"abc123", "def456"} {
+				payload, err := json.Marshal(tt.payload(sha))
+				if err != nil {
+					t.Fatalf("Error marshalling payload: %s", err)
+				}
+				if err := e.HandleRequest(context.Background(), newEvent(t, fmt.Sprintf("delivery-%d", i), tt.eventType, payload)); err != nil {
+					t.Fatalf("Error handling request: %s", err)
+				}
+			}
+			// the second push to the pull request supersedes the first's run
+			runs := runsBySha(t, e)
+			if got := runs["abc123"].Spec.Status; got != pipelinev1alpha1.PipelineRunSpecStatusCancelled {
+				t.Errorf("Expected the superseded run to be cancelled, got status %q", got)
+			}
+			if got := runs["def456"].Spec.Status; got != "" {
+				t.Errorf("Expected the newest run to be left alone, got status %q", got)
+			}
+			if key := runs["def456"].Annotations[concurrencyKeyAnnotation]; key != tt.wantKey {
+				t.Errorf("Expected the run to be annotated with the concurrency key %q, got %q", tt.wantKey, key)
+			}
+		})
+	}
+}
+
+func TestHandleRequestCancelInFlightSkipsFinishedRuns(t *testing.T) {
+	e, _ := newTestListener()
+	e.eventType = githubPullRequestEventType
+	e.cancelInFlight = true
+
+	if err := e.HandleRequest(context.Background(), newEvent(t, "delivery-1", githubPullRequestEventType, pullRequestPayload(t, 1, "abc123"))); err != nil {
+		t.Fatalf("Error handling request: %s", err)
+	}
+	finished := runsBySha(t, e)["abc123"]
+	finished.Status.Conditions = duckv1alpha1.Conditions{{Type: duckv1alpha1.ConditionSucceeded, Status: "True"}}
+	if _, err := e.pipelineClientset.Tekton().PipelineRuns(e.runNamespace).Update(&finished); err != nil {
+		t.Fatalf("Error updating pipelinerun: %s", err)
+	}
+
+	if err := e.HandleRequest(context.Background(), newEvent(t, "delivery-2", githubPullRequestEventType, pullRequestPayload(t, 1, "def456"))); err != nil {
+		t.Fatalf("Error handling request: %s", err)
+	}
+	if got := runsBySha(t, e)["abc123"].Spec.Status; got != "" {
+		t.Errorf("Expected the finished run to be left alone, got status %q", got)
+	}
+}
+
+func TestHandleRequestWithoutCancelInFlight(t *testing.T) {
+	e, _ := newTestListener()
+	e.eventType = githubPullRequestEventType
+
+	for i, sha := range []string{"abc123", "def456"} {
+		if err := e.HandleRequest(context.Background(), newEvent(t, "delivery-"+sha, githubPullRequestEventType, pullRequestPayload(t, 1, sha))); err != nil {
+			t.Fatalf("Error handling request %d: %s", i, err)
+		}
+	}
+	for sha, run := range runsBySha(t, e) {
+		if run.Spec.Status != "" {
+			t.Errorf("Expected the run for %s not to be cancelled, got status %q", sha, run.Spec.Status)
+		}
+		if _, ok := run.Annotations[concurrencyKeyAnnotation]; ok {
+			t.Errorf("Expected the run for %s to have no concurrency key", sha)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/cloudevents/sdk-go/pkg/cloudevents"
+	cehttp "github.com/cloudevents/sdk-go/pkg/cloudevents/transport/http"
+	"github.com/knative/pkg/logging"
+	"github.com/pkg/errors"
+)
+
+// deadLetterReasonHeader carries why an event was dead lettered, as the
+// deadletterreason extension of the binary encoded cloudevent.
+const deadLetterReasonHeader = "Ce-Deadletterreason"
+
+// handleFailure forwards an event that could not be handled to the
+// DEAD_LETTER_URL, with the error as the reason, so that it can be
+// reprocessed later. A dead lettered event is acknowledged; the error is
+// returned when there is no dead letter sink, when the payload itself was
+// rejected, or when the event could not be forwarded.
+func (e *EventListener) handleFailure(ctx context.Context, event cloudevents.Event, err error) error {
+	if err == nil || e.deadLetterURL == "" {
+		return err
+	}
+	if _, ok := errors.Cause(err).(*payloadError); ok {
+		return err
+	}
+	logger := logging.FromContext(ctx)
+	logger.Errorf("Error handling event, forwarding it to the dead letter sink: %v", err)
+	if dlErr := e.deadLetter(ctx, event, err.Error()); dlErr != nil {
+		logger.Errorf("Error forwarding event to the dead letter sink: %v", dlErr)
+		return err
+	}
+	return nil
+}
+
+// deadLetter POSTs the event, binary encoded as it was received, to the
+// DEAD_LETTER_URL with the reason it failed.
+func (e *EventListener) deadLetter(ctx context.Context, event cloudevents.Event, reason string) error {
+	codec := &cehttp.Codec{}
+	msg, err := codec.Encode(event)
+	if err != nil {
+		return errors.Wrap(err, "Error encoding dead letter event")
+	}
+	m, ok := msg.(*cehttp.Message)
+	if !ok {
+		return fmt.Errorf("Unexpected dead letter message type %T", msg)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, e.deadLetterURL, bytes.NewReader(m.Body))
+	if err != nil {
+		return errors.Wrap(err, "Error creating dead letter request")
+	}
+	req = req.WithContext(ctx)
+	for name, values := range m.Header {
+		req.Header[name] = values
+	}
+	req.Header.Set(deadLetterReasonHeader, reason)
+
+	resp, err := e.deadLetterClient.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "Error posting event to %q", e.deadLetterURL)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("Dead letter sink %q returned %d: %s", e.deadLetterURL, resp.StatusCode, body)
+	}
+	logging.FromContext(ctx).Infof("Forwarded event to the dead letter sink %q", e.deadLetterURL)
+	return nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	cehttp "github.com/cloudevents/sdk-go/pkg/cloudevents/transport/http"
+	fakepipeline "github.com/tektoncd/pipeline/pkg/client/clientset/versioned/fake"
+	"k8s.io/apimachinery/pkg/runtime"
+	ktesting "k8s.io/client-go/testing"
+)
+
+// failingListener returns a test listener whose pipelinerun creation fails.
+func failingListener() *EventListener {
+	e, _ := newTestListener()
+	client := fakepipeline.NewSimpleClientset()
+	client.PrependReactor("create", "pipelineruns", func(action ktesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("quota exceeded")
+	})
+	e.pipelineClientset = client
+	return e
+}
+
+func TestHandleRequestDeadLetter(t *testing.T) {
+	var gotID, gotType, gotReason, gotData string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("Error reading dead letter request: %s", err)
+		}
+		codec := &cehttp.Codec{}
+		event, err := codec.Decode(&cehttp.Message{Header: r.Header, Body: body})
+		if err != nil {
+			t.Errorf("Error decoding dead letter event: %s", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		gotID, gotType, gotReason = event.ID(), event.Type(), r.Header.Get(deadLetterReasonHeader)
+		var data json.RawMessage
+		if err := event.DataAs(&data); err != nil {
+			t.Errorf("Error decoding dead letter payload: %s", err)
+		}
+		gotData = string(data)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer ts.Close()
+
+	e := failingListener()
+	e.deadLetterURL = ts.URL
+	e.deadLetterClient = ts.Client()
+	payload := checkSuitePayload(t, "success", "abc123")
+	if err := e.HandleRequest(context.Background(), newEvent(t, "delivery-1234", checkSuiteEventType, payload)); err != nil {
+		t.Fatalf("Expected the dead lettered event to be acknowledged, got %s", err)
+	}
+	if gotID != "delivery-1234" || gotType != checkSuiteEventType {
+		t.Errorf("Expected the original event to be dead lettered, got ID %q of type %q", gotID, gotType)
+	}
+	if gotData != string(payload) {
+		t.Errorf("Expected the original payload to be dead lettered, got %s", gotData)
+	}
+	if !strings.Contains(gotReason, "quota exceeded") {
+		t.Errorf("Expected the failure reason to be dead lettered, got %q", gotReason)
+	}
+}
+
+func TestHandleRequestDeadLetterFailure(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	for name, url := range map[string]string{"unset": "", "unavailable": ts.URL} {
+		t.Run(name, func(t *testing.T) {
+			e := failingListener()
+			e.deadLetterURL = url
+			e.deadLetterClient = ts.Client()
+			err := e.HandleRequest(context.Background(), newCheckSuiteEvent(t, "delivery-1234", "success", "abc123"))
+			if err == nil || !strings.Contains(err.Error(), "quota exceeded") {
+				t.Errorf("Expected the creation failure to be returned, got %v", err)
+			}
+		})
+	}
+}
+
+func TestHandleRequestDeadLetterSkipsPayloadErrors(t *testing.T) {
+	called := false
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer ts.Close()
+
+	e := failingListener()
+	e.deadLetterURL = ts.URL
+	e.deadLetterClient = ts.Client()
+	if err := e.HandleRequest(context.Background(), newEvent(t, "delivery-1234", checkSuiteEventType, []byte(`{"action": `))); err == nil {
+		t.Error("Expected an error for a malformed payload")
+	}
+	if called {
+		t.Error("Expected a malformed payload not to be dead lettered")
+	}
+}
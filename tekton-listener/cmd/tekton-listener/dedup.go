@@ -0,0 +1,46 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru/simplelru"
+)
+
+// deliveryCache remembers recently seen event IDs so that retried deliveries
+// of the same event do not trigger a second pipeline run. It also remembers
+// the SHAs built recently when deduplicating by SHA.
+type deliveryCache struct {
+	mu    sync.Mutex
+	cache *lru.LRU
+	ttl   time.Duration
+	now   func() time.Time
+}
+
+func newDeliveryCache(size int, ttl time.Duration) (*deliveryCache, error) {
+	cache, err := lru.NewLRU(size, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &deliveryCache{cache: cache, ttl: ttl, now: time.Now}, nil
+}
+
+// seen records id and reports whether it was already recorded within the TTL.
+func (d *deliveryCache) seen(id string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.now()
+	if v, ok := d.cache.Get(id); ok && now.Sub(v.(time.Time)) < d.ttl {
+		return true
+	}
+	d.cache.Add(id, now)
+	return false
+}
+
+// forget removes id so that a later delivery of it is handled again.
+func (d *deliveryCache) forget(id string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.cache.Remove(id)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// eventTypePrefix maps cloudevent types starting with from onto the
+// internal types starting with to.
+type eventTypePrefix struct {
+	from string
+	to   string
+}
+
+// eventTypePrefixes are the prefixes event types are mapped from, longest
+// first so the most specific prefix wins.
+type eventTypePrefixes []eventTypePrefix
+
+// parseEventTypePrefixes parses either a JSON object of external prefix to
+// internal prefix, or a comma separated list of from=to pairs.
+func parseEventTypePrefixes(s string) (eventTypePrefixes, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+
+	mapping := map[string]string{}
+	if strings.HasPrefix(s, "{") {
+		if err := json.Unmarshal([]byte(s), &mapping); err != nil {
+			return nil, errors.Wrap(err, "Error parsing event type prefixes as JSON")
+		}
+	} else {
+		for _, pair := range strings.Split(s, ",") {
+			parts := strings.SplitN(pair, "=", 2)
+			if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || strings.TrimSpace(parts[1]) == "" {
+				return nil, errors.Errorf("Invalid event type prefix %q, expected from=to", pair)
+			}
+			mapping[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+		}
+	}
+
+	var prefixes eventTypePrefixes
+	for from, to := range mapping {
+		if from == "" || to == "" {
+			return nil, errors.Errorf("Invalid event type prefix %q=%q, neither can be empty", from, to)
+		}
+		prefixes = append(prefixes, eventTypePrefix{from: from, to: to})
+	}
+	sort.Slice(prefixes, func(i, j int) bool {
+		if len(prefixes[i].from) != len(prefixes[j].from) {
+			return len(prefixes[i].from) > len(prefixes[j].from)
+		}
+		return prefixes[i].from < prefixes[j].from
+	})
+	return prefixes, nil
+}
+
+// normalize returns the internal type of the cloudevent type eventType. The
+// longest matching prefix is replaced and underscores are dropped from the
+// rest, so dev.knative.source.github.check_suite maps onto
+// com.github.checksuite with a dev.knative.source.github.=com.github. prefix.
+// Types matching no prefix are returned unchanged.
+func (p eventTypePrefixes) normalize(eventType string) string {
+	for _, prefix := range p {
+		if strings.HasPrefix(eventType, prefix.from) {
+			rest := strings.Replace(strings.TrimPrefix(eventType, prefix.from), "_", "", -1)
+			return prefix.to + rest
+		}
+	}
+	return eventType
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestEventTypePrefixes(t *testing.T) {
+	tests := []struct {
+		name      string
+		prefixes  string
+		eventType string
+		want      string
+	}{
+		{name: "empty", prefixes: "", eventType: "dev.knative.source.github.push", want: "dev.knative.source.github.push"},
+		{name: "internal type", prefixes: "dev.knative.source.github.=com.github.", eventType: "com.github.push", want: "com.github.push"},
+		{name: "prefix", prefixes: "dev.knative.source.github.=com.github.", eventType: "dev.knative.source.github.push", want: "com.github.push"},
+		{name: "underscores dropped", prefixes: "dev.knative.source.github.=com.github.", eventType: "dev.knative.source.github.check_suite", want: "com.github.checksuite"},
+		{name: "list", prefixes: "org.example.=com.example., dev.knative.source.github.=com.github.", eventType: "org.example.build", want: "com.example.build"},
+		{name: "json", prefixes: `{"io.gitea.": "com.gitea."}`, eventType: "io.gitea.pull_request", want: "com.gitea.pullrequest"},
+		{name: "longest prefix", prefixes: "dev.knative.=com.knative.,dev.knative.source.github.=com.github.", eventType: "dev.knative.source.github.push", want: "com.github.push"},
+		{name: "no match", prefixes: "dev.knative.source.github.=com.github.", eventType: "dev.knative.source.gitlab.push", want: "dev.knative.source.gitlab.push"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prefixes, err := parseEventTypePrefixes(tt.prefixes)
+			if err != nil {
+				t.Fatalf("Unexpected error parsing %q: %s", tt.prefixes, err)
+			}
+			if got := prefixes.normalize(tt.eventType); got != tt.want {
+				t.Errorf("Expected %q to map onto %q, got %q", tt.eventType, tt.want, got)
+			}
+		})
+	}
+
+	for _, in := range []string{"com.github.", "=com.github.", "dev.knative.=", `{"dev.knative.": ""}`, `{"dev.knative.": `} {
+		if _, err := parseEventTypePrefixes(in); err == nil {
+			t.Errorf("Expected an error parsing %q", in)
+		}
+	}
+}
+
+func TestHandleRequestEventTypePrefixes(t *testing.T) {
+	prefixes, err := parseEventTypePrefixes("dev.knative.source.github.=com.github.,org.example.github.=com.github.")
+	if err != nil {
+		t.Fatalf("Error parsing event type prefixes: %s", err)
+	}
+	payload, err := json.Marshal(map[string]interface{}{
+		"check_suite": map[string]interface{}{"conclusion": "success", "head_sha": "abc123"},
+		"repository":  map[string]interface{}{"name": "bar", "full_name": "foo/bar"},
+	})
+	if err != nil {
+		t.Fatalf("Error marshalling payload: %s", err)
+	}
+
+	for i, eventType := range []string{checkSuiteEventType, "dev.knative.source.github.check_suite", "dev.knative.source.github.checksuite", "org.example.github.check_suite"} {
+		t.Run(eventType, func(t *testing.T) {
+			e, _ := newTestListener()
+			e.eventTypePrefixes = prefixes
+			event := newEvent(t, fmt.Sprintf("delivery-%d", i), eventType, payload)
+			if err := e.HandleRequest(context.Background(), event); err != nil {
+				t.Fatalf("Error handling request: %s", err)
+			}
+
+			runs, err := e.pipelineClientset.Tekton().PipelineRuns(e.namespace).List(metav1.ListOptions{})
+			if err != nil {
+				t.Fatalf("Error listing pipelineruns: %s", err)
+			}
+			if len(runs.Items) != 1 {
+				t.Errorf("Expected the check suite handler to create a pipelinerun, got %d", len(runs.Items))
+			}
+		})
+	}
+
+	e, _ := newTestListener()
+	e.eventTypePrefixes = prefixes
+	if err := e.HandleRequest(context.Background(), newEvent(t, "delivery-1234", "dev.knative.source.gitlab.check_suite", payload)); err == nil {
+		t.Error("Expected an error handling an event type matching no prefix")
+	}
+}
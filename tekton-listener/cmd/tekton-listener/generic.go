@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cloudevents/sdk-go/pkg/cloudevents"
+	"github.com/pkg/errors"
+)
+
+// parseShaPath parses the SHA_PATH JSONPath expression, returning nil when
+// it is empty.
+func parseShaPath(path string) (*paramMapping, error) {
+	if path == "" {
+		return nil, nil
+	}
+	m, err := newParamMapping("sha", path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Error parsing SHA path %q", path)
+	}
+	return &m, nil
+}
+
+// handleGenericEvent triggers a run for an event of the GENERIC_EVENT_TYPE,
+// whose commit is found in the payload at SHA_PATH. The payload has no
+// known repository, so runs are rate limited by the event's source.
+func (e *EventListener) handleGenericEvent(ctx context.Context, event cloudevents.Event, payload interface{}) error {
+	sha, ok := e.shaPath.resolve(payload)
+	if !ok || sha == "" {
+		// the same payload would never resolve, so it is rejected rather than retried
+		return &payloadError{
+			message: fmt.Sprintf("Error handling %s payload", event.Type()),
+			reason:  fmt.Sprintf("SHA path %q did not resolve", e.shaPath.path),
+		}
+	}
+	if err := e.trigger(ctx, event.Source(), sha, payload); err != nil {
+		return errors.Wrapf(err, "Error creating pipeline run for %q event", event.Type())
+	}
+	return nil
+}
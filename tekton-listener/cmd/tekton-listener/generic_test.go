@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+const genericEventType = "com.example.build"
+
+func TestHandleGenericEvent(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		payload string
+		wantSHA string
+	}{
+		{
+			name:    "nested field",
+			path:    ".head_commit.id",
+			payload: `{"head_commit": {"id": "abc123"}}`,
+			wantSHA: "abc123",
+		},
+		{
+			name:    "array index with braces",
+			path:    "{.data.commits[0].sha}",
+			payload: `{"data": {"commits": [{"sha": "def456"}, {"sha": "0000"}]}}`,
+			wantSHA: "def456",
+		},
+		{
+			name:    "filter",
+			path:    `.changes[?(@.type=="commit")].hash`,
+			payload: `{"changes": [{"type": "tag", "hash": "1111"}, {"type": "commit", "hash": "789abc"}]}`,
+			wantSHA: "789abc",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, _ := newTestListener()
+			e.eventType = genericEventType
+			e.genericEventType = genericEventType
+			shaPath, err := parseShaPath(tt.path)
+			if err != nil {
+				t.Fatalf("Error parsing SHA path: %s", err)
+			}
+			e.shaPath = shaPath
+
+			if err := e.HandleRequest(context.Background(), newEvent(t, "delivery-1234", genericEventType, []byte(tt.payload))); err != nil {
+				t.Fatalf("Error handling request: %s", err)
+			}
+			run := createdRun(t, e)
+			if revision := run.Labels[revisionLabel]; revision != tt.wantSHA {
+				t.Errorf("Expected a run for %q, got %q", tt.wantSHA, revision)
+			}
+		})
+	}
+}
+
+func TestHandleGenericEventUnresolvedSHA(t *testing.T) {
+	e, _ := newTestListener()
+	e.eventType = genericEventType
+	e.genericEventType = genericEventType
+	shaPath, err := parseShaPath(".head_commit.id")
+	if err != nil {
+		t.Fatalf("Error parsing SHA path: %s", err)
+	}
+	e.shaPath = shaPath
+
+	err = e.HandleRequest(context.Background(), newEvent(t, "delivery-1234", genericEventType, []byte(`{"ref": "refs/heads/master"}`)))
+	if _, ok := errors.Cause(err).(*payloadError); !ok {
+		t.Fatalf("Expected a payload error, got %v", err)
+	}
+	if !strings.Contains(err.Error(), `SHA path ".head_commit.id" did not resolve`) {
+		t.Errorf("Expected the error to name the SHA path, got %q", err)
+	}
+}
+
+func TestParseShaPath(t *testing.T) {
+	if m, err := parseShaPath(""); m != nil || err != nil {
+		t.Errorf("Expected no SHA path when unset, got %v, %v", m, err)
+	}
+	if _, err := parseShaPath(".commits[0"); err == nil {
+		t.Error("Expected an error for an invalid JSONPath")
+	}
+}
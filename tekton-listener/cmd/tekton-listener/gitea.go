@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+
+	"github.com/cloudevents/sdk-go/pkg/cloudevents"
+	"github.com/knative/pkg/logging"
+	"github.com/pkg/errors"
+)
+
+const (
+	giteaPushEventType        = "com.gitea.push"
+	giteaPullRequestEventType = "com.gitea.pullrequest"
+
+	// giteaNullHash is the hash Gitea reports as the new commit of a deleted ref.
+	giteaNullHash = "0000000000000000000000000000000000000000"
+)
+
+// Gitea payloads follow GitHub's, but the github package's structs can't
+// decode them: Gitea sends timestamps such as repository.created_at as
+// strings where GitHub sends numbers, and reports pull request updates with
+// the "synchronized" rather than "synchronize" action. The fields used from
+// the Gitea payloads are declared here with GitHub's names.
+
+// giteaPushPayload is the push payload sent by Gitea.
+type giteaPushPayload struct {
+	Ref        string          `json:"ref"`
+	After      string          `json:"after"`
+	Repository giteaRepository `json:"repository"`
+	Sender     giteaUser       `json:"sender"`
+}
+
+// giteaPullRequestPayload is the pull_request payload sent by Gitea.
+type giteaPullRequestPayload struct {
+	Action      string `json:"action"`
+	Number      int    `json:"number"`
+	PullRequest struct {
+		Head struct {
+			Ref string `json:"ref"`
+			Sha string `json:"sha"`
+		} `json:"head"`
+		Base struct {
+			Ref string `json:"ref"`
+		} `json:"base"`
+	} `json:"pull_request"`
+	Repository giteaRepository `json:"repository"`
+	Sender     giteaUser       `json:"sender"`
+}
+
+type giteaRepository struct {
+	FullName string `json:"full_name"`
+}
+
+// giteaUser is the user that sent an event. Gitea sets both login and
+// username, older versions only username.
+type giteaUser struct {
+	Login    string `json:"login"`
+	Username string `json:"username"`
+}
+
+func (u giteaUser) login() string {
+	if u.Login != "" {
+		return u.Login
+	}
+	return u.Username
+}
+
+// giteaPullRequestActions are the pull request actions that trigger a run.
+var giteaPullRequestActions = []string{"opened", "reopened", "synchronized"}
+
+func (e *EventListener) handleGiteaPush(ctx context.Context, event cloudevents.Event, payload interface{}) error {
+	push := &giteaPushPayload{}
+	if err := decodePayload(ctx, event, push, "Error handling gitea push payload"); err != nil {
+		return err
+	}
+	if e.skipRepository(ctx, push.Repository.FullName) || e.skipAuthor(ctx, push.Sender.login()) {
+		return nil
+	}
+	if push.After == "" || push.After == giteaNullHash {
+		logging.FromContext(ctx).Infof("Gitea push deleted %q, skipping", push.Ref)
+		return nil
+	}
+
+	if err := e.trigger(withEventBranch(ctx, push.Ref), push.Repository.FullName, push.After, payload); err != nil {
+		return errors.Wrapf(err, "Error creating pipeline run for gitea push event: %q", event.Type())
+	}
+	return nil
+}
+
+func (e *EventListener) handleGiteaPullRequest(ctx context.Context, event cloudevents.Event, payload interface{}) error {
+	pr := &giteaPullRequestPayload{}
+	if err := decodePayload(ctx, event, pr, "Error handling gitea pull request payload"); err != nil {
+		return err
+	}
+	if e.skipRepository(ctx, pr.Repository.FullName) || e.skipAuthor(ctx, pr.Sender.login()) {
+		return nil
+	}
+	if !containsString(giteaPullRequestActions, pr.Action) {
+		logging.FromContext(ctx).Infof("Gitea pull request %d was %s, skipping", pr.Number, pr.Action)
+		return nil
+	}
+	sha := pr.PullRequest.Head.Sha
+	if sha == "" {
+		return errors.New("Gitea pull request payload has no head commit")
+	}
+
+	ctx = withPullRequest(withEventBranch(ctx, pr.PullRequest.Base.Ref), pr.Number)
+	if err := e.trigger(ctx, pr.Repository.FullName, sha, payload); err != nil {
+		return errors.Wrapf(err, "Error creating pipeline run for gitea pull request event: %q", event.Type())
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	pipelinev1alpha1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const giteaPushPayloadJSON = `{
+	"secret": "",
+	"ref": "refs/heads/master",
+	"before": "1111111111111111111111111111111111111111",
+	"after": "5555555555555555555555555555555555555555",
+	"compare_url": "https://gitea.example.com/foo/bar/compare/1111111111111111111111111111111111111111...5555555555555555555555555555555555555555",
+	"commits": [
+		{
+			"id": "5555555555555555555555555555555555555555",
+			"message": "Fix the build\n",
+			"url": "https://gitea.example.com/foo/bar/commit/5555555555555555555555555555555555555555",
+			"author": {"name": "Jane Doe", "email": "jane@example.com", "username": "jane"},
+			"timestamp": "2019-06-01T10:00:00Z"
+		}
+	],
+	"repository": {
+		"id": 1,
+		"owner": {"id": 1, "login": "foo", "username": "foo"},
+		"name": "bar",
+		"full_name": "foo/bar",
+		"private": false,
+		"html_url": "https://gitea.example.com/foo/bar",
+		"clone_url": "https://gitea.example.com/foo/bar.git",
+		"default_branch": "master",
+		"created_at": "2019-01-01T10:00:00Z",
+		"updated_at": "2019-06-01T10:00:00Z"
+	},
+	"pusher": {"id": 2, "login": "jane", "username": "jane"},
+	"sender": {"id": 2, "login": "jane", "username": "jane"}
+}`
+
+const giteaPullRequestPayloadJSON = `{
+	"secret": "",
+	"action": "synchronized",
+	"number": 3,
+	"pull_request": {
+		"id": 3,
+		"number": 3,
+		"title": "Add feature",
+		"state": "open",
+		"merged": false,
+		"head": {
+			"label": "feature",
+			"ref": "feature",
+			"sha": "6666666666666666666666666666666666666666",
+			"repo_id": 1
+		},
+		"base": {
+			"label": "master",
+			"ref": "master",
+			"sha": "5555555555555555555555555555555555555555",
+			"repo_id": 1
+		},
+		"created_at": "2019-06-01T10:00:00Z"
+	},
+	"repository": {
+		"id": 1,
+		"name": "bar",
+		"full_name": "foo/bar",
+		"created_at": "2019-01-01T10:00:00Z"
+	},
+	"sender": {"id": 2, "login": "jane", "username": "jane"}
+}`
+
+func TestGiteaEvents(t *testing.T) {
+	tests := []struct {
+		name      string
+		eventType string
+		payload   string
+		wantSha   string
+	}{
+		{name: "push", eventType: giteaPushEventType, payload: giteaPushPayloadJSON, wantSha: "5555555555555555555555555555555555555555"},
+		{name: "pull request", eventType: giteaPullRequestEventType, payload: giteaPullRequestPayloadJSON, wantSha: "6666666666666666666666666666666666666666"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, _ := newTestListener()
+			e.eventType = tt.eventType
+			e.setBuildSha = true
+			e.runSpec.Params = []pipelinev1alpha1.Param{{Name: "revision"}}
+			filter, err := parseRepositoryFilter("foo/bar")
+			if err != nil {
+				t.Fatalf("Error parsing repositories: %s", err)
+			}
+			e.repositories = filter
+
+			event := newEvent(t, "delivery-1234", tt.eventType, []byte(tt.payload))
+			if err := e.HandleRequest(context.Background(), event); err != nil {
+				t.Fatalf("Unexpected error handling request: %s", err)
+			}
+
+			runs, err := e.pipelineClientset.Tekton().PipelineRuns(e.namespace).List(metav1.ListOptions{})
+			if err != nil {
+				t.Fatalf("Error listing pipelineruns: %s", err)
+			}
+			if len(runs.Items) != 1 {
+				t.Fatalf("Expected one pipelinerun, got %d", len(runs.Items))
+			}
+			if got := runs.Items[0].Spec.Params[0].Value; got != tt.wantSha {
+				t.Errorf("Expected revision %q, got %q", tt.wantSha, got)
+			}
+		})
+	}
+}
+
+func TestGiteaSkippedEvents(t *testing.T) {
+	tests := []struct {
+		name      string
+		eventType string
+		payload   string
+	}{
+		{name: "deleted branch", eventType: giteaPushEventType, payload: `{"ref": "refs/heads/old", "after": "0000000000000000000000000000000000000000", "repository": {"full_name": "foo/bar"}}`},
+		{name: "closed pull request", eventType: giteaPullRequestEventType, payload: `{"action": "closed", "number": 3, "pull_request": {"head": {"sha": "6666666666666666666666666666666666666666"}}, "repository": {"full_name": "foo/bar"}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, _ := newTestListener()
+			e.eventType = tt.eventType
+
+			event := newEvent(t, "delivery-1234", tt.eventType, []byte(tt.payload))
+			if err := e.HandleRequest(context.Background(), event); err != nil {
+				t.Fatalf("Unexpected error handling request: %s", err)
+			}
+
+			runs, err := e.pipelineClientset.Tekton().PipelineRuns(e.namespace).List(metav1.ListOptions{})
+			if err != nil {
+				t.Fatalf("Error listing pipelineruns: %s", err)
+			}
+			if len(runs.Items) != 0 {
+				t.Errorf("Expected no pipelineruns, got %d", len(runs.Items))
+			}
+		})
+	}
+}
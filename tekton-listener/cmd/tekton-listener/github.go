@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/knative/pkg/logging"
+	"github.com/pkg/errors"
+	gh "gopkg.in/go-playground/webhooks.v5/github"
+)
+
+// serveRawWebhook handles a GitHub webhook posted directly rather than wrapped
+// in a cloudevent. The X-GitHub-Event header selects the payload type and the
+// X-Hub-Signature header is verified against the webhook secret. The
+// check_suite, ping, pull_request, pull_request_review, push and release
+// events are handled.
+func (e *EventListener) serveRawWebhook(w http.ResponseWriter, req *http.Request) {
+	body, ok := e.readBody(w, req)
+	if !ok {
+		return
+	}
+	// the webhook parser reads the body again
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	parsed, err := e.githubHook.Parse(req, gh.CheckSuiteEvent, gh.PushEvent, gh.PingEvent, gh.ReleaseEvent, gh.PullRequestEvent, gh.PullRequestReviewEvent)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to parse github webhook: %v", err), rawWebhookErrorStatus(err))
+		return
+	}
+	// the generic form of the payload is used to resolve param mappings
+	var payload interface{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		http.Error(w, fmt.Sprintf("failed to decode github webhook: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if err := e.handleRawWebhook(e.withHeaderParams(req.Context(), req.Header), req.Header.Get("X-GitHub-Delivery"), parsed, body, payload); err != nil {
+		http.Error(w, err.Error(), handleErrorStatus(err))
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// handleRawWebhook dispatches a parsed GitHub webhook to its handler. The pull
+// request handlers decode their own form of the payload from body.
+func (e *EventListener) handleRawWebhook(ctx context.Context, id string, parsed interface{}, body []byte, payload interface{}) error {
+	// All log lines for this delivery carry its ID so they can be correlated
+	logger := e.logger.With("eventID", id)
+	ctx = logging.WithLogger(ctx, logger)
+	// raw webhooks have no source or subject, only the delivery ID
+	ctx = withEventAnnotations(ctx, "", "", id)
+
+	return e.deduplicate(ctx, id, func() error {
+		switch p := parsed.(type) {
+		case gh.CheckSuitePayload:
+			logger.Info("Handling github webhook: check_suite")
+			return e.handleCheckSuite(ctx, &p, payload)
+		case gh.PingPayload:
+			logger.Info("received ping")
+			return nil
+		case gh.PushPayload:
+			logger.Info("Handling github webhook: push")
+			return e.handlePush(ctx, &p, payload)
+		case gh.ReleasePayload:
+			logger.Info("Handling github webhook: release")
+			return e.handleRelease(ctx, &p, payload)
+		case gh.PullRequestPayload:
+			logger.Info("Handling github webhook: pull_request")
+			pr := &githubPullRequestPayload{}
+			if err := decodeRawPayload(ctx, body, pr, "Error handling pull request payload"); err != nil {
+				return err
+			}
+			return e.handlePullRequestPayload(ctx, string(gh.PullRequestEvent), pr, payload)
+		case gh.PullRequestReviewPayload:
+			logger.Info("Handling github webhook: pull_request_review")
+			review := &githubPullRequestReviewPayload{}
+			if err := decodeRawPayload(ctx, body, review, "Error handling pull request review payload"); err != nil {
+				return err
+			}
+			return e.handlePullRequestReviewPayload(ctx, string(gh.PullRequestReviewEvent), review, payload)
+		}
+		return errors.Errorf("Unsupported github webhook payload %T", parsed)
+	})
+}
+
+// rawWebhookErrorStatus maps a webhook parsing error to a response status.
+func rawWebhookErrorStatus(err error) int {
+	switch err {
+	case gh.ErrInvalidHTTPMethod:
+		return http.StatusMethodNotAllowed
+	case gh.ErrMissingHubSignatureHeader, gh.ErrHMACVerificationFailed:
+		return http.StatusUnauthorized
+	}
+	return http.StatusBadRequest
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	pipelinev1alpha1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1alpha1"
+	gh "gopkg.in/go-playground/webhooks.v5/github"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const testWebhookSecret = "s3cr3t"
+
+// newRawWebhookListener returns a test listener serving raw GitHub webhooks
+// signed with testWebhookSecret.
+func newRawWebhookListener(t *testing.T) *EventListener {
+	e, _ := newTestListener()
+	hook, err := gh.New(gh.Options.Secret(testWebhookSecret))
+	if err != nil {
+		t.Fatalf("Error creating github webhook parser: %s", err)
+	}
+	e.rawWebhook = true
+	e.githubHook = hook
+	return e
+}
+
+// newRawWebhookRequest returns a GitHub webhook request for event carrying
+// body, signed with secret.
+func newRawWebhookRequest(event, secret string, body []byte) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, listenerPath, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-GitHub-Event", event)
+	req.Header.Set("X-GitHub-Delivery", "delivery-1234")
+	mac := hmac.New(sha1.New, []byte(secret))
+	mac.Write(body)
+	req.Header.Set("X-Hub-Signature", "sha1="+hex.EncodeToString(mac.Sum(nil)))
+	return req
+}
+
+func TestServeRawWebhook(t *testing.T) {
+	pushPayload, err := json.Marshal(map[string]interface{}{"ref": "refs/heads/master", "after": "def456"})
+	if err != nil {
+		t.Fatalf("Error marshalling payload: %s", err)
+	}
+	deletePayload, err := json.Marshal(map[string]interface{}{"ref": "refs/heads/old", "deleted": true})
+	if err != nil {
+		t.Fatalf("Error marshalling payload: %s", err)
+	}
+
+	tests := []struct {
+		name     string
+		event    string
+		secret   string
+		body     []byte
+		wantCode int
+		wantRuns int
+	}{
+		{name: "check suite", event: "check_suite", secret: testWebhookSecret, body: checkSuitePayload(t, "success", "abc123"), wantCode: http.StatusAccepted, wantRuns: 1},
+		{name: "failed check suite", event: "check_suite", secret: testWebhookSecret, body: checkSuitePayload(t, "failure", "abc123"), wantCode: http.StatusAccepted},
+		{name: "push", event: "push", secret: testWebhookSecret, body: pushPayload, wantCode: http.StatusAccepted, wantRuns: 1},
+		{name: "deleted ref", event: "push", secret: testWebhookSecret, body: deletePayload, wantCode: http.StatusAccepted},
+		{name: "ping", event: "ping", secret: testWebhookSecret, body: []byte(`{"zen": "Keep it logically awesome.", "hook_id": 1}`), wantCode: http.StatusAccepted},
+		{name: "bad signature", event: "check_suite", secret: "wrong", body: checkSuitePayload(t, "success", "abc123"), wantCode: http.StatusUnauthorized},
+		{name: "unsupported event", event: "issues", secret: testWebhookSecret, body: []byte(`{}`), wantCode: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newRawWebhookListener(t)
+			rec := httptest.NewRecorder()
+			e.newServer().Handler.ServeHTTP(rec, newRawWebhookRequest(tt.event, tt.secret, tt.body))
+			if rec.Code != tt.wantCode {
+				t.Errorf("Expected status %d, got %d: %s", tt.wantCode, rec.Code, rec.Body.String())
+			}
+
+			runs, err := e.pipelineClientset.Tekton().PipelineRuns(e.namespace).List(metav1.ListOptions{})
+			if err != nil {
+				t.Fatalf("Error listing pipelineruns: %s", err)
+			}
+			if len(runs.Items) != tt.wantRuns {
+				t.Errorf("Expected %d pipelineruns, got %d", tt.wantRuns, len(runs.Items))
+			}
+		})
+	}
+}
+
+func TestServeRawWebhookPushRevision(t *testing.T) {
+	e := newRawWebhookListener(t)
+	e.setBuildSha = true
+	e.runSpec.Params = []pipelinev1alpha1.Param{{Name: "revision", Value: "master"}}
+	body, err := json.Marshal(map[string]interface{}{"ref": "refs/heads/master", "after": "def456"})
+	if err != nil {
+		t.Fatalf("Error marshalling payload: %s", err)
+	}
+
+	rec := httptest.NewRecorder()
+	e.newServer().Handler.ServeHTTP(rec, newRawWebhookRequest("push", testWebhookSecret, body))
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusAccepted, rec.Code, rec.Body.String())
+	}
+	run := createdRun(t, e)
+	if len(run.Spec.Params) != 1 || run.Spec.Params[0].Value != "def456" {
+		t.Errorf("Expected the revision param to be the pushed SHA, got %v", run.Spec.Params)
+	}
+}
+
+func TestHandleRequestPing(t *testing.T) {
+	e, logs := newTestListener()
+	event := newEvent(t, "delivery-1234", githubPingEventType, []byte(`{"zen": "Keep it logically awesome.", "hook_id": 1}`))
+	if err := e.HandleRequest(context.Background(), event); err != nil {
+		t.Fatalf("Expected a ping to be acknowledged, got %s", err)
+	}
+	if logs.FilterMessage("received ping").Len() != 1 {
+		t.Errorf("Expected the ping to be logged, got %+v", logs.All())
+	}
+
+	runs, err := e.pipelineClientset.Tekton().PipelineRuns(e.namespace).List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("Error listing pipelineruns: %s", err)
+	}
+	if len(runs.Items) != 0 {
+		t.Errorf("Expected no pipelineruns, got %d", len(runs.Items))
+	}
+}
+
+// releasePayload returns a marshalled release payload.
+func releasePayload(t *testing.T, action, tag string, draft bool) []byte {
+	payload, err := json.Marshal(map[string]interface{}{
+		"action": action,
+		"release": map[string]interface{}{
+			"tag_name":         tag,
+			"target_commitish": "master",
+			"draft":            draft,
+		},
+		"repository": map[string]interface{}{"full_name": "foo/bar"},
+	})
+	if err != nil {
+		t.Fatalf("Error marshalling payload: %s", err)
+	}
+	return payload
+}
+
+func TestHandleRequestRelease(t *testing.T) {
+	tests := []struct {
+		name     string
+		action   string
+		draft    bool
+		wantRuns int
+	}{
+		{name: "published", action: "published", wantRuns: 1},
+		{name: "draft", action: "created", draft: true},
+		{name: "published draft", action: "published", draft: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, _ := newTestListener()
+			e.eventType = githubReleaseEventType
+			e.setBuildSha = true
+			e.runSpec.Params = []pipelinev1alpha1.Param{{Name: "revision", Value: "master"}, {Name: "tag", Value: "latest"}}
+
+			event := newEvent(t, "delivery-1234", githubReleaseEventType, releasePayload(t, tt.action, "v1.2.0", tt.draft))
+			if err := e.HandleRequest(context.Background(), event); err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			runs, err := e.pipelineClientset.Tekton().PipelineRuns(e.namespace).List(metav1.ListOptions{})
+			if err != nil {
+				t.Fatalf("Error listing pipelineruns: %s", err)
+			}
+			if len(runs.Items) != tt.wantRuns {
+				t.Fatalf("Expected %d pipelineruns, got %d", tt.wantRuns, len(runs.Items))
+			}
+			if tt.wantRuns == 0 {
+				return
+			}
+			want := []pipelinev1alpha1.Param{{Name: "revision", Value: "v1.2.0"}, {Name: "tag", Value: "v1.2.0"}}
+			if !reflect.DeepEqual(runs.Items[0].Spec.Params, want) {
+				t.Errorf("Expected params %v, got %v", want, runs.Items[0].Spec.Params)
+			}
+		})
+	}
+}
+
+func TestServeRawWebhookRelease(t *testing.T) {
+	e := newRawWebhookListener(t)
+	rec := httptest.NewRecorder()
+	e.newServer().Handler.ServeHTTP(rec, newRawWebhookRequest("release", testWebhookSecret, releasePayload(t, "published", "v1.2.0", false)))
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusAccepted, rec.Code, rec.Body.String())
+	}
+	createdRun(t, e)
+}
+
+func TestServeRawWebhookPullRequests(t *testing.T) {
+	pullRequest := `{"action": %q, "number": 7, "pull_request": {"number": 7, "head": {"ref": "feature", "sha": "7777777777777777777777777777777777777777", "repo": {"full_name": "foo/bar"}}, "base": {"ref": "master", "repo": {"full_name": "foo/bar"}}}, "repository": {"full_name": "foo/bar"}}`
+	tests := []struct {
+		name     string
+		event    string
+		body     string
+		wantRuns int
+	}{
+		{name: "opened pull request", event: "pull_request", body: fmt.Sprintf(pullRequest, "opened"), wantRuns: 1},
+		{name: "closed pull request", event: "pull_request", body: fmt.Sprintf(pullRequest, "closed")},
+		{name: "approving review", event: "pull_request_review", body: pullRequestReviewPayload("submitted", "approved"), wantRuns: 1},
+		{name: "dismissed review", event: "pull_request_review", body: pullRequestReviewPayload("dismissed", "approved")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newRawWebhookListener(t)
+			e.setBuildSha = true
+			e.runSpec.Params = []pipelinev1alpha1.Param{{Name: "revision"}}
+			e.reviewStates = []string{"approved"}
+			rec := httptest.NewRecorder()
+			e.newServer().Handler.ServeHTTP(rec, newRawWebhookRequest(tt.event, testWebhookSecret, []byte(tt.body)))
+			if rec.Code != http.StatusAccepted {
+				t.Fatalf("Expected status %d, got %d: %s", http.StatusAccepted, rec.Code, rec.Body.String())
+			}
+
+			runs, err := e.pipelineClientset.Tekton().PipelineRuns(e.namespace).List(metav1.ListOptions{})
+			if err != nil {
+				t.Fatalf("Error listing pipelineruns: %s", err)
+			}
+			if len(runs.Items) != tt.wantRuns {
+				t.Fatalf("Expected %d pipelineruns, got %d", tt.wantRuns, len(runs.Items))
+			}
+			if tt.wantRuns == 1 && runs.Items[0].Spec.Params[0].Value != "7777777777777777777777777777777777777777" {
+				t.Errorf("Expected the pull request head to be built, got %v", runs.Items[0].Spec.Params)
+			}
+		})
+	}
+}
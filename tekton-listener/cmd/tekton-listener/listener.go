@@ -0,0 +1,193 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	experimentalClientset "github.com/tektoncd/experimental/tekton-listener/pkg/client/clientset/versioned"
+	pipelineClientset "github.com/tektoncd/pipeline/pkg/client/clientset/versioned"
+	"go.uber.org/zap"
+	gh "gopkg.in/go-playground/webhooks.v5/github"
+	"k8s.io/client-go/tools/record"
+)
+
+// NewEventListener returns the EventListener configured by cfg, creating runs
+// with pipelineClient for the TektonListener read with experimentClient. The
+// TektonListener is waited for for up to cfg.StartupTimeout. An error is
+// returned if the TektonListener can't be read or cfg is not valid.
+//
+// Until a recorder is set on the returned listener the events recorded
+// against the TektonListener are discarded.
+func NewEventListener(cfg Config, logger *zap.SugaredLogger, pipelineClient pipelineClientset.Interface, experimentClient experimentalClientset.Interface) (*EventListener, error) {
+	if cfg.Namespace == "" {
+		return nil, errors.New("NAMESPACE env var can not be empty")
+	}
+	runNamespace := cfg.RunNamespace
+	if runNamespace == "" {
+		runNamespace = cfg.Namespace
+	}
+	if cfg.Event != cloudEventType {
+		return nil, errors.Errorf("invalid event type: %q", cfg.Event)
+	}
+	switch cfg.Mode {
+	case pipelineRunMode:
+	case triggerBindingMode:
+		if cfg.TriggersURL == "" {
+			return nil, errors.Errorf("TRIGGERS_URL must be set in %s mode", triggerBindingMode)
+		}
+	default:
+		return nil, errors.Errorf("invalid mode: %q", cfg.Mode)
+	}
+	if !validForkPolicy(cfg.ForkPolicy) {
+		return nil, errors.Errorf("invalid fork policy: %q", cfg.ForkPolicy)
+	}
+	checkSuiteActions := splitList(cfg.CheckSuiteActions)
+	for _, action := range checkSuiteActions {
+		if !containsString(checkSuiteRequestActions, action) {
+			return nil, errors.Errorf("invalid check suite action: %q", action)
+		}
+	}
+	reviewStates := splitList(cfg.ReviewStates)
+	for _, state := range reviewStates {
+		if !containsString(githubReviewStates, state) {
+			return nil, errors.Errorf("invalid review state: %q", state)
+		}
+	}
+
+	deliveries, err := newDeliveryCache(cfg.DedupCacheSize, cfg.DedupTTL)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error creating delivery cache")
+	}
+	var builtShas *deliveryCache
+	if cfg.DedupeBySha {
+		builtShas, err = newDeliveryCache(cfg.DedupCacheSize, cfg.DedupTTL)
+		if err != nil {
+			return nil, errors.Wrap(err, "Error creating SHA cache")
+		}
+	}
+	paramMappings, err := parseParamMappings(cfg.ParamMappings)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error parsing param mappings")
+	}
+	headerParams, err := parseHeaderParams(cfg.HeaderParams)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error parsing header params")
+	}
+	eventTypePrefixes, err := parseEventTypePrefixes(cfg.EventTypePrefixes)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error parsing event type prefixes")
+	}
+	if !strings.HasPrefix(cfg.ListenerPath, "/") {
+		return nil, errors.Errorf("invalid listener path %q: it must start with /", cfg.ListenerPath)
+	}
+	receivers, err := parseReceivers(cfg.Receivers, cfg.Port, cfg.ListenerPath, cfg.EventType)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error parsing receivers")
+	}
+	repositories, err := parseRepositoryFilter(cfg.Repositories)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error parsing repositories")
+	}
+	ignoreAuthors, err := parseAuthorFilter(cfg.IgnoreAuthors)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error parsing ignored authors")
+	}
+	podTemplate, err := parsePodTemplate(cfg.PodTemplate)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error parsing pod template")
+	}
+	pipelineByBranch, err := parsePipelineByBranch(cfg.PipelineByBranch)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error parsing pipelines by branch")
+	}
+	shaPath, err := parseShaPath(cfg.ShaPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error parsing SHA path")
+	}
+	if cfg.GenericEventType != "" && shaPath == nil {
+		return nil, errors.Errorf("SHA_PATH must be set with GENERIC_EVENT_TYPE %q", cfg.GenericEventType)
+	}
+	githubHook, err := gh.New(gh.Options.Secret(cfg.WebhookSecret))
+	if err != nil {
+		return nil, errors.Wrap(err, "Error creating github webhook parser")
+	}
+	if cfg.RawWebhook && cfg.WebhookSecret == "" {
+		logger.Warn("WEBHOOK_SECRET is not set, raw webhook signatures will not be verified")
+	}
+	if cfg.WebhookSecret == "" {
+		logger.Infof("WEBHOOK_SECRET is not set, runs can not be triggered by hand at %s", triggerPath)
+	}
+
+	listener, err := waitForListener(logger, experimentClient, cfg.Namespace, cfg.ListenerResource, cfg.StartupTimeout, listenerPollInterval)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get tekton listener spec: %s in namespace: %s", cfg.ListenerResource, cfg.Namespace)
+	}
+	runSpec, err := listenerRunSpec(listener)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Invalid tekton listener %s in namespace %s", cfg.ListenerResource, cfg.Namespace)
+	}
+
+	return &EventListener{
+		event:               cfg.Event,
+		eventType:           cfg.EventType,
+		port:                cfg.Port,
+		listenerPath:        cfg.ListenerPath,
+		namespace:           cfg.Namespace,
+		runNamespace:        runNamespace,
+		mux:                 &sync.Mutex{},
+		pipelineClientset:   pipelineClient,
+		experimentClientset: experimentClient,
+		runName:             listenerInstanceName(listener.Name, cfg.Port),
+		runSpec:             runSpec,
+		runLabels:           listener.Spec.RunLabels,
+		runAnnotations:      listener.Spec.RunAnnotations,
+		setBuildSha:         cfg.SetBuildSha,
+		serviceAccount:      cfg.ServiceAccount,
+		logger:              logger,
+		deliveries:          deliveries,
+		builtShas:           builtShas,
+		readTimeout:         cfg.ReadTimeout,
+		writeTimeout:        cfg.WriteTimeout,
+		idleTimeout:         cfg.IdleTimeout,
+		maxPayloadBytes:     cfg.MaxPayloadBytes,
+		tlsCertFile:         cfg.TLSCertFile,
+		tlsKeyFile:          cfg.TLSKeyFile,
+		paramMappings:       paramMappings,
+		headerParams:        headerParams,
+		payloadParam:        cfg.PayloadParam,
+		commitAuthorParam:   cfg.CommitAuthorParam,
+		commitMessageParam:  cfg.CommitMessageParam,
+		listener:            listener,
+		recorder:            &record.FakeRecorder{},
+		dryRun:              cfg.DryRun,
+		mode:                cfg.Mode,
+		triggersURL:         cfg.TriggersURL,
+		triggersClient:      &http.Client{Timeout: 30 * time.Second},
+		receivers:           receivers,
+		rawWebhook:          cfg.RawWebhook,
+		githubHook:          githubHook,
+		webhookSecret:       cfg.WebhookSecret,
+		runTimeout:          cfg.RunTimeout,
+		podTemplate:         podTemplate,
+		limiter:             newRepositoryLimiter(cfg.RateLimit, cfg.RateBurst),
+		forceTimeout:        cfg.ForceTimeout,
+		repositories:        repositories,
+		ignoreAuthors:       ignoreAuthors,
+		allowedSources:      splitList(cfg.AllowedSources),
+		strictSpecVersion:   cfg.StrictSpecVersion,
+		forkPolicy:          cfg.ForkPolicy,
+		checkSuiteActions:   checkSuiteActions,
+		reviewStates:        reviewStates,
+		genericEventType:    cfg.GenericEventType,
+		eventTypePrefixes:   eventTypePrefixes,
+		pipelineByBranch:    pipelineByBranch,
+		shaPath:             shaPath,
+		deadLetterURL:       cfg.DeadLetterURL,
+		deadLetterClient:    &http.Client{Timeout: 30 * time.Second},
+		repositoryRunNames:  cfg.RepositoryRunNames,
+		cancelInFlight:      cfg.CancelInFlight,
+	}, nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	experimentalv1alpha1 "github.com/tektoncd/experimental/tekton-listener/pkg/apis/pipelineexperimental/v1alpha1"
+	fakeexperimental "github.com/tektoncd/experimental/tekton-listener/pkg/client/clientset/versioned/fake"
+	pipelinev1alpha1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1alpha1"
+	fakepipeline "github.com/tektoncd/pipeline/pkg/client/clientset/versioned/fake"
+	"go.uber.org/zap"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// testConfig returns a valid config, with the defaults envdecode would set,
+// for the test-listener.
+func testConfig() Config {
+	return Config{
+		Event:            cloudEventType,
+		EventType:        checkSuiteEventType,
+		Namespace:        "default",
+		ListenerResource: "test-listener",
+		Port:             8082,
+		ListenerPath:     listenerPath,
+		DedupCacheSize:   1024,
+		DedupTTL:         time.Hour,
+		MaxPayloadBytes:  1 << 20,
+		Mode:             pipelineRunMode,
+		ForkPolicy:       "skip",
+		ReviewStates:     "approved",
+		RateBurst:        5,
+	}
+}
+
+// testListenerClient returns a clientset holding the test-listener with a runspec.
+func testListenerClient() *fakeexperimental.Clientset {
+	return fakeexperimental.NewSimpleClientset(&experimentalv1alpha1.TektonListener{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-listener", Namespace: "default"},
+		Spec: experimentalv1alpha1.TektonListenerSpec{
+			PipelineRunSpec: &pipelinev1alpha1.PipelineRunSpec{
+				PipelineRef: pipelinev1alpha1.PipelineRef{Name: "test-pipeline"},
+			},
+		},
+	})
+}
+
+func TestNewEventListener(t *testing.T) {
+	pipelineClient := generateNames(fakepipeline.NewSimpleClientset())
+	e, err := NewEventListener(testConfig(), zap.NewNop().Sugar(), pipelineClient, testListenerClient())
+	if err != nil {
+		t.Fatalf("Error creating the listener: %s", err)
+	}
+	if e.runNamespace != "default" {
+		t.Errorf("Expected runs to be created in the listener's namespace, got %q", e.runNamespace)
+	}
+	if e.runName != listenerInstanceName("test-listener", 8082) {
+		t.Errorf("Expected runs to be named after the listener, got %q", e.runName)
+	}
+	if e.runSpec.PipelineRef.Name != "test-pipeline" {
+		t.Errorf("Expected the listener's runspec, got %+v", e.runSpec)
+	}
+
+	rec := httptest.NewRecorder()
+	e.newServer().Handler.ServeHTTP(rec, newCheckSuiteRequest("delivery-1234", checkSuitePayload(t, "success", "abc123")))
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusAccepted, rec.Code, rec.Body.String())
+	}
+	runs, err := pipelineClient.Tekton().PipelineRuns("default").List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("Error listing pipelineruns: %s", err)
+	}
+	if len(runs.Items) != 1 {
+		t.Errorf("Expected one pipelinerun, got %d", len(runs.Items))
+	}
+}
+
+func TestNewEventListenerInvalidConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		update func(*Config)
+	}{
+		{name: "no namespace", update: func(cfg *Config) { cfg.Namespace = "" }},
+		{name: "invalid event", update: func(cfg *Config) { cfg.Event = "webhook" }},
+		{name: "invalid mode", update: func(cfg *Config) { cfg.Mode = "taskrun" }},
+		{name: "trigger binding mode without URL", update: func(cfg *Config) { cfg.Mode = triggerBindingMode }},
+		{name: "invalid fork policy", update: func(cfg *Config) { cfg.ForkPolicy = "always" }},
+		{name: "invalid check suite action", update: func(cfg *Config) { cfg.CheckSuiteActions = "completed" }},
+		{name: "invalid review state", update: func(cfg *Config) { cfg.ReviewStates = "dismissed" }},
+		{name: "invalid dedup cache size", update: func(cfg *Config) { cfg.DedupCacheSize = -1 }},
+		{name: "invalid param mappings", update: func(cfg *Config) { cfg.ParamMappings = "{" }},
+		{name: "invalid event type prefixes", update: func(cfg *Config) { cfg.EventTypePrefixes = "com.github." }},
+		{name: "invalid pipelines by branch", update: func(cfg *Config) { cfg.PipelineByBranch = "main" }},
+		{name: "invalid listener path", update: func(cfg *Config) { cfg.ListenerPath = "events" }},
+		{name: "invalid receivers", update: func(cfg *Config) { cfg.Receivers = "[]" }},
+		{name: "generic event type without SHA path", update: func(cfg *Config) { cfg.GenericEventType = "com.example.build" }},
+		{name: "missing listener", update: func(cfg *Config) { cfg.ListenerResource = "missing" }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig()
+			tt.update(&cfg)
+			if _, err := NewEventListener(cfg, zap.NewNop().Sugar(), fakepipeline.NewSimpleClientset(), testListenerClient()); err == nil {
+				t.Error("Expected an error creating the listener")
+			}
+		})
+	}
+}
+
+func TestNewEventListenerNoRunSpec(t *testing.T) {
+	client := fakeexperimental.NewSimpleClientset(&experimentalv1alpha1.TektonListener{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-listener", Namespace: "default"},
+	})
+	if _, err := NewEventListener(testConfig(), zap.NewNop().Sugar(), fakepipeline.NewSimpleClientset(), client); err == nil {
+		t.Error("Expected an error creating a listener without a runspec")
+	}
+}
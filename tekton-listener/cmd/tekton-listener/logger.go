@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// newLoggerConfig returns the zap config for the given level and format. The
+// dpanic, panic and fatal levels are rejected, as they would drop the
+// listener's error logs.
+func newLoggerConfig(level, format string) (zap.Config, error) {
+	cfg := zap.NewProductionConfig()
+	var l zapcore.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil || l < zapcore.DebugLevel || l > zapcore.ErrorLevel {
+		return cfg, fmt.Errorf("invalid log level %q, expected one of debug, info, warn or error", level)
+	}
+	switch format {
+	case "json", "console":
+	default:
+		return cfg, fmt.Errorf("invalid log format %q, expected json or console", format)
+	}
+	cfg.Level = zap.NewAtomicLevelAt(l)
+	cfg.Encoding = format
+	if format == "console" {
+		cfg.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+	}
+	return cfg, nil
+}
+
+// newLogger builds the listener's logger at level, writing lines in format.
+func newLogger(level, format string) (*zap.SugaredLogger, error) {
+	cfg, err := newLoggerConfig(level, format)
+	if err != nil {
+		return nil, err
+	}
+	logger, err := cfg.Build()
+	if err != nil {
+		return nil, err
+	}
+	return logger.Named("event-listener").Sugar(), nil
+}
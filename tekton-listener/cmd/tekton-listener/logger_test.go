@@ -0,0 +1,62 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoggerLevel(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logger")
+	if err != nil {
+		t.Fatalf("Error creating temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, format := range []string{"json", "console"} {
+		cfg, err := newLoggerConfig("info", format)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		out := filepath.Join(dir, format+".log")
+		cfg.OutputPaths = []string{out}
+		logger, err := cfg.Build()
+		if err != nil {
+			t.Fatalf("Error building logger: %s", err)
+		}
+		logger.Debug("debug line")
+		logger.Info("info line")
+		logger.Sync()
+
+		b, err := ioutil.ReadFile(out)
+		if err != nil {
+			t.Fatalf("Error reading log output: %s", err)
+		}
+		if strings.Contains(string(b), "debug line") {
+			t.Errorf("Expected debug lines to be suppressed at info level in %s output: %s", format, b)
+		}
+		if !strings.Contains(string(b), "info line") {
+			t.Errorf("Expected info lines in %s output: %s", format, b)
+		}
+		if format == "json" && !strings.HasPrefix(string(b), "{") {
+			t.Errorf("Expected json output, got %s", b)
+		}
+	}
+}
+
+func TestLoggerConfigInvalid(t *testing.T) {
+	if _, err := newLoggerConfig("verbose", "json"); err == nil {
+		t.Error("Expected an error for an invalid level")
+	}
+	// levels above error would drop the listener's error logs
+	for _, level := range []string{"dpanic", "panic", "fatal"} {
+		if _, err := newLoggerConfig(level, "json"); err == nil {
+			t.Errorf("Expected an error for the %s level", level)
+		}
+	}
+	if _, err := newLoggerConfig("info", "xml"); err == nil {
+		t.Error("Expected an error for an invalid format")
+	}
+}
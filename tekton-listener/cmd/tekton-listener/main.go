@@ -1,33 +1,72 @@
 package main
 
 import (
+	"compress/gzip"
 	"context"
+	"encoding/json"
 	"fmt"
-	"log"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
 	"strings"
 	"sync"
+	"syscall"
+	"time"
 
 	"github.com/cloudevents/sdk-go/pkg/cloudevents"
-	"github.com/cloudevents/sdk-go/pkg/cloudevents/client"
-	"github.com/cloudevents/sdk-go/pkg/cloudevents/transport/http"
+	cehttp "github.com/cloudevents/sdk-go/pkg/cloudevents/transport/http"
 	"github.com/joeshaw/envdecode"
+	experimentalv1alpha1 "github.com/tektoncd/experimental/tekton-listener/pkg/apis/pipelineexperimental/v1alpha1"
 	experimentalClientset "github.com/tektoncd/experimental/tekton-listener/pkg/client/clientset/versioned"
+	experimentalScheme "github.com/tektoncd/experimental/tekton-listener/pkg/client/clientset/versioned/scheme"
 
 	pipelinev1alpha1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1alpha1"
 	pipelineClientset "github.com/tektoncd/pipeline/pkg/client/clientset/versioned"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/knative/pkg/logging"
 	"github.com/pkg/errors"
+	"go.uber.org/zap"
 	gh "gopkg.in/go-playground/webhooks.v5/github"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/record"
 )
 
 const (
+	// listenerPath is the path events are received at when no other is configured
 	listenerPath   = "/events"
 	cloudEventType = "cloudevent"
+
+	githubCheckSuiteEventType = "com.github.checksuite"
+	githubPushEventType       = "com.github.push"
+	githubPingEventType       = "com.github.ping"
+	githubReleaseEventType    = "com.github.release"
+
+	// listenerLabel and revisionLabel are set on each run to the listener
+	// that created it and the revision it was created for, and instanceLabel
+	// to the port qualified name of the listener process
+	listenerLabel = "tekton.dev/tektonlistener"
+	revisionLabel = "tekton.dev/revision"
+	instanceLabel = "webhooks.tekton.dev/listener-instance"
+
+	// eventSourceAnnotation, eventSubjectAnnotation and eventIDAnnotation
+	// are set on each run to the cloudevent it was created for, so that
+	// runs can be traced back to their event
+	eventSourceAnnotation  = "webhooks.tekton.dev/event-source"
+	eventSubjectAnnotation = "webhooks.tekton.dev/event-subject"
+	eventIDAnnotation      = "webhooks.tekton.dev/event-id"
 )
 
+// checkSuiteRequestActions are the check_suite actions that can be set in CHECK_SUITE_ACTIONS.
+var checkSuiteRequestActions = []string{"requested", "rerequested"}
+
 type Config struct {
 	Event            string `env:"EVENT,default=cloudevent"`
 	EventType        string `env:"EVENT_TYPE,default=com.github.checksuite"`
@@ -38,6 +77,124 @@ type Config struct {
 	ListenerResource string `env:"LISTENER_RESOURCE"`
 	Port             int    `env:"PORT,default=8082"`
 	SetBuildSha      bool   `env:"SETBUILDSHA"`
+	// ListenerPath is the path events are received at, by receivers
+	// configured without a path too. It must start with /.
+	ListenerPath string `env:"LISTENER_PATH,default=/events"`
+	// RunNamespace is the namespace PipelineRuns are created in, and that
+	// the pipeline and resources of the runspec are in. When empty runs are
+	// created in Namespace, with the listener.
+	RunNamespace string `env:"RUN_NAMESPACE"`
+	// DedupCacheSize and DedupTTL control how many recent delivery IDs are
+	// remembered, and for how long, to drop retried deliveries.
+	DedupCacheSize int           `env:"DEDUP_CACHE_SIZE,default=1024"`
+	DedupTTL       time.Duration `env:"DEDUP_TTL,default=1h"`
+	// DedupeBySha skips the runs of events for a SHA this listener created
+	// a run for within DedupTTL, such as a push followed by its check suite.
+	DedupeBySha bool `env:"DEDUPE_BY_SHA"`
+	// ReadTimeout, WriteTimeout and IdleTimeout are applied to the listener's HTTP server.
+	ReadTimeout  time.Duration `env:"READ_TIMEOUT,default=10s"`
+	WriteTimeout time.Duration `env:"WRITE_TIMEOUT,default=30s"`
+	IdleTimeout  time.Duration `env:"IDLE_TIMEOUT,default=120s"`
+	// MaxPayloadBytes is the largest request body the listener will accept.
+	MaxPayloadBytes int64 `env:"MAX_PAYLOAD_BYTES,default=1048576"`
+	// TLSCertFile and TLSKeyFile enable HTTPS when both are set.
+	TLSCertFile string `env:"TLS_CERT_FILE"`
+	TLSKeyFile  string `env:"TLS_KEY_FILE"`
+	// ParamMappings sets PipelineRun params from the event payload, given as
+	// a JSON object or comma separated name=jsonpath pairs.
+	ParamMappings string `env:"PARAM_MAPPINGS"`
+	// HeaderParams sets PipelineRun params from request headers, given as a
+	// JSON object or comma separated header=param pairs.
+	HeaderParams string `env:"HEADER_PARAMS"`
+	// PayloadParam names a PipelineRun param set to the whole event payload as JSON.
+	PayloadParam string `env:"PAYLOAD_PARAM"`
+	// CommitAuthorParam and CommitMessageParam name PipelineRun params set
+	// to the author email and message of the event's head commit.
+	CommitAuthorParam  string `env:"COMMIT_AUTHOR_PARAM"`
+	CommitMessageParam string `env:"COMMIT_MESSAGE_PARAM"`
+	// DryRun logs the PipelineRuns that would be created instead of creating them.
+	DryRun bool `env:"DRY_RUN"`
+	// Mode is pipelinerun to create PipelineRuns, or triggerbinding to POST
+	// the params extracted from each event to the TriggersURL instead.
+	Mode        string `env:"MODE,default=pipelinerun"`
+	TriggersURL string `env:"TRIGGERS_URL"`
+	// Receivers is a JSON list of {"port", "path", "eventTypes"} objects to
+	// serve several receivers from one process. When empty a single receiver
+	// serves EventType on Port.
+	Receivers string `env:"RECEIVERS"`
+	// RawWebhook accepts GitHub webhooks posted directly, selecting the
+	// payload type from the X-GitHub-Event header, instead of cloudevents.
+	// WebhookSecret is used to verify their signature, and that of manual
+	// triggers, which are only served when it is set.
+	RawWebhook    bool   `env:"RAW_WEBHOOK"`
+	WebhookSecret string `env:"WEBHOOK_SECRET"`
+	// LogLevel is one of debug, info, warn or error and LogFormat is json or console.
+	LogLevel  string `env:"LOG_LEVEL,default=info"`
+	LogFormat string `env:"LOG_FORMAT,default=json"`
+	// RunTimeout is set as the timeout of runs whose template has none, or of
+	// every run when ForceTimeout is true.
+	RunTimeout   time.Duration `env:"RUN_TIMEOUT"`
+	ForceTimeout bool          `env:"FORCE_TIMEOUT"`
+	// PodTemplate is a JSON object with the nodeSelector and tolerations
+	// merged into each run's spec.
+	PodTemplate string `env:"POD_TEMPLATE"`
+	// RateLimit is the number of runs per second triggered for each
+	// repository, with bursts of up to RateBurst runs. Events over the limit
+	// are acknowledged without a run. When 0 runs are not limited.
+	RateLimit float64 `env:"RATE_LIMIT"`
+	RateBurst int     `env:"RATE_BURST,default=5"`
+	// Repositories limits the events handled to those for repositories
+	// matching one of its comma separated owner/name patterns.
+	Repositories string `env:"REPOSITORIES"`
+	// IgnoreAuthors is a comma separated list of login patterns, such as
+	// bots, whose pushes and pull requests do not create runs.
+	IgnoreAuthors string `env:"IGNORE_AUTHORS"`
+	// AllowedSources is a comma separated list of the cloudevent sources
+	// accepted. When empty events from any source are accepted.
+	AllowedSources string `env:"ALLOWED_SOURCES"`
+	// StrictSpecVersion rejects events not declaring cloudevents version 0.2.
+	// When false the payload is decoded whatever version is declared.
+	StrictSpecVersion bool `env:"STRICT_SPEC_VERSION,default=true"`
+	// ForkPolicy selects what is built for pull requests from forks: skip
+	// ignores them, base builds the merge commit in the base repository and
+	// head builds the fork's head commit.
+	ForkPolicy string `env:"FORK_POLICY,default=skip"`
+	// CheckSuiteActions is a comma separated list of the check_suite
+	// actions, requested or rerequested, that trigger a run when the suite
+	// is queued, in addition to a run when it concludes successfully.
+	CheckSuiteActions string `env:"CHECK_SUITE_ACTIONS"`
+	// ReviewStates is a comma separated list of the pull request review
+	// states, approved, changes_requested or commented, whose submission
+	// triggers a run.
+	ReviewStates string `env:"REVIEW_STATES,default=approved"`
+	// GenericEventType is a cloudevent type, of any provider, for which a
+	// run is triggered at the commit found in the payload at the ShaPath
+	// JSONPath expression.
+	GenericEventType string `env:"GENERIC_EVENT_TYPE"`
+	ShaPath          string `env:"SHA_PATH"`
+	// PipelineByBranch runs another pipeline than the runspec's for events
+	// for some branches, given as a JSON object or comma separated
+	// branch=pipeline pairs. The branch of a pull request is its target.
+	PipelineByBranch string `env:"PIPELINE_BY_BRANCH"`
+	// EventTypePrefixes maps the cloudevent types of other senders onto
+	// the internal types, such as com.github.push, given as a JSON object
+	// or comma separated from=to prefix pairs.
+	EventTypePrefixes string `env:"EVENT_TYPE_PREFIXES"`
+	// DeadLetterURL is a cloudevents sink to which events whose run could
+	// not be created are forwarded, with the reason, to be reprocessed later.
+	// When empty the failure is returned to the sender.
+	DeadLetterURL string `env:"DEAD_LETTER_URL"`
+	// RepositoryRunNames names runs after the repository of the event
+	// rather than the listener, so several repositories sharing a listener
+	// can be told apart.
+	RepositoryRunNames bool `env:"REPOSITORY_RUN_NAMES"`
+	// CancelInFlight cancels the unfinished runs for the same pull request,
+	// or for other events the same branch, when a run is created, so that a
+	// new push supersedes the previous run rather than piling up.
+	CancelInFlight bool `env:"CANCEL_IN_FLIGHT"`
+	// StartupTimeout is how long to wait at startup for the TektonListener,
+	// and its CRD, to be created.
+	StartupTimeout time.Duration `env:"STARTUP_TIMEOUT,default=2m"`
 }
 
 // EventListener starts an event receiver to accept data to trigger pipelineruns.
@@ -45,29 +202,76 @@ type EventListener struct {
 	event               string
 	eventType           string
 	namespace           string
+	runNamespace        string
 	runName             string
 	serviceAccount      string
 	pipelineClientset   pipelineClientset.Interface
 	experimentClientset experimentalClientset.Interface
 	mux                 *sync.Mutex
 	runSpec             pipelinev1alpha1.PipelineRunSpec
+	runLabels           map[string]string
+	runAnnotations      map[string]string
 	port                int
+	listenerPath        string
 	setBuildSha         bool
+	logger              *zap.SugaredLogger
+	deliveries          *deliveryCache
+	builtShas           *deliveryCache
+	readTimeout         time.Duration
+	writeTimeout        time.Duration
+	idleTimeout         time.Duration
+	maxPayloadBytes     int64
+	tlsCertFile         string
+	tlsKeyFile          string
+	paramMappings       []paramMapping
+	headerParams        map[string]string
+	payloadParam        string
+	commitAuthorParam   string
+	commitMessageParam  string
+	listener            *experimentalv1alpha1.TektonListener
+	recorder            record.EventRecorder
+	dryRun              bool
+	mode                string
+	triggersURL         string
+	triggersClient      *http.Client
+	receivers           []receiverConfig
+	rawWebhook          bool
+	githubHook          *gh.Webhook
+	webhookSecret       string
+	runTimeout          time.Duration
+	podTemplate         *podTemplate
+	limiter             *repositoryLimiter
+	forceTimeout        bool
+	repositories        repositoryFilter
+	ignoreAuthors       authorFilter
+	allowedSources      []string
+	strictSpecVersion   bool
+	forkPolicy          string
+	checkSuiteActions   []string
+	reviewStates        []string
+	genericEventType    string
+	eventTypePrefixes   eventTypePrefixes
+	pipelineByBranch    map[string]string
+	shaPath             *paramMapping
+	deadLetterURL       string
+	deadLetterClient    *http.Client
+	repositoryRunNames  bool
+	cancelInFlight      bool
 }
 
 func main() {
 	var cfg Config
 	err := envdecode.Decode(&cfg)
 	if err != nil {
-		log.Fatalf("Failed loading env config: %q", err)
+		// the configured logger can't be built without the config
+		logging.FromContext(context.Background()).Fatalf("Failed loading env config: %q", err)
 	}
 
-	logger, _ := logging.NewLogger("", "event-listener")
-	defer logger.Sync()
-
-	if cfg.Namespace == "" {
-		log.Fatal("NAMESPACE env var can not be empty")
+	logger, err := newLogger(cfg.LogLevel, cfg.LogFormat)
+	if err != nil {
+		logging.FromContext(context.Background()).Fatalf("Error building logger: %v", err)
 	}
+	defer logger.Sync()
 
 	clientcfg, err := clientcmd.BuildConfigFromFlags(cfg.MasterURL, cfg.Kubeconfig)
 	if err != nil {
@@ -83,127 +287,536 @@ func main() {
 		logger.Fatalf("Error building experimental tekton clientset: %v", err)
 	}
 
-	listener, err := experimentClient.PipelineexperimentalV1alpha1().TektonListeners(cfg.Namespace).Get(cfg.ListenerResource, metav1.GetOptions{})
+	e, err := NewEventListener(cfg, logger, pipelineClient, experimentClient)
 	if err != nil {
-		log.Fatalf("failed to get tekton listener spec: %s in namespace: %s error: %q", cfg.ListenerResource, cfg.Namespace, err)
-	}
-	listenerName := fmt.Sprintf("%s-%d", listener.Name, cfg.Port)
-	e := &EventListener{
-		event:               cfg.Event,
-		eventType:           cfg.EventType,
-		port:                cfg.Port,
-		namespace:           cfg.Namespace,
-		mux:                 &sync.Mutex{},
-		pipelineClientset:   pipelineClient,
-		experimentClientset: experimentClient,
-		runName:             listenerName,
-		runSpec:             *listener.Spec.PipelineRunSpec,
-		setBuildSha:         cfg.SetBuildSha,
-		serviceAccount:      cfg.ServiceAccount,
+		logger.Fatalf("Error creating event listener: %v", err)
 	}
 
-	switch e.event {
-	case cloudEventType:
-		e.startCloudEventListener() // handle cloud events
-	default:
-		log.Fatalf("invalid event type: %q", e.event)
+	kubeClient, err := kubernetes.NewForConfig(clientcfg)
+	if err != nil {
+		logger.Fatalf("Error building kubernetes clientset: %v", err)
 	}
+	// Add the experimental types to the scheme so Events can be recorded against the TektonListener
+	experimentalScheme.AddToScheme(scheme.Scheme)
+	eventBroadcaster := record.NewBroadcaster()
+	eventBroadcaster.StartLogging(logger.Named("event-broadcaster").Infof)
+	eventBroadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: kubeClient.CoreV1().Events("")})
+	e.recorder = eventBroadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: "tekton-listener"})
+
+	e.startCloudEventListener() // handle cloud events
 }
 
 func (e *EventListener) startCloudEventListener() {
-	log.Printf("Starting listener on port %d", e.port)
-
-	t, err := http.New(
-		http.WithPort(e.port),
-		http.WithPath(listenerPath),
-	)
+	servers := e.newServers(e.receivers)
+	tlsConfig, err := e.newTLSConfig()
 	if err != nil {
-		log.Fatalf("failed to create http client, %v", err)
+		e.logger.Fatalf("failed to configure TLS: %v", err)
+	}
+	if tlsConfig == nil && (e.tlsCertFile != "" || e.tlsKeyFile != "") {
+		e.logger.Warn("Both TLS_CERT_FILE and TLS_KEY_FILE must be set to enable TLS, serving plain HTTP")
+	}
+
+	// bind every port before serving any so that a bad port fails at startup
+	var listeners []net.Listener
+	for _, srv := range servers {
+		ln, err := net.Listen("tcp", srv.Addr)
+		if err != nil {
+			e.logger.Fatalf("Failed to start cloudevent receiver on %s: %q", srv.Addr, err)
+		}
+		listeners = append(listeners, ln)
+	}
+	for _, r := range e.receivers {
+		e.logger.Infof("Starting listener on port %d at %s for %s (TLS: %t)", r.Port, r.Path, strings.Join(r.EventTypes, ", "), tlsConfig != nil)
 	}
-	client, err := client.New(t, client.WithTimeNow(), client.WithUUIDs())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-signals
+		cancel()
+	}()
+	if err := serve(ctx, servers, listeners, tlsConfig); err != nil {
+		e.logger.Fatalf("Failed to start cloudevent receiver: %q", err)
+	}
+}
+
+// newServer returns the HTTP server that receives cloudevents of eventType on
+// port at the listener path. The server is built here rather than by the cloudevents
+// transport so that its timeouts can be set.
+func (e *EventListener) newServer() *http.Server {
+	return e.newServers([]receiverConfig{{Port: e.port, Path: e.listenerPath, EventTypes: []string{e.eventType}}})[0]
+}
+
+// cloudEventHandler returns a handler that decodes a cloudevent from the
+// request and handles it if it is one of eventTypes.
+func (e *EventListener) cloudEventHandler(eventTypes []string) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		e.serveCloudEvent(w, req, eventTypes)
+	}
+}
+
+// serveCloudEvent decodes a cloudevent from the request and hands it to handleRequest.
+func (e *EventListener) serveCloudEvent(w http.ResponseWriter, req *http.Request, eventTypes []string) {
+	body, ok := e.readBody(w, req)
+	if !ok {
+		return
+	}
+	codec := &cehttp.Codec{}
+	event, err := codec.Decode(&cehttp.Message{Header: req.Header, Body: body})
 	if err != nil {
-		log.Fatalf("failed to create client, %v", err)
+		http.Error(w, fmt.Sprintf("failed to decode cloudevent: %v", err), http.StatusBadRequest)
+		return
+	}
+	if err := e.handleRequest(e.withHeaderParams(req.Context(), req.Header), *event, eventTypes); err != nil {
+		http.Error(w, err.Error(), handleErrorStatus(err))
+		return
 	}
+	w.WriteHeader(http.StatusAccepted)
+}
 
-	log.Fatalf("Failed to start cloudevent receiver: %q", client.StartReceiver(context.Background(), e.HandleRequest))
+// readBody reads the request body, decompressing a gzip encoded body, writing
+// an error response and returning false if it cannot be read or exceeds
+// maxPayloadBytes.
+func (e *EventListener) readBody(w http.ResponseWriter, req *http.Request) ([]byte, bool) {
+	if req.ContentLength > e.maxPayloadBytes {
+		http.Error(w, fmt.Sprintf("request body of %d bytes exceeds the limit of %d bytes", req.ContentLength, e.maxPayloadBytes), http.StatusRequestEntityTooLarge)
+		return nil, false
+	}
+	reader := io.Reader(req.Body)
+	// proxies may compress the body, decompress it so that it can be decoded
+	// and so that the limit applies to the decompressed size
+	if strings.EqualFold(req.Header.Get("Content-Encoding"), "gzip") {
+		gz, err := gzip.NewReader(req.Body)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to read gzip request body: %v", err), http.StatusBadRequest)
+			return nil, false
+		}
+		defer gz.Close()
+		reader = gz
+		req.Header.Del("Content-Encoding")
+	}
+	// read one byte past the limit so that an oversized body without a
+	// content length is still detected
+	body, err := ioutil.ReadAll(io.LimitReader(reader, e.maxPayloadBytes+1))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to read request body: %v", err), http.StatusBadRequest)
+		return nil, false
+	}
+	if int64(len(body)) > e.maxPayloadBytes {
+		http.Error(w, fmt.Sprintf("request body exceeds the limit of %d bytes", e.maxPayloadBytes), http.StatusRequestEntityTooLarge)
+		return nil, false
+	}
+	return body, true
 }
 
 // HandleRequest will decode the body of the cloudevent into the correct payload type based on event type,
 // match on the event type and submit build from repo/branch.
-// Only check_suite events are supported.
+// GitHub check_suite, push and release, and Bitbucket Server and Gitea push and pull request events are supported,
+// as are events of the GENERIC_EVENT_TYPE.
 func (e *EventListener) HandleRequest(ctx context.Context, event cloudevents.Event) error {
+	return e.handleRequest(ctx, event, []string{e.eventType})
+}
+
+// handleRequest is HandleRequest for a receiver accepting eventTypes.
+func (e *EventListener) handleRequest(ctx context.Context, event cloudevents.Event, eventTypes []string) error {
 	// todo: contribute nil check upstream
 	if event.Context == nil {
 		return errors.New("Empty event context")
 	}
 
-	if event.SpecVersion() != "0.2" {
+	if e.strictSpecVersion && event.SpecVersion() != "0.2" {
 		return errors.New("Only cloudevents version 0.2 supported")
 	}
-	if event.Type() != e.eventType {
+	// senders name types differently, the internal type selects the handler
+	eventType := e.eventTypePrefixes.normalize(event.Type())
+	// GitHub sends a ping when a webhook is created, acknowledge it so the
+	// delivery is not reported as failed
+	if eventType == githubPingEventType {
+		e.logger.With("eventID", event.ID()).Info("received ping")
+		return nil
+	}
+	if !containsString(eventTypes, eventType) {
 		return errors.New("Mismatched event type submitted")
 
 	}
+	if len(e.allowedSources) > 0 && !containsString(e.allowedSources, event.Source()) {
+		return fmt.Errorf("Event source %q is not allowed", event.Source())
+	}
+
+	// All log lines for this event carry its ID so they can be correlated
+	logger := e.logger.With("eventID", event.ID())
+	ctx = logging.WithLogger(ctx, logger)
+	logger.Infof("Handling event Type: %q as %q", event.Type(), eventType)
+	ctx = withEventAnnotations(ctx, event.Source(), event.Subject(), event.ID())
+
+	return e.deduplicate(ctx, event.ID(), func() error {
+		return e.handleFailure(ctx, event, e.handleEvent(ctx, event, eventType))
+	})
+}
+
+// deduplicate calls handle unless the delivery with the given ID has already
+// been handled.
+func (e *EventListener) deduplicate(ctx context.Context, id string, handle func() error) error {
+	// GitHub retries deliveries, so the same ID may arrive more than once
+	if id != "" && e.deliveries != nil {
+		if e.deliveries.seen(id) {
+			logging.FromContext(ctx).Info("duplicate delivery, skipping")
+			return nil
+		}
+	}
+
+	if err := handle(); err != nil {
+		// allow a retry of a failed delivery to be handled again
+		if id != "" && e.deliveries != nil {
+			e.deliveries.forget(id)
+		}
+		return err
+	}
+
+	return nil
+}
 
-	log.Printf("Handling event Type: %q", event.Type())
+// handleEvent handles the event with the handler of its internal eventType.
+func (e *EventListener) handleEvent(ctx context.Context, event cloudevents.Event, eventType string) error {
+	// the generic form of the payload is used to resolve param mappings
+	var payload interface{}
+	if err := decodePayload(ctx, event, &payload, fmt.Sprintf("Error decoding %s event payload", event.Type())); err != nil {
+		return err
+	}
 
-	switch event.Type() {
-	case "com.github.checksuite":
+	switch eventType {
+	case githubCheckSuiteEventType:
 		cs := &gh.CheckSuitePayload{}
-		if err := event.DataAs(cs); err != nil {
-			return errors.Wrap(err, "Error handling check suite payload")
+		if err := decodePayload(ctx, event, cs, "Error handling check suite payload"); err != nil {
+			return err
+		}
+		if err := e.handleCheckSuite(ctx, cs, payload); err != nil {
+			return err
 		}
-		if err := e.handleCheckSuite(event, cs); err != nil {
+	case githubPushEventType:
+		push := &gh.PushPayload{}
+		if err := decodePayload(ctx, event, push, "Error handling push payload"); err != nil {
 			return err
 		}
+		return e.handlePush(ctx, push, payload)
+	case githubReleaseEventType:
+		release := &gh.ReleasePayload{}
+		if err := decodePayload(ctx, event, release, "Error handling release payload"); err != nil {
+			return err
+		}
+		return e.handleRelease(ctx, release, payload)
+	case githubPullRequestEventType:
+		return e.handlePullRequest(ctx, event, payload)
+	case githubPullRequestReviewEventType:
+		return e.handlePullRequestReview(ctx, event, payload)
+	case bitbucketPushEventType:
+		return e.handleBitbucketPush(ctx, event, payload)
+	case bitbucketPullRequestEventType:
+		return e.handleBitbucketPullRequest(ctx, event, payload)
+	case giteaPushEventType:
+		return e.handleGiteaPush(ctx, event, payload)
+	case giteaPullRequestEventType:
+		return e.handleGiteaPullRequest(ctx, event, payload)
+	case e.genericEventType:
+		return e.handleGenericEvent(ctx, event, payload)
 	}
 
 	return nil
 }
 
-func (r *EventListener) handleCheckSuite(event cloudevents.Event, cs *gh.CheckSuitePayload) error {
-	if cs.CheckSuite.Conclusion == "success" {
-		build, err := r.createPipelineRun(cs.CheckSuite.HeadSHA)
-		if err != nil {
-			return errors.Wrapf(err, "Error creating pipeline run for check_suite event: %q", event.Type())
+func (r *EventListener) handleCheckSuite(ctx context.Context, cs *gh.CheckSuitePayload, payload interface{}) error {
+	if r.skipRepository(ctx, cs.Repository.FullName) || r.skipAuthor(ctx, cs.Sender.Login) {
+		return nil
+	}
+	// a suite may also be built when it is queued, the head commit is the
+	// same either way
+	if containsString(r.checkSuiteActions, cs.Action) || cs.CheckSuite.Conclusion == "success" {
+		if err := r.trigger(withEventBranch(ctx, cs.CheckSuite.HeadBranch), cs.Repository.FullName, cs.CheckSuite.HeadSHA, payload); err != nil {
+			return errors.Wrap(err, "Error creating pipeline run for check_suite event")
 		}
+	}
+	return nil
+}
 
-		log.Printf("Created pipeline run %q!", build.Name)
+func (e *EventListener) handlePush(ctx context.Context, push *gh.PushPayload, payload interface{}) error {
+	if e.skipRepository(ctx, push.Repository.FullName) || e.skipAuthor(ctx, push.Sender.Login) {
+		return nil
+	}
+	if push.Deleted {
+		logging.FromContext(ctx).Infof("Push deleted %q, skipping", push.Ref)
+		return nil
+	}
+	if err := e.trigger(withEventBranch(ctx, push.Ref), push.Repository.FullName, push.After, payload); err != nil {
+		return errors.Wrap(err, "Error creating pipeline run for push event")
 	}
 	return nil
 }
 
-func (e *EventListener) createPipelineRun(sha string) (*pipelinev1alpha1.PipelineRun, error) {
+// eventAnnotationsKey is the context key of the annotations identifying the event a run is created for.
+type eventAnnotationsKey struct{}
+
+// withEventAnnotations returns a context carrying the annotations for the
+// event's source, subject and ID. Empty values are left out.
+func withEventAnnotations(ctx context.Context, source, subject, id string) context.Context {
+	annotations := map[string]string{}
+	for name, value := range map[string]string{
+		eventSourceAnnotation:  source,
+		eventSubjectAnnotation: subject,
+		eventIDAnnotation:      id,
+	} {
+		if value != "" {
+			annotations[name] = value
+		}
+	}
+	return context.WithValue(ctx, eventAnnotationsKey{}, annotations)
+}
+
+// releaseTagKey is the context key of the tag of the release a run is triggered for.
+type releaseTagKey struct{}
+
+// releaseTag returns the tag of the release being handled, or "" for other events.
+func releaseTag(ctx context.Context) string {
+	tag, _ := ctx.Value(releaseTagKey{}).(string)
+	return tag
+}
+
+// handleRelease triggers a run for a published release. The payload carries
+// no commit SHA so the tag is used as the revision.
+func (e *EventListener) handleRelease(ctx context.Context, release *gh.ReleasePayload, payload interface{}) error {
+	if e.skipRepository(ctx, release.Repository.FullName) {
+		return nil
+	}
+	if release.Action != "published" || release.Release.Draft {
+		logging.FromContext(ctx).Infof("Release %q was %s, skipping", release.Release.TagName, release.Action)
+		return nil
+	}
+	tag := release.Release.TagName
+	if err := e.trigger(context.WithValue(ctx, releaseTagKey{}, tag), release.Repository.FullName, tag, payload); err != nil {
+		return errors.Wrap(err, "Error creating pipeline run for release event")
+	}
+	return nil
+}
+
+// splitList splits a comma separated list, dropping empty entries.
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// containsString reports whether s is one of list.
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// runLabelsFor returns the template labels merged with the labels identifying
+// the listener and sha, which take precedence.
+func (e *EventListener) runLabelsFor(sha string) map[string]string {
+	labels := copyStringMap(e.runLabels)
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	if e.listener != nil {
+		labels[listenerLabel] = e.listener.Name
+	}
+	if e.runName != "" {
+		labels[instanceLabel] = e.runName
+	}
+	if sha != "" {
+		labels[revisionLabel] = sha
+	}
+	return labels
+}
+
+// listenerRunSpec returns the spec of the runs the listener creates. The
+// runspec is required, but is not checked for listeners created before the
+// validation webhook, so a missing one is reported rather than dereferenced.
+func listenerRunSpec(listener *experimentalv1alpha1.TektonListener) (pipelinev1alpha1.PipelineRunSpec, error) {
+	if listener.Spec.PipelineRunSpec == nil {
+		return pipelinev1alpha1.PipelineRunSpec{}, errors.New("the listener has no runspec")
+	}
+	return *listener.Spec.PipelineRunSpec, nil
+}
+
+// listenerInstanceName qualifies the listener name with the port, so that
+// runs created by listener processes on different ports can be told apart.
+func listenerInstanceName(name string, port int) string {
+	return fmt.Sprintf("%s-%d", name, port)
+}
+
+// maxRunNamePrefix is the longest generateName prefix that leaves room for
+// the generated suffix in a 63 character name.
+const maxRunNamePrefix = 57
+
+// runNamePrefix returns the generateName of a run: the repository the event
+// is for with REPOSITORY_RUN_NAMES, falling back to the listener instance
+// name when there is no repository or nothing of it is valid in a name.
+func (e *EventListener) runNamePrefix(ctx context.Context) string {
+	if e.repositoryRunNames {
+		if name := repositoryRunName(eventRepository(ctx)); name != "" {
+			return name + "-"
+		}
+	}
+	return e.runName + "-"
+}
+
+// repositoryRunName reduces a repository, an owner/name or the URL of an
+// event source, to its last path segment, made valid as the start of a
+// resource name: lower case alphanumerics and dashes, not starting with a
+// dash.
+func repositoryRunName(repo string) string {
+	repo = strings.TrimRight(repo, "/")
+	if i := strings.LastIndex(repo, "/"); i >= 0 {
+		repo = repo[i+1:]
+	}
+	repo = strings.TrimSuffix(strings.ToLower(repo), ".git")
+	name := strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '-'
+	}, repo)
+	if len(name) > maxRunNamePrefix-1 {
+		name = name[:maxRunNamePrefix-1]
+	}
+	return strings.Trim(name, "-")
+}
+
+func copyStringMap(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+	c := make(map[string]string, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+func (e *EventListener) createPipelineRun(ctx context.Context, sha string, payload interface{}) (*pipelinev1alpha1.PipelineRun, error) {
+	logger := logging.FromContext(ctx)
 	e.mux.Lock()
 	defer e.mux.Unlock()
 
+	// runs are named after the port qualified listener name, or the
+	// repository, with a suffix generated by the API server so that runs for
+	// successive events don't collide
 	pr := &pipelinev1alpha1.PipelineRun{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      e.runName,
-			Namespace: e.namespace,
+			GenerateName: e.runNamePrefix(ctx),
+			Namespace:    e.runNamespace,
+			Labels:       e.runLabelsFor(sha),
+			Annotations:  copyStringMap(e.runAnnotations),
 		},
 	}
-	// copy the spec template into place
-	pr.Spec = e.runSpec
+	if annotations, _ := ctx.Value(eventAnnotationsKey{}).(map[string]string); len(annotations) > 0 {
+		if pr.Annotations == nil {
+			pr.Annotations = map[string]string{}
+		}
+		for name, value := range annotations {
+			pr.Annotations[name] = value
+		}
+	}
+	// copy the spec template into place, deep so that setting params does
+	// not modify the template
+	pr.Spec = *e.runSpec.DeepCopy()
+	e.applyPipelineByBranch(ctx, &pr.Spec)
+	key := ""
+	if e.cancelInFlight {
+		key = concurrencyKey(ctx)
+	}
+	if key != "" {
+		if pr.Annotations == nil {
+			pr.Annotations = map[string]string{}
+		}
+		pr.Annotations[concurrencyKeyAnnotation] = key
+	}
 
 	if e.setBuildSha {
-		// if enabled, set the builds git revision to the github events SHA
-		for _, param := range pr.Spec.Params {
+		// if enabled, set the builds git revision to the github events SHA,
+		// and the tag for releases
+		tag := releaseTag(ctx)
+		for i := range pr.Spec.Params {
 			switch {
-			case strings.EqualFold(param.Name, "Revision"):
-				param.Value = sha
+			case strings.EqualFold(pr.Spec.Params[i].Name, "Revision"):
+				pr.Spec.Params[i].Value = sha
+			case strings.EqualFold(pr.Spec.Params[i].Name, "Tag") && tag != "":
+				pr.Spec.Params[i].Value = tag
 			default:
-				log.Print("No SHA param to update")
+				logger.Info("No SHA param to update")
 			}
 		}
 	}
 
-	log.Printf("Creating pipelinerun %q sha %q namespace %q", pr.Name, sha, pr.Namespace)
+	pr.Spec.Params = applyParamMappings(logger, e.paramMappings, payload, pr.Spec.Params)
+	pr.Spec.Params = applyHeaderParams(ctx, pr.Spec.Params)
+	pr.Spec.Params = applyCommitParams(e.commitAuthorParam, e.commitMessageParam, payload, pr.Spec.Params)
+	pr.Spec.Params = applyPayloadParam(logger, e.payloadParam, payload, pr.Spec.Params)
+
+	if e.runTimeout > 0 && (pr.Spec.Timeout == nil || e.forceTimeout) {
+		pr.Spec.Timeout = &metav1.Duration{Duration: e.runTimeout}
+	}
+	e.podTemplate.apply(&pr.Spec)
+
+	var pinned []pinnedResource
+	if e.setBuildSha {
+		// git resources get their revision from a copy pinned to the SHA
+		var err error
+		pinned, err = e.pinGitResources(logger, &pr.Spec, sha)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if e.dryRun {
+		for _, pin := range pinned {
+			pr.Spec.Resources[pin.binding].ResourceRef.Name = pin.resource.Name
+		}
+		spec, err := json.Marshal(pr)
+		if err != nil {
+			return nil, errors.Wrap(err, "Error marshalling pipelinerun")
+		}
+		logger.Infow("Dry run, not creating pipelinerun",
+			"generateName", pr.GenerateName,
+			"namespace", pr.Namespace,
+			"sha", sha,
+			"params", pr.Spec.Params,
+			"labels", pr.Labels,
+			"pipelinerun", string(spec))
+		return pr, nil
+	}
+
+	if key != "" {
+		e.cancelInFlightRuns(ctx, key)
+	}
+
+	bound, created, err := e.createPinnedResources(pinned, &pr.Spec)
+	if err != nil {
+		e.recorder.Eventf(e.listener, corev1.EventTypeWarning, "PipelineRunCreationFailed", "Failed to create PipelineRun %q: %v", pr.GenerateName, err)
+		return nil, err
+	}
+
+	logger.Infof("Creating pipelinerun %q sha %q namespace %q", pr.GenerateName, sha, pr.Namespace)
 
-	run, err := e.pipelineClientset.Tekton().PipelineRuns(e.namespace).Create(pr)
+	run, err := e.pipelineClientset.Tekton().PipelineRuns(e.runNamespace).Create(pr)
 	if err != nil {
-		log.Fatalf("failed to get pipeline listener spec: %q", err)
+		e.deleteResources(created)
+		e.recorder.Eventf(e.listener, corev1.EventTypeWarning, "PipelineRunCreationFailed", "Failed to create PipelineRun %q: %v", pr.GenerateName, err)
+		return nil, errors.Wrapf(err, "Error creating pipelinerun %q", pr.GenerateName)
 	}
+	e.ownResources(logger, run, bound)
+	e.recorder.Eventf(e.listener, corev1.EventTypeNormal, "CreatedPipelineRun", "Created PipelineRun %q", run.Name)
+	logger.Infof("Created pipeline run %q!", run.Name)
 
 	return run, nil
 }
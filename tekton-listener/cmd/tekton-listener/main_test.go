@@ -0,0 +1,798 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cloudevents/sdk-go/pkg/cloudevents"
+	"github.com/cloudevents/sdk-go/pkg/cloudevents/types"
+	experimentalv1alpha1 "github.com/tektoncd/experimental/tekton-listener/pkg/apis/pipelineexperimental/v1alpha1"
+	pipelinev1alpha1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1alpha1"
+	fakepipeline "github.com/tektoncd/pipeline/pkg/client/clientset/versioned/fake"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ktesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
+)
+
+const checkSuiteEventType = "com.github.checksuite"
+
+// newTestListener returns a listener backed by a fake pipeline clientset whose
+// logs are captured by the returned observer.
+func newTestListener() (*EventListener, *observer.ObservedLogs) {
+	core, logs := observer.New(zap.DebugLevel)
+	deliveries, err := newDeliveryCache(16, time.Hour)
+	if err != nil {
+		panic(err)
+	}
+	return &EventListener{
+		event:             cloudEventType,
+		eventType:         checkSuiteEventType,
+		namespace:         "default",
+		runNamespace:      "default",
+		runName:           "test-run",
+		pipelineClientset: generateNames(fakepipeline.NewSimpleClientset()),
+		mux:               &sync.Mutex{},
+		runSpec: pipelinev1alpha1.PipelineRunSpec{
+			PipelineRef: pipelinev1alpha1.PipelineRef{Name: "test-pipeline"},
+		},
+		logger:          zap.New(core).Sugar(),
+		deliveries:      deliveries,
+		maxPayloadBytes: 1 << 20,
+		listenerPath:    listenerPath,
+		listener: &experimentalv1alpha1.TektonListener{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-listener", Namespace: "default"},
+		},
+		recorder:          record.NewFakeRecorder(10),
+		mode:              pipelineRunMode,
+		strictSpecVersion: true,
+		forkPolicy:        forkSkipPolicy,
+	}, logs
+}
+
+// generateNames makes the fake clientset name created objects from their
+// GenerateName, as the API server does.
+func generateNames(client *fakepipeline.Clientset) *fakepipeline.Clientset {
+	count := 0
+	client.PrependReactor("create", "*", func(action ktesting.Action) (bool, runtime.Object, error) {
+		obj, err := meta.Accessor(action.(ktesting.CreateAction).GetObject())
+		if err == nil && obj.GetName() == "" && obj.GetGenerateName() != "" {
+			count++
+			obj.SetName(fmt.Sprintf("%s%d", obj.GetGenerateName(), count))
+		}
+		return false, nil, nil
+	})
+	return client
+}
+
+// createdRun returns the only pipelinerun created by the listener.
+func createdRun(t *testing.T, e *EventListener) pipelinev1alpha1.PipelineRun {
+	t.Helper()
+	runs, err := e.pipelineClientset.Tekton().PipelineRuns(e.namespace).List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("Error listing pipelineruns: %s", err)
+	}
+	if len(runs.Items) != 1 {
+		t.Fatalf("Expected 1 pipelinerun, got %d", len(runs.Items))
+	}
+	return runs.Items[0]
+}
+
+// checkSuitePayload returns a marshalled check_suite payload.
+func checkSuitePayload(t *testing.T, conclusion, sha string) []byte {
+	payload, err := json.Marshal(map[string]interface{}{
+		"check_suite": map[string]interface{}{
+			"conclusion": conclusion,
+			"head_sha":   sha,
+		},
+	})
+	if err != nil {
+		t.Fatalf("Error marshalling payload: %s", err)
+	}
+	return payload
+}
+
+// newCheckSuiteRequest returns a binary mode cloudevent HTTP request carrying body.
+func newCheckSuiteRequest(id string, body []byte) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, listenerPath, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("ce-specversion", "0.2")
+	req.Header.Set("ce-id", id)
+	req.Header.Set("ce-type", checkSuiteEventType)
+	req.Header.Set("ce-source", "https://github.com/foo/bar")
+	return req
+}
+
+// newCheckSuiteEvent returns a check_suite cloudevent with the given ID.
+func newCheckSuiteEvent(t *testing.T, id, conclusion, sha string) cloudevents.Event {
+	return newEvent(t, id, checkSuiteEventType, checkSuitePayload(t, conclusion, sha))
+}
+
+// newEvent returns a JSON cloudevent of the given type carrying payload.
+func newEvent(t *testing.T, id, eventType string, payload []byte) cloudevents.Event {
+	source, err := url.Parse("https://github.com/foo/bar")
+	if err != nil {
+		t.Fatalf("Error parsing source: %s", err)
+	}
+	contentType := "application/json"
+	return cloudevents.Event{
+		Context: cloudevents.EventContextV02{
+			ID:          id,
+			Type:        eventType,
+			Source:      types.URLRef{URL: *source},
+			ContentType: &contentType,
+		}.AsV02(),
+		Data: payload,
+	}
+}
+
+func TestHandleRequestLogsEventID(t *testing.T) {
+	e, logs := newTestListener()
+	event := newCheckSuiteEvent(t, "delivery-1234", "success", "abc123")
+
+	if err := e.HandleRequest(context.Background(), event); err != nil {
+		t.Fatalf("Unexpected error handling request: %s", err)
+	}
+
+	eventLogs := logs.FilterField(zap.String("eventID", "delivery-1234"))
+	if eventLogs.Len() < 2 {
+		t.Errorf("Expected at least two log lines with the event ID, got %d of %d: %+v", eventLogs.Len(), logs.Len(), logs.All())
+	}
+}
+
+func TestHandleRequestDuplicateDelivery(t *testing.T) {
+	e, logs := newTestListener()
+	event := newCheckSuiteEvent(t, "delivery-1234", "success", "abc123")
+
+	for i := 0; i < 2; i++ {
+		if err := e.HandleRequest(context.Background(), event); err != nil {
+			t.Fatalf("Unexpected error handling request %d: %s", i, err)
+		}
+	}
+
+	runs, err := e.pipelineClientset.Tekton().PipelineRuns(e.namespace).List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("Error listing pipelineruns: %s", err)
+	}
+	if len(runs.Items) != 1 {
+		t.Errorf("Expected one pipelinerun, got %d", len(runs.Items))
+	}
+	if logs.FilterMessage("duplicate delivery, skipping").Len() != 1 {
+		t.Errorf("Expected the duplicate delivery to be logged once")
+	}
+}
+
+func TestHandleRequestDedupeBySha(t *testing.T) {
+	push, err := json.Marshal(map[string]interface{}{"after": "abc123", "ref": "refs/heads/master"})
+	if err != nil {
+		t.Fatalf("Error marshalling payload: %s", err)
+	}
+	eventTypes := []string{checkSuiteEventType, githubPushEventType}
+
+	tests := []struct {
+		name     string
+		dedupe   bool
+		pushSha  string
+		wantRuns int
+	}{
+		{name: "not deduplicated", wantRuns: 2},
+		{name: "same sha", dedupe: true, wantRuns: 1},
+		{name: "other sha", dedupe: true, pushSha: "def456", wantRuns: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, logs := newTestListener()
+			if tt.dedupe {
+				builtShas, err := newDeliveryCache(16, time.Hour)
+				if err != nil {
+					t.Fatalf("Error creating cache: %s", err)
+				}
+				e.builtShas = builtShas
+			}
+			pushPayload := push
+			if tt.pushSha != "" {
+				pushPayload = bytes.Replace(push, []byte("abc123"), []byte(tt.pushSha), 1)
+			}
+			// two events, with their own delivery IDs, for the commit
+			events := []cloudevents.Event{
+				newEvent(t, "delivery-1", githubPushEventType, pushPayload),
+				newCheckSuiteEvent(t, "delivery-2", "success", "abc123"),
+			}
+			for _, event := range events {
+				if err := e.handleRequest(context.Background(), event, eventTypes); err != nil {
+					t.Fatalf("Unexpected error handling %s: %s", event.Type(), err)
+				}
+			}
+
+			runs, err := e.pipelineClientset.Tekton().PipelineRuns(e.namespace).List(metav1.ListOptions{})
+			if err != nil {
+				t.Fatalf("Error listing pipelineruns: %s", err)
+			}
+			if len(runs.Items) != tt.wantRuns {
+				t.Errorf("Expected %d pipelineruns, got %d", tt.wantRuns, len(runs.Items))
+			}
+			if skipped := logs.FilterMessage("sha already built, skipping").Len(); skipped != 2-tt.wantRuns {
+				t.Errorf("Expected %d runs to be skipped, %d were", 2-tt.wantRuns, skipped)
+			}
+		})
+	}
+}
+
+func TestDeliveryCacheExpiry(t *testing.T) {
+	d, err := newDeliveryCache(2, time.Minute)
+	if err != nil {
+		t.Fatalf("Error creating cache: %s", err)
+	}
+	now := time.Now()
+	d.now = func() time.Time { return now }
+
+	if d.seen("a") {
+		t.Errorf("Expected first delivery of a to be new")
+	}
+	if !d.seen("a") {
+		t.Errorf("Expected second delivery of a to be a duplicate")
+	}
+
+	now = now.Add(2 * time.Minute)
+	if d.seen("a") {
+		t.Errorf("Expected delivery of a after the TTL to be new")
+	}
+}
+
+func TestNewServerTimeouts(t *testing.T) {
+	e, _ := newTestListener()
+	e.port = 8082
+	e.readTimeout = 5 * time.Second
+	e.writeTimeout = 15 * time.Second
+	e.idleTimeout = time.Minute
+
+	srv := e.newServer()
+	if srv.Addr != ":8082" {
+		t.Errorf("Expected server address :8082, got %q", srv.Addr)
+	}
+	if srv.ReadTimeout != e.readTimeout {
+		t.Errorf("Expected read timeout %s, got %s", e.readTimeout, srv.ReadTimeout)
+	}
+	if srv.WriteTimeout != e.writeTimeout {
+		t.Errorf("Expected write timeout %s, got %s", e.writeTimeout, srv.WriteTimeout)
+	}
+	if srv.IdleTimeout != e.idleTimeout {
+		t.Errorf("Expected idle timeout %s, got %s", e.idleTimeout, srv.IdleTimeout)
+	}
+}
+
+func TestServeCloudEvent(t *testing.T) {
+	e, _ := newTestListener()
+	srv := e.newServer()
+
+	rec := httptest.NewRecorder()
+	srv.Handler.ServeHTTP(rec, newCheckSuiteRequest("delivery-1234", checkSuitePayload(t, "success", "abc123")))
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusAccepted, rec.Code, rec.Body.String())
+	}
+
+	runs, err := e.pipelineClientset.Tekton().PipelineRuns(e.namespace).List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("Error listing pipelineruns: %s", err)
+	}
+	if len(runs.Items) != 1 {
+		t.Errorf("Expected one pipelinerun, got %d", len(runs.Items))
+	}
+}
+
+func TestServeCloudEventListenerPath(t *testing.T) {
+	e, _ := newTestListener()
+	e.listenerPath = "/github/events"
+	srv := e.newServer()
+
+	rec := httptest.NewRecorder()
+	srv.Handler.ServeHTTP(rec, newCheckSuiteRequest("delivery-1234", checkSuitePayload(t, "success", "abc123")))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d at the default path, got %d", http.StatusNotFound, rec.Code)
+	}
+
+	req := newCheckSuiteRequest("delivery-1234", checkSuitePayload(t, "success", "abc123"))
+	req.URL.Path = e.listenerPath
+	rec = httptest.NewRecorder()
+	srv.Handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("Expected status %d at the listener path, got %d: %s", http.StatusAccepted, rec.Code, rec.Body.String())
+	}
+	runs, err := e.pipelineClientset.Tekton().PipelineRuns(e.namespace).List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("Error listing pipelineruns: %s", err)
+	}
+	if len(runs.Items) != 1 {
+		t.Errorf("Expected one pipelinerun, got %d", len(runs.Items))
+	}
+}
+
+func TestServeCloudEventPayloadTooLarge(t *testing.T) {
+	e, _ := newTestListener()
+	e.maxPayloadBytes = 64
+	srv := e.newServer()
+
+	body := checkSuitePayload(t, "success", strings.Repeat("a", 128))
+	for _, chunked := range []bool{false, true} {
+		req := newCheckSuiteRequest("delivery-1234", body)
+		if chunked {
+			// hide the length so the limit is enforced while reading
+			req.ContentLength = -1
+		}
+		rec := httptest.NewRecorder()
+		srv.Handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("Expected status %d with chunked=%t, got %d", http.StatusRequestEntityTooLarge, chunked, rec.Code)
+		}
+	}
+
+	runs, err := e.pipelineClientset.Tekton().PipelineRuns(e.namespace).List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("Error listing pipelineruns: %s", err)
+	}
+	if len(runs.Items) != 0 {
+		t.Errorf("Expected no pipelineruns, got %d", len(runs.Items))
+	}
+}
+
+// recordedEvents drains the events recorded by the listener's fake recorder.
+func recordedEvents(e *EventListener) []string {
+	var events []string
+	recorder := e.recorder.(*record.FakeRecorder)
+	for {
+		select {
+		case event := <-recorder.Events:
+			events = append(events, event)
+		default:
+			return events
+		}
+	}
+}
+
+func TestCreatePipelineRunEvents(t *testing.T) {
+	e, _ := newTestListener()
+	if _, err := e.createPipelineRun(context.Background(), "abc123", nil); err != nil {
+		t.Fatalf("Error creating pipelinerun: %s", err)
+	}
+	events := recordedEvents(e)
+	want := `Normal CreatedPipelineRun Created PipelineRun "test-run-1"`
+	if len(events) != 1 || events[0] != want {
+		t.Errorf("Expected event %q, got %v", want, events)
+	}
+}
+
+func TestCreatePipelineRunFailureEvents(t *testing.T) {
+	e, _ := newTestListener()
+	client := fakepipeline.NewSimpleClientset()
+	client.PrependReactor("create", "pipelineruns", func(action ktesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("quota exceeded")
+	})
+	e.pipelineClientset = client
+
+	if _, err := e.createPipelineRun(context.Background(), "abc123", nil); err == nil {
+		t.Fatal("Expected an error creating the pipelinerun")
+	}
+	events := recordedEvents(e)
+	want := `Warning PipelineRunCreationFailed Failed to create PipelineRun "test-run-": quota exceeded`
+	if len(events) != 1 || events[0] != want {
+		t.Errorf("Expected event %q, got %v", want, events)
+	}
+}
+
+func TestCreatePipelineRunDryRun(t *testing.T) {
+	e, logs := newTestListener()
+	e.dryRun = true
+	e.setBuildSha = true
+	e.runSpec.Params = []pipelinev1alpha1.Param{{Name: "revision"}}
+	client := fakepipeline.NewSimpleClientset()
+	e.pipelineClientset = client
+
+	event := newCheckSuiteEvent(t, "delivery-1234", "success", "abc123")
+	if err := e.HandleRequest(context.Background(), event); err != nil {
+		t.Fatalf("Unexpected error handling request: %s", err)
+	}
+
+	for _, action := range client.Actions() {
+		if action.GetVerb() == "create" {
+			t.Errorf("Expected no create calls in dry run mode, got %v", action)
+		}
+	}
+
+	dryRunLogs := logs.FilterMessage("Dry run, not creating pipelinerun").All()
+	if len(dryRunLogs) != 1 {
+		t.Fatalf("Expected the intended pipelinerun to be logged once, got %d", len(dryRunLogs))
+	}
+	fields := dryRunLogs[0].ContextMap()
+	if fields["generateName"] != "test-run-" {
+		t.Errorf("Expected logged generateName %q, got %v", "test-run-", fields["generateName"])
+	}
+	if fields["sha"] != "abc123" {
+		t.Errorf("Expected logged sha %q, got %v", "abc123", fields["sha"])
+	}
+	if spec, ok := fields["pipelinerun"].(string); !ok || !strings.Contains(spec, `"value":"abc123"`) {
+		t.Errorf("Expected the logged pipelinerun to carry the resolved revision, got %v", fields["pipelinerun"])
+	}
+	if len(recordedEvents(e)) != 0 {
+		t.Error("Expected no events to be recorded in dry run mode")
+	}
+}
+
+func TestCreatePipelineRunLabels(t *testing.T) {
+	e, _ := newTestListener()
+	e.runLabels = map[string]string{"team": "web", revisionLabel: "template"}
+	e.runAnnotations = map[string]string{"owner": "web-team"}
+
+	run, err := e.createPipelineRun(context.Background(), "abc123", nil)
+	if err != nil {
+		t.Fatalf("Error creating pipelinerun: %s", err)
+	}
+	wantLabels := map[string]string{
+		"team":        "web",
+		listenerLabel: "test-listener",
+		revisionLabel: "abc123",
+		instanceLabel: "test-run",
+	}
+	if !reflect.DeepEqual(run.Labels, wantLabels) {
+		t.Errorf("Expected labels %v, got %v", wantLabels, run.Labels)
+	}
+	if !reflect.DeepEqual(run.Annotations, e.runAnnotations) {
+		t.Errorf("Expected annotations %v, got %v", e.runAnnotations, run.Annotations)
+	}
+	if e.runLabels[revisionLabel] != "template" || len(e.runLabels) != 2 {
+		t.Errorf("Expected the template labels to be unchanged, got %v", e.runLabels)
+	}
+}
+
+func TestCreatePipelineRunInstanceName(t *testing.T) {
+	e, _ := newTestListener()
+	e.runName = listenerInstanceName("test-listener", 8082)
+
+	names := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		run, err := e.createPipelineRun(context.Background(), "abc123", nil)
+		if err != nil {
+			t.Fatalf("Error creating pipelinerun: %s", err)
+		}
+		if run.GenerateName != "test-listener-8082-" || !strings.HasPrefix(run.Name, run.GenerateName) {
+			t.Errorf("Expected the run name to be generated from the port qualified listener name, got %q from %q", run.Name, run.GenerateName)
+		}
+		if run.Labels[instanceLabel] != "test-listener-8082" {
+			t.Errorf("Expected the %s label to be the port qualified listener name, got %v", instanceLabel, run.Labels)
+		}
+		names[run.Name] = true
+	}
+	if len(names) != 2 {
+		t.Errorf("Expected successive runs to have distinct names, got %v", names)
+	}
+}
+
+func TestCreatePipelineRunRepositoryName(t *testing.T) {
+	tests := []struct {
+		name               string
+		repositoryRunNames bool
+		payload            []byte
+		wantGenerateName   string
+	}{
+		{name: "listener name", payload: checkSuitePayload(t, "success", "abc123"), wantGenerateName: "test-run-"},
+		{
+			name:               "repository name",
+			repositoryRunNames: true,
+			payload:            []byte(`{"action": "completed", "check_suite": {"conclusion": "success", "head_sha": "abc123"}, "repository": {"full_name": "foo/bar"}}`),
+			wantGenerateName:   "bar-",
+		},
+		{name: "no repository", repositoryRunNames: true, payload: checkSuitePayload(t, "success", "abc123"), wantGenerateName: "test-run-"},
+		{
+			name:               "sanitized repository name",
+			repositoryRunNames: true,
+			payload:            []byte(`{"action": "completed", "check_suite": {"conclusion": "success", "head_sha": "abc123"}, "repository": {"full_name": "foo/_My.Repo_"}}`),
+			wantGenerateName:   "my-repo-",
+		},
+		{
+			name:               "nothing valid in the repository name",
+			repositoryRunNames: true,
+			payload:            []byte(`{"action": "completed", "check_suite": {"conclusion": "success", "head_sha": "abc123"}, "repository": {"full_name": "foo/___"}}`),
+			wantGenerateName:   "test-run-",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, _ := newTestListener()
+			e.repositoryRunNames = tt.repositoryRunNames
+			if err := e.HandleRequest(context.Background(), newEvent(t, "delivery-1234", checkSuiteEventType, tt.payload)); err != nil {
+				t.Fatalf("Unexpected error handling request: %s", err)
+			}
+			runs, err := e.pipelineClientset.Tekton().PipelineRuns(e.namespace).List(metav1.ListOptions{})
+			if err != nil {
+				t.Fatalf("Error listing pipelineruns: %s", err)
+			}
+			if len(runs.Items) != 1 {
+				t.Fatalf("Expected one pipelinerun, got %d", len(runs.Items))
+			}
+			if got := runs.Items[0].GenerateName; got != tt.wantGenerateName {
+				t.Errorf("Expected generateName %q, got %q", tt.wantGenerateName, got)
+			}
+		})
+	}
+}
+
+func TestRepositoryRunName(t *testing.T) {
+	for repo, want := range map[string]string{
+		"foo/bar":                        "bar",
+		"https://github.com/foo/bar/":    "bar",
+		"https://gitlab.com/foo/Bar.git": "bar",
+		"foo/" + strings.Repeat("a", 70): strings.Repeat("a", maxRunNamePrefix-1),
+		"":                               "",
+	} {
+		if got := repositoryRunName(repo); got != want {
+			t.Errorf("Expected the run name for %q to be %q, got %q", repo, want, got)
+		}
+	}
+}
+
+func TestListenerRunSpec(t *testing.T) {
+	listener := &experimentalv1alpha1.TektonListener{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-listener", Namespace: "default"},
+	}
+	if _, err := listenerRunSpec(listener); err == nil {
+		t.Error("Expected an error for a listener without a runspec")
+	}
+
+	listener.Spec.PipelineRunSpec = &pipelinev1alpha1.PipelineRunSpec{
+		PipelineRef: pipelinev1alpha1.PipelineRef{Name: "test-pipeline"},
+	}
+	runSpec, err := listenerRunSpec(listener)
+	if err != nil {
+		t.Fatalf("Error getting the runspec: %s", err)
+	}
+	if runSpec.PipelineRef.Name != "test-pipeline" {
+		t.Errorf("Expected the listener's runspec, got %+v", runSpec)
+	}
+}
+
+func TestHandleRequestEventAnnotations(t *testing.T) {
+	e, _ := newTestListener()
+	e.runAnnotations = map[string]string{"owner": "web-team"}
+	// the subject is only part of cloudevents from version 0.3
+	e.strictSpecVersion = false
+	source, err := url.Parse("https://github.com/foo/bar")
+	if err != nil {
+		t.Fatalf("Error parsing source: %s", err)
+	}
+	subject, contentType := "refs/heads/master", "application/json"
+	event := cloudevents.Event{
+		Context: cloudevents.EventContextV03{
+			ID:              "delivery-1234",
+			Type:            checkSuiteEventType,
+			Source:          types.URLRef{URL: *source},
+			Subject:         &subject,
+			DataContentType: &contentType,
+		}.AsV03(),
+		Data: checkSuitePayload(t, "success", "abc123"),
+	}
+	if err := e.HandleRequest(context.Background(), event); err != nil {
+		t.Fatalf("Error handling request: %s", err)
+	}
+
+	run := createdRun(t, e)
+	want := map[string]string{
+		"owner":                "web-team",
+		eventSourceAnnotation:  "https://github.com/foo/bar",
+		eventSubjectAnnotation: "refs/heads/master",
+		eventIDAnnotation:      "delivery-1234",
+	}
+	if !reflect.DeepEqual(run.Annotations, want) {
+		t.Errorf("Expected annotations %v, got %v", want, run.Annotations)
+	}
+	if len(e.runAnnotations) != 1 {
+		t.Errorf("Expected the template annotations to be unchanged, got %v", e.runAnnotations)
+	}
+}
+
+func TestHandleCheckSuiteActions(t *testing.T) {
+	tests := []struct {
+		name       string
+		actions    []string
+		action     string
+		conclusion string
+		wantRuns   int
+	}{
+		{name: "requested", actions: []string{"requested", "rerequested"}, action: "requested", wantRuns: 1},
+		{name: "rerequested", actions: []string{"requested", "rerequested"}, action: "rerequested", wantRuns: 1},
+		{name: "completed success", actions: []string{"requested", "rerequested"}, action: "completed", conclusion: "success", wantRuns: 1},
+		{name: "completed failure", actions: []string{"requested", "rerequested"}, action: "completed", conclusion: "failure"},
+		{name: "requested not configured", actions: []string{"rerequested"}, action: "requested"},
+		{name: "requested by default", action: "requested"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, _ := newTestListener()
+			e.checkSuiteActions = tt.actions
+			payload, err := json.Marshal(map[string]interface{}{
+				"action": tt.action,
+				"check_suite": map[string]interface{}{
+					"conclusion": tt.conclusion,
+					"head_sha":   "abc123",
+				},
+			})
+			if err != nil {
+				t.Fatalf("Error marshalling payload: %s", err)
+			}
+			if err := e.HandleRequest(context.Background(), newEvent(t, "delivery-1234", checkSuiteEventType, payload)); err != nil {
+				t.Fatalf("Error handling request: %s", err)
+			}
+
+			runs, err := e.pipelineClientset.Tekton().PipelineRuns(e.namespace).List(metav1.ListOptions{})
+			if err != nil {
+				t.Fatalf("Error listing pipelineruns: %s", err)
+			}
+			if len(runs.Items) != tt.wantRuns {
+				t.Fatalf("Expected %d pipelineruns, got %d", tt.wantRuns, len(runs.Items))
+			}
+			if tt.wantRuns == 1 {
+				if revision := runs.Items[0].Labels[revisionLabel]; revision != "abc123" {
+					t.Errorf("Expected the run for the head commit abc123, got %q", revision)
+				}
+			}
+		})
+	}
+}
+
+func TestCreatePipelineRunTimeout(t *testing.T) {
+	templateTimeout := &metav1.Duration{Duration: 2 * time.Hour}
+	tests := []struct {
+		name     string
+		template *metav1.Duration
+		timeout  time.Duration
+		force    bool
+		want     *metav1.Duration
+	}{
+		{name: "unset", want: nil},
+		{name: "template only", template: templateTimeout, want: templateTimeout},
+		{name: "default", timeout: 30 * time.Minute, want: &metav1.Duration{Duration: 30 * time.Minute}},
+		{name: "template preserved", template: templateTimeout, timeout: 30 * time.Minute, want: templateTimeout},
+		{name: "forced", template: templateTimeout, timeout: 30 * time.Minute, force: true, want: &metav1.Duration{Duration: 30 * time.Minute}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, _ := newTestListener()
+			e.runSpec.Timeout = tt.template
+			e.runTimeout = tt.timeout
+			e.forceTimeout = tt.force
+
+			run, err := e.createPipelineRun(context.Background(), "abc123", nil)
+			if err != nil {
+				t.Fatalf("Error creating pipelinerun: %s", err)
+			}
+			if !reflect.DeepEqual(run.Spec.Timeout, tt.want) {
+				t.Errorf("Expected timeout %v, got %v", tt.want, run.Spec.Timeout)
+			}
+			if !reflect.DeepEqual(e.runSpec.Timeout, tt.template) {
+				t.Errorf("Expected the template timeout to be unchanged, got %v", e.runSpec.Timeout)
+			}
+		})
+	}
+}
+
+func TestHandleRequestAllowedSources(t *testing.T) {
+	tests := []struct {
+		name           string
+		allowedSources string
+		wantErr        bool
+	}{
+		{name: "any source", allowedSources: ""},
+		{name: "allowed source", allowedSources: "https://github.com/other/repo, https://github.com/foo/bar"},
+		{name: "disallowed source", allowedSources: "https://github.com/other/repo", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, _ := newTestListener()
+			e.allowedSources = splitList(tt.allowedSources)
+
+			err := e.HandleRequest(context.Background(), newCheckSuiteEvent(t, "delivery-1234", "success", "abc123"))
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("Expected error: %t, got %v", tt.wantErr, err)
+			}
+
+			runs, err := e.pipelineClientset.Tekton().PipelineRuns(e.namespace).List(metav1.ListOptions{})
+			if err != nil {
+				t.Fatalf("Error listing pipelineruns: %s", err)
+			}
+			wantRuns := 1
+			if tt.wantErr {
+				wantRuns = 0
+			}
+			if len(runs.Items) != wantRuns {
+				t.Errorf("Expected %d pipelineruns, got %d", wantRuns, len(runs.Items))
+			}
+		})
+	}
+}
+
+func TestHandleRequestSpecVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		strict  bool
+		wantErr bool
+	}{
+		{name: "strict", strict: true, wantErr: true},
+		{name: "lenient", strict: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, _ := newTestListener()
+			e.strictSpecVersion = tt.strict
+			event := newCheckSuiteEvent(t, "delivery-1234", "success", "abc123")
+			ec := event.Context.AsV02()
+			ec.SpecVersion = "0.1-internal"
+			event.Context = ec
+
+			err := e.HandleRequest(context.Background(), event)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("Expected error: %t, got %v", tt.wantErr, err)
+			}
+
+			runs, err := e.pipelineClientset.Tekton().PipelineRuns(e.namespace).List(metav1.ListOptions{})
+			if err != nil {
+				t.Fatalf("Error listing pipelineruns: %s", err)
+			}
+			wantRuns := 1
+			if tt.wantErr {
+				wantRuns = 0
+			}
+			if len(runs.Items) != wantRuns {
+				t.Errorf("Expected %d pipelineruns, got %d", wantRuns, len(runs.Items))
+			}
+		})
+	}
+}
+
+func TestServeCloudEventGzip(t *testing.T) {
+	e, _ := newTestListener()
+	e.setBuildSha = true
+	e.runSpec.Params = []pipelinev1alpha1.Param{{Name: "revision", Value: "master"}}
+	srv := e.newServer()
+
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	if _, err := gz.Write(checkSuitePayload(t, "success", "abc123")); err != nil {
+		t.Fatalf("Error compressing payload: %s", err)
+	}
+	gz.Close()
+	req := newCheckSuiteRequest("delivery-1234", compressed.Bytes())
+	req.Header.Set("Content-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	srv.Handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusAccepted, rec.Code, rec.Body.String())
+	}
+	run := createdRun(t, e)
+	if run.Spec.Params[0].Value != "abc123" {
+		t.Errorf("Expected the revision from the decompressed payload, got %v", run.Spec.Params)
+	}
+
+	req = newCheckSuiteRequest("delivery-5678", []byte("not gzip"))
+	req.Header.Set("Content-Encoding", "gzip")
+	rec = httptest.NewRecorder()
+	srv.Handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for a malformed gzip body, got %d", http.StatusBadRequest, rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), "gzip") {
+		t.Errorf("Expected the error to mention gzip, got %q", rec.Body.String())
+	}
+}
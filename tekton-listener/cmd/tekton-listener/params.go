@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	pipelinev1alpha1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1alpha1"
+	"go.uber.org/zap"
+	"k8s.io/client-go/util/jsonpath"
+)
+
+// paramMapping populates the PipelineRun param name from a JSONPath
+// expression evaluated against the event payload.
+type paramMapping struct {
+	name string
+	path string
+	jp   *jsonpath.JSONPath
+}
+
+// parseParamMappings parses either a JSON object of param name to JSONPath,
+// or a comma separated list of name=jsonpath pairs. Expressions may be given
+// with or without the surrounding braces, e.g. ".pull_request.number".
+func parseParamMappings(s string) ([]paramMapping, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+
+	paths := map[string]string{}
+	if strings.HasPrefix(s, "{") {
+		if err := json.Unmarshal([]byte(s), &paths); err != nil {
+			return nil, errors.Wrap(err, "Error parsing param mappings as JSON")
+		}
+	} else {
+		for _, pair := range strings.Split(s, ",") {
+			parts := strings.SplitN(pair, "=", 2)
+			if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || strings.TrimSpace(parts[1]) == "" {
+				return nil, errors.Errorf("Invalid param mapping %q, expected name=jsonpath", pair)
+			}
+			paths[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+		}
+	}
+
+	names := make([]string, 0, len(paths))
+	for name := range paths {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	mappings := make([]paramMapping, 0, len(names))
+	for _, name := range names {
+		m, err := newParamMapping(name, paths[name])
+		if err != nil {
+			return nil, errors.Wrapf(err, "Error parsing JSONPath %q for param %q", paths[name], name)
+		}
+		mappings = append(mappings, m)
+	}
+	return mappings, nil
+}
+
+// newParamMapping parses the JSONPath expression path, which may be given
+// with or without the surrounding braces.
+func newParamMapping(name, path string) (paramMapping, error) {
+	expr := path
+	if !strings.HasPrefix(expr, "{") {
+		expr = "{" + expr + "}"
+	}
+	jp := jsonpath.New(name)
+	if err := jp.Parse(expr); err != nil {
+		return paramMapping{}, err
+	}
+	return paramMapping{name: name, path: path, jp: jp}, nil
+}
+
+// resolve evaluates the mapping against payload, reporting false if the path
+// does not resolve to a value.
+func (m paramMapping) resolve(payload interface{}) (string, bool) {
+	results, err := m.jp.FindResults(payload)
+	if err != nil || len(results) == 0 || len(results[0]) == 0 {
+		return "", false
+	}
+	value := results[0][0].Interface()
+	if str, ok := value.(string); ok {
+		return str, true
+	}
+	// numbers, booleans and objects are rendered as JSON
+	buf, err := json.Marshal(value)
+	if err != nil {
+		return "", false
+	}
+	return string(buf), true
+}
+
+// applyParamMappings sets params from the payload, overwriting any param of
+// the same name. Mappings that do not resolve are logged and skipped.
+func applyParamMappings(logger *zap.SugaredLogger, mappings []paramMapping, payload interface{}, params []pipelinev1alpha1.Param) []pipelinev1alpha1.Param {
+	for _, m := range mappings {
+		value, ok := m.resolve(payload)
+		if !ok {
+			logger.Warnf("Param %q not set, JSONPath %q did not resolve against the event payload", m.name, m.path)
+			continue
+		}
+		params = setParam(params, m.name, value)
+	}
+	return params
+}
+
+// applyPayloadParam sets the param name to the payload serialized as JSON,
+// overwriting any param of the same name. It does nothing when name is empty.
+func applyPayloadParam(logger *zap.SugaredLogger, name string, payload interface{}, params []pipelinev1alpha1.Param) []pipelinev1alpha1.Param {
+	if name == "" {
+		return params
+	}
+	buf, err := json.Marshal(payload)
+	if err != nil {
+		logger.Warnf("Param %q not set, error serializing the event payload: %v", name, err)
+		return params
+	}
+	return setParam(params, name, string(buf))
+}
+
+// setParam sets the value of the named param, appending it if not present.
+func setParam(params []pipelinev1alpha1.Param, name, value string) []pipelinev1alpha1.Param {
+	for i := range params {
+		if params[i].Name == name {
+			params[i].Value = value
+			return params
+		}
+	}
+	return append(params, pipelinev1alpha1.Param{Name: name, Value: value})
+}
+
+// headerParamsKey is the context key of the params taken from the request headers.
+type headerParamsKey struct{}
+
+// parseHeaderParams parses either a JSON object of header name to param name,
+// or a comma separated list of header=param pairs.
+func parseHeaderParams(s string) (map[string]string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+
+	params := map[string]string{}
+	if strings.HasPrefix(s, "{") {
+		if err := json.Unmarshal([]byte(s), &params); err != nil {
+			return nil, errors.Wrap(err, "Error parsing header params as JSON")
+		}
+	} else {
+		for _, pair := range strings.Split(s, ",") {
+			parts := strings.SplitN(pair, "=", 2)
+			if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || strings.TrimSpace(parts[1]) == "" {
+				return nil, errors.Errorf("Invalid header param %q, expected header=param", pair)
+			}
+			params[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+		}
+	}
+	return params, nil
+}
+
+// withHeaderParams returns a context carrying the params set from the
+// configured request headers. Headers missing from the request are skipped.
+func (e *EventListener) withHeaderParams(ctx context.Context, header http.Header) context.Context {
+	if len(e.headerParams) == 0 {
+		return ctx
+	}
+	values := map[string]string{}
+	for name, param := range e.headerParams {
+		if value := header.Get(name); value != "" {
+			values[param] = value
+		}
+	}
+	return context.WithValue(ctx, headerParamsKey{}, values)
+}
+
+// applyHeaderParams sets the params carried by ctx, overwriting any param of
+// the same name.
+func applyHeaderParams(ctx context.Context, params []pipelinev1alpha1.Param) []pipelinev1alpha1.Param {
+	values, _ := ctx.Value(headerParamsKey{}).(map[string]string)
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	// sorted so that the params appended are in a stable order
+	sort.Strings(names)
+	for _, name := range names {
+		params = setParam(params, name, values[name])
+	}
+	return params
+}
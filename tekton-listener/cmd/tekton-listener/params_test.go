@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	pipelinev1alpha1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1alpha1"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+const samplePullRequestPayload = `{
+	"action": "opened",
+	"number": 42,
+	"pull_request": {
+		"head": {"ref": "feature", "sha": "abc123"},
+		"draft": false
+	},
+	"repository": {"name": "bar", "full_name": "foo/bar"}
+}`
+
+func samplePayload(t *testing.T) interface{} {
+	var payload interface{}
+	if err := json.Unmarshal([]byte(samplePullRequestPayload), &payload); err != nil {
+		t.Fatalf("Error unmarshalling sample payload: %s", err)
+	}
+	return payload
+}
+
+func TestParseParamMappings(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    map[string]string
+		wantErr bool
+	}{
+		{name: "empty", in: "", want: map[string]string{}},
+		{name: "pairs", in: "pr-number=.number, repo={.repository.name}", want: map[string]string{"pr-number": ".number", "repo": "{.repository.name}"}},
+		{name: "json", in: `{"pr-number": ".number", "repo": ".repository.name"}`, want: map[string]string{"pr-number": ".number", "repo": ".repository.name"}},
+		{name: "missing path", in: "pr-number=", wantErr: true},
+		{name: "missing name", in: "=.number", wantErr: true},
+		{name: "invalid json", in: `{"pr-number": `, wantErr: true},
+		{name: "invalid jsonpath", in: "pr-number=.number[", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mappings, err := parseParamMappings(tt.in)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected an error parsing %q", tt.in)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error parsing %q: %s", tt.in, err)
+			}
+			got := map[string]string{}
+			for _, m := range mappings {
+				got[m.name] = m.path
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected mappings %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestApplyParamMappings(t *testing.T) {
+	mappings, err := parseParamMappings("pr-number=.number,repo=.repository.full_name,ref=.pull_request.head.ref,draft=.pull_request.draft,missing=.pull_request.base.ref")
+	if err != nil {
+		t.Fatalf("Error parsing mappings: %s", err)
+	}
+	core, logs := observer.New(zap.DebugLevel)
+
+	params := []pipelinev1alpha1.Param{{Name: "ref", Value: "master"}, {Name: "other", Value: "kept"}}
+	got := applyParamMappings(zap.New(core).Sugar(), mappings, samplePayload(t), params)
+
+	want := map[string]string{
+		"pr-number": "42",
+		"repo":      "foo/bar",
+		"ref":       "feature",
+		"draft":     "false",
+		"other":     "kept",
+	}
+	if len(got) != len(want) {
+		t.Errorf("Expected %d params, got %d: %v", len(want), len(got), got)
+	}
+	for _, p := range got {
+		if want[p.Name] != p.Value {
+			t.Errorf("Expected param %q to be %q, got %q", p.Name, want[p.Name], p.Value)
+		}
+	}
+	warned := false
+	for _, entry := range logs.All() {
+		if entry.Level == zapcore.WarnLevel && strings.Contains(entry.Message, `"missing"`) {
+			warned = true
+		}
+	}
+	if !warned {
+		t.Errorf("Expected a warning for the unresolved param, got %+v", logs.All())
+	}
+}
+
+func TestCreatePipelineRunParamMappings(t *testing.T) {
+	e, _ := newTestListener()
+	e.setBuildSha = true
+	e.runSpec.Params = []pipelinev1alpha1.Param{{Name: "revision", Value: "master"}}
+	mappings, err := parseParamMappings("pr-number=.number")
+	if err != nil {
+		t.Fatalf("Error parsing mappings: %s", err)
+	}
+	e.paramMappings = mappings
+
+	run, err := e.createPipelineRun(context.Background(), "abc123", samplePayload(t))
+	if err != nil {
+		t.Fatalf("Error creating pipelinerun: %s", err)
+	}
+	want := []pipelinev1alpha1.Param{{Name: "revision", Value: "abc123"}, {Name: "pr-number", Value: "42"}}
+	if !reflect.DeepEqual(run.Spec.Params, want) {
+		t.Errorf("Expected params %v, got %v", want, run.Spec.Params)
+	}
+	if e.runSpec.Params[0].Value != "master" || len(e.runSpec.Params) != 1 {
+		t.Errorf("Expected the run spec template to be unchanged, got %v", e.runSpec.Params)
+	}
+}
+
+func TestCreatePipelineRunPayloadParam(t *testing.T) {
+	e, _ := newTestListener()
+	e.runSpec.Params = []pipelinev1alpha1.Param{{Name: "payload", Value: "{}"}}
+	e.payloadParam = "payload"
+
+	run, err := e.createPipelineRun(context.Background(), "abc123", samplePayload(t))
+	if err != nil {
+		t.Fatalf("Error creating pipelinerun: %s", err)
+	}
+	if len(run.Spec.Params) != 1 || run.Spec.Params[0].Name != "payload" {
+		t.Fatalf("Expected only the payload param, got %v", run.Spec.Params)
+	}
+	var got interface{}
+	if err := json.Unmarshal([]byte(run.Spec.Params[0].Value), &got); err != nil {
+		t.Fatalf("Expected the payload param to be valid JSON, got %q: %s", run.Spec.Params[0].Value, err)
+	}
+	if !reflect.DeepEqual(got, samplePayload(t)) {
+		t.Errorf("Expected the payload param to hold the event payload, got %v", got)
+	}
+}
+
+func TestServeCloudEventHeaderParams(t *testing.T) {
+	e, _ := newTestListener()
+	e.runSpec.Params = []pipelinev1alpha1.Param{{Name: "deploy-env", Value: "dev"}}
+	headerParams, err := parseHeaderParams("X-Deploy-Env=deploy-env,X-Missing=missing")
+	if err != nil {
+		t.Fatalf("Error parsing header params: %s", err)
+	}
+	e.headerParams = headerParams
+
+	req := newCheckSuiteRequest("delivery-1234", checkSuitePayload(t, "success", "abc123"))
+	req.Header.Set("X-Deploy-Env", "prod")
+	rec := httptest.NewRecorder()
+	e.newServer().Handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusAccepted, rec.Code, rec.Body.String())
+	}
+	run := createdRun(t, e)
+	want := []pipelinev1alpha1.Param{{Name: "deploy-env", Value: "prod"}}
+	if !reflect.DeepEqual(run.Spec.Params, want) {
+		t.Errorf("Expected params %v, got %v", want, run.Spec.Params)
+	}
+
+	for _, in := range []string{"X-Deploy-Env", "=deploy-env", `{"X-Deploy-Env": 1}`} {
+		if _, err := parseHeaderParams(in); err == nil {
+			t.Errorf("Expected an error parsing %q", in)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/cloudevents/sdk-go/pkg/cloudevents"
+	"github.com/knative/pkg/logging"
+	"github.com/pkg/errors"
+)
+
+// maxPayloadSnippet bounds how much of an undecodable payload is logged.
+const maxPayloadSnippet = 256
+
+// payloadError is returned for an event whose payload can not be decoded.
+// The same payload would fail again, so the sender is answered with a 400
+// rather than a 500 it would retry.
+type payloadError struct {
+	message string
+	reason  string
+}
+
+func (e *payloadError) Error() string {
+	return fmt.Sprintf("%s: %s", e.message, e.reason)
+}
+
+// decodePayload decodes the data of event into v. If it can not be decoded
+// the decode error and the start of the data are logged, and a payloadError
+// with message is returned. The data is left out of the error as it is sent
+// back in the response.
+func decodePayload(ctx context.Context, event cloudevents.Event, v interface{}, message string) error {
+	err := event.DataAs(v)
+	if err == nil {
+		return nil
+	}
+	data := eventData(event)
+	// the codec's error quotes all of the data, decode it again for the
+	// underlying error
+	cause := json.Unmarshal(data, v)
+	if cause == nil {
+		cause = err
+	}
+	return newPayloadError(ctx, data, cause, message)
+}
+
+// decodeRawPayload decodes the body of a raw GitHub webhook into v, failing
+// as decodePayload does.
+func decodeRawPayload(ctx context.Context, body []byte, v interface{}, message string) error {
+	err := json.Unmarshal(body, v)
+	if err == nil {
+		return nil
+	}
+	return newPayloadError(ctx, body, err, message)
+}
+
+// newPayloadError logs cause and the start of data, and returns a
+// payloadError with message.
+func newPayloadError(ctx context.Context, data []byte, cause error, message string) error {
+	reason := "unexpected JSON shape"
+	if !json.Valid(data) {
+		reason = "malformed JSON"
+	}
+	logging.FromContext(ctx).Errorf("%s, %s: %v, data: %q", message, reason, cause, payloadSnippet(data))
+	return &payloadError{message: message, reason: reason}
+}
+
+// eventData returns the raw data of event.
+func eventData(event cloudevents.Event) []byte {
+	switch data := event.Data.(type) {
+	case []byte:
+		return data
+	case string:
+		return []byte(data)
+	}
+	data, _ := json.Marshal(event.Data)
+	return data
+}
+
+// payloadSnippet returns up to maxPayloadSnippet bytes of data.
+func payloadSnippet(data []byte) string {
+	if len(data) <= maxPayloadSnippet {
+		return string(data)
+	}
+	return string(data[:maxPayloadSnippet]) + "..."
+}
+
+// handleErrorStatus returns the response status for an error handling an event.
+func handleErrorStatus(err error) int {
+	if _, ok := errors.Cause(err).(*payloadError); ok {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
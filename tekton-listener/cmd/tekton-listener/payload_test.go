@@ -0,0 +1,85 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// loggedMessage returns the first log message containing substr.
+func loggedMessage(logs *observer.ObservedLogs, substr string) (string, bool) {
+	for _, entry := range logs.All() {
+		if strings.Contains(entry.Message, substr) {
+			return entry.Message, true
+		}
+	}
+	return "", false
+}
+
+func TestServeCloudEventMalformedPayload(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		message  string
+		reason   string
+		logCause string
+	}{
+		{
+			name:     "malformed JSON",
+			body:     `{"check_suite": {"conclusion": "success",`,
+			message:  "Error decoding com.github.checksuite event payload",
+			reason:   "malformed JSON",
+			logCause: "unexpected end of JSON input",
+		},
+		{
+			name:     "unexpected shape",
+			body:     `{"check_suite": "success"}`,
+			message:  "Error handling check suite payload",
+			reason:   "unexpected JSON shape",
+			logCause: "cannot unmarshal string",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, logs := newTestListener()
+			srv := e.newServer()
+
+			rec := httptest.NewRecorder()
+			srv.Handler.ServeHTTP(rec, newCheckSuiteRequest("delivery-1234", []byte(tt.body)))
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("Expected status %d, got %d: %s", http.StatusBadRequest, rec.Code, rec.Body.String())
+			}
+			if want := tt.message + ": " + tt.reason; !strings.Contains(rec.Body.String(), want) {
+				t.Errorf("Expected response %q, got %q", want, rec.Body.String())
+			}
+			// the payload is only logged
+			if strings.Contains(rec.Body.String(), "check_suite") {
+				t.Errorf("Expected the payload to be left out of the response, got %q", rec.Body.String())
+			}
+
+			message, ok := loggedMessage(logs, tt.message)
+			if !ok {
+				t.Fatalf("Expected the decode failure to be logged, got %+v", logs.All())
+			}
+			for _, want := range []string{tt.reason, tt.logCause, `data: "{\"check_suite\": `} {
+				if !strings.Contains(message, want) {
+					t.Errorf("Expected log message %q to contain %q", message, want)
+				}
+			}
+		})
+	}
+}
+
+func TestPayloadSnippet(t *testing.T) {
+	data := []byte(strings.Repeat("a", maxPayloadSnippet+10))
+	snippet := payloadSnippet(data)
+	if len(snippet) != maxPayloadSnippet+len("...") || !strings.HasSuffix(snippet, "...") {
+		t.Errorf("Expected the snippet to be cut at %d bytes, got %d bytes", maxPayloadSnippet, len(snippet))
+	}
+	if snippet := payloadSnippet([]byte("short")); snippet != "short" {
+		t.Errorf("Expected a short payload to be logged whole, got %q", snippet)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/pkg/errors"
+	pipelinev1alpha1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// podTemplate holds the scheduling settings merged into each run's spec so
+// that its pods land on a dedicated node pool. This version of the
+// PipelineRunSpec carries them as fields of its own rather than in a pod
+// template.
+type podTemplate struct {
+	NodeSelector map[string]string   `json:"nodeSelector,omitempty"`
+	Tolerations  []corev1.Toleration `json:"tolerations,omitempty"`
+}
+
+// parsePodTemplate parses the POD_TEMPLATE setting, a JSON object with
+// nodeSelector and tolerations. It returns nil when s is empty.
+func parsePodTemplate(s string) (*podTemplate, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	t := &podTemplate{}
+	if err := json.Unmarshal([]byte(s), t); err != nil {
+		return nil, errors.Wrap(err, "Error parsing pod template")
+	}
+	return t, nil
+}
+
+// apply merges the template into spec. Node selector labels and tolerations
+// the runspec already sets are kept unless the template sets the same label,
+// or a toleration with the same key and effect.
+func (t *podTemplate) apply(spec *pipelinev1alpha1.PipelineRunSpec) {
+	if t == nil {
+		return
+	}
+	if len(t.NodeSelector) > 0 && spec.NodeSelector == nil {
+		spec.NodeSelector = map[string]string{}
+	}
+	for k, v := range t.NodeSelector {
+		spec.NodeSelector[k] = v
+	}
+	for _, toleration := range t.Tolerations {
+		replaced := false
+		for i := range spec.Tolerations {
+			if spec.Tolerations[i].Key == toleration.Key && spec.Tolerations[i].Effect == toleration.Effect {
+				spec.Tolerations[i] = toleration
+				replaced = true
+			}
+		}
+		if !replaced {
+			spec.Tolerations = append(spec.Tolerations, toleration)
+		}
+	}
+}
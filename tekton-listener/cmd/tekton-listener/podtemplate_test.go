@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestCreatePipelineRunPodTemplate(t *testing.T) {
+	e, _ := newTestListener()
+	e.runSpec.NodeSelector = map[string]string{"disktype": "ssd", "pool": "default"}
+	e.runSpec.Tolerations = []corev1.Toleration{
+		{Key: "gpu", Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule},
+		{Key: "dedicated", Operator: corev1.TolerationOpEqual, Value: "other", Effect: corev1.TaintEffectNoSchedule},
+	}
+	template, err := parsePodTemplate(`{
+		"nodeSelector": {"pool": "builds"},
+		"tolerations": [{"key": "dedicated", "operator": "Equal", "value": "builds", "effect": "NoSchedule"}]
+	}`)
+	if err != nil {
+		t.Fatalf("Error parsing pod template: %s", err)
+	}
+	e.podTemplate = template
+
+	run, err := e.createPipelineRun(context.Background(), "abc123", nil)
+	if err != nil {
+		t.Fatalf("Error creating pipelinerun: %s", err)
+	}
+	wantNodeSelector := map[string]string{"disktype": "ssd", "pool": "builds"}
+	if !reflect.DeepEqual(run.Spec.NodeSelector, wantNodeSelector) {
+		t.Errorf("Expected node selector %v, got %v", wantNodeSelector, run.Spec.NodeSelector)
+	}
+	wantTolerations := []corev1.Toleration{
+		{Key: "gpu", Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule},
+		{Key: "dedicated", Operator: corev1.TolerationOpEqual, Value: "builds", Effect: corev1.TaintEffectNoSchedule},
+	}
+	if !reflect.DeepEqual(run.Spec.Tolerations, wantTolerations) {
+		t.Errorf("Expected tolerations %v, got %v", wantTolerations, run.Spec.Tolerations)
+	}
+	if e.runSpec.NodeSelector["pool"] != "default" || e.runSpec.Tolerations[1].Value != "other" {
+		t.Errorf("Expected the run spec template to be unchanged, got %v %v", e.runSpec.NodeSelector, e.runSpec.Tolerations)
+	}
+}
+
+func TestParsePodTemplate(t *testing.T) {
+	template, err := parsePodTemplate("")
+	if err != nil || template != nil {
+		t.Errorf("Expected no pod template when unset, got %v, %v", template, err)
+	}
+	// an unset template leaves the spec alone
+	e, _ := newTestListener()
+	run, err := e.createPipelineRun(context.Background(), "abc123", nil)
+	if err != nil {
+		t.Fatalf("Error creating pipelinerun: %s", err)
+	}
+	if run.Spec.NodeSelector != nil || run.Spec.Tolerations != nil {
+		t.Errorf("Expected no scheduling settings, got %v %v", run.Spec.NodeSelector, run.Spec.Tolerations)
+	}
+
+	if _, err := parsePodTemplate(`{"nodeSelector": "builds"}`); err == nil {
+		t.Error("Expected an error parsing an invalid pod template")
+	}
+}
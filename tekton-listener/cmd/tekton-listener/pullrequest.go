@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+
+	"github.com/cloudevents/sdk-go/pkg/cloudevents"
+	"github.com/knative/pkg/logging"
+	"github.com/pkg/errors"
+)
+
+const githubPullRequestEventType = "com.github.pullrequest"
+
+const (
+	// forkSkipPolicy ignores pull requests from forks
+	forkSkipPolicy = "skip"
+	// forkBasePolicy builds the merge commit GitHub creates in the base
+	// repository rather than any commit of the fork
+	forkBasePolicy = "base"
+	// forkHeadPolicy builds the fork's head commit, as for other pull requests
+	forkHeadPolicy = "head"
+)
+
+// githubPullRequestActions are the pull request actions that trigger a run.
+var githubPullRequestActions = []string{"opened", "reopened", "synchronize"}
+
+// githubPullRequestPayload declares the fields used from the pull_request
+// payload. The repositories are pointers as GitHub sends the head
+// repository as null once the fork is deleted.
+type githubPullRequestPayload struct {
+	Action      string            `json:"action"`
+	Number      int               `json:"number"`
+	PullRequest githubPullRequest `json:"pull_request"`
+	Repository  struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+	Sender struct {
+		Login string `json:"login"`
+	} `json:"sender"`
+}
+
+// githubPullRequest is the pull request of the pull_request and
+// pull_request_review payloads.
+type githubPullRequest struct {
+	// MergeCommitSHA is null until GitHub has tested the merge
+	MergeCommitSHA *string              `json:"merge_commit_sha"`
+	Head           githubPullRequestRef `json:"head"`
+	Base           githubPullRequestRef `json:"base"`
+}
+
+type githubPullRequestRef struct {
+	Ref  string `json:"ref"`
+	SHA  string `json:"sha"`
+	Repo *struct {
+		FullName string `json:"full_name"`
+	} `json:"repo"`
+}
+
+// fromFork reports whether the pull request's head is in another repository
+// than its base. A head repository that was deleted is taken to be a fork.
+func (p *githubPullRequest) fromFork() bool {
+	head, base := p.Head.Repo, p.Base.Repo
+	return head == nil || base == nil || head.FullName != base.FullName
+}
+
+// validForkPolicy reports whether policy is a FORK_POLICY setting.
+func validForkPolicy(policy string) bool {
+	return containsString([]string{forkSkipPolicy, forkBasePolicy, forkHeadPolicy}, policy)
+}
+
+func (e *EventListener) handlePullRequest(ctx context.Context, event cloudevents.Event, payload interface{}) error {
+	pr := &githubPullRequestPayload{}
+	if err := decodePayload(ctx, event, pr, "Error handling pull request payload"); err != nil {
+		return err
+	}
+	return e.handlePullRequestPayload(ctx, event.Type(), pr, payload)
+}
+
+// handlePullRequestPayload triggers a run for a pull request event of the
+// given type, sent as a cloudevent or a raw webhook.
+func (e *EventListener) handlePullRequestPayload(ctx context.Context, eventType string, pr *githubPullRequestPayload, payload interface{}) error {
+	logger := logging.FromContext(ctx)
+	if e.skipRepository(ctx, pr.Repository.FullName) || e.skipAuthor(ctx, pr.Sender.Login) {
+		return nil
+	}
+	if !containsString(githubPullRequestActions, pr.Action) {
+		logger.Infof("Pull request %d was %s, skipping", pr.Number, pr.Action)
+		return nil
+	}
+
+	sha, ok := e.pullRequestSha(ctx, pr.Number, &pr.PullRequest)
+	if !ok {
+		return nil
+	}
+	if sha == "" {
+		return errors.New("Pull request payload has no head commit")
+	}
+
+	ctx = withPullRequest(withEventBranch(ctx, pr.PullRequest.Base.Ref), pr.Number)
+	if err := e.trigger(ctx, pr.Repository.FullName, sha, payload); err != nil {
+		return errors.Wrapf(err, "Error creating pipeline run for pull request event: %q", eventType)
+	}
+	return nil
+}
+
+// pullRequestSha returns the commit to build for a pull request, following
+// the FORK_POLICY for pull requests from forks, or false if the pull request
+// is skipped.
+func (e *EventListener) pullRequestSha(ctx context.Context, number int, pr *githubPullRequest) (string, bool) {
+	logger := logging.FromContext(ctx)
+	if !pr.fromFork() {
+		return pr.Head.SHA, true
+	}
+	switch e.forkPolicy {
+	case forkSkipPolicy:
+		logger.Infof("Pull request %d is from a fork, skipping", number)
+		return "", false
+	case forkBasePolicy:
+		if pr.MergeCommitSHA == nil || *pr.MergeCommitSHA == "" {
+			logger.Infof("Pull request %d is from a fork and has no merge commit yet, skipping", number)
+			return "", false
+		}
+		return *pr.MergeCommitSHA, true
+	}
+	return pr.Head.SHA, true
+}
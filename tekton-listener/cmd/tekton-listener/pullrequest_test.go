@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	pipelinev1alpha1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const forkPullRequestPayloadJSON = `{
+	"action": "synchronize",
+	"number": 7,
+	"pull_request": {
+		"number": 7,
+		"state": "open",
+		"merge_commit_sha": "8888888888888888888888888888888888888888",
+		"head": {
+			"label": "contributor:feature",
+			"ref": "feature",
+			"sha": "7777777777777777777777777777777777777777",
+			"repo": {"name": "bar", "full_name": "contributor/bar"}
+		},
+		"base": {
+			"label": "foo:master",
+			"ref": "master",
+			"sha": "5555555555555555555555555555555555555555",
+			"repo": {"name": "bar", "full_name": "foo/bar"}
+		}
+	},
+	"repository": {"name": "bar", "full_name": "foo/bar", "created_at": "2019-01-01T10:00:00Z"},
+	"sender": {"login": "contributor"}
+}`
+
+func TestHandleRequestPullRequestForkPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  string
+		payload string
+		wantSha string
+	}{
+		{name: "skip", policy: forkSkipPolicy, payload: forkPullRequestPayloadJSON},
+		{name: "base", policy: forkBasePolicy, payload: forkPullRequestPayloadJSON, wantSha: "8888888888888888888888888888888888888888"},
+		{name: "head", policy: forkHeadPolicy, payload: forkPullRequestPayloadJSON, wantSha: "7777777777777777777777777777777777777777"},
+		{
+			name:    "base without a merge commit",
+			policy:  forkBasePolicy,
+			payload: `{"action": "opened", "number": 7, "pull_request": {"merge_commit_sha": null, "head": {"sha": "7777777777777777777777777777777777777777", "repo": {"full_name": "contributor/bar"}}, "base": {"repo": {"full_name": "foo/bar"}}}, "repository": {"full_name": "foo/bar"}}`,
+		},
+		{
+			name:    "deleted fork",
+			policy:  forkSkipPolicy,
+			payload: `{"action": "opened", "number": 7, "pull_request": {"head": {"sha": "7777777777777777777777777777777777777777", "repo": null}, "base": {"repo": {"full_name": "foo/bar"}}}, "repository": {"full_name": "foo/bar"}}`,
+		},
+		{
+			name:    "same repository",
+			policy:  forkSkipPolicy,
+			payload: `{"action": "opened", "number": 7, "pull_request": {"merge_commit_sha": "8888888888888888888888888888888888888888", "head": {"sha": "7777777777777777777777777777777777777777", "repo": {"full_name": "foo/bar"}}, "base": {"repo": {"full_name": "foo/bar"}}}, "repository": {"full_name": "foo/bar"}}`,
+			wantSha: "7777777777777777777777777777777777777777",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, _ := newTestListener()
+			e.eventType = githubPullRequestEventType
+			e.setBuildSha = true
+			e.runSpec.Params = []pipelinev1alpha1.Param{{Name: "revision"}}
+			e.forkPolicy = tt.policy
+
+			event := newEvent(t, "delivery-1234", githubPullRequestEventType, []byte(tt.payload))
+			if err := e.HandleRequest(context.Background(), event); err != nil {
+				t.Fatalf("Unexpected error handling request: %s", err)
+			}
+
+			runs, err := e.pipelineClientset.Tekton().PipelineRuns(e.namespace).List(metav1.ListOptions{})
+			if err != nil {
+				t.Fatalf("Error listing pipelineruns: %s", err)
+			}
+			if tt.wantSha == "" {
+				if len(runs.Items) != 0 {
+					t.Errorf("Expected no pipelineruns, got %d", len(runs.Items))
+				}
+				return
+			}
+			if len(runs.Items) != 1 {
+				t.Fatalf("Expected one pipelinerun, got %d", len(runs.Items))
+			}
+			if got := runs.Items[0].Spec.Params[0].Value; got != tt.wantSha {
+				t.Errorf("Expected revision %q, got %q", tt.wantSha, got)
+			}
+		})
+	}
+}
+
+func TestValidForkPolicy(t *testing.T) {
+	for _, policy := range []string{forkSkipPolicy, forkBasePolicy, forkHeadPolicy} {
+		if !validForkPolicy(policy) {
+			t.Errorf("Expected %q to be a valid fork policy", policy)
+		}
+	}
+	if validForkPolicy("merge") {
+		t.Error("Expected merge not to be a valid fork policy")
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	"github.com/cloudevents/sdk-go/pkg/cloudevents"
+	"github.com/knative/pkg/logging"
+	"github.com/pkg/errors"
+)
+
+const githubPullRequestReviewEventType = "com.github.pullrequestreview"
+
+// githubReviewStates are the review states that can be set in REVIEW_STATES.
+var githubReviewStates = []string{"approved", "changes_requested", "commented"}
+
+// githubPullRequestReviewPayload declares the fields used from the
+// pull_request_review payload.
+type githubPullRequestReviewPayload struct {
+	Action string `json:"action"`
+	Review struct {
+		State string `json:"state"`
+	} `json:"review"`
+	PullRequest struct {
+		githubPullRequest
+		Number int `json:"number"`
+	} `json:"pull_request"`
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+	Sender struct {
+		Login string `json:"login"`
+	} `json:"sender"`
+}
+
+// handlePullRequestReview triggers a run of the pull request's head commit
+// when a review in one of the REVIEW_STATES is submitted. Edited and
+// dismissed reviews are skipped.
+func (e *EventListener) handlePullRequestReview(ctx context.Context, event cloudevents.Event, payload interface{}) error {
+	review := &githubPullRequestReviewPayload{}
+	if err := decodePayload(ctx, event, review, "Error handling pull request review payload"); err != nil {
+		return err
+	}
+	return e.handlePullRequestReviewPayload(ctx, event.Type(), review, payload)
+}
+
+// handlePullRequestReviewPayload triggers a run for a pull request review
+// event of the given type, sent as a cloudevent or a raw webhook.
+func (e *EventListener) handlePullRequestReviewPayload(ctx context.Context, eventType string, review *githubPullRequestReviewPayload, payload interface{}) error {
+	logger := logging.FromContext(ctx)
+	if e.skipRepository(ctx, review.Repository.FullName) || e.skipAuthor(ctx, review.Sender.Login) {
+		return nil
+	}
+	number := review.PullRequest.Number
+	state := strings.ToLower(review.Review.State)
+	if review.Action != "submitted" || !containsString(e.reviewStates, state) {
+		logger.Infof("Review of pull request %d was %s as %s, skipping", number, review.Action, state)
+		return nil
+	}
+
+	sha, ok := e.pullRequestSha(ctx, number, &review.PullRequest.githubPullRequest)
+	if !ok {
+		return nil
+	}
+	if sha == "" {
+		return errors.New("Pull request review payload has no head commit")
+	}
+
+	ctx = withPullRequest(withEventBranch(ctx, review.PullRequest.Base.Ref), number)
+	if err := e.trigger(ctx, review.Repository.FullName, sha, payload); err != nil {
+		return errors.Wrapf(err, "Error creating pipeline run for pull request review event: %q", eventType)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	pipelinev1alpha1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func pullRequestReviewPayload(action, state string) string {
+	return fmt.Sprintf(`{
+	"action": %q,
+	"review": {"id": 80, "state": %q, "commit_id": "5555555555555555555555555555555555555555"},
+	"pull_request": {
+		"number": 7,
+		"state": "open",
+		"head": {"ref": "feature", "sha": "7777777777777777777777777777777777777777", "repo": {"full_name": "foo/bar"}},
+		"base": {"ref": "master", "sha": "5555555555555555555555555555555555555555", "repo": {"full_name": "foo/bar"}}
+	},
+	"repository": {"name": "bar", "full_name": "foo/bar"},
+	"sender": {"login": "reviewer"}
+}`, action, state)
+}
+
+func TestHandleRequestPullRequestReview(t *testing.T) {
+	tests := []struct {
+		name     string
+		states   []string
+		action   string
+		state    string
+		wantRuns int
+	}{
+		{name: "approved", states: []string{"approved"}, action: "submitted", state: "approved", wantRuns: 1},
+		{name: "changes requested", states: []string{"approved"}, action: "submitted", state: "changes_requested"},
+		{name: "commented", states: []string{"approved"}, action: "submitted", state: "commented"},
+		{name: "commented when configured", states: []string{"approved", "commented"}, action: "submitted", state: "commented", wantRuns: 1},
+		{name: "upper case state", states: []string{"approved"}, action: "submitted", state: "APPROVED", wantRuns: 1},
+		{name: "dismissed", states: []string{"approved"}, action: "dismissed", state: "approved"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, _ := newTestListener()
+			e.eventType = githubPullRequestReviewEventType
+			e.setBuildSha = true
+			e.runSpec.Params = []pipelinev1alpha1.Param{{Name: "revision"}}
+			e.reviewStates = tt.states
+
+			event := newEvent(t, "delivery-1234", githubPullRequestReviewEventType, []byte(pullRequestReviewPayload(tt.action, tt.state)))
+			if err := e.HandleRequest(context.Background(), event); err != nil {
+				t.Fatalf("Unexpected error handling request: %s", err)
+			}
+
+			runs, err := e.pipelineClientset.Tekton().PipelineRuns(e.namespace).List(metav1.ListOptions{})
+			if err != nil {
+				t.Fatalf("Error listing pipelineruns: %s", err)
+			}
+			if len(runs.Items) != tt.wantRuns {
+				t.Fatalf("Expected %d pipelineruns, got %d", tt.wantRuns, len(runs.Items))
+			}
+			if tt.wantRuns == 0 {
+				return
+			}
+			// the head of the pull request is built rather than the reviewed commit
+			if got := runs.Items[0].Spec.Params[0].Value; got != "7777777777777777777777777777777777777777" {
+				t.Errorf("Expected the head revision, got %q", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"sync"
+
+	"golang.org/x/time/rate"
+)
+
+// repositoryLimiter limits the runs triggered for each repository with a
+// token bucket per repository, so that a flood of events for one repository
+// does not create dozens of concurrent runs. A bucket is kept for each
+// repository seen for the life of the listener.
+type repositoryLimiter struct {
+	mu       sync.Mutex
+	limit    rate.Limit
+	burst    int
+	limiters map[string]*rate.Limiter
+}
+
+// newRepositoryLimiter returns a limiter allowing perSecond runs per
+// repository with bursts of burst runs, or nil, which allows every run, when
+// perSecond is not positive.
+func newRepositoryLimiter(perSecond float64, burst int) *repositoryLimiter {
+	if perSecond <= 0 {
+		return nil
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &repositoryLimiter{
+		limit:    rate.Limit(perSecond),
+		burst:    burst,
+		limiters: map[string]*rate.Limiter{},
+	}
+}
+
+// allow reports whether a run may be triggered for repo now, taking a token
+// from its bucket if so.
+func (l *repositoryLimiter) allow(repo string) bool {
+	if l == nil {
+		return true
+	}
+	l.mu.Lock()
+	limiter, ok := l.limiters[repo]
+	if !ok {
+		limiter = rate.NewLimiter(l.limit, l.burst)
+		l.limiters[repo] = limiter
+	}
+	l.mu.Unlock()
+	return limiter.Allow()
+}
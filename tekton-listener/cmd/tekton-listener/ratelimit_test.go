@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestHandleRequestRateLimit(t *testing.T) {
+	e, logs := newTestListener()
+	// a rate low enough that no token is added back during the test
+	e.limiter = newRepositoryLimiter(0.001, 3)
+
+	send := func(i int, repo string) {
+		payload, err := json.Marshal(map[string]interface{}{
+			"check_suite": map[string]interface{}{"conclusion": "success", "head_sha": fmt.Sprintf("sha%d", i)},
+			"repository":  map[string]interface{}{"full_name": repo},
+		})
+		if err != nil {
+			t.Fatalf("Error marshalling payload: %s", err)
+		}
+		event := newEvent(t, fmt.Sprintf("delivery-%d", i), checkSuiteEventType, payload)
+		if err := e.HandleRequest(context.Background(), event); err != nil {
+			t.Fatalf("Expected rate limited events to be acknowledged, got %s", err)
+		}
+	}
+	for i := 0; i < 10; i++ {
+		send(i, "foo/bar")
+	}
+	// other repositories have their own bucket
+	send(10, "foo/other")
+
+	runs, err := e.pipelineClientset.Tekton().PipelineRuns(e.namespace).List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("Error listing pipelineruns: %s", err)
+	}
+	if len(runs.Items) != 4 {
+		t.Errorf("Expected the burst of 3 runs for foo/bar and 1 for foo/other, got %d", len(runs.Items))
+	}
+	if limited := logs.FilterMessage("rate limited, skipping").Len(); limited != 7 {
+		t.Errorf("Expected 7 rate limited events to be logged, got %d", limited)
+	}
+}
+
+func TestHandleRequestRateLimitDedupeBySha(t *testing.T) {
+	e, logs := newTestListener()
+	e.limiter = newRepositoryLimiter(0.001, 1)
+	builtShas, err := newDeliveryCache(16, time.Hour)
+	if err != nil {
+		t.Fatalf("Error creating cache: %s", err)
+	}
+	e.builtShas = builtShas
+
+	send := func(id, sha string) {
+		payload, err := json.Marshal(map[string]interface{}{
+			"check_suite": map[string]interface{}{"conclusion": "success", "head_sha": sha},
+			"repository":  map[string]interface{}{"full_name": "foo/bar"},
+		})
+		if err != nil {
+			t.Fatalf("Error marshalling payload: %s", err)
+		}
+		if err := e.HandleRequest(context.Background(), newEvent(t, id, checkSuiteEventType, payload)); err != nil {
+			t.Fatalf("Unexpected error handling %s: %s", id, err)
+		}
+	}
+	send("delivery-1", "sha1")
+	// the bucket is empty, so the event for sha2 is rate limited
+	send("delivery-2", "sha2")
+	// once runs are allowed again, another event for sha2 builds it
+	e.limiter = nil
+	send("delivery-3", "sha2")
+
+	runs, err := e.pipelineClientset.Tekton().PipelineRuns(e.namespace).List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("Error listing pipelineruns: %s", err)
+	}
+	if len(runs.Items) != 2 {
+		t.Errorf("Expected runs for sha1 and sha2, got %d", len(runs.Items))
+	}
+	if limited := logs.FilterMessage("rate limited, skipping").Len(); limited != 1 {
+		t.Errorf("Expected 1 rate limited event to be logged, got %d", limited)
+	}
+	if skipped := logs.FilterMessage("sha already built, skipping").Len(); skipped != 0 {
+		t.Errorf("Expected no event to be skipped as already built, %d were", skipped)
+	}
+}
+
+func TestRepositoryLimiterDisabled(t *testing.T) {
+	limiter := newRepositoryLimiter(0, 0)
+	if limiter != nil {
+		t.Fatalf("Expected no limiter when the rate is 0, got %+v", limiter)
+	}
+	for i := 0; i < 100; i++ {
+		if !limiter.allow("foo/bar") {
+			t.Fatal("Expected a disabled limiter to allow every run")
+		}
+	}
+}
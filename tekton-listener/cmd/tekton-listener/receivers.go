@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// shutdownTimeout bounds how long in flight requests are given to finish
+// when the receivers are shut down.
+const shutdownTimeout = 10 * time.Second
+
+// receiverConfig is a port and path on which events of the given types are accepted.
+type receiverConfig struct {
+	Port       int      `json:"port"`
+	Path       string   `json:"path"`
+	EventTypes []string `json:"eventTypes"`
+}
+
+// parseReceivers parses the RECEIVERS setting, a JSON list of receiver
+// configs. A receiver without a path serves path and one without event
+// types accepts eventType. When s is empty a single receiver on port is
+// returned.
+func parseReceivers(s string, port int, path, eventType string) ([]receiverConfig, error) {
+	if strings.TrimSpace(s) == "" {
+		return []receiverConfig{{Port: port, Path: path, EventTypes: []string{eventType}}}, nil
+	}
+	var receivers []receiverConfig
+	if err := json.Unmarshal([]byte(s), &receivers); err != nil {
+		return nil, errors.Wrap(err, "Error parsing receivers")
+	}
+	if len(receivers) == 0 {
+		return nil, errors.New("At least one receiver must be configured")
+	}
+	seen := map[string]bool{}
+	for i := range receivers {
+		r := &receivers[i]
+		if r.Port <= 0 {
+			return nil, fmt.Errorf("Receiver %d has an invalid port %d", i, r.Port)
+		}
+		if r.Path == "" {
+			r.Path = path
+		}
+		if !strings.HasPrefix(r.Path, "/") {
+			return nil, fmt.Errorf("Receiver path %q must start with /", r.Path)
+		}
+		if len(r.EventTypes) == 0 {
+			r.EventTypes = []string{eventType}
+		}
+		key := fmt.Sprintf("%d%s", r.Port, r.Path)
+		if seen[key] {
+			return nil, fmt.Errorf("Receiver path %q is configured more than once on port %d", r.Path, r.Port)
+		}
+		seen[key] = true
+	}
+	return receivers, nil
+}
+
+// newServers returns one HTTP server per port, serving each receiver on that
+// port at its path and, when a webhook secret is set to sign them with,
+// manual triggers at triggerPath.
+func (e *EventListener) newServers(receivers []receiverConfig) []*http.Server {
+	var servers []*http.Server
+	muxes := map[int]*http.ServeMux{}
+	// the trigger path is served on each port, unless a receiver uses it
+	triggered := map[int]bool{}
+	for _, r := range receivers {
+		if r.Path == triggerPath {
+			triggered[r.Port] = true
+		}
+	}
+	for _, r := range receivers {
+		mux, ok := muxes[r.Port]
+		if !ok {
+			mux = http.NewServeMux()
+			muxes[r.Port] = mux
+			if !triggered[r.Port] && e.webhookSecret != "" {
+				mux.HandleFunc(triggerPath, e.serveManualTrigger)
+			}
+			servers = append(servers, &http.Server{
+				Addr:         fmt.Sprintf(":%d", r.Port),
+				Handler:      mux,
+				ReadTimeout:  e.readTimeout,
+				WriteTimeout: e.writeTimeout,
+				IdleTimeout:  e.idleTimeout,
+			})
+		}
+		if e.rawWebhook {
+			mux.HandleFunc(r.Path, e.serveRawWebhook)
+			continue
+		}
+		mux.HandleFunc(r.Path, e.cloudEventHandler(r.EventTypes))
+	}
+	return servers
+}
+
+// serve runs each server on its listener until ctx is done or any of them
+// fails, then shuts them all down. It returns the first failure.
+func serve(ctx context.Context, servers []*http.Server, listeners []net.Listener, tlsConfig *tls.Config) error {
+	var wg sync.WaitGroup
+	errs := make(chan error, len(servers))
+	for i, srv := range servers {
+		wg.Add(1)
+		go func(srv *http.Server, ln net.Listener) {
+			defer wg.Done()
+			var err error
+			if tlsConfig != nil {
+				srv.TLSConfig = tlsConfig
+				// the certificate comes from tlsConfig so that rotated files are reloaded
+				err = srv.ServeTLS(ln, "", "")
+			} else {
+				err = srv.Serve(ln)
+			}
+			if err != http.ErrServerClosed {
+				errs <- errors.Wrapf(err, "Receiver on %s failed", ln.Addr())
+			}
+		}(srv, listeners[i])
+	}
+
+	var err error
+	select {
+	case <-ctx.Done():
+	case err = <-errs:
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	for _, srv := range servers {
+		srv.Shutdown(shutdownCtx)
+	}
+	wg.Wait()
+	return err
+}
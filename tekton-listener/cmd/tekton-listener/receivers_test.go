@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseReceivers(t *testing.T) {
+	receivers, err := parseReceivers("", 8082, listenerPath, checkSuiteEventType)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	want := []receiverConfig{{Port: 8082, Path: listenerPath, EventTypes: []string{checkSuiteEventType}}}
+	if !reflect.DeepEqual(receivers, want) {
+		t.Errorf("Expected the default receiver %v, got %v", want, receivers)
+	}
+
+	receivers, err = parseReceivers(`[{"port": 8082, "path": "/github"}, {"port": 8083, "eventTypes": ["com.bitbucket.push", "com.bitbucket.pullrequest"]}]`, 8082, listenerPath, checkSuiteEventType)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	want = []receiverConfig{
+		{Port: 8082, Path: "/github", EventTypes: []string{checkSuiteEventType}},
+		{Port: 8083, Path: listenerPath, EventTypes: []string{bitbucketPushEventType, bitbucketPullRequestEventType}},
+	}
+	if !reflect.DeepEqual(receivers, want) {
+		t.Errorf("Expected receivers %v, got %v", want, receivers)
+	}
+
+	receivers, err = parseReceivers(`[{"port": 8083}]`, 8082, "/github", checkSuiteEventType)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	want = []receiverConfig{{Port: 8083, Path: "/github", EventTypes: []string{checkSuiteEventType}}}
+	if !reflect.DeepEqual(receivers, want) {
+		t.Errorf("Expected a receiver without a path to serve the listener path %v, got %v", want, receivers)
+	}
+
+	for _, in := range []string{`[]`, `[{"port": 0}]`, `[{"port": 8082, "path": "github"}]`, `[{"port": 8082}, {"port": 8082}]`, `{`} {
+		if _, err := parseReceivers(in, 8082, listenerPath, checkSuiteEventType); err == nil {
+			t.Errorf("Expected an error parsing %q", in)
+		}
+	}
+}
+
+func TestServeMultipleReceivers(t *testing.T) {
+	e, _ := newTestListener()
+	receivers := []receiverConfig{
+		{Port: 1, Path: "/github", EventTypes: []string{checkSuiteEventType}},
+		{Port: 2, Path: "/bitbucket", EventTypes: []string{bitbucketPushEventType}},
+	}
+	servers := e.newServers(receivers)
+	if len(servers) != 2 {
+		t.Fatalf("Expected a server per port, got %d", len(servers))
+	}
+	var listeners []net.Listener
+	for range servers {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Error listening: %s", err)
+		}
+		listeners = append(listeners, ln)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- serve(ctx, servers, listeners, nil)
+	}()
+
+	post := func(ln net.Listener, path string) int {
+		req := newCheckSuiteRequest("delivery-1234", checkSuitePayload(t, "success", "abc123"))
+		req.RequestURI = ""
+		req.URL.Scheme = "http"
+		req.URL.Host = ln.Addr().String()
+		req.URL.Path = path
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Error posting to %s: %s", req.URL, err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	if code := post(listeners[0], "/github"); code != http.StatusAccepted {
+		t.Errorf("Expected the github receiver to accept the check_suite event, got %d", code)
+	}
+	if code := post(listeners[1], "/bitbucket"); code != http.StatusInternalServerError {
+		t.Errorf("Expected the bitbucket receiver to reject the check_suite event, got %d", code)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Expected a clean shutdown, got %s", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the receivers to shut down")
+	}
+	for _, ln := range listeners {
+		if conn, err := net.Dial("tcp", ln.Addr().String()); err == nil {
+			conn.Close()
+			t.Errorf("Expected %s to be closed after shutdown", ln.Addr())
+		}
+	}
+}
+
+func TestServeStopsAllReceiversOnFailure(t *testing.T) {
+	e, _ := newTestListener()
+	servers := e.newServers([]receiverConfig{
+		{Port: 1, Path: listenerPath, EventTypes: []string{checkSuiteEventType}},
+		{Port: 2, Path: listenerPath, EventTypes: []string{checkSuiteEventType}},
+	})
+	healthy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error listening: %s", err)
+	}
+	failing, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error listening: %s", err)
+	}
+	// a closed listener makes its server fail straight away
+	failing.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- serve(context.Background(), servers, []net.Listener{healthy, failing}, nil)
+	}()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("Expected the failed receiver's error")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the receivers to shut down")
+	}
+	if conn, err := net.Dial("tcp", healthy.Addr().String()); err == nil {
+		conn.Close()
+		t.Error("Expected the healthy receiver to be shut down")
+	}
+}
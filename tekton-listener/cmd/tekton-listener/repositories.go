@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"path"
+	"strings"
+
+	"github.com/knative/pkg/logging"
+	"github.com/pkg/errors"
+)
+
+// repositoryFilter holds owner/name patterns, which may use path.Match
+// wildcards, that a repository must match for its events to be handled.
+type repositoryFilter []string
+
+// parseRepositoryFilter parses comma separated owner/name patterns. Patterns
+// are matched case insensitively.
+func parseRepositoryFilter(s string) (repositoryFilter, error) {
+	var filter repositoryFilter
+	for _, pattern := range strings.Split(s, ",") {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if pattern == "" {
+			continue
+		}
+		if strings.Count(pattern, "/") != 1 {
+			return nil, errors.Errorf("Repository pattern %q must be of the form owner/name", pattern)
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, errors.Wrapf(err, "Invalid repository pattern %q", pattern)
+		}
+		filter = append(filter, pattern)
+	}
+	return filter, nil
+}
+
+// matches reports whether fullName matches any of the patterns. An empty
+// filter matches every repository.
+func (f repositoryFilter) matches(fullName string) bool {
+	if len(f) == 0 {
+		return true
+	}
+	fullName = strings.ToLower(fullName)
+	for _, pattern := range f {
+		if ok, _ := path.Match(pattern, fullName); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// skipRepository reports whether events for the repository fullName should be
+// skipped because it does not match the configured repositories.
+func (e *EventListener) skipRepository(ctx context.Context, fullName string) bool {
+	if e.repositories.matches(fullName) {
+		return false
+	}
+	logging.FromContext(ctx).Infof("Repository %q does not match the configured repositories, skipping", fullName)
+	return true
+}
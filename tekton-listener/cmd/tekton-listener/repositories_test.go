@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRepositoryFilter(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns string
+		repo     string
+		want     bool
+	}{
+		{name: "empty", patterns: "", repo: "foo/bar", want: true},
+		{name: "match", patterns: "foo/bar", repo: "foo/bar", want: true},
+		{name: "case insensitive", patterns: "Foo/Bar", repo: "foo/BAR", want: true},
+		{name: "no match", patterns: "foo/bar", repo: "foo/baz", want: false},
+		{name: "list", patterns: "foo/bar, foo/baz", repo: "foo/baz", want: true},
+		{name: "owner wildcard", patterns: "foo/*", repo: "foo/anything", want: true},
+		{name: "owner wildcard other owner", patterns: "foo/*", repo: "other/anything", want: false},
+		{name: "prefix wildcard", patterns: "*/web-*", repo: "foo/web-app", want: true},
+		{name: "unknown repository", patterns: "foo/bar", repo: "", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := parseRepositoryFilter(tt.patterns)
+			if err != nil {
+				t.Fatalf("Unexpected error parsing %q: %s", tt.patterns, err)
+			}
+			if got := filter.matches(tt.repo); got != tt.want {
+				t.Errorf("Expected %q to match %q: %t, got %t", tt.repo, tt.patterns, tt.want, got)
+			}
+		})
+	}
+
+	for _, in := range []string{"bar", "foo/bar/baz", "foo/[bar"} {
+		if _, err := parseRepositoryFilter(in); err == nil {
+			t.Errorf("Expected an error parsing %q", in)
+		}
+	}
+}
+
+func TestHandleRequestRepositoryFilter(t *testing.T) {
+	payload, err := json.Marshal(map[string]interface{}{
+		"check_suite": map[string]interface{}{"conclusion": "success", "head_sha": "abc123"},
+		"repository":  map[string]interface{}{"name": "bar", "full_name": "foo/bar"},
+	})
+	if err != nil {
+		t.Fatalf("Error marshalling payload: %s", err)
+	}
+
+	tests := []struct {
+		name     string
+		patterns string
+		wantRuns int
+	}{
+		{name: "all", patterns: "", wantRuns: 1},
+		{name: "matching", patterns: "foo/bar", wantRuns: 1},
+		{name: "non matching", patterns: "foo/other", wantRuns: 0},
+		{name: "wildcard", patterns: "other/*, foo/*", wantRuns: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, _ := newTestListener()
+			filter, err := parseRepositoryFilter(tt.patterns)
+			if err != nil {
+				t.Fatalf("Error parsing repositories: %s", err)
+			}
+			e.repositories = filter
+			event := newEvent(t, "delivery-1234", checkSuiteEventType, payload)
+			if err := e.HandleRequest(context.Background(), event); err != nil {
+				t.Fatalf("Error handling request: %s", err)
+			}
+
+			runs, err := e.pipelineClientset.Tekton().PipelineRuns(e.namespace).List(metav1.ListOptions{})
+			if err != nil {
+				t.Fatalf("Error listing pipelineruns: %s", err)
+			}
+			if len(runs.Items) != tt.wantRuns {
+				t.Errorf("Expected %d pipelineruns, got %d", tt.wantRuns, len(runs.Items))
+			}
+		})
+	}
+}
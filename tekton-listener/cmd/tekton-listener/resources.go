@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	pipelinev1alpha1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1alpha1"
+	"go.uber.org/zap"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// pinnedResource is a copy of a git PipelineResource bound by the run spec,
+// with its revision set to the event SHA.
+type pinnedResource struct {
+	binding  int
+	resource *pipelinev1alpha1.PipelineResource
+}
+
+// pinGitResources returns copies of the git resources bound in spec with their
+// revision param set to sha. Other resource types are left untouched. The
+// copies are not created, see createPinnedResources.
+func (e *EventListener) pinGitResources(logger *zap.SugaredLogger, spec *pipelinev1alpha1.PipelineRunSpec, sha string) ([]pinnedResource, error) {
+	var pinned []pinnedResource
+	for i, binding := range spec.Resources {
+		resource, err := e.pipelineClientset.Tekton().PipelineResources(e.runNamespace).Get(binding.ResourceRef.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, errors.Wrapf(err, "Error getting pipelineresource %q", binding.ResourceRef.Name)
+		}
+		if resource.Spec.Type != pipelinev1alpha1.PipelineResourceTypeGit {
+			continue
+		}
+
+		pin := &pipelinev1alpha1.PipelineResource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      pinnedResourceName(resource.Name, sha),
+				Namespace: resource.Namespace,
+				Labels:    resource.Labels,
+			},
+			Spec: *resource.Spec.DeepCopy(),
+		}
+		pin.Spec.Params = setRevisionParam(pin.Spec.Params, sha)
+		logger.Infof("Setting revision of git resource %q to %q", resource.Name, sha)
+		pinned = append(pinned, pinnedResource{binding: i, resource: pin})
+	}
+	return pinned, nil
+}
+
+// pinnedResourceName names the copy of resource pinned to sha, so that runs for
+// the same revision share one copy. The whole SHA is used, as commits sharing
+// a short SHA must not share a copy.
+func pinnedResourceName(resource, sha string) string {
+	return fmt.Sprintf("%s-%s", resource, strings.ToLower(sha))
+}
+
+// revisionParam returns the revision param of a resource, matching its name
+// case insensitively as setRevisionParam does.
+func revisionParam(resource *pipelinev1alpha1.PipelineResource) string {
+	for _, param := range resource.Spec.Params {
+		if strings.EqualFold(param.Name, "revision") {
+			return param.Value
+		}
+	}
+	return ""
+}
+
+// setRevisionParam sets the revision param, matching its name case
+// insensitively as the SHA params are.
+func setRevisionParam(params []pipelinev1alpha1.Param, sha string) []pipelinev1alpha1.Param {
+	for i := range params {
+		if strings.EqualFold(params[i].Name, "revision") {
+			params[i].Value = sha
+			return params
+		}
+	}
+	return append(params, pipelinev1alpha1.Param{Name: "revision", Value: sha})
+}
+
+// createPinnedResources creates the pinned resources, reusing any that already
+// exist for the same revision, and binds them into spec in place of the
+// resources they were copied from. An existing copy pinned to another revision
+// is an error. It returns the resources now bound and the subset of them that
+// it created.
+func (e *EventListener) createPinnedResources(pinned []pinnedResource, spec *pipelinev1alpha1.PipelineRunSpec) (bound, created []*pipelinev1alpha1.PipelineResource, err error) {
+	resources := e.pipelineClientset.Tekton().PipelineResources(e.runNamespace)
+	for _, pin := range pinned {
+		resource, err := resources.Create(pin.resource)
+		if k8serrors.IsAlreadyExists(err) {
+			resource, err = resources.Get(pin.resource.Name, metav1.GetOptions{})
+			if err == nil && revisionParam(resource) != revisionParam(pin.resource) {
+				err = errors.Errorf("existing copy has revision %q rather than %q", revisionParam(resource), revisionParam(pin.resource))
+			}
+		} else if err == nil {
+			created = append(created, resource)
+		}
+		if err != nil {
+			e.deleteResources(created)
+			return nil, nil, errors.Wrapf(err, "Error creating pipelineresource %q", pin.resource.Name)
+		}
+		bound = append(bound, resource)
+		spec.Resources[pin.binding].ResourceRef.Name = resource.Name
+	}
+	return bound, created, nil
+}
+
+// ownResources adds run as an owner of resources so they are garbage
+// collected once every run using them is deleted.
+func (e *EventListener) ownResources(logger *zap.SugaredLogger, run *pipelinev1alpha1.PipelineRun, resources []*pipelinev1alpha1.PipelineResource) {
+	gvk := pipelinev1alpha1.SchemeGroupVersion.WithKind("PipelineRun")
+	for _, resource := range resources {
+		resource.OwnerReferences = append(resource.OwnerReferences, metav1.OwnerReference{
+			APIVersion: gvk.GroupVersion().String(),
+			Kind:       gvk.Kind,
+			Name:       run.Name,
+			UID:        run.UID,
+		})
+		if _, err := e.pipelineClientset.Tekton().PipelineResources(e.runNamespace).Update(resource); err != nil {
+			logger.Errorf("Error setting owner of pipelineresource %q: %s", resource.Name, err)
+		}
+	}
+}
+
+func (e *EventListener) deleteResources(resources []*pipelinev1alpha1.PipelineResource) {
+	for _, resource := range resources {
+		e.pipelineClientset.Tekton().PipelineResources(e.runNamespace).Delete(resource.Name, &metav1.DeleteOptions{})
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	pipelinev1alpha1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1alpha1"
+	fakepipeline "github.com/tektoncd/pipeline/pkg/client/clientset/versioned/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func revisionOf(resource *pipelinev1alpha1.PipelineResource) string {
+	for _, p := range resource.Spec.Params {
+		if p.Name == "revision" {
+			return p.Value
+		}
+	}
+	return ""
+}
+
+func TestCreatePipelineRunGitResourceRevision(t *testing.T) {
+	gitResource := &pipelinev1alpha1.PipelineResource{
+		ObjectMeta: metav1.ObjectMeta{Name: "source-repo", Namespace: "default"},
+		Spec: pipelinev1alpha1.PipelineResourceSpec{
+			Type: pipelinev1alpha1.PipelineResourceTypeGit,
+			Params: []pipelinev1alpha1.Param{
+				{Name: "url", Value: "https://github.com/foo/bar"},
+				{Name: "revision", Value: "master"},
+			},
+		},
+	}
+	imageResource := &pipelinev1alpha1.PipelineResource{
+		ObjectMeta: metav1.ObjectMeta{Name: "web-image", Namespace: "default"},
+		Spec: pipelinev1alpha1.PipelineResourceSpec{
+			Type:   pipelinev1alpha1.PipelineResourceTypeImage,
+			Params: []pipelinev1alpha1.Param{{Name: "url", Value: "registry/web"}},
+		},
+	}
+
+	e, _ := newTestListener()
+	e.pipelineClientset = generateNames(fakepipeline.NewSimpleClientset(gitResource, imageResource))
+	e.setBuildSha = true
+	e.runSpec.Resources = []pipelinev1alpha1.PipelineResourceBinding{
+		{Name: "source", ResourceRef: pipelinev1alpha1.PipelineResourceRef{Name: "source-repo"}},
+		{Name: "image", ResourceRef: pipelinev1alpha1.PipelineResourceRef{Name: "web-image"}},
+	}
+
+	sha := "abc1234def5678"
+	run, err := e.createPipelineRun(context.Background(), sha, nil)
+	if err != nil {
+		t.Fatalf("Error creating pipelinerun: %s", err)
+	}
+
+	resources := e.pipelineClientset.Tekton().PipelineResources("default")
+	pinnedName := run.Spec.Resources[0].ResourceRef.Name
+	if pinnedName != "source-repo-abc1234def5678" {
+		t.Fatalf("Expected the git resource to be bound to source-repo-abc1234def5678, got %q", pinnedName)
+	}
+	pinned, err := resources.Get(pinnedName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Error getting pinned resource: %s", err)
+	}
+	if revisionOf(pinned) != sha {
+		t.Errorf("Expected pinned revision %q, got %q", sha, revisionOf(pinned))
+	}
+	if len(pinned.OwnerReferences) != 1 || pinned.OwnerReferences[0].Name != run.Name {
+		t.Errorf("Expected the pinned resource to be owned by the run, got %v", pinned.OwnerReferences)
+	}
+
+	if run.Spec.Resources[1].ResourceRef.Name != "web-image" {
+		t.Errorf("Expected the image resource binding to be untouched, got %q", run.Spec.Resources[1].ResourceRef.Name)
+	}
+	original, err := resources.Get("source-repo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Error getting original resource: %s", err)
+	}
+	if revisionOf(original) != "master" {
+		t.Errorf("Expected the original git resource to be untouched, got revision %q", revisionOf(original))
+	}
+	if e.runSpec.Resources[0].ResourceRef.Name != "source-repo" {
+		t.Errorf("Expected the run spec template to be untouched, got %q", e.runSpec.Resources[0].ResourceRef.Name)
+	}
+}
+
+func TestCreatePipelineRunPinnedResourceRevisionMismatch(t *testing.T) {
+	gitResource := &pipelinev1alpha1.PipelineResource{
+		ObjectMeta: metav1.ObjectMeta{Name: "source-repo", Namespace: "default"},
+		Spec: pipelinev1alpha1.PipelineResourceSpec{
+			Type:   pipelinev1alpha1.PipelineResourceTypeGit,
+			Params: []pipelinev1alpha1.Param{{Name: "url", Value: "https://github.com/foo/bar"}},
+		},
+	}
+	// a copy of the right name pinned to another revision must not be run
+	existing := gitResource.DeepCopy()
+	existing.Name = "source-repo-abc1234def5678"
+	existing.Spec.Params = append(existing.Spec.Params, pipelinev1alpha1.Param{Name: "revision", Value: "master"})
+
+	e, _ := newTestListener()
+	e.pipelineClientset = generateNames(fakepipeline.NewSimpleClientset(gitResource, existing))
+	e.setBuildSha = true
+	e.runSpec.Resources = []pipelinev1alpha1.PipelineResourceBinding{
+		{Name: "source", ResourceRef: pipelinev1alpha1.PipelineResourceRef{Name: "source-repo"}},
+	}
+
+	if _, err := e.createPipelineRun(context.Background(), "abc1234def5678", nil); err == nil {
+		t.Fatal("Expected an error binding a copy pinned to another revision")
+	}
+	runs, err := e.pipelineClientset.Tekton().PipelineRuns(e.runNamespace).List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("Error listing pipelineruns: %s", err)
+	}
+	if len(runs.Items) != 0 {
+		t.Errorf("Expected no pipelinerun to be created, got %d", len(runs.Items))
+	}
+}
+
+func TestSetRevisionParam(t *testing.T) {
+	params := setRevisionParam([]pipelinev1alpha1.Param{{Name: "url", Value: "u"}}, "abc")
+	if len(params) != 2 || params[1].Name != "revision" || params[1].Value != "abc" {
+		t.Errorf("Expected a revision param to be added, got %v", params)
+	}
+	params = setRevisionParam([]pipelinev1alpha1.Param{{Name: "Revision", Value: "master"}}, "abc")
+	if len(params) != 1 || params[0].Value != "abc" {
+		t.Errorf("Expected the existing revision param to be set, got %v", params)
+	}
+}
+
+func TestCreatePipelineRunNamespace(t *testing.T) {
+	gitResource := &pipelinev1alpha1.PipelineResource{
+		ObjectMeta: metav1.ObjectMeta{Name: "source-repo", Namespace: "runs"},
+		Spec: pipelinev1alpha1.PipelineResourceSpec{
+			Type:   pipelinev1alpha1.PipelineResourceTypeGit,
+			Params: []pipelinev1alpha1.Param{{Name: "url", Value: "https://github.com/foo/bar"}},
+		},
+	}
+
+	e, _ := newTestListener()
+	e.pipelineClientset = generateNames(fakepipeline.NewSimpleClientset(gitResource))
+	e.runNamespace = "runs"
+	e.setBuildSha = true
+	e.runSpec.Resources = []pipelinev1alpha1.PipelineResourceBinding{
+		{Name: "source", ResourceRef: pipelinev1alpha1.PipelineResourceRef{Name: "source-repo"}},
+	}
+
+	run, err := e.createPipelineRun(context.Background(), "abc1234def5678", nil)
+	if err != nil {
+		t.Fatalf("Error creating pipelinerun: %s", err)
+	}
+	if run.Namespace != "runs" {
+		t.Errorf("Expected the run to be created in namespace runs, got %q", run.Namespace)
+	}
+	for namespace, want := range map[string]int{"runs": 1, e.namespace: 0} {
+		runs, err := e.pipelineClientset.Tekton().PipelineRuns(namespace).List(metav1.ListOptions{})
+		if err != nil {
+			t.Fatalf("Error listing pipelineruns: %s", err)
+		}
+		if len(runs.Items) != want {
+			t.Errorf("Expected %d pipelineruns in namespace %s, got %d", want, namespace, len(runs.Items))
+		}
+	}
+
+	// the pinned resource goes with the run so that the run can bind it and own it
+	pinned, err := e.pipelineClientset.Tekton().PipelineResources("runs").Get(run.Spec.Resources[0].ResourceRef.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Error getting pinned resource: %s", err)
+	}
+	if len(pinned.OwnerReferences) != 1 || pinned.OwnerReferences[0].Name != run.Name {
+		t.Errorf("Expected the pinned resource to be owned by the run, got %v", pinned.OwnerReferences)
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	experimentalv1alpha1 "github.com/tektoncd/experimental/tekton-listener/pkg/apis/pipelineexperimental/v1alpha1"
+	experimentalClientset "github.com/tektoncd/experimental/tekton-listener/pkg/client/clientset/versioned"
+	"go.uber.org/zap"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// listenerPollInterval is how often the TektonListener is read again while
+// waiting for it at startup.
+const listenerPollInterval = 5 * time.Second
+
+// waitForListener gets the TektonListener, retrying while it is not found
+// for up to timeout. During a fresh install the listener may start before
+// the TektonListener CRD, or the resource itself, has been created; both are
+// reported as not found. Other errors are returned straight away.
+func waitForListener(logger *zap.SugaredLogger, client experimentalClientset.Interface, namespace, name string, timeout, interval time.Duration) (*experimentalv1alpha1.TektonListener, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		listener, err := client.PipelineexperimentalV1alpha1().TektonListeners(namespace).Get(name, metav1.GetOptions{})
+		if err == nil {
+			return listener, nil
+		}
+		if !k8serrors.IsNotFound(err) {
+			return nil, err
+		}
+		if !time.Now().Before(deadline) {
+			return nil, errors.Wrapf(err, "TektonListener still not found after %s", timeout)
+		}
+		logger.Infof("Waiting for tekton listener %s in namespace %s: %v", name, namespace, err)
+		time.Sleep(interval)
+	}
+}
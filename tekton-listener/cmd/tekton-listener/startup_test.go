@@ -0,0 +1,55 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	experimentalv1alpha1 "github.com/tektoncd/experimental/tekton-listener/pkg/apis/pipelineexperimental/v1alpha1"
+	fakeexperimental "github.com/tektoncd/experimental/tekton-listener/pkg/client/clientset/versioned/fake"
+	"go.uber.org/zap"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	ktesting "k8s.io/client-go/testing"
+)
+
+// notFoundClient returns a clientset holding a TektonListener that is not
+// found for the first misses gets.
+func notFoundClient(misses int) (*fakeexperimental.Clientset, *int) {
+	client := fakeexperimental.NewSimpleClientset(&experimentalv1alpha1.TektonListener{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-listener", Namespace: "default"},
+	})
+	gets := 0
+	client.PrependReactor("get", "tektonlisteners", func(action ktesting.Action) (bool, runtime.Object, error) {
+		gets++
+		if gets <= misses {
+			return true, nil, k8serrors.NewNotFound(schema.GroupResource{Group: "tekton.dev", Resource: "tektonlisteners"}, "test-listener")
+		}
+		return false, nil, nil
+	})
+	return client, &gets
+}
+
+func TestWaitForListener(t *testing.T) {
+	client, gets := notFoundClient(2)
+	listener, err := waitForListener(zap.NewNop().Sugar(), client, "default", "test-listener", time.Minute, time.Millisecond)
+	if err != nil {
+		t.Fatalf("Error waiting for the listener: %s", err)
+	}
+	if listener.Name != "test-listener" {
+		t.Errorf("Expected the test-listener, got %q", listener.Name)
+	}
+	if *gets != 3 {
+		t.Errorf("Expected the listener to be read 3 times, got %d", *gets)
+	}
+}
+
+func TestWaitForListenerTimeout(t *testing.T) {
+	client, _ := notFoundClient(1 << 30)
+	_, err := waitForListener(zap.NewNop().Sugar(), client, "default", "test-listener", 20*time.Millisecond, time.Millisecond)
+	if err == nil || !k8serrors.IsNotFound(errors.Cause(err)) {
+		t.Errorf("Expected a not found error after the timeout, got %v", err)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"crypto/tls"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// certReloader serves a TLS key pair from disk and reloads it when either file
+// changes, so that rotated certificates are picked up without a restart.
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile}
+	if _, err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// load returns the current key pair, reading it again if the files were
+// modified since it was last read.
+func (r *certReloader) load() (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	modTime, err := latestModTime(r.certFile, r.keyFile)
+	if err != nil {
+		return nil, err
+	}
+	if r.cert != nil && !modTime.After(r.modTime) {
+		return r.cert, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		if r.cert != nil {
+			// keep serving the old pair while a rotation is half written
+			return r.cert, nil
+		}
+		return nil, errors.Wrap(err, "Error loading TLS key pair")
+	}
+	r.cert = &cert
+	r.modTime = modTime
+	return r.cert, nil
+}
+
+// GetCertificate implements tls.Config.GetCertificate.
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return r.load()
+}
+
+func latestModTime(files ...string) (time.Time, error) {
+	var latest time.Time
+	for _, f := range files {
+		info, err := os.Stat(f)
+		if err != nil {
+			return time.Time{}, errors.Wrapf(err, "Error reading %q", f)
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// newTLSConfig returns the TLS configuration for the listener's server, or nil
+// if TLS is not configured.
+func (e *EventListener) newTLSConfig() (*tls.Config, error) {
+	if e.tlsCertFile == "" || e.tlsKeyFile == "" {
+		return nil, nil
+	}
+	reloader, err := newCertReloader(e.tlsCertFile, e.tlsKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{GetCertificate: reloader.GetCertificate}, nil
+}
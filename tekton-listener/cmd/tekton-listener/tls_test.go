@@ -0,0 +1,133 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeTestCert writes a self signed certificate for 127.0.0.1 and its key to
+// dir, returning the parsed certificate.
+func writeTestCert(t *testing.T, dir string, serial int64) *x509.Certificate {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Error generating key: %s", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: "tekton-listener"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Error creating certificate: %s", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Error parsing certificate: %s", err)
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	if err := ioutil.WriteFile(filepath.Join(dir, "tls.crt"), certPEM, 0600); err != nil {
+		t.Fatalf("Error writing certificate: %s", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "tls.key"), keyPEM, 0600); err != nil {
+		t.Fatalf("Error writing key: %s", err)
+	}
+	return cert
+}
+
+// serialFromServer returns the serial number of the certificate the server at addr presents.
+func serialFromServer(t *testing.T, addr string, roots *x509.CertPool) int64 {
+	conn, err := tls.Dial("tcp", addr, &tls.Config{RootCAs: roots})
+	if err != nil {
+		t.Fatalf("Error negotiating TLS: %s", err)
+	}
+	defer conn.Close()
+	return conn.ConnectionState().PeerCertificates[0].SerialNumber.Int64()
+}
+
+func TestTLSListener(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tekton-listener-tls")
+	if err != nil {
+		t.Fatalf("Error creating temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	first := writeTestCert(t, dir, 1)
+
+	e, _ := newTestListener()
+	e.tlsCertFile = filepath.Join(dir, "tls.crt")
+	e.tlsKeyFile = filepath.Join(dir, "tls.key")
+	tlsConfig, err := e.newTLSConfig()
+	if err != nil {
+		t.Fatalf("Error configuring TLS: %s", err)
+	}
+	if tlsConfig == nil {
+		t.Fatal("Expected a TLS config when both files are set")
+	}
+
+	srv := e.newServer()
+	srv.TLSConfig = tlsConfig
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error listening: %s", err)
+	}
+	go srv.ServeTLS(ln, "", "")
+	defer srv.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(first)
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}}}
+	resp, err := client.Get("https://" + ln.Addr().String() + listenerPath)
+	if err != nil {
+		t.Fatalf("Error making TLS request: %s", err)
+	}
+	resp.Body.Close()
+	if resp.TLS == nil {
+		t.Error("Expected the response to be served over TLS")
+	}
+
+	// rotate the certificate and check that new connections get the new one
+	second := writeTestCert(t, dir, 2)
+	future := time.Now().Add(time.Minute)
+	for _, f := range []string{e.tlsCertFile, e.tlsKeyFile} {
+		if err := os.Chtimes(f, future, future); err != nil {
+			t.Fatalf("Error touching %q: %s", f, err)
+		}
+	}
+	roots.AddCert(second)
+	if serial := serialFromServer(t, ln.Addr().String(), roots); serial != 2 {
+		t.Errorf("Expected the rotated certificate with serial 2, got %d", serial)
+	}
+}
+
+func TestTLSConfigDisabled(t *testing.T) {
+	e, _ := newTestListener()
+	e.tlsCertFile = "tls.crt"
+	tlsConfig, err := e.newTLSConfig()
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if tlsConfig != nil {
+		t.Error("Expected no TLS config unless both files are set")
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/knative/pkg/logging"
+	"github.com/pkg/errors"
+)
+
+// triggerPath is the path runs are triggered at by hand, on each port the
+// listener serves when a webhook secret is set.
+const triggerPath = "/trigger"
+
+// manualTrigger is the body POSTed to triggerPath: the SHA to build, the
+// repository it is built for, if any, and params set on the run, overwriting
+// any of the same name.
+type manualTrigger struct {
+	SHA        string            `json:"sha"`
+	Repository string            `json:"repository,omitempty"`
+	Params     map[string]string `json:"params,omitempty"`
+}
+
+// serveManualTrigger creates a run for the SHA POSTed, without an event, so
+// that runs can be rerun or tested by hand. The X-Hub-Signature header must
+// be the body's signature with the webhook secret, as GitHub signs webhooks.
+// Triggers are rate limited with the events of their repository, and in
+// triggerbinding mode their params are posted instead. They are not
+// deduplicated by SHA, so that a commit already built can be rerun.
+func (e *EventListener) serveManualTrigger(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "only POST is supported", http.StatusMethodNotAllowed)
+		return
+	}
+	body, ok := e.readBody(w, req)
+	if !ok {
+		return
+	}
+	if err := verifySignature(e.webhookSecret, req.Header.Get("X-Hub-Signature"), body); err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	trigger := manualTrigger{}
+	if err := json.Unmarshal(body, &trigger); err != nil {
+		http.Error(w, fmt.Sprintf("failed to decode trigger: %v", err), http.StatusBadRequest)
+		return
+	}
+	if trigger.SHA == "" {
+		http.Error(w, "sha must be set", http.StatusBadRequest)
+		return
+	}
+	// the generic form of the body is used to resolve param mappings
+	var payload interface{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		http.Error(w, fmt.Sprintf("failed to decode trigger: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	logger := e.logger.With("sha", trigger.SHA)
+	ctx := logging.WithLogger(e.withHeaderParams(req.Context(), req.Header), logger)
+	ctx = context.WithValue(withParams(ctx, trigger.Params), repositoryKey{}, trigger.Repository)
+	logger.Info("Handling manual trigger")
+	if !e.limiter.allow(trigger.Repository) {
+		logger.Infow("rate limited, skipping", "repository", trigger.Repository)
+		http.Error(w, "rate limited", http.StatusTooManyRequests)
+		return
+	}
+	run, err := e.startRun(ctx, trigger.SHA, payload)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if run == nil {
+		// the params were posted to the Triggers EventListener
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]string{"name": run.Name, "namespace": run.Namespace})
+}
+
+// withParams returns a context carrying params, in addition to any header
+// params it carries already, to be set on the run.
+func withParams(ctx context.Context, params map[string]string) context.Context {
+	if len(params) == 0 {
+		return ctx
+	}
+	values := map[string]string{}
+	existing, _ := ctx.Value(headerParamsKey{}).(map[string]string)
+	for name, value := range existing {
+		values[name] = value
+	}
+	for name, value := range params {
+		values[name] = value
+	}
+	return context.WithValue(ctx, headerParamsKey{}, values)
+}
+
+// verifySignature checks that signature, of the form sha1=<hex>, is the HMAC
+// of body with secret. Any signature is accepted when secret is empty.
+func verifySignature(secret, signature string, body []byte) error {
+	if secret == "" {
+		return nil
+	}
+	if signature == "" {
+		return errors.New("missing X-Hub-Signature header")
+	}
+	if !strings.HasPrefix(signature, "sha1=") {
+		return errors.New("invalid X-Hub-Signature header, expected sha1=<signature>")
+	}
+	sent, err := hex.DecodeString(strings.TrimPrefix(signature, "sha1="))
+	if err != nil {
+		return errors.Wrap(err, "invalid X-Hub-Signature header")
+	}
+	mac := hmac.New(sha1.New, []byte(secret))
+	mac.Write(body)
+	if !hmac.Equal(sent, mac.Sum(nil)) {
+		return errors.New("signature verification failed")
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	pipelinev1alpha1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// sign returns the X-Hub-Signature of body with secret.
+func sign(secret string, body []byte) string {
+	mac := hmac.New(sha1.New, []byte(secret))
+	mac.Write(body)
+	return "sha1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestServeManualTrigger(t *testing.T) {
+	e, _ := newTestListener()
+	e.webhookSecret = "secret"
+	e.setBuildSha = true
+	e.runSpec.Params = []pipelinev1alpha1.Param{{Name: "revision", Value: "master"}}
+	body := []byte(`{"sha": "abc123", "params": {"env": "staging"}}`)
+
+	req := httptest.NewRequest(http.MethodPost, triggerPath, bytes.NewReader(body))
+	req.Header.Set("X-Hub-Signature", sign("secret", body))
+	rec := httptest.NewRecorder()
+	e.newServer().Handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, rec.Code, rec.Body.String())
+	}
+	created := map[string]string{}
+	if err := json.NewDecoder(rec.Body).Decode(&created); err != nil {
+		t.Fatalf("Error decoding response: %s", err)
+	}
+
+	run, err := e.pipelineClientset.Tekton().PipelineRuns(e.runNamespace).Get(created["name"], metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Error getting the pipelinerun %q: %s", created["name"], err)
+	}
+	want := []pipelinev1alpha1.Param{{Name: "revision", Value: "abc123"}, {Name: "env", Value: "staging"}}
+	if len(run.Spec.Params) != len(want) {
+		t.Fatalf("Expected params %+v, got %+v", want, run.Spec.Params)
+	}
+	for i := range want {
+		if run.Spec.Params[i] != want[i] {
+			t.Errorf("Expected params %+v, got %+v", want, run.Spec.Params)
+		}
+	}
+}
+
+func TestServeManualTriggerRejected(t *testing.T) {
+	body := []byte(`{"sha": "abc123"}`)
+	tests := []struct {
+		name       string
+		method     string
+		body       []byte
+		signature  string
+		wantStatus int
+	}{
+		{name: "unsigned", method: http.MethodPost, body: body, wantStatus: http.StatusUnauthorized},
+		{name: "wrong secret", method: http.MethodPost, body: body, signature: sign("other", body), wantStatus: http.StatusUnauthorized},
+		{name: "malformed signature", method: http.MethodPost, body: body, signature: "abc123", wantStatus: http.StatusUnauthorized},
+		{name: "no sha", method: http.MethodPost, body: []byte(`{}`), signature: sign("secret", []byte(`{}`)), wantStatus: http.StatusBadRequest},
+		{name: "not json", method: http.MethodPost, body: []byte(`sha`), signature: sign("secret", []byte(`sha`)), wantStatus: http.StatusBadRequest},
+		{name: "get", method: http.MethodGet, wantStatus: http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, _ := newTestListener()
+			e.webhookSecret = "secret"
+			req := httptest.NewRequest(tt.method, triggerPath, bytes.NewReader(tt.body))
+			if tt.signature != "" {
+				req.Header.Set("X-Hub-Signature", tt.signature)
+			}
+			rec := httptest.NewRecorder()
+			e.newServer().Handler.ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+
+			runs, err := e.pipelineClientset.Tekton().PipelineRuns(e.runNamespace).List(metav1.ListOptions{})
+			if err != nil {
+				t.Fatalf("Error listing pipelineruns: %s", err)
+			}
+			if len(runs.Items) != 0 {
+				t.Errorf("Expected no pipelineruns, got %d", len(runs.Items))
+			}
+		})
+	}
+}
+
+func TestServeManualTriggerWithoutSecret(t *testing.T) {
+	e, _ := newTestListener()
+	e.listenerPath = "/"
+	body := []byte(`{"sha": "abc123"}`)
+	req := httptest.NewRequest(http.MethodPost, triggerPath, bytes.NewReader(body))
+	rec := httptest.NewRecorder()
+	e.newServer().Handler.ServeHTTP(rec, req)
+	if rec.Code == http.StatusCreated {
+		t.Errorf("Expected manual triggers not to be served without a webhook secret, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	runs, err := e.pipelineClientset.Tekton().PipelineRuns(e.runNamespace).List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("Error listing pipelineruns: %s", err)
+	}
+	if len(runs.Items) != 0 {
+		t.Errorf("Expected no pipelineruns, got %d", len(runs.Items))
+	}
+
+	// the trigger path is not found when no receiver serves it either
+	e.listenerPath = listenerPath
+	rec = httptest.NewRecorder()
+	e.newServer().Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, triggerPath, bytes.NewReader(body)))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d: %s", http.StatusNotFound, rec.Code, rec.Body.String())
+	}
+}
+
+// postTrigger POSTs body, signed with the listener's webhook secret, to the
+// listener's trigger path.
+func postTrigger(e *EventListener, body []byte) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, triggerPath, bytes.NewReader(body))
+	req.Header.Set("X-Hub-Signature", sign(e.webhookSecret, body))
+	rec := httptest.NewRecorder()
+	e.newServer().Handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestServeManualTriggerRateLimited(t *testing.T) {
+	e, _ := newTestListener()
+	e.webhookSecret = "secret"
+	e.limiter = newRepositoryLimiter(0.001, 1)
+
+	if rec := postTrigger(e, []byte(`{"sha": "abc123", "repository": "foo/bar"}`)); rec.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, rec.Code, rec.Body.String())
+	}
+	if rec := postTrigger(e, []byte(`{"sha": "def456", "repository": "foo/bar"}`)); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected status %d once foo/bar is rate limited, got %d: %s", http.StatusTooManyRequests, rec.Code, rec.Body.String())
+	}
+	// other repositories have their own bucket
+	if rec := postTrigger(e, []byte(`{"sha": "def456", "repository": "foo/other"}`)); rec.Code != http.StatusCreated {
+		t.Errorf("Expected status %d for foo/other, got %d: %s", http.StatusCreated, rec.Code, rec.Body.String())
+	}
+}
+
+func TestServeManualTriggerTriggerBindingMode(t *testing.T) {
+	var gotBody map[string]string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&gotBody); err != nil {
+			t.Errorf("Error decoding trigger params: %s", err)
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer ts.Close()
+
+	e, _ := newTestListener()
+	e.webhookSecret = "secret"
+	e.mode = triggerBindingMode
+	e.triggersURL = ts.URL
+	e.triggersClient = ts.Client()
+
+	if rec := postTrigger(e, []byte(`{"sha": "abc123", "params": {"env": "staging"}}`)); rec.Code != http.StatusAccepted {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusAccepted, rec.Code, rec.Body.String())
+	}
+	if want := map[string]string{"revision": "abc123", "env": "staging"}; !reflect.DeepEqual(gotBody, want) {
+		t.Errorf("Expected trigger params %v, got %v", want, gotBody)
+	}
+	runs, err := e.pipelineClientset.Tekton().PipelineRuns(e.runNamespace).List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("Error listing pipelineruns: %s", err)
+	}
+	if len(runs.Items) != 0 {
+		t.Errorf("Expected no pipelineruns in %s mode, got %d", triggerBindingMode, len(runs.Items))
+	}
+}
+
+func TestServeManualTriggerNotDeduplicated(t *testing.T) {
+	e, _ := newTestListener()
+	e.webhookSecret = "secret"
+	builtShas, err := newDeliveryCache(16, time.Hour)
+	if err != nil {
+		t.Fatalf("Error creating cache: %s", err)
+	}
+	e.builtShas = builtShas
+
+	// a commit already built is built again when triggered by hand
+	for i := 0; i < 2; i++ {
+		if rec := postTrigger(e, []byte(`{"sha": "abc123"}`)); rec.Code != http.StatusCreated {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, rec.Code, rec.Body.String())
+		}
+	}
+	runs, err := e.pipelineClientset.Tekton().PipelineRuns(e.runNamespace).List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("Error listing pipelineruns: %s", err)
+	}
+	if len(runs.Items) != 2 {
+		t.Errorf("Expected a run for each trigger, got %d", len(runs.Items))
+	}
+}
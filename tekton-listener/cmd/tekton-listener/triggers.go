@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/knative/pkg/logging"
+	"github.com/pkg/errors"
+	pipelinev1alpha1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1alpha1"
+)
+
+const (
+	// pipelineRunMode creates a PipelineRun for each event
+	pipelineRunMode = "pipelinerun"
+	// triggerBindingMode forwards the params extracted from each event to a
+	// Tekton Triggers EventListener, whose TriggerBindings read them from the body
+	triggerBindingMode = "triggerbinding"
+)
+
+// repositoryKey is the context key of the repository a run is triggered for.
+type repositoryKey struct{}
+
+// eventRepository returns the repository of the event being handled, or ""
+// when no run is being triggered.
+func eventRepository(ctx context.Context) string {
+	repo, _ := ctx.Value(repositoryKey{}).(string)
+	return repo
+}
+
+// trigger starts the pipeline for an event for repo at sha in the configured
+// mode, unless events for repo are being rate limited or, when deduplicating
+// by SHA, sha was built recently.
+func (e *EventListener) trigger(ctx context.Context, repo, sha string, payload interface{}) error {
+	if e.builtShas != nil && sha != "" {
+		// several events, such as a push and its check suite, refer to the
+		// same commit, which is only built once
+		key := e.runName + "/" + sha
+		if e.builtShas.seen(key) {
+			logging.FromContext(ctx).Infow("sha already built, skipping", "sha", sha)
+			return nil
+		}
+		triggered, err := e.triggerRun(ctx, repo, sha, payload)
+		if !triggered {
+			// allow another event for the commit to build it
+			e.builtShas.forget(key)
+		}
+		return err
+	}
+	_, err := e.triggerRun(ctx, repo, sha, payload)
+	return err
+}
+
+// triggerRun is trigger without the deduplication by SHA. It reports whether
+// a run was created or its params posted, which a rate limited event or a dry
+// run does not do.
+func (e *EventListener) triggerRun(ctx context.Context, repo, sha string, payload interface{}) (bool, error) {
+	if !e.limiter.allow(repo) {
+		logging.FromContext(ctx).Infow("rate limited, skipping", "repository", repo)
+		return false, nil
+	}
+	if _, err := e.startRun(context.WithValue(ctx, repositoryKey{}, repo), sha, payload); err != nil {
+		return false, err
+	}
+	return !e.dryRun, nil
+}
+
+// startRun creates a run for sha or, in triggerbinding mode, posts its params,
+// returning the run created, which is nil in triggerbinding mode.
+func (e *EventListener) startRun(ctx context.Context, sha string, payload interface{}) (*pipelinev1alpha1.PipelineRun, error) {
+	if e.mode == triggerBindingMode {
+		return nil, e.postTriggerParams(ctx, sha, payload)
+	}
+	return e.createPipelineRun(ctx, sha, payload)
+}
+
+// triggerParams returns the params extracted from an event: the revision,
+// the tag for releases, any param mappings, the commit params and any header
+// params.
+func (e *EventListener) triggerParams(ctx context.Context, sha string, payload interface{}) []pipelinev1alpha1.Param {
+	var params []pipelinev1alpha1.Param
+	if sha != "" {
+		params = setParam(params, "revision", sha)
+	}
+	if tag := releaseTag(ctx); tag != "" {
+		params = setParam(params, "tag", tag)
+	}
+	params = applyParamMappings(logging.FromContext(ctx), e.paramMappings, payload, params)
+	params = applyCommitParams(e.commitAuthorParam, e.commitMessageParam, payload, params)
+	return applyHeaderParams(ctx, params)
+}
+
+// postTriggerParams POSTs the params extracted from an event to the Triggers
+// EventListener as a JSON object of param name to value.
+func (e *EventListener) postTriggerParams(ctx context.Context, sha string, payload interface{}) error {
+	logger := logging.FromContext(ctx)
+	body := map[string]string{}
+	for _, p := range e.triggerParams(ctx, sha, payload) {
+		body[p.Name] = p.Value
+	}
+	buf, err := json.Marshal(body)
+	if err != nil {
+		return errors.Wrap(err, "Error marshalling trigger params")
+	}
+
+	if e.dryRun {
+		logger.Infow("Dry run, not posting trigger params", "url", e.triggersURL, "params", string(buf))
+		return nil
+	}
+
+	req, err := http.NewRequest(http.MethodPost, e.triggersURL, bytes.NewReader(buf))
+	if err != nil {
+		return errors.Wrap(err, "Error creating triggers request")
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := e.triggersClient.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "Error posting trigger params to %q", e.triggersURL)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("Triggers EventListener %q returned %d: %s", e.triggersURL, resp.StatusCode, msg)
+	}
+	logger.Infof("Posted trigger params to %q", e.triggersURL)
+	return nil
+}
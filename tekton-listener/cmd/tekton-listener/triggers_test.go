@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestTriggerBindingMode(t *testing.T) {
+	var gotMethod, gotContentType string
+	var gotBody map[string]string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotMethod = r.Method
+		gotContentType = r.Header.Get("Content-Type")
+		if err := json.NewDecoder(r.Body).Decode(&gotBody); err != nil {
+			t.Errorf("Error decoding trigger params: %s", err)
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer ts.Close()
+
+	e, _ := newTestListener()
+	e.mode = triggerBindingMode
+	e.triggersURL = ts.URL
+	e.triggersClient = ts.Client()
+	mappings, err := parseParamMappings("conclusion=.check_suite.conclusion")
+	if err != nil {
+		t.Fatalf("Error parsing mappings: %s", err)
+	}
+	e.paramMappings = mappings
+
+	event := newCheckSuiteEvent(t, "delivery-1234", "success", "abc123")
+	if err := e.HandleRequest(context.Background(), event); err != nil {
+		t.Fatalf("Unexpected error handling request: %s", err)
+	}
+
+	if gotMethod != http.MethodPost {
+		t.Errorf("Expected a POST, got %q", gotMethod)
+	}
+	if gotContentType != "application/json" {
+		t.Errorf("Expected content type application/json, got %q", gotContentType)
+	}
+	want := map[string]string{"revision": "abc123", "conclusion": "success"}
+	if !reflect.DeepEqual(gotBody, want) {
+		t.Errorf("Expected trigger params %v, got %v", want, gotBody)
+	}
+
+	runs, err := e.pipelineClientset.Tekton().PipelineRuns(e.namespace).List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("Error listing pipelineruns: %s", err)
+	}
+	if len(runs.Items) != 0 {
+		t.Errorf("Expected no pipelineruns in %s mode, got %d", triggerBindingMode, len(runs.Items))
+	}
+}
+
+func TestTriggerBindingModeError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "no trigger matched", http.StatusBadRequest)
+	}))
+	defer ts.Close()
+
+	e, _ := newTestListener()
+	e.mode = triggerBindingMode
+	e.triggersURL = ts.URL
+	e.triggersClient = ts.Client()
+
+	if err := e.trigger(context.Background(), "foo/bar", "abc123", nil); err == nil {
+		t.Error("Expected an error when the EventListener rejects the params")
+	}
+}
@@ -36,6 +36,12 @@ type TektonListenerSpec struct {
 	Namespace string `json:"namespace"`
 	// The spec of the desired pipeline run
 	PipelineRunSpec *pipelinev1alpha1.PipelineRunSpec `json:"runspec"`
+	// Labels to set on the pipeline runs, merged with those the listener adds
+	// +optional
+	RunLabels map[string]string `json:"runlabels,omitempty"`
+	// Annotations to set on the pipeline runs
+	// +optional
+	RunAnnotations map[string]string `json:"runannotations,omitempty"`
 	// The status of the listener
 	TektonListenerSpecStatus string `json:"pipelinespecstatus"`
 }
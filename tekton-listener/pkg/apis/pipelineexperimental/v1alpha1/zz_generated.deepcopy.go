@@ -216,6 +216,20 @@ func (in *TektonListenerSpec) DeepCopyInto(out *TektonListenerSpec) {
 		*out = new(pipelinev1alpha1.PipelineRunSpec)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.RunLabels != nil {
+		in, out := &in.RunLabels, &out.RunLabels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.RunAnnotations != nil {
+		in, out := &in.RunAnnotations, &out.RunAnnotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 	return
 }
 
@@ -14,6 +14,7 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"net/http"
 	"os"
 
@@ -29,13 +30,37 @@ func main() {
 		logging.Log.Fatalf("Fatal error creating resource: %s.", err.Error())
 	}
 
+	// Copy the webhooks of the unnamed install when a release name is set
+	if err := r.MigrateWebhooksToRelease(context.Background()); err != nil {
+		logging.Log.Fatalf("Fatal error copying webhooks to the release configmap: %s.", err.Error())
+	}
+
+	// Import the webhooks of the ConfigMap when webhooks are stored as Webhooks
+	if err := r.MigrateWebhooksToCRD(context.Background()); err != nil {
+		logging.Log.Fatalf("Fatal error importing webhooks from the configmap: %s.", err.Error())
+	}
+
+	// Remove webhooks whose GitHub source is deleted directly
+	go func() {
+		if err := r.WatchGitHubSources(make(chan struct{})); err != nil {
+			logging.Log.Errorf("error watching GitHub sources: %s.", err.Error())
+		}
+	}()
+
+	// Mint the installation tokens of GitHub App webhooks again before they expire
+	go r.RefreshGitHubAppTokens(make(chan struct{}))
+
 	// Set up routes
 	wsContainer := restful.NewContainer()
+	// Allow cross origin requests from the configured origins
+	wsContainer.Filter(endpoints.CORSFilter(r.Defaults))
 	// Add extension
 	wsContainer.Add(endpoints.ExtensionWebService(r))
 	// Add liveness/readiness
 	wsContainer.Add(endpoints.LivenessWebService())
 	wsContainer.Add(endpoints.ReadinessWebService())
+	// Add metrics
+	wsContainer.Add(endpoints.MetricsWebService())
 
 	// Serve
 	logging.Log.Info("Creating server and entering wait loop.")
@@ -46,5 +71,15 @@ func main() {
 		logging.Log.Infof("Port number from config: %s.", portnum)
 	}
 	server := &http.Server{Addr: port, Handler: wsContainer}
+	// Serve HTTPS when a certificate and key are configured
+	tlsConfig, err := endpoints.ServerTLSConfig(os.Getenv("TLS_CERT_FILE"), os.Getenv("TLS_KEY_FILE"))
+	if err != nil {
+		logging.Log.Fatalf("Fatal error configuring TLS: %s.", err.Error())
+	}
+	if tlsConfig != nil {
+		logging.Log.Info("Serving HTTPS.")
+		server.TLSConfig = tlsConfig
+		logging.Log.Fatal(server.ListenAndServeTLS("", ""))
+	}
 	logging.Log.Fatal(server.ListenAndServe())
 }
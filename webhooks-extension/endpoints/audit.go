@@ -0,0 +1,51 @@
+/*
+Copyright 2019 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+		http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package endpoints
+
+import (
+	logging "github.com/tektoncd/experimental/webhooks-extension/pkg/logging"
+	"time"
+
+	restful "github.com/emicklei/go-restful"
+)
+
+// actorHeader is the header naming the user making a request, as set by an authenticating proxy
+const actorHeader = "X-Forwarded-User"
+
+// auditMessage is the message of the log lines recording webhook lifecycle operations
+const auditMessage = "audit"
+
+// Operations recorded in the audit log
+const (
+	auditOperationCreate         = "create"
+	auditOperationDelete         = "delete"
+	auditOperationUpdateDefaults = "updatedefaults"
+	auditOperationRotateSecret   = "rotatesecret"
+	auditOperationPause          = "pause"
+	auditOperationUnpause        = "unpause"
+)
+
+// audit writes a structured log line recording that the request's actor completed operation on the named webhook.
+// The line carries the request ID like the other lines logged for the request.
+func audit(request *restful.Request, operation string, webhookName string) {
+	actor := request.HeaderParameter(actorHeader)
+	if actor == "" {
+		actor = "unknown"
+	}
+	logging.FromContext(request.Request.Context()).Infow(auditMessage,
+		"actor", actor,
+		"operation", operation,
+		"webhook", webhookName,
+		"timestamp", time.Now().UTC().Format(time.RFC3339))
+}
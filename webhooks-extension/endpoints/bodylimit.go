@@ -0,0 +1,61 @@
+/*
+Copyright 2019 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+		http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package endpoints
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	restful "github.com/emicklei/go-restful"
+	logging "github.com/tektoncd/experimental/webhooks-extension/pkg/logging"
+)
+
+// defaultMaxRequestBodyBytes bounds the size of a request body when no limit is configured. Webhooks are small,
+// so this leaves room for batches and imports of many of them.
+const defaultMaxRequestBodyBytes = 64 * 1024
+
+// maxRequestBodyBytes returns the largest request body that is read
+func (r Resource) maxRequestBodyBytes() int64 {
+	if r.Defaults.MaxRequestBodyBytes <= 0 {
+		return defaultMaxRequestBodyBytes
+	}
+	return r.Defaults.MaxRequestBodyBytes
+}
+
+// requestBodyLimitFilter reads the request body up to the body size limit before the route's handler runs,
+// responding with HTTP code 413 if the body is larger, so an oversized body is never decoded
+func (r Resource) requestBodyLimitFilter(request *restful.Request, response *restful.Response, chain *restful.FilterChain) {
+	if request.Request.Body == nil {
+		chain.ProcessFilter(request, response)
+		return
+	}
+	logger := logging.FromContext(request.Request.Context())
+	limit := r.maxRequestBodyBytes()
+	body, err := ioutil.ReadAll(http.MaxBytesReader(response.ResponseWriter, request.Request.Body, limit))
+	if err != nil {
+		status := http.StatusBadRequest
+		// the reader stops with an error once the limit is read and more remains
+		if int64(len(body)) >= limit {
+			err = fmt.Errorf("the request body is larger than the limit of %d bytes", limit)
+			status = http.StatusRequestEntityTooLarge
+		}
+		logger.Errorf("error reading the request body: %s.", err.Error())
+		RespondError(response, err, status)
+		return
+	}
+	request.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+	chain.ProcessFilter(request, response)
+}
@@ -0,0 +1,255 @@
+/*
+Copyright 2019 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+		http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package endpoints
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	logging "github.com/tektoncd/experimental/webhooks-extension/pkg/logging"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// configMapWriterAnnotation names the only replica allowed to write the webhooks ConfigMap, as set in its
+// ConfigMapWriter default. Any replica may write a ConfigMap without the annotation.
+const configMapWriterAnnotation = "webhooks.tekton.dev/writer"
+
+// configMapMovedAnnotation is set on the githubwebhook ConfigMap, once its entries are copied to the ConfigMap of
+// an install given a release name, to the name of that ConfigMap
+const configMapMovedAnnotation = "webhooks.tekton.dev/moved-to"
+
+// defaultConfigMapMaxRetries is how many times a failed ConfigMap read or write is retried when no limit is configured
+const defaultConfigMapMaxRetries = 3
+
+// configMapRetryDelay is how long the first retry of a ConfigMap read or write waits, each further retry waits
+// twice as long as the one before
+const configMapRetryDelay = 50 * time.Millisecond
+
+// errNotConfigMapWriter is returned when the webhooks ConfigMap names another replica as its writer
+var errNotConfigMapWriter = errors.New("the webhooks ConfigMap is written by another replica")
+
+// configMapMaxRetries returns how many times a failed ConfigMap read or write is retried
+func (r Resource) configMapMaxRetries() int {
+	if r.Defaults.ConfigMapMaxRetries <= 0 {
+		return defaultConfigMapMaxRetries
+	}
+	return r.Defaults.ConfigMapMaxRetries
+}
+
+// retryableConfigMapError returns whether a ConfigMap read or write that failed with err may succeed if retried:
+// the API server timed out or was too busy, or, for writes, the ConfigMap was written in between. The
+// extension's own API timeout is not retried as the request's time is already used up.
+func retryableConfigMapError(err error) bool {
+	return k8serrors.IsServerTimeout(err) || k8serrors.IsTimeout(err) || k8serrors.IsTooManyRequests(err) ||
+		k8serrors.IsConflict(err) || k8serrors.IsAlreadyExists(err)
+}
+
+// waitToRetryConfigMap waits before the given retry of a ConfigMap read or write, returning early with the
+// context's error if it is done first
+func waitToRetryConfigMap(ctx context.Context, retry int) error {
+	timer := time.NewTimer(configMapRetryDelay << uint(retry))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// getConfigMap reads the webhooks ConfigMap in namespace, retrying with backoff if the read fails with a
+// retryable error
+func (r Resource) getConfigMap(ctx context.Context, namespace string) (*corev1.ConfigMap, error) {
+	logger := logging.FromContext(ctx)
+	configMapClient := r.K8sClient.CoreV1().ConfigMaps(namespace)
+	for retries := 0; ; retries++ {
+		var configMap *corev1.ConfigMap
+		err := r.withAPITimeout(ctx, func() (err error) {
+			configMap, err = configMapClient.Get(r.configMapName(), metav1.GetOptions{})
+			return err
+		})
+		if err == nil || !retryableConfigMapError(err) || retries >= r.configMapMaxRetries() {
+			return configMap, err
+		}
+		logger.Infof("Error reading the configmap, retrying: %s.", err.Error())
+		if err := waitToRetryConfigMap(ctx, retries); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// writeConfigMapKey stores the value returned by update under key in the webhooks ConfigMap. update is passed the
+// value currently stored under key, and whether there is one. The ConfigMap is updated at the resourceVersion it
+// was read at, so a write made in between, by another replica or request, fails with a conflict. The ConfigMap is
+// then read again and update called again with the new value, keeping the changes written by others. Writes
+// failing with other retryable errors are retried too, each retry after a longer wait. An error returned by
+// update is returned without retrying.
+func (r Resource) writeConfigMapKey(ctx context.Context, namespace, key string, update func(value []byte, found bool) ([]byte, error)) error {
+	logger := logging.FromContext(ctx)
+	for retries := 0; ; retries++ {
+		err := r.updateConfigMapKey(ctx, namespace, key, update)
+		if err == nil {
+			return nil
+		}
+		if !retryableConfigMapError(err) {
+			logger.Errorf("error writing %s to the configmap: %s.", key, err.Error())
+			return err
+		}
+		if retries >= r.configMapMaxRetries() {
+			logger.Errorf("error writing %s to the configmap, giving up after %d retries: %s.", key, retries, err.Error())
+			return err
+		}
+		logger.Infof("Error writing %s to the configmap, retrying: %s.", key, err.Error())
+		if err := waitToRetryConfigMap(ctx, retries); err != nil {
+			return err
+		}
+	}
+}
+
+// updateConfigMapKey reads the webhooks ConfigMap and writes it back with the value returned by update under key,
+// creating it if it does not exist
+func (r Resource) updateConfigMapKey(ctx context.Context, namespace, key string, update func(value []byte, found bool) ([]byte, error)) error {
+	configMapClient := r.K8sClient.CoreV1().ConfigMaps(namespace)
+	var configMap *corev1.ConfigMap
+	err := r.withAPITimeout(ctx, func() (err error) {
+		configMap, err = configMapClient.Get(r.configMapName(), metav1.GetOptions{})
+		return err
+	})
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return err
+	}
+	var create = false
+	if err != nil {
+		configMap = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      r.configMapName(),
+				Namespace: namespace,
+			},
+		}
+		create = true
+	}
+	if writer, ok := configMap.Annotations[configMapWriterAnnotation]; ok && writer != r.Defaults.ConfigMapWriter {
+		return errNotConfigMapWriter
+	}
+	value, found := configMapValue(configMap, key)
+	buf, err := update(value, found)
+	if err != nil {
+		return err
+	}
+	if configMap.Data == nil {
+		configMap.Data = make(map[string]string)
+	}
+	configMap.Data[key] = string(buf)
+	// A key must not be present in both Data and BinaryData, so drop any old format entry
+	delete(configMap.BinaryData, key)
+	if create {
+		_, err = configMapClient.Create(configMap)
+		return err
+	}
+	_, err = configMapClient.Update(configMap)
+	return err
+}
+
+// configMapValue returns the value stored under key in configMap, and whether there is one
+func configMapValue(configMap *corev1.ConfigMap, key string) ([]byte, bool) {
+	if data, ok := configMap.Data[key]; ok {
+		return []byte(data), true
+	}
+	// Entries written by older versions of the extension are stored under BinaryData
+	value, ok := configMap.BinaryData[key]
+	return value, ok
+}
+
+// MigrateWebhooksToRelease copies the entries of the githubwebhook ConfigMap in the install namespace to this
+// install's ConfigMap when it has a release name, so that setting RELEASE_NAME on an existing install keeps its
+// webhooks. Only an install without a ConfigMap of its own copies the entries. The githubwebhook ConfigMap is first
+// annotated with the name of the install's ConfigMap, at the resourceVersion it was read at, so that only one
+// install copies them. It is kept, for an older version of the extension to read.
+func (r Resource) MigrateWebhooksToRelease(ctx context.Context) error {
+	if r.configMapName() == ConfigMapName {
+		return nil
+	}
+	logger := logging.FromContext(ctx)
+	// Install namespace
+	installNs := r.Defaults.Namespace
+	if installNs == "" {
+		installNs = "default"
+	}
+	configMapClient := r.K8sClient.CoreV1().ConfigMaps(installNs)
+	_, err := r.getConfigMap(ctx, installNs)
+	if err == nil {
+		return nil
+	}
+	if !k8serrors.IsNotFound(err) {
+		logger.Errorf("error getting configmap %s: %s.", r.configMapName(), err.Error())
+		return err
+	}
+	var legacy *corev1.ConfigMap
+	err = r.withAPITimeout(ctx, func() (err error) {
+		legacy, err = configMapClient.Get(ConfigMapName, metav1.GetOptions{})
+		return err
+	})
+	if k8serrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		logger.Errorf("error getting configmap %s to copy webhooks from: %s.", ConfigMapName, err.Error())
+		return err
+	}
+
+	// an install whose copy failed after claiming the entries copies them again when restarted
+	movedTo, ok := legacy.Annotations[configMapMovedAnnotation]
+	if ok && movedTo != r.configMapName() {
+		logger.Infof("Not copying the webhooks of configmap %s, they were copied to configmap %s.", ConfigMapName, movedTo)
+		return nil
+	}
+	if !ok {
+		if legacy.Annotations == nil {
+			legacy.Annotations = make(map[string]string)
+		}
+		legacy.Annotations[configMapMovedAnnotation] = r.configMapName()
+		legacy, err = configMapClient.Update(legacy)
+		// another install, or replica of this one, may have claimed the entries since they were read
+		if k8serrors.IsConflict(err) {
+			return r.MigrateWebhooksToRelease(ctx)
+		}
+		if err != nil {
+			logger.Errorf("error marking configmap %s as copied: %s.", ConfigMapName, err.Error())
+			return err
+		}
+	}
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      r.configMapName(),
+			Namespace: installNs,
+		},
+		Data:       legacy.Data,
+		BinaryData: legacy.BinaryData,
+	}
+	_, err = configMapClient.Create(configMap)
+	// another replica of this install may have copied the entries first
+	if k8serrors.IsAlreadyExists(err) {
+		return nil
+	}
+	if err != nil {
+		logger.Errorf("error copying configmap %s to configmap %s: %s.", ConfigMapName, configMap.Name, err.Error())
+		return err
+	}
+	logger.Infof("Copied the webhooks of configmap %s to configmap %s.", ConfigMapName, configMap.Name)
+	return nil
+}
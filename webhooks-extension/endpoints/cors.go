@@ -0,0 +1,75 @@
+/*
+Copyright 2019 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+		http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package endpoints
+
+import (
+	"net/http"
+	"strings"
+
+	restful "github.com/emicklei/go-restful"
+)
+
+// Methods and headers allowed in cross origin requests when none are configured
+var (
+	defaultCORSAllowedMethods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete}
+	defaultCORSAllowedHeaders = []string{"Content-Type", requestIDHeader}
+)
+
+// splitList splits a comma separated list, dropping empty entries
+func splitList(s string) []string {
+	list := []string{}
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// CORSFilter returns a container filter adding the CORS headers to requests from the allowed origins. With no
+// allowed origins only same origin requests can be made by browsers. It is added to the container rather than a
+// web service because no route handles the OPTIONS preflight requests, which the filter answers itself.
+func CORSFilter(defaults EnvDefaults) restful.FilterFunction {
+	methods := defaults.CORSAllowedMethods
+	if len(methods) == 0 {
+		methods = defaultCORSAllowedMethods
+	}
+	headers := defaults.CORSAllowedHeaders
+	if len(headers) == 0 {
+		headers = defaultCORSAllowedHeaders
+	}
+	origins := map[string]bool{}
+	for _, origin := range defaults.CORSAllowedOrigins {
+		origins[origin] = true
+	}
+
+	return func(request *restful.Request, response *restful.Response, chain *restful.FilterChain) {
+		origin := request.HeaderParameter("Origin")
+		if origin == "" || !origins[origin] {
+			chain.ProcessFilter(request, response)
+			return
+		}
+		response.AddHeader("Access-Control-Allow-Origin", origin)
+		response.AddHeader("Vary", "Origin")
+		if request.Request.Method == http.MethodOptions && request.HeaderParameter("Access-Control-Request-Method") != "" {
+			response.AddHeader("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+			response.AddHeader("Access-Control-Allow-Headers", strings.Join(headers, ", "))
+			response.WriteHeader(http.StatusNoContent)
+			return
+		}
+		// the request ID is returned on every response, let the dashboard read it
+		response.AddHeader("Access-Control-Expose-Headers", requestIDHeader)
+		chain.ProcessFilter(request, response)
+	}
+}
@@ -0,0 +1,264 @@
+/*
+Copyright 2019 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+		http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package endpoints
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	logging "github.com/tektoncd/experimental/webhooks-extension/pkg/logging"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// The Webhook custom resource stores one webhook, under its spec, when webhooks are stored as Webhooks. It has no
+// generated clientset, so it is managed through the dynamic client.
+var webhookResource = schema.GroupVersionResource{
+	Group:    "webhooks.tekton.dev",
+	Version:  "v1alpha1",
+	Resource: "webhooks",
+}
+
+// webhookStorageCRD is the WEBHOOK_STORAGE that stores webhooks as Webhooks rather than in the webhooks ConfigMap
+const webhookStorageCRD = "crd"
+
+// webhookStoreLabel is set on each Webhook to the name of its install's webhooks ConfigMap, so that installs with
+// different release names in one namespace keep separate webhook lists
+const webhookStoreLabel = "webhooks.tekton.dev/store"
+
+// configMapMigratedAnnotation is set on the webhooks ConfigMap once its webhooks are imported as Webhooks
+const configMapMigratedAnnotation = "webhooks.tekton.dev/migrated"
+
+// storesWebhookCRs returns whether webhooks are stored as Webhooks
+func (r Resource) storesWebhookCRs() bool {
+	return r.Defaults.WebhookStorage == webhookStorageCRD
+}
+
+// webhookCRName returns the name of the Webhook storing the named webhook. The Webhooks of an install with a
+// release name are prefixed with its ConfigMap's name, so they don't collide with those of other installs.
+func (r Resource) webhookCRName(name string) string {
+	if r.configMapName() == ConfigMapName {
+		return name
+	}
+	return r.configMapName() + "." + name
+}
+
+// webhookCR returns the Webhook storing hook in namespace
+func (r Resource) webhookCR(namespace string, hook webhook) (*unstructured.Unstructured, error) {
+	spec, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&hook)
+	if err != nil {
+		return nil, err
+	}
+	entry := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": webhookResource.GroupVersion().String(),
+			"kind":       "Webhook",
+			"spec":       spec,
+		},
+	}
+	entry.SetName(r.webhookCRName(hook.Name))
+	entry.SetNamespace(namespace)
+	entry.SetLabels(map[string]string{webhookStoreLabel: r.configMapName()})
+	return entry, nil
+}
+
+// listWebhookCRs returns the Webhooks of this install in namespace
+func (r Resource) listWebhookCRs(ctx context.Context, namespace string) ([]unstructured.Unstructured, error) {
+	var list *unstructured.UnstructuredList
+	err := r.withAPITimeout(ctx, func() (err error) {
+		list, err = r.DynamicClient.Resource(webhookResource).Namespace(namespace).List(metav1.ListOptions{
+			LabelSelector: webhookStoreLabel + "=" + r.configMapName(),
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+// decodeWebhookCRs returns the webhooks stored in entries by name
+func decodeWebhookCRs(entries []unstructured.Unstructured) (map[string]webhook, error) {
+	result := make(map[string]webhook, len(entries))
+	for _, entry := range entries {
+		spec, found, err := unstructured.NestedMap(entry.Object, "spec")
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			return nil, fmt.Errorf("webhook %s has no spec", entry.GetName())
+		}
+		var hook webhook
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(spec, &hook); err != nil {
+			return nil, err
+		}
+		result[hook.Name] = hook
+	}
+	return result, nil
+}
+
+// readWebhookCRs returns the webhooks stored as Webhooks in namespace
+func (r Resource) readWebhookCRs(ctx context.Context, namespace string) (map[string]webhook, error) {
+	entries, err := r.listWebhookCRs(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+	return decodeWebhookCRs(entries)
+}
+
+// updateWebhookCRs changes the webhooks stored as Webhooks in namespace with mutate, then creates, updates and
+// deletes Webhooks to match. Each Webhook is created only if absent, and updated at the resourceVersion it was
+// listed at, so a write made in between, by another replica or request, fails. The Webhooks are then listed again
+// and mutate called again, as for the webhooks ConfigMap. The Webhooks written before the failed write are kept,
+// so mutate is then passed them as stored.
+func (r Resource) updateWebhookCRs(ctx context.Context, namespace string, mutate func(webhooks map[string]webhook) error) error {
+	logger := logging.FromContext(ctx)
+	for retries := 0; ; retries++ {
+		err := r.applyWebhookCRs(ctx, namespace, mutate)
+		if err == nil {
+			return nil
+		}
+		if !retryableConfigMapError(err) {
+			logger.Errorf("error writing webhooks: %s.", err.Error())
+			return err
+		}
+		if retries >= r.configMapMaxRetries() {
+			logger.Errorf("error writing webhooks, giving up after %d retries: %s.", retries, err.Error())
+			return err
+		}
+		logger.Infof("Error writing webhooks, retrying: %s.", err.Error())
+		if err := waitToRetryConfigMap(ctx, retries); err != nil {
+			return err
+		}
+	}
+}
+
+// applyWebhookCRs lists the Webhooks in namespace, changes their webhooks with mutate and writes the changed ones
+func (r Resource) applyWebhookCRs(ctx context.Context, namespace string, mutate func(webhooks map[string]webhook) error) error {
+	entries, err := r.listWebhookCRs(ctx, namespace)
+	if err != nil {
+		return err
+	}
+	stored, err := decodeWebhookCRs(entries)
+	if err != nil {
+		return err
+	}
+	// mutate is passed a copy of its own, so the webhooks it changes are told apart from the stored ones
+	webhooks, err := decodeWebhookCRs(entries)
+	if err != nil {
+		return err
+	}
+	if err := mutate(webhooks); err != nil {
+		return err
+	}
+	resourceVersions := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		resourceVersions[entry.GetName()] = entry.GetResourceVersion()
+	}
+
+	client := r.DynamicClient.Resource(webhookResource).Namespace(namespace)
+	for name, hook := range webhooks {
+		old, ok := stored[name]
+		if ok && reflect.DeepEqual(old, hook) {
+			continue
+		}
+		entry, err := r.webhookCR(namespace, hook)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			_, err = client.Create(entry, metav1.CreateOptions{})
+		} else {
+			entry.SetResourceVersion(resourceVersions[entry.GetName()])
+			_, err = client.Update(entry, metav1.UpdateOptions{})
+		}
+		// a Webhook deleted since it was listed is written again once the Webhooks are listed again
+		if ok && k8serrors.IsNotFound(err) {
+			return k8serrors.NewConflict(webhookResource.GroupResource(), entry.GetName(), err)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	for name := range stored {
+		if _, ok := webhooks[name]; ok {
+			continue
+		}
+		err := client.Delete(r.webhookCRName(name), &metav1.DeleteOptions{})
+		if err != nil && !k8serrors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// MigrateWebhooksToCRD imports the webhooks stored in the install namespace's webhooks ConfigMap as Webhooks, when
+// webhooks are stored as Webhooks. The ConfigMap is then annotated so that its webhooks are only imported once.
+// Its webhooks are kept, for an older version of the extension to read, and any already imported are not replaced.
+func (r Resource) MigrateWebhooksToCRD(ctx context.Context) error {
+	if !r.storesWebhookCRs() {
+		return nil
+	}
+	logger := logging.FromContext(ctx)
+	// Install namespace
+	installNs := r.Defaults.Namespace
+	if installNs == "" {
+		installNs = "default"
+	}
+	configMap, err := r.getConfigMap(ctx, installNs)
+	if k8serrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		logger.Errorf("error getting configmap to import webhooks from: %s.", err.Error())
+		return err
+	}
+	if _, ok := configMap.Annotations[configMapMigratedAnnotation]; ok {
+		return nil
+	}
+	webhooks, err := decodeGitHubWebhooks(configMapValue(configMap, configMapKey))
+	if err != nil {
+		logger.Errorf("error unmarshalling webhooks to import: %s.", err.Error())
+		return err
+	}
+
+	client := r.DynamicClient.Resource(webhookResource).Namespace(installNs)
+	for name, hook := range webhooks {
+		entry, err := r.webhookCR(installNs, hook)
+		if err != nil {
+			return err
+		}
+		_, err = client.Create(entry, metav1.CreateOptions{})
+		if k8serrors.IsAlreadyExists(err) {
+			logger.Infof("Webhook %s was already imported from the configmap.", name)
+			continue
+		}
+		if err != nil {
+			logger.Errorf("error importing webhook %s from the configmap: %s.", name, err.Error())
+			return err
+		}
+		logger.Infof("Imported webhook %s from the configmap.", name)
+	}
+
+	if configMap.Annotations == nil {
+		configMap.Annotations = make(map[string]string)
+	}
+	configMap.Annotations[configMapMigratedAnnotation] = "true"
+	_, err = r.K8sClient.CoreV1().ConfigMaps(installNs).Update(configMap)
+	return err
+}
@@ -0,0 +1,126 @@
+/*
+Copyright 2019 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+		http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package endpoints
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	logging "github.com/tektoncd/experimental/webhooks-extension/pkg/logging"
+	"net/http"
+	"regexp"
+
+	restful "github.com/emicklei/go-restful"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// dockerRegistryPattern matches a registry host with an optional port followed by optional path components,
+// for example docker.io/myorg or registry.example.com:5000/team/images
+var dockerRegistryPattern = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9.-]*[a-zA-Z0-9])?(:[0-9]+)?(/[a-z0-9]+([._-][a-z0-9]+)*)*$`)
+
+// validateDockerRegistry checks that a docker registry is a registry location in the form host[:port][/path]
+func validateDockerRegistry(dockerRegistry string) error {
+	if !dockerRegistryPattern.MatchString(dockerRegistry) {
+		return fmt.Errorf("requested docker registry (%s) is not a valid registry location, expected a host with an optional port and path, for example docker.io/myorg", dockerRegistry)
+	}
+	return nil
+}
+
+func (r Resource) getDefaults(request *restful.Request, response *restful.Response) {
+	ctx := request.Request.Context()
+	logger := logging.FromContext(ctx)
+	defaults := r.getStoredDefaults(ctx)
+	logger.Debugf("getDefaults returning: %v", defaults)
+	response.WriteEntity(defaults)
+}
+
+// updateDefaults sets the defaults that can be changed at runtime, currently the docker registry, storing them
+// in the webhooks ConfigMap so that they survive restarts, and returns the new defaults
+func (r Resource) updateDefaults(request *restful.Request, response *restful.Response) {
+	ctx := request.Request.Context()
+	logger := logging.FromContext(ctx)
+	update := EnvDefaults{}
+	if err := request.ReadEntity(&update); err != nil {
+		logger.Errorf("error trying to read request entity as defaults: %s.", err)
+		RespondError(response, err, http.StatusBadRequest)
+		return
+	}
+
+	if update.Namespace != "" && update.Namespace != r.Defaults.Namespace {
+		err := fmt.Errorf("the install namespace (%s) can not be changed", r.Defaults.Namespace)
+		logger.Errorf("error: %s", err.Error())
+		RespondError(response, err, http.StatusBadRequest)
+		return
+	}
+	if err := validateDockerRegistry(update.DockerRegistry); err != nil {
+		logger.Errorf("error: %s", err.Error())
+		RespondError(response, err, http.StatusBadRequest)
+		return
+	}
+
+	defaults := r.getStoredDefaults(ctx)
+	defaults.DockerRegistry = update.DockerRegistry
+	if err := r.writeStoredDefaults(ctx, defaults); err != nil {
+		RespondError(response, err, apiErrorStatus(err, http.StatusInternalServerError))
+		return
+	}
+	logger.Infof("Updated defaults to: %v", defaults)
+	audit(request, auditOperationUpdateDefaults, "")
+	response.WriteEntity(defaults)
+}
+
+// getStoredDefaults returns the defaults from the environment with any defaults updated at runtime applied
+func (r Resource) getStoredDefaults(ctx context.Context) EnvDefaults {
+	logger := logging.FromContext(ctx)
+	defaults := r.Defaults
+	configMap, err := r.getConfigMap(ctx, r.defaultsNamespace())
+	if err != nil {
+		if !k8serrors.IsNotFound(err) {
+			logger.Errorf("error getting configmap for defaults, using environment defaults: %s.", err.Error())
+		}
+		return defaults
+	}
+	data, ok := configMap.Data[defaultsConfigMapKey]
+	if !ok {
+		return defaults
+	}
+	stored := EnvDefaults{}
+	if err := json.Unmarshal([]byte(data), &stored); err != nil {
+		logger.Errorf("error unmarshalling stored defaults, using environment defaults: %s.", err.Error())
+		return defaults
+	}
+	if stored.DockerRegistry != "" {
+		defaults.DockerRegistry = stored.DockerRegistry
+	}
+	return defaults
+}
+
+func (r Resource) writeStoredDefaults(ctx context.Context, defaults EnvDefaults) error {
+	buf, err := json.Marshal(defaults)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("error marshalling defaults: %s.", err.Error())
+		return err
+	}
+	return r.writeConfigMapKey(ctx, r.defaultsNamespace(), defaultsConfigMapKey, func([]byte, bool) ([]byte, error) {
+		return buf, nil
+	})
+}
+
+// defaultsNamespace returns the install namespace, which holds the webhooks ConfigMap
+func (r Resource) defaultsNamespace() string {
+	if r.Defaults.Namespace == "" {
+		return "default"
+	}
+	return r.Defaults.Namespace
+}
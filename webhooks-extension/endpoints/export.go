@@ -0,0 +1,97 @@
+/*
+Copyright 2019 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+		http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package endpoints
+
+import (
+	"io/ioutil"
+	"net/http"
+	"sort"
+
+	restful "github.com/emicklei/go-restful"
+	"github.com/ghodss/yaml"
+	logging "github.com/tektoncd/experimental/webhooks-extension/pkg/logging"
+)
+
+// exportMIMEType is the content type of the exported webhooks
+const exportMIMEType = "application/x-yaml"
+
+// exportWebhooks responds with every webhook of the install namespace as a YAML list, sorted by name. The list
+// holds the webhooks as stored, which is all that is needed to create their event sources again with an import.
+// The secrets they reference are not exported.
+func (r Resource) exportWebhooks(request *restful.Request, response *restful.Response) {
+	ctx := request.Request.Context()
+	logger := logging.FromContext(ctx)
+	// Install namespace
+	installNs := r.Defaults.Namespace
+	if installNs == "" {
+		installNs = "default"
+	}
+
+	sources, err := r.readGitHubWebhooks(ctx, installNs)
+	if err != nil {
+		logger.Errorf("error trying to get webhooks: %s.", err.Error())
+		RespondError(response, err, apiErrorStatus(err, http.StatusInternalServerError))
+		return
+	}
+	hooks := []webhook{}
+	for _, hook := range sources {
+		hook.Status, hook.InstallNamespace = nil, ""
+		hooks = append(hooks, hook)
+	}
+	sort.Slice(hooks, func(i, j int) bool { return hooks[i].Name < hooks[j].Name })
+
+	// the YAML is written from the JSON encoding so the field names match the rest of the API
+	buf, err := yaml.Marshal(hooks)
+	if err != nil {
+		logger.Errorf("error marshalling webhooks: %s.", err.Error())
+		RespondError(response, err, http.StatusInternalServerError)
+		return
+	}
+	response.AddHeader("Content-Type", exportMIMEType)
+	response.WriteHeader(http.StatusOK)
+	response.Write(buf)
+}
+
+// importWebhooks creates the webhooks of a YAML list, as exported, or a JSON list. As with a batch create, it
+// responds with a result per webhook, and webhooks identical to an existing one succeed without being created
+// again so the same list can be imported more than once.
+func (r Resource) importWebhooks(request *restful.Request, response *restful.Response) {
+	ctx := request.Request.Context()
+	logger := logging.FromContext(ctx)
+	// Install namespace
+	installNs := r.Defaults.Namespace
+	if installNs == "" {
+		installNs = "default"
+	}
+
+	if status, err := r.checkInstallNamespace(ctx, installNs); err != nil {
+		RespondError(response, err, status)
+		return
+	}
+
+	body, err := ioutil.ReadAll(request.Request.Body)
+	if err != nil {
+		logger.Errorf("error reading the imported webhooks: %s.", err.Error())
+		RespondError(response, err, http.StatusBadRequest)
+		return
+	}
+	// JSON is valid YAML, so both are read the same way
+	batch := []webhook{}
+	if err := yaml.Unmarshal(body, &batch); err != nil {
+		logger.Errorf("error trying to read the imported webhooks: %s.", err.Error())
+		RespondError(response, err, http.StatusBadRequest)
+		return
+	}
+	r.createBatch(request, response, installNs, batch)
+}
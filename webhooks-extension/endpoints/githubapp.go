@@ -0,0 +1,332 @@
+/*
+Copyright 2019 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+		http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package endpoints
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	eventapi "github.com/knative/eventing-sources/pkg/apis/sources/v1alpha1"
+	logging "github.com/tektoncd/experimental/webhooks-extension/pkg/logging"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Authentication modes for creating GitHub webhooks, a personal access token is used when no mode is given
+const (
+	authModePAT       = "pat"
+	authModeGitHubApp = "githubapp"
+)
+
+// gitHubAppKeyName is the key of the GitHub App private key in the secret referenced by a webhook
+const gitHubAppKeyName = "privateKey"
+
+// defaultGitHubAPIURL is used to mint installation tokens for github.com repositories
+const defaultGitHubAPIURL = "https://api.github.com/"
+
+// gitHubAPIClient is the http client used to call the GitHub API
+var gitHubAPIClient = &http.Client{Timeout: 30 * time.Second}
+
+// validateGitHubApp checks the GitHub App fields of a webhook using the githubapp auth mode, recording each that is
+// missing in errs
+func validateGitHubApp(webhook webhook, errs *validationErrors) {
+	if webhook.GitHubAppID == 0 {
+		errs.add("githubappid", errors.New("githubappid is required when using the githubapp auth mode"))
+	}
+	if webhook.GitHubAppInstallationID == 0 {
+		errs.add("githubappinstallationid", errors.New("githubappinstallationid is required when using the githubapp auth mode"))
+	}
+	if webhook.GitHubAppKeySecret == "" {
+		errs.add("githubappkeysecret", errors.New("githubappkeysecret is required when using the githubapp auth mode"))
+	}
+	// the extension writes the installation token secret itself, with the default keys
+	if webhook.AccessTokenKey != "" || webhook.SecretTokenKey != "" {
+		errs.add("accesstokenkey", errors.New("accesstokenkey and secrettokenkey can not be set when using the githubapp auth mode"))
+	}
+	// the installation token secret always holds a generated secret token
+	if webhook.NoSecret {
+		errs.add("nosecret", errors.New("nosecret can not be set when using the githubapp auth mode"))
+	}
+}
+
+// gitHubAppTokenSecretName returns the name of the secret holding the installation token for a webhook
+func gitHubAppTokenSecretName(webhook webhook) string {
+	return webhook.Name + "-github-app-token"
+}
+
+// gitHubAppTokenRefreshPeriod is how often the installation tokens of webhooks using the githubapp auth mode are
+// minted again, well within the hour GitHub installation tokens expire after
+const gitHubAppTokenRefreshPeriod = 30 * time.Minute
+
+// createGitHubAppTokenSecret exchanges the webhook's GitHub App credentials for an installation token and
+// stores it in a secret the GitHubSource can reference. The secret name is returned.
+func (r Resource) createGitHubAppTokenSecret(ctx context.Context, webhook webhook, gitHubAPIURL, installNs string) (string, error) {
+	logger := logging.FromContext(ctx)
+	token, err := r.mintGitHubAppToken(ctx, webhook, gitHubAPIURL, installNs)
+	if err != nil {
+		return "", err
+	}
+	secretToken, err := generateSecretToken()
+	if err != nil {
+		return "", err
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      gitHubAppTokenSecretName(webhook),
+			Namespace: installNs,
+		},
+		StringData: map[string]string{
+			defaultAccessTokenKey: token,
+			defaultSecretTokenKey: secretToken,
+		},
+	}
+	secretsClient := r.K8sClient.CoreV1().Secrets(installNs)
+	_, err = secretsClient.Create(secret)
+	if k8serrors.IsAlreadyExists(err) {
+		_, err = secretsClient.Update(secret)
+	}
+	if err != nil {
+		logger.Errorf("error writing GitHub App token secret %s: %s.", secret.Name, err.Error())
+		return "", err
+	}
+	return secret.Name, nil
+}
+
+// mintGitHubAppToken returns a new installation token for a webhook using the githubapp auth mode, authenticating
+// with the private key in the webhook's GitHub App key secret
+func (r Resource) mintGitHubAppToken(ctx context.Context, webhook webhook, gitHubAPIURL, installNs string) (string, error) {
+	logger := logging.FromContext(ctx)
+	var keySecret *corev1.Secret
+	err := r.withAPITimeout(ctx, func() (err error) {
+		keySecret, err = r.K8sClient.CoreV1().Secrets(installNs).Get(webhook.GitHubAppKeySecret, metav1.GetOptions{})
+		return err
+	})
+	if err != nil {
+		logger.Errorf("error getting GitHub App private key secret %s: %s.", webhook.GitHubAppKeySecret, err.Error())
+		return "", err
+	}
+	privateKey, ok := keySecret.Data[gitHubAppKeyName]
+	if !ok {
+		return "", fmt.Errorf("secret %s does not contain the key %s", webhook.GitHubAppKeySecret, gitHubAppKeyName)
+	}
+
+	token, err := mintInstallationToken(gitHubAPIURL, webhook.GitHubAppID, webhook.GitHubAppInstallationID, privateKey)
+	if err != nil {
+		logger.Errorf("error minting GitHub App installation token: %s.", err.Error())
+		return "", err
+	}
+	return token, nil
+}
+
+// gitHubAppAPIURL returns the GitHub API URL installation tokens for a webhook's repository are minted with
+func gitHubAppAPIURL(webhook webhook) (string, error) {
+	apiURL, _, err := getGitHubValues(webhook.GitRepositoryURL)
+	if err != nil {
+		return "", err
+	}
+	// github.com webhooks use the public API rather than the derived URL
+	if apiURL == "" {
+		return defaultGitHubAPIURL, nil
+	}
+	return apiURL, nil
+}
+
+// refreshGitHubAppToken replaces the installation token in the token secret of a webhook using the githubapp auth
+// mode with a newly minted one, returning it. The secret token GitHub signs deliveries with is kept.
+func (r Resource) refreshGitHubAppToken(ctx context.Context, webhook webhook, installNs string) (string, error) {
+	logger := logging.FromContext(ctx)
+	gitHubAPIURL, err := gitHubAppAPIURL(webhook)
+	if err != nil {
+		return "", err
+	}
+	token, err := r.mintGitHubAppToken(ctx, webhook, gitHubAPIURL, installNs)
+	if err != nil {
+		return "", err
+	}
+	name := gitHubAppTokenSecretName(webhook)
+	secretsClient := r.K8sClient.CoreV1().Secrets(installNs)
+	var secret *corev1.Secret
+	err = r.withAPITimeout(ctx, func() (err error) {
+		secret, err = secretsClient.Get(name, metav1.GetOptions{})
+		return err
+	})
+	if err != nil {
+		logger.Errorf("error getting GitHub App token secret %s: %s.", name, err.Error())
+		return "", err
+	}
+	// string data only replaces the keys it holds
+	if secret.StringData == nil {
+		secret.StringData = make(map[string]string)
+	}
+	secret.StringData[defaultAccessTokenKey] = token
+	if _, err := secretsClient.Update(secret); err != nil {
+		logger.Errorf("error writing GitHub App token secret %s: %s.", name, err.Error())
+		return "", err
+	}
+	return token, nil
+}
+
+// ownGitHubAppTokenSecret makes a webhook's GitHubSources the owners of its GitHub App token secret, so that the
+// secret is garbage collected along with the webhook. It is only collected once every source is deleted, after
+// the sources' finalizers have used the token to delete the webhooks registered with GitHub.
+func (r Resource) ownGitHubAppTokenSecret(ctx context.Context, webhook webhook, installNs string, sources []*eventapi.GitHubSource) error {
+	name := gitHubAppTokenSecretName(webhook)
+	secretsClient := r.K8sClient.CoreV1().Secrets(installNs)
+	var secret *corev1.Secret
+	err := r.withAPITimeout(ctx, func() (err error) {
+		secret, err = secretsClient.Get(name, metav1.GetOptions{})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	secret.OwnerReferences = nil
+	for _, source := range sources {
+		secret.OwnerReferences = append(secret.OwnerReferences, metav1.OwnerReference{
+			APIVersion: eventapi.SchemeGroupVersion.String(),
+			Kind:       "GitHubSource",
+			Name:       source.Name,
+			UID:        source.UID,
+		})
+	}
+	_, err = secretsClient.Update(secret)
+	return err
+}
+
+// RefreshGitHubAppTokens mints a new installation token for each webhook in the install namespace using the
+// githubapp auth mode every gitHubAppTokenRefreshPeriod, as GitHub installation tokens expire after an hour and
+// the GitHubSource of a webhook uses its token to delete the webhook registered with GitHub. It runs until stopCh
+// is closed.
+func (r Resource) RefreshGitHubAppTokens(stopCh <-chan struct{}) {
+	installNs := r.Defaults.Namespace
+	if installNs == "" {
+		installNs = "default"
+	}
+	ticker := time.NewTicker(gitHubAppTokenRefreshPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			r.refreshGitHubAppTokens(context.Background(), installNs)
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+// refreshGitHubAppTokens mints a new installation token for each unpaused webhook using the githubapp auth mode
+func (r Resource) refreshGitHubAppTokens(ctx context.Context, installNs string) {
+	logger := logging.FromContext(ctx)
+	webhooks, err := r.readGitHubWebhooks(ctx, installNs)
+	if err != nil {
+		logger.Errorf("error getting GitHub webhooks to refresh GitHub App tokens: %s.", err.Error())
+		return
+	}
+	for name, hook := range webhooks {
+		// a paused webhook's token is minted again when its source is
+		if hook.AuthMode != authModeGitHubApp || hook.Paused {
+			continue
+		}
+		if _, err := r.refreshGitHubAppToken(ctx, hook, installNs); err != nil {
+			logger.Errorf("error refreshing the GitHub App token of webhook %s: %s.", name, err.Error())
+		}
+	}
+}
+
+// mintInstallationToken authenticates as the GitHub App and returns a short-lived installation access token
+func mintInstallationToken(gitHubAPIURL string, appID, installationID int64, privateKeyPEM []byte) (string, error) {
+	jwt, err := gitHubAppJWT(appID, privateKeyPEM, time.Now())
+	if err != nil {
+		return "", err
+	}
+
+	tokenURL := fmt.Sprintf("%s/app/installations/%d/access_tokens", strings.TrimSuffix(gitHubAPIURL, "/"), installationID)
+	req, err := http.NewRequest(http.MethodPost, tokenURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+jwt)
+	req.Header.Set("Accept", "application/vnd.github.machine-man-preview+json")
+
+	resp, err := gitHubAPIClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return "", fmt.Errorf("unexpected status %d requesting an installation token for installation %d", resp.StatusCode, installationID)
+	}
+
+	result := struct {
+		Token string `json:"token"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", err
+	}
+	if result.Token == "" {
+		return "", fmt.Errorf("no token returned for installation %d", installationID)
+	}
+	return result.Token, nil
+}
+
+// gitHubAppJWT returns a JWT signed with the GitHub App's private key, valid for nine minutes
+func gitHubAppJWT(appID int64, privateKeyPEM []byte, now time.Time) (string, error) {
+	block, _ := pem.Decode(privateKeyPEM)
+	if block == nil {
+		return "", errors.New("GitHub App private key is not PEM encoded")
+	}
+	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		return "", fmt.Errorf("parsing GitHub App private key: %s", err.Error())
+	}
+
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	// Backdate the issued at time to allow for clock drift
+	claims, _ := json.Marshal(map[string]interface{}{
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": appID,
+	})
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	hash := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+	if err != nil {
+		return "", err
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// generateSecretToken returns a random token used to sign webhook deliveries
+func generateSecretToken() (string, error) {
+	buf := make([]byte, 20)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,121 @@
+/*
+Copyright 2019 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+		http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package endpoints
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	logging "github.com/tektoncd/experimental/webhooks-extension/pkg/logging"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// The GitLabSource lives in the knative eventing-sources contrib tree which has no generated clientset,
+// so it is managed through the dynamic client
+var gitLabSourceResource = schema.GroupVersionResource{
+	Group:    "sources.eventing.knative.dev",
+	Version:  "v1alpha1",
+	Resource: "gitlabsources",
+}
+
+// getGitLabValues returns the API URL and the project path of a GitLab repository URL.
+// GitLab projects can be nested in groups and subgroups, so the project path is everything
+// after the host, e.g. group/subgroup/project
+func getGitLabValues(gitRepositoryURL string) (apiURL, projectPath string, err error) {
+	u, err := url.Parse(gitRepositoryURL)
+	if err != nil {
+		return "", "", err
+	}
+	projectPath = strings.TrimSuffix(strings.Trim(u.Path, "/"), ".git")
+	if u.Host == "" || !strings.Contains(projectPath, "/") {
+		return "", "", fmt.Errorf("GitRepositoryURL format error (%s)", gitRepositoryURL)
+	}
+	apiURL = fmt.Sprintf("%s://%s/api/v4/", u.Scheme, u.Host)
+	return apiURL, projectPath, nil
+}
+
+// createGitLabSource creates the GitLabSource for a webhook, returning how its URL was interpreted
+// and the http status to respond with on error
+func (r Resource) createGitLabSource(ctx context.Context, webhook webhook, installNs string) (createResult, int, error) {
+	logger := logging.FromContext(ctx)
+	apiURL, projectPath, err := getGitLabValues(webhook.GitRepositoryURL)
+	if err != nil {
+		logger.Errorf("error creating webhook: %s.", err.Error())
+		return createResult{}, http.StatusUnprocessableEntity, err
+	}
+	u, _ := url.Parse(webhook.GitRepositoryURL)
+	projectURL := fmt.Sprintf("%s://%s/%s", u.Scheme, u.Host, projectPath)
+
+	logger.Debugf("Creating GitLab source with apiURL: %s and project: %s.", apiURL, projectPath)
+
+	entry := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": gitLabSourceResource.GroupVersion().String(),
+			"kind":       "GitLabSource",
+			"spec": map[string]interface{}{
+				"projectUrl": projectURL,
+				"eventTypes": webhook.gitLabEventTypes(),
+				"accessToken": map[string]interface{}{
+					"secretKeyRef": map[string]interface{}{
+						"name": webhook.AccessTokenRef,
+						"key":  webhook.accessTokenKey(),
+					},
+				},
+			},
+		},
+	}
+	if !webhook.NoSecret {
+		entry.Object["spec"].(map[string]interface{})["secretToken"] = map[string]interface{}{
+			"secretKeyRef": map[string]interface{}{
+				"name": webhook.AccessTokenRef,
+				"key":  webhook.secretTokenKey(),
+			},
+		}
+	}
+	if webhook.SourceServiceAccount != "" {
+		entry.Object["spec"].(map[string]interface{})["serviceAccountName"] = webhook.SourceServiceAccount
+	}
+	if labels := webhook.sourceLabels(); len(labels) > 0 {
+		entry.SetLabels(labels)
+	}
+	if len(webhook.Annotations) > 0 {
+		entry.SetAnnotations(webhook.Annotations)
+	}
+	if ownerRef := r.getSourceOwnerReference(ctx, installNs); ownerRef != nil {
+		entry.SetOwnerReferences([]metav1.OwnerReference{*ownerRef})
+	}
+	status, err := r.createEventSources(ctx, webhook, installNs, "GitLab source", func(source eventSource) error {
+		sourceEntry := entry.DeepCopy()
+		sourceEntry.SetName(source.Name)
+		sink := map[string]interface{}{
+			"apiVersion": source.Sink.APIVersion,
+			"kind":       source.Sink.Kind,
+			"name":       source.Sink.Name,
+		}
+		if err := unstructured.SetNestedMap(sourceEntry.Object, sink, "spec", "sink"); err != nil {
+			return err
+		}
+		_, err := r.DynamicClient.Resource(gitLabSourceResource).Namespace(installNs).Create(sourceEntry, metav1.CreateOptions{})
+		return err
+	})
+	if err != nil {
+		return createResult{}, status, err
+	}
+	return createResult{APIURL: apiURL, OwnerRepo: projectPath}, http.StatusCreated, nil
+}
@@ -0,0 +1,88 @@
+/*
+Copyright 2019 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+		http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package endpoints
+
+import (
+	"strconv"
+	"time"
+
+	restful "github.com/emicklei/go-restful"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Operations counted in the metrics, one for each route of the webhooks web service
+const (
+	metricsOperationCreate         = "create"
+	metricsOperationBatchCreate    = "batchcreate"
+	metricsOperationValidate       = "validate"
+	metricsOperationGet            = "get"
+	metricsOperationGetDefaults    = "getdefaults"
+	metricsOperationUpdateDefaults = "updatedefaults"
+	metricsOperationDelete         = "delete"
+	metricsOperationRotateSecret   = "rotatesecret"
+	metricsOperationExport         = "export"
+	metricsOperationImport         = "import"
+	metricsOperationPause          = "pause"
+	metricsOperationUnpause        = "unpause"
+	metricsOperationPipelineRuns   = "pipelineruns"
+	metricsOperationTestDelivery   = "testdelivery"
+)
+
+var (
+	// operationsTotal counts the requests handled by the webhooks web service by operation and response code
+	operationsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "webhooks_extension",
+		Name:      "operations_total",
+		Help:      "Number of webhook operations handled, by operation and HTTP response code.",
+	}, []string{"operation", "code"})
+
+	// configMapDuration observes how long reading and writing the webhooks ConfigMap takes
+	configMapDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "webhooks_extension",
+		Name:      "configmap_duration_seconds",
+		Help:      "Time taken to read or write the webhooks ConfigMap.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation"})
+)
+
+func init() {
+	prometheus.MustRegister(operationsTotal, configMapDuration)
+}
+
+// instrument counts the requests handled by handler as operation, by the status code responded with
+func instrument(operation string, handler restful.RouteFunction) restful.RouteFunction {
+	return func(request *restful.Request, response *restful.Response) {
+		handler(request, response)
+		operationsTotal.WithLabelValues(operation, strconv.Itoa(response.StatusCode())).Inc()
+	}
+}
+
+// observeConfigMap records the time since start taken by a read or write of the webhooks ConfigMap
+func observeConfigMap(operation string, start time.Time) {
+	configMapDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+}
+
+func serveMetrics(request *restful.Request, response *restful.Response) {
+	promhttp.Handler().ServeHTTP(response.ResponseWriter, request.Request)
+}
+
+// MetricsWebService returns the web service serving the extension's Prometheus metrics
+func MetricsWebService() *restful.WebService {
+	ws := new(restful.WebService)
+	ws.Path("/metrics")
+	ws.Route(ws.GET("").To(serveMetrics))
+
+	return ws
+}
@@ -0,0 +1,85 @@
+/*
+Copyright 2019 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+		http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package endpoints
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	logging "github.com/tektoncd/experimental/webhooks-extension/pkg/logging"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// orgHookScopes are the OAuth scopes, any of which lets a token manage an organization's webhooks
+var orgHookScopes = []string{"admin:org_hook", "admin:org"}
+
+// gitHubOrganization returns the organization of a GitHub URL that is an organization's root, with no
+// repository segment, such as https://github.com/myorg
+func gitHubOrganization(gitRepositoryURL string) (string, bool) {
+	parsed, err := url.Parse(gitRepositoryURL)
+	if err != nil {
+		return "", false
+	}
+	segments := strings.Split(strings.Trim(parsed.Path, "/"), "/")
+	if len(segments) != 1 || segments[0] == "" {
+		return "", false
+	}
+	return segments[0], true
+}
+
+// checkOrgHookScope checks that the access token of an organization webhook may manage the organization's
+// webhooks. GitHub lists the OAuth scopes of a personal access token in the X-OAuth-Scopes header of API
+// responses; when the token or its scopes can not be read the event source reports any failure instead.
+func (r Resource) checkOrgHookScope(ctx context.Context, hook webhook, gitHubAPIURL, installNs string) error {
+	logger := logging.FromContext(ctx)
+	var secret *corev1.Secret
+	err := r.withAPITimeout(ctx, func() (err error) {
+		secret, err = r.K8sClient.CoreV1().Secrets(installNs).Get(hook.AccessTokenRef, metav1.GetOptions{})
+		return err
+	})
+	if err != nil {
+		logger.Infof("Not checking the scopes of the access token of webhook %s: %s.", hook.Name, err.Error())
+		return nil
+	}
+	accessToken := string(secret.Data[hook.accessTokenKey()])
+
+	req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(gitHubAPIURL, "/")+"/user", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "token "+accessToken)
+	resp, err := gitHubAPIClient.Do(req)
+	if err != nil {
+		logger.Infof("Not checking the scopes of the access token of webhook %s: %s.", hook.Name, err.Error())
+		return nil
+	}
+	defer resp.Body.Close()
+	header, ok := resp.Header["X-Oauth-Scopes"]
+	if resp.StatusCode != http.StatusOK || !ok {
+		logger.Infof("Not checking the scopes of the access token of webhook %s: no scopes returned.", hook.Name)
+		return nil
+	}
+	for _, scope := range splitList(strings.Join(header, ",")) {
+		for _, required := range orgHookScopes {
+			if scope == required {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("the access token in secret %s needs the %s scope to create a webhook for organization %s", hook.AccessTokenRef, strings.Join(orgHookScopes, " or "), hook.Organization)
+}
@@ -0,0 +1,138 @@
+/*
+Copyright 2019 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+		http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package endpoints
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	restful "github.com/emicklei/go-restful"
+	logging "github.com/tektoncd/experimental/webhooks-extension/pkg/logging"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// createSourceUnlessPaused creates the event source for a webhook unless it is paused, in which case only
+// how its URL would be interpreted is returned
+func (r Resource) createSourceUnlessPaused(ctx context.Context, webhook webhook, installNs string) (createResult, int, error) {
+	if !webhook.Paused {
+		return r.createSource(ctx, webhook, installNs)
+	}
+	logging.FromContext(ctx).Infof("Webhook %s is paused, not creating its event source.", webhook.Name)
+	result, err := interpretRepositoryURL(webhook)
+	if err != nil {
+		return createResult{}, http.StatusUnprocessableEntity, err
+	}
+	return result, 0, nil
+}
+
+// pauseWebhook deletes the event source of the named webhook, keeping the webhook so that its source can be
+// created again by unpausing it.
+func (r Resource) pauseWebhook(request *restful.Request, response *restful.Response) {
+	r.setWebhookPaused(request, response, true)
+}
+
+// unpauseWebhook creates the event source of the named paused webhook again. Unpausing a webhook that is not
+// paused does nothing.
+func (r Resource) unpauseWebhook(request *restful.Request, response *restful.Response) {
+	r.setWebhookPaused(request, response, false)
+}
+
+// setWebhookPaused sets whether the named webhook is paused, responding with the webhook
+func (r Resource) setWebhookPaused(request *restful.Request, response *restful.Response, paused bool) {
+	ctx := request.Request.Context()
+	logger := logging.FromContext(ctx)
+	// Install namespace
+	installNs := r.Defaults.Namespace
+	if installNs == "" {
+		installNs = "default"
+	}
+
+	name := request.PathParameter("name")
+	webhooks, err := r.readGitHubWebhooks(ctx, installNs)
+	if err != nil {
+		logger.Errorf("error getting GitHub webhooks: %s.", err.Error())
+		RespondError(response, err, apiErrorStatus(err, http.StatusInternalServerError))
+		return
+	}
+	hook, ok := webhooks[name]
+	if !ok {
+		err := fmt.Errorf("could not find webhook named %s", name)
+		logger.Errorf("error: %s.", err.Error())
+		RespondError(response, err, http.StatusNotFound)
+		return
+	}
+	if paused {
+		// the webhook is stored as paused first, so the source watch does not remove it when its source is deleted.
+		// The source of a paused webhook is deleted again, in case deleting it failed when it was paused.
+		if !hook.Paused {
+			hook.Paused = true
+			if status, err := r.storeWebhookPaused(ctx, installNs, name, true); err != nil {
+				RespondError(response, err, status)
+				return
+			}
+		}
+		if err := r.deleteSource(ctx, hook, installNs); err != nil && !k8serrors.IsNotFound(err) {
+			logger.Errorf("error deleting source %s: %s.", name, err.Error())
+			RespondError(response, err, apiErrorStatus(err, http.StatusInternalServerError))
+			return
+		}
+		audit(request, auditOperationPause, name)
+		logger.Infof("Paused webhook %s.", name)
+		response.WriteEntity(hook)
+		return
+	}
+
+	if !hook.Paused {
+		logger.Infof("Webhook %s is not paused.", name)
+		response.WriteEntity(hook)
+		return
+	}
+	hook.Paused = false
+	if _, status, err := r.createSource(ctx, hook, installNs); err != nil {
+		RespondError(response, err, status)
+		return
+	}
+	if status, err := r.storeWebhookPaused(ctx, installNs, name, false); err != nil {
+		RespondError(response, err, status)
+		return
+	}
+	audit(request, auditOperationUnpause, name)
+	logger.Infof("Unpaused webhook %s.", name)
+	response.WriteEntity(hook)
+}
+
+// storeWebhookPaused stores whether the named webhook is paused, returning the http status to respond with if it
+// fails. The webhook is looked up again when it is written, in case it was changed or deleted in between.
+func (r Resource) storeWebhookPaused(ctx context.Context, installNs, name string, paused bool) (int, error) {
+	logger := logging.FromContext(ctx)
+	found := true
+	err := r.updateGitHubWebhooks(ctx, installNs, func(webhooks map[string]webhook) error {
+		var hook webhook
+		if hook, found = webhooks[name]; !found {
+			return fmt.Errorf("could not find webhook named %s", name)
+		}
+		hook.Paused = paused
+		webhooks[name] = hook
+		return nil
+	})
+	if err != nil {
+		logger.Errorf("error writing GitHub webhooks: %s.", err.Error())
+		if !found {
+			return http.StatusNotFound, err
+		}
+		return apiErrorStatus(err, http.StatusInternalServerError), err
+	}
+	return http.StatusOK, nil
+}
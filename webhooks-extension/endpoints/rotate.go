@@ -0,0 +1,205 @@
+/*
+Copyright 2019 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+		http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package endpoints
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	restful "github.com/emicklei/go-restful"
+	logging "github.com/tektoncd/experimental/webhooks-extension/pkg/logging"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// rotateSecretResult is the response to rotating a webhook's secret token, the only time the new token is returned
+type rotateSecretResult struct {
+	SecretToken string `json:"secrettoken"`
+	// RemoteUpdated is whether the secret of the webhook registered with the git provider was updated too
+	RemoteUpdated bool   `json:"remoteupdated"`
+	RemoteError   string `json:"remoteerror,omitempty"`
+}
+
+// rotateWebhookSecret generates a new secret token for the named webhook and stores it in the secret its event
+// source references, so the source does not need to be recreated. For GitHub the registered webhook is updated
+// to sign deliveries with the new token.
+func (r Resource) rotateWebhookSecret(request *restful.Request, response *restful.Response) {
+	ctx := request.Request.Context()
+	logger := logging.FromContext(ctx)
+	// Install namespace
+	installNs := r.Defaults.Namespace
+	if installNs == "" {
+		installNs = "default"
+	}
+
+	name := request.PathParameter("name")
+	webhooks, err := r.readGitHubWebhooks(ctx, installNs)
+	if err != nil {
+		logger.Errorf("error getting GitHub webhooks: %s.", err.Error())
+		RespondError(response, err, apiErrorStatus(err, http.StatusInternalServerError))
+		return
+	}
+	hook, ok := webhooks[name]
+	if !ok {
+		err := fmt.Errorf("could not find webhook named %s", name)
+		logger.Errorf("error: %s.", err.Error())
+		RespondError(response, err, http.StatusNotFound)
+		return
+	}
+	if hook.NoSecret {
+		err := fmt.Errorf("webhook %s has no secret token to rotate", name)
+		logger.Errorf("error: %s.", err.Error())
+		RespondError(response, err, http.StatusBadRequest)
+		return
+	}
+
+	secretToken, err := generateSecretToken()
+	if err != nil {
+		logger.Errorf("error generating secret token: %s.", err.Error())
+		RespondError(response, err, http.StatusInternalServerError)
+		return
+	}
+	accessToken, status, err := r.writeSecretToken(ctx, hook, installNs, secretToken)
+	if err != nil {
+		RespondError(response, err, status)
+		return
+	}
+	audit(request, auditOperationRotateSecret, name)
+
+	result := rotateSecretResult{SecretToken: secretToken}
+	if hook.Provider != providerGitLab {
+		// each of the webhook's sources has registered a webhook of its own
+		result.RemoteUpdated = true
+		for _, source := range hook.eventSources() {
+			if err := r.updateGitHubWebhookSecret(ctx, hook, source.Name, installNs, accessToken, secretToken); err != nil {
+				logger.Errorf("error updating the GitHub webhook secret for %s: %s.", name, err.Error())
+				result.RemoteUpdated, result.RemoteError = false, err.Error()
+				break
+			}
+		}
+	}
+	logger.Infof("Rotated the secret token of webhook %s.", name)
+	response.WriteEntity(result)
+}
+
+// tokenSecretName returns the name of the secret holding the webhook's access and secret tokens
+func tokenSecretName(hook webhook) string {
+	if hook.AuthMode == authModeGitHubApp {
+		return gitHubAppTokenSecretName(hook)
+	}
+	return hook.AccessTokenRef
+}
+
+// writeSecretToken overwrites the secret token key of the webhook's token secret, returning the access token
+// held alongside it. On error the http status to respond with is returned.
+func (r Resource) writeSecretToken(ctx context.Context, hook webhook, installNs, secretToken string) (string, int, error) {
+	logger := logging.FromContext(ctx)
+	name := tokenSecretName(hook)
+	secretsClient := r.K8sClient.CoreV1().Secrets(installNs)
+	var secret *corev1.Secret
+	err := r.withAPITimeout(ctx, func() (err error) {
+		secret, err = secretsClient.Get(name, metav1.GetOptions{})
+		return err
+	})
+	if k8serrors.IsNotFound(err) {
+		err = fmt.Errorf("the secret %s does not exist in namespace %s", name, installNs)
+		logger.Errorf("error: %s.", err.Error())
+		return "", http.StatusUnprocessableEntity, err
+	}
+	if err != nil {
+		logger.Errorf("error getting the secret %s: %s.", name, err.Error())
+		return "", apiErrorStatus(err, http.StatusInternalServerError), err
+	}
+
+	accessToken := string(secret.Data[hook.accessTokenKey()])
+	if hook.AuthMode == authModeGitHubApp {
+		// the installation token stored may have expired
+		gitHubAPIURL, err := gitHubAppAPIURL(hook)
+		if err != nil {
+			return "", http.StatusInternalServerError, err
+		}
+		if accessToken, err = r.mintGitHubAppToken(ctx, hook, gitHubAPIURL, installNs); err != nil {
+			return "", apiErrorStatus(err, http.StatusInternalServerError), err
+		}
+		if secret.StringData == nil {
+			secret.StringData = map[string]string{}
+		}
+		secret.StringData[hook.accessTokenKey()] = accessToken
+	}
+	if secret.Data == nil {
+		secret.Data = map[string][]byte{}
+	}
+	secret.Data[hook.secretTokenKey()] = []byte(secretToken)
+	_, err = secretsClient.Update(secret)
+	if err != nil {
+		logger.Errorf("error writing the secret %s: %s.", name, err.Error())
+		return "", apiErrorStatus(err, http.StatusInternalServerError), err
+	}
+	return accessToken, http.StatusOK, nil
+}
+
+// updateGitHubWebhookSecret sets the secret of the webhook GitHub delivers events to the named GitHubSource with.
+// The webhook's ID is taken from the source's status.
+func (r Resource) updateGitHubWebhookSecret(ctx context.Context, hook webhook, sourceName, installNs, accessToken, secretToken string) error {
+	var webhookID, gitHubAPIURL string
+	err := r.withAPITimeout(ctx, func() error {
+		source, err := r.EventSrcClient.SourcesV1alpha1().GitHubSources(installNs).Get(sourceName, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		webhookID = source.Status.WebhookIDKey
+		gitHubAPIURL = source.Spec.GitHubAPIURL
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if webhookID == "" {
+		return fmt.Errorf("the GitHub source %s has not registered a webhook", sourceName)
+	}
+	if gitHubAPIURL == "" {
+		gitHubAPIURL = defaultGitHubAPIURL
+	}
+	_, ownerRepo, err := getGitHubValues(hook.GitRepositoryURL)
+	if err != nil {
+		return err
+	}
+
+	body, _ := json.Marshal(map[string]string{"secret": secretToken})
+	configURL := fmt.Sprintf("%s/repos/%s/hooks/%s/config", strings.TrimSuffix(gitHubAPIURL, "/"), ownerRepo, webhookID)
+	if hook.Organization != "" {
+		configURL = fmt.Sprintf("%s/orgs/%s/hooks/%s/config", strings.TrimSuffix(gitHubAPIURL, "/"), hook.Organization, webhookID)
+	}
+	req, err := http.NewRequest(http.MethodPatch, configURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "token "+accessToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := gitHubAPIClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d updating webhook %s of %s", resp.StatusCode, webhookID, ownerRepo)
+	}
+	return nil
+}
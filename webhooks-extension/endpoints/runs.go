@@ -0,0 +1,144 @@
+/*
+Copyright 2019 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+		http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package endpoints
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	restful "github.com/emicklei/go-restful"
+	logging "github.com/tektoncd/experimental/webhooks-extension/pkg/logging"
+	v1alpha1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// webhookLabel is set on the PipelineRuns the sink creates to the name of the webhook they were created for
+const webhookLabel = "webhooks.tekton.dev/webhook"
+
+// defaultPipelineRunsLimit is how many of a webhook's PipelineRuns are returned when no limit is requested
+const defaultPipelineRunsLimit = 10
+
+// succeededConditionType is the type of the condition reporting whether a PipelineRun succeeded
+const succeededConditionType = "Succeeded"
+
+// pipelineRunSummary describes one of a webhook's PipelineRuns
+type pipelineRunSummary struct {
+	Name string `json:"name"`
+	// Status is the status of the run's Succeeded condition, True, False or Unknown, and Unknown while the
+	// run has not started
+	Status    string       `json:"status"`
+	Reason    string       `json:"reason,omitempty"`
+	StartTime *metav1.Time `json:"starttime,omitempty"`
+}
+
+// getWebhookPipelineRuns returns the most recent PipelineRuns created for the named webhook, newest first, up
+// to the requested limit
+func (r Resource) getWebhookPipelineRuns(request *restful.Request, response *restful.Response) {
+	ctx := request.Request.Context()
+	logger := logging.FromContext(ctx)
+	// Install namespace
+	installNs := r.Defaults.Namespace
+	if installNs == "" {
+		installNs = "default"
+	}
+
+	limit := defaultPipelineRunsLimit
+	if value := request.QueryParameter("limit"); value != "" {
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			err := fmt.Errorf("limit must be a positive number, but was %s", value)
+			logger.Errorf("error: %s.", err.Error())
+			RespondError(response, err, http.StatusBadRequest)
+			return
+		}
+	}
+
+	name := request.PathParameter("name")
+	webhooks, err := r.readGitHubWebhooks(ctx, installNs)
+	if err != nil {
+		logger.Errorf("error getting GitHub webhooks: %s.", err.Error())
+		RespondError(response, err, apiErrorStatus(err, http.StatusInternalServerError))
+		return
+	}
+	hook, ok := webhooks[name]
+	if !ok {
+		err := fmt.Errorf("could not find webhook named %s", name)
+		logger.Errorf("error: %s.", err.Error())
+		RespondError(response, err, http.StatusNotFound)
+		return
+	}
+
+	runs, err := r.listWebhookPipelineRuns(ctx, hook)
+	if err != nil {
+		logger.Errorf("error listing PipelineRuns of webhook %s: %s.", name, err.Error())
+		RespondError(response, err, apiErrorStatus(err, http.StatusInternalServerError))
+		return
+	}
+	response.WriteEntity(summarizePipelineRuns(runs, limit))
+}
+
+// listWebhookPipelineRuns returns the PipelineRuns the sink created for a webhook, found by webhookLabel in the
+// webhook's namespace
+func (r Resource) listWebhookPipelineRuns(ctx context.Context, hook webhook) ([]v1alpha1.PipelineRun, error) {
+	var runs *v1alpha1.PipelineRunList
+	err := r.withAPITimeout(ctx, func() (err error) {
+		runs, err = r.TektonClient.TektonV1alpha1().PipelineRuns(hook.Namespace).List(metav1.ListOptions{
+			LabelSelector: fmt.Sprintf("%s=%s", webhookLabel, hook.Name),
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return runs.Items, nil
+}
+
+// summarizePipelineRuns returns summaries of up to limit of runs, newest first. Runs that have not started are
+// ordered by when they were created.
+func summarizePipelineRuns(runs []v1alpha1.PipelineRun, limit int) []pipelineRunSummary {
+	started := func(run v1alpha1.PipelineRun) metav1.Time {
+		if run.Status.StartTime != nil {
+			return *run.Status.StartTime
+		}
+		return run.CreationTimestamp
+	}
+	sort.SliceStable(runs, func(i, j int) bool {
+		newer, older := started(runs[i]), started(runs[j])
+		return older.Before(&newer)
+	})
+	if len(runs) > limit {
+		runs = runs[:limit]
+	}
+	summaries := []pipelineRunSummary{}
+	for _, run := range runs {
+		summary := pipelineRunSummary{
+			Name:      run.Name,
+			Status:    string(corev1.ConditionUnknown),
+			StartTime: run.Status.StartTime,
+		}
+		for _, condition := range run.Status.Conditions {
+			if string(condition.Type) == succeededConditionType {
+				summary.Status = string(condition.Status)
+				summary.Reason = condition.Reason
+			}
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries
+}
@@ -20,11 +20,18 @@ import (
 	restful "github.com/emicklei/go-restful"
 	eventsrcclient "github.com/knative/eventing-sources/pkg/client/clientset/versioned/fake"
 	fakeclientset "github.com/tektoncd/pipeline/pkg/client/clientset/versioned/fake"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakedynamic "k8s.io/client-go/dynamic/fake"
 	fakek8sclientset "k8s.io/client-go/kubernetes/fake"
 )
 
 func dummyK8sClientset() *fakek8sclientset.Clientset {
-	result := fakek8sclientset.NewSimpleClientset()
+	// event sources are created in the install namespace, which defaults to "default"
+	result := fakek8sclientset.NewSimpleClientset(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}})
 	return result
 }
 
@@ -37,6 +44,14 @@ func dummyEventSrcClient() *eventsrcclient.Clientset {
 	return result
 }
 
+func dummyDynamicClient() *fakedynamic.FakeDynamicClient {
+	scheme := runtime.NewScheme()
+	// The fake client lists resources as a v1 List, whose items its tracker looks up as the scheme's ListList
+	scheme.AddKnownTypeWithName(schema.GroupVersionKind{Version: "v1", Kind: "ListList"}, &unstructured.UnstructuredList{})
+	result := fakedynamic.NewSimpleDynamicClient(scheme)
+	return result
+}
+
 func dummyHTTPRequest(method string, url string, body io.Reader) *http.Request {
 	httpReq, _ := http.NewRequest(method, url, body)
 	httpReq.Header.Set("Content-Type", "application/json")
@@ -74,6 +89,7 @@ func updateResourceDefaults(r *Resource, newDefaults EnvDefaults) *Resource {
 		K8sClient:      r.K8sClient,
 		TektonClient:   r.TektonClient,
 		EventSrcClient: r.EventSrcClient,
+		DynamicClient:  r.DynamicClient,
 		Defaults:       newDefaults,
 	}
 	return &newResource
@@ -84,6 +100,7 @@ func dummyResource() *Resource {
 		K8sClient:      dummyK8sClientset(),
 		TektonClient:   dummyClientset(),
 		EventSrcClient: dummyEventSrcClient(),
+		DynamicClient:  dummyDynamicClient(),
 		Defaults:       dummyDefaults(),
 	}
 	return &resource
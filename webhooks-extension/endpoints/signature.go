@@ -0,0 +1,168 @@
+/*
+Copyright 2019 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+		http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package endpoints
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"net/http"
+	"strings"
+
+	logging "github.com/tektoncd/experimental/webhooks-extension/pkg/logging"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ErrInvalidSignature is returned when a delivery's signature does not match the secret token of its webhook
+var ErrInvalidSignature = errors.New("the delivery signature does not match the webhook's secret token")
+
+// VerifyWebhookSignature checks a delivery forwarded for the webhook of a repository, or of its organization,
+// against the secret token stored for it. For GitHub the signature is the X-Hub-Signature header, sha1=<hex HMAC>, or the
+// X-Hub-Signature-256 header, sha256=<hex HMAC>, of the payload. GitLab does not sign deliveries, so for GitLab
+// the signature is the X-Gitlab-Token header, which must be the token itself. ErrWebhookNotFound is returned if
+// there is no webhook for the repository and ErrInvalidSignature if the signature does not match. Deliveries for
+// a webhook without a secret token can't be checked, and are reported with an error.
+func (r Resource) VerifyWebhookSignature(ctx context.Context, repoURL string, payload []byte, signature string) error {
+	// Install namespace
+	installNs := r.Defaults.Namespace
+	if installNs == "" {
+		installNs = "default"
+	}
+
+	hook, err := r.getWebhookForEvent(ctx, repoURL, installNs)
+	if err != nil {
+		return err
+	}
+	if hook.NoSecret {
+		return fmt.Errorf("webhook %s has no secret token to check deliveries against", hook.Name)
+	}
+	return r.verifyHookSignature(ctx, hook, installNs, payload, signature)
+}
+
+// verifyDelivery checks a delivery received by the sink for the webhook of repoURL against the webhook's secret
+// token, returning the http status to refuse the delivery with if it fails. The signature is read from the
+// delivery's header, see deliverySignature. Deliveries for a webhook without a secret token are not signed, and
+// are accepted.
+func (r Resource) verifyDelivery(ctx context.Context, repoURL string, payload []byte, header http.Header) (int, error) {
+	// Install namespace
+	installNs := r.Defaults.Namespace
+	if installNs == "" {
+		installNs = "default"
+	}
+
+	hook, err := r.getWebhookForEvent(ctx, repoURL, installNs)
+	if err == ErrWebhookNotFound {
+		return http.StatusNotFound, err
+	}
+	if err != nil {
+		return apiErrorStatus(err, http.StatusInternalServerError), err
+	}
+	if hook.NoSecret {
+		return http.StatusOK, nil
+	}
+	err = r.verifyHookSignature(ctx, hook, installNs, payload, deliverySignature(hook, header))
+	if err == ErrInvalidSignature {
+		return http.StatusUnauthorized, err
+	}
+	if err != nil {
+		return apiErrorStatus(err, http.StatusInternalServerError), err
+	}
+	return http.StatusOK, nil
+}
+
+// deliverySignature returns the signature of a delivery for the webhook from the delivery's header: the
+// X-Gitlab-Token for GitLab, otherwise X-Hub-Signature-256, or X-Hub-Signature if GitHub only sent that
+func deliverySignature(hook webhook, header http.Header) string {
+	if hook.Provider == providerGitLab {
+		return header.Get("X-Gitlab-Token")
+	}
+	if signature := header.Get("X-Hub-Signature-256"); signature != "" {
+		return signature
+	}
+	return header.Get("X-Hub-Signature")
+}
+
+// verifyHookSignature checks a delivery's signature against the secret token of the webhook
+func (r Resource) verifyHookSignature(ctx context.Context, hook webhook, installNs string, payload []byte, signature string) error {
+	secretToken, err := r.readSecretToken(ctx, hook, installNs)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("error reading the secret token of webhook %s: %s.", hook.Name, err.Error())
+		return err
+	}
+
+	if hook.Provider == providerGitLab {
+		if subtle.ConstantTimeCompare([]byte(signature), []byte(secretToken)) != 1 {
+			return ErrInvalidSignature
+		}
+		return nil
+	}
+	return verifyHMACSignature(payload, signature, secretToken)
+}
+
+// signDelivery returns the X-Hub-Signature-256 GitHub would send a payload with, signed with secretToken
+func signDelivery(payload []byte, secretToken string) string {
+	mac := hmac.New(sha256.New, []byte(secretToken))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// verifyHMACSignature checks a GitHub signature, the hex HMAC of payload keyed with secretToken prefixed with
+// the name of the hash
+func verifyHMACSignature(payload []byte, signature, secretToken string) error {
+	var newHash func() hash.Hash
+	switch {
+	case strings.HasPrefix(signature, "sha1="):
+		newHash = sha1.New
+	case strings.HasPrefix(signature, "sha256="):
+		newHash = sha256.New
+	default:
+		return ErrInvalidSignature
+	}
+	expected, err := hex.DecodeString(signature[strings.Index(signature, "=")+1:])
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	mac := hmac.New(newHash, []byte(secretToken))
+	mac.Write(payload)
+	if !hmac.Equal(mac.Sum(nil), expected) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// readSecretToken returns the secret token key of the webhook's token secret
+func (r Resource) readSecretToken(ctx context.Context, hook webhook, installNs string) (string, error) {
+	name := tokenSecretName(hook)
+	var secret *corev1.Secret
+	err := r.withAPITimeout(ctx, func() (err error) {
+		secret, err = r.K8sClient.CoreV1().Secrets(installNs).Get(name, metav1.GetOptions{})
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	// the API server returns the secret's stringData merged into its data
+	token, ok := secret.Data[hook.secretTokenKey()]
+	if !ok {
+		return "", fmt.Errorf("the secret %s has no %s", name, hook.secretTokenKey())
+	}
+	return string(token), nil
+}
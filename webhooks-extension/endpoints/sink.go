@@ -14,12 +14,15 @@ limitations under the License.
 package endpoints
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	restful "github.com/emicklei/go-restful"
 	logging "github.com/tektoncd/experimental/webhooks-extension/pkg/logging"
 	v1alpha1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1alpha1"
 	gh "gopkg.in/go-playground/webhooks.v3/github"
+	"io/ioutil"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"net/http"
 	"os"
@@ -45,31 +48,46 @@ type BuildInformation struct {
 
 // handleWebhook should be called when we hit the / endpoint with webhook data. Todo provide proper responses e.g. 503, server errors, 200 if good
 func (r Resource) handleWebhook(request *restful.Request, response *restful.Response) {
-	logging.Log.Info("In HandleWebhook for a GitHub event...")
+	ctx := request.Request.Context()
+	logger := logging.FromContext(ctx)
+	logger.Info("In HandleWebhook for a GitHub event...")
 	buildInformation := BuildInformation{}
-	logging.Log.Infof("Github event name to look for is: %s.", githubEventParameter)
+	logger.Infof("Github event name to look for is: %s.", githubEventParameter)
 	gitHubEventType := request.HeaderParameter(githubEventParameter)
 
 	if len(gitHubEventType) < 1 {
-		logging.Log.Errorf("error found header (%s) exists but has no value. Request is: %+v.", githubEventParameter, request)
+		logger.Errorf("error found header (%s) exists but has no value. Request is: %+v.", githubEventParameter, request)
 		return
 	}
 
 	gitHubEventTypeString := strings.Replace(gitHubEventType, "\"", "", -1)
 
-	logging.Log.Debugf("GitHub event type is: %s.", gitHubEventTypeString)
+	logger.Debugf("GitHub event type is: %s.", gitHubEventTypeString)
 
 	timestamp := getDateTimeAsString()
 
+	// the payload is read as sent, as its signature is checked against it
+	payload, err := ioutil.ReadAll(request.Request.Body)
+	if err != nil {
+		logger.Errorf("error reading webhook data: %s.", err.Error())
+		response.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
 	if gitHubEventTypeString == "ping" {
 		response.WriteHeader(http.StatusNoContent)
 	} else if gitHubEventTypeString == "push" {
-		logging.Log.Info("Handling a push event.")
+		logger.Info("Handling a push event.")
 
 		webhookData := gh.PushPayload{}
 
-		if err := request.ReadEntity(&webhookData); err != nil {
-			logging.Log.Errorf("error decoding webhook data: %s.", err.Error())
+		if err := json.Unmarshal(payload, &webhookData); err != nil {
+			logger.Errorf("error decoding webhook data: %s.", err.Error())
+			return
+		}
+		if status, err := r.verifyDelivery(ctx, webhookData.Repository.URL, payload, request.Request.Header); err != nil {
+			logger.Errorf("error verifying the delivery for repository %s: %s.", webhookData.Repository.URL, err.Error())
+			response.WriteHeader(status)
 			return
 		}
 
@@ -79,16 +97,21 @@ func (r Resource) handleWebhook(request *restful.Request, response *restful.Resp
 		buildInformation.REPONAME = webhookData.Repository.Name
 		buildInformation.TIMESTAMP = timestamp
 
-		createPipelineRunFromWebhookData(buildInformation, r)
-		logging.Log.Debugf("Build information for repository %s:%s: %s.", buildInformation.REPOURL, buildInformation.SHORTID, buildInformation)
+		createPipelineRunFromWebhookData(ctx, buildInformation, r)
+		logger.Debugf("Build information for repository %s:%s: %s.", buildInformation.REPOURL, buildInformation.SHORTID, buildInformation)
 
 	} else if gitHubEventTypeString == "pull_request" {
-		logging.Log.Info("Handling a pull request event.")
+		logger.Info("Handling a pull request event.")
 
 		webhookData := gh.PullRequestPayload{}
 
-		if err := request.ReadEntity(&webhookData); err != nil {
-			logging.Log.Errorf("error decoding webhook data: %s.", err.Error())
+		if err := json.Unmarshal(payload, &webhookData); err != nil {
+			logger.Errorf("error decoding webhook data: %s.", err.Error())
+			return
+		}
+		if status, err := r.verifyDelivery(ctx, webhookData.Repository.HTMLURL, payload, request.Request.Header); err != nil {
+			logger.Errorf("error verifying the delivery for repository %s: %s.", webhookData.Repository.HTMLURL, err.Error())
+			response.WriteHeader(status)
 			return
 		}
 
@@ -98,17 +121,18 @@ func (r Resource) handleWebhook(request *restful.Request, response *restful.Resp
 		buildInformation.REPONAME = webhookData.Repository.Name
 		buildInformation.TIMESTAMP = timestamp
 
-		createPipelineRunFromWebhookData(buildInformation, r)
-		logging.Log.Debugf("Build information for repository %s:%s: %s.", buildInformation.REPOURL, buildInformation.SHORTID, buildInformation)
+		createPipelineRunFromWebhookData(ctx, buildInformation, r)
+		logger.Debugf("Build information for repository %s:%s: %s.", buildInformation.REPOURL, buildInformation.SHORTID, buildInformation)
 
 	} else {
-		logging.Log.Errorf("error: event wasn't a push, pull, or ping event, no action will be taken. Request is: %+v.", request)
+		logger.Errorf("error: event wasn't a push, pull, or ping event, no action will be taken. Request is: %+v.", request)
 	}
 }
 
 // This is the main flow that handles building and deploying: given everything we need to kick off a build, do so
-func createPipelineRunFromWebhookData(buildInformation BuildInformation, r Resource) {
-	logging.Log.Debugf("In createPipelineRunFromWebhookData, build information: %s.", buildInformation)
+func createPipelineRunFromWebhookData(ctx context.Context, buildInformation BuildInformation, r Resource) {
+	logger := logging.FromContext(ctx)
+	logger.Debugf("In createPipelineRunFromWebhookData, build information: %s.", buildInformation)
 
 	// TODO: Use the dashboard endpoint to create the PipelineRun
 	// Track PR: https://github.com/tektoncd/dashboard/pull/33
@@ -120,17 +144,34 @@ func createPipelineRunFromWebhookData(buildInformation BuildInformation, r Resou
 		installNs = "default"
 	}
 
-	logging.Log.Debugf("Looking for the pipeline configmap in the install namespace %s.", installNs)
+	logger.Debugf("Looking for the pipeline configmap in the install namespace %s.", installNs)
 
 	// get information from related githubsource instance
-	webhook, err := r.getGitHubWebhook(buildInformation.REPOURL, installNs)
+	webhook, err := r.getWebhookForEvent(ctx, buildInformation.REPOURL, installNs)
 	if err != nil {
-		logging.Log.Errorf("error getting github webhook: %s.", err.Error())
+		logger.Errorf("error getting github webhook: %s.", err.Error())
 		return
 	}
+	pipelineNames := webhook.pipelineNames()
+	if len(pipelineNames) == 0 {
+		logger.Errorf("error: webhook %s has no pipelines to run.", webhook.Name)
+		return
+	}
+	for _, pipelineTemplateName := range pipelineNames {
+		// Names are generated from the release or webhook name, so include the pipeline when there are several
+		namePrefix := webhook.runNamePrefix()
+		if len(pipelineNames) > 1 {
+			namePrefix = fmt.Sprintf("%s-%s", namePrefix, pipelineTemplateName)
+		}
+		createPipelineRunForPipeline(ctx, buildInformation, webhook, pipelineTemplateName, namePrefix, r)
+	}
+}
+
+// createPipelineRunForPipeline creates the PipelineResources and PipelineRun that run one of a webhook's pipelines
+func createPipelineRunForPipeline(ctx context.Context, buildInformation BuildInformation, webhook webhook, pipelineTemplateName string, namePrefix string, r Resource) {
+	logger := logging.FromContext(ctx)
 	dockerRegistry := webhook.DockerRegistry
 	helmSecret := webhook.HelmSecret
-	pipelineTemplateName := webhook.Pipeline
 	pipelineNs := webhook.Namespace
 	saName := webhook.ServiceAccount
 	requestedReleaseName := webhook.ReleaseName
@@ -139,46 +180,46 @@ func createPipelineRunFromWebhookData(buildInformation BuildInformation, r Resou
 		saName = "default"
 	}
 
-	logging.Log.Debugf("Build information: %+v.", buildInformation)
+	logger.Debugf("Build information: %+v.", buildInformation)
 
 	// Assumes you've already applied the yml: so the pipeline definition and its tasks must exist upfront.
 	startTime := getDateTimeAsString()
-	generatedPipelineRunName := fmt.Sprintf("%s-%s", webhook.Name, startTime)
+	generatedPipelineRunName := fmt.Sprintf("%s-%s", namePrefix, startTime)
 
 	// Unique names are required so timestamp them.
-	imageResourceName := fmt.Sprintf("%s-docker-image-%s", webhook.Name, startTime)
-	gitResourceName := fmt.Sprintf("%s-git-source-%s", webhook.Name, startTime)
+	imageResourceName := fmt.Sprintf("%s-docker-image-%s", namePrefix, startTime)
+	gitResourceName := fmt.Sprintf("%s-git-source-%s", namePrefix, startTime)
 
 	pipeline, err := r.getPipelineImpl(pipelineTemplateName, pipelineNs)
 	if err != nil {
-		logging.Log.Errorf("could not find the pipeline template %s in namespace %s.", pipelineTemplateName, pipelineNs)
+		logger.Errorf("could not find the pipeline template %s in namespace %s.", pipelineTemplateName, pipelineNs)
 		return
 	}
-	logging.Log.Debugf("Found the pipeline template %s OK.", pipelineTemplateName)
+	logger.Debugf("Found the pipeline template %s OK.", pipelineTemplateName)
 
-	logging.Log.Debug("Creating PipelineResources.")
+	logger.Debug("Creating PipelineResources.")
 
 	urlToUse := fmt.Sprintf("%s/%s:%s", dockerRegistry, strings.ToLower(buildInformation.REPONAME), buildInformation.SHORTID)
-	logging.Log.Debugf("Constructed image URL is: %s.", urlToUse)
+	logger.Debugf("Constructed image URL is: %s.", urlToUse)
 
 	paramsForImageResource := []v1alpha1.Param{{Name: "url", Value: urlToUse}}
 	pipelineImageResource := definePipelineResource(imageResourceName, pipelineNs, paramsForImageResource, "image")
 	createdPipelineImageResource, err := r.TektonClient.TektonV1alpha1().PipelineResources(pipelineNs).Create(pipelineImageResource)
 	if err != nil {
-		logging.Log.Errorf("error creating pipeline image resource to be used in the pipeline: %s.", err.Error())
+		logger.Errorf("error creating pipeline image resource to be used in the pipeline: %s.", err.Error())
 		return
 	}
-	logging.Log.Infof("Created pipeline image resource %s successfully.", createdPipelineImageResource.Name)
+	logger.Infof("Created pipeline image resource %s successfully.", createdPipelineImageResource.Name)
 
 	paramsForGitResource := []v1alpha1.Param{{Name: "revision", Value: buildInformation.COMMITID}, {Name: "url", Value: buildInformation.REPOURL}}
 	pipelineGitResource := definePipelineResource(gitResourceName, pipelineNs, paramsForGitResource, "git")
 	createdPipelineGitResource, err := r.TektonClient.TektonV1alpha1().PipelineResources(pipelineNs).Create(pipelineGitResource)
 
 	if err != nil {
-		logging.Log.Errorf("error creating pipeline git resource to be used in the pipeline: %s.", err.Error())
+		logger.Errorf("error creating pipeline git resource to be used in the pipeline: %s.", err.Error())
 		return
 	}
-	logging.Log.Infof("Created pipeline git resource %s successfully.", createdPipelineGitResource.Name)
+	logger.Infof("Created pipeline git resource %s successfully.", createdPipelineGitResource.Name)
 
 	gitResourceRef := v1alpha1.PipelineResourceRef{Name: gitResourceName}
 	imageResourceRef := v1alpha1.PipelineResourceRef{Name: imageResourceName}
@@ -191,11 +232,11 @@ func createPipelineRunFromWebhookData(buildInformation BuildInformation, r Resou
 	releaseName := ""
 
 	if requestedReleaseName != "" {
-		logging.Log.Infof("Release name based on input: %s", requestedReleaseName)
+		logger.Infof("Release name based on input: %s", requestedReleaseName)
 		releaseName = requestedReleaseName
 	} else {
 		releaseName = fmt.Sprintf("%s", strings.ToLower(buildInformation.REPONAME))
-		logging.Log.Infof("Release name based on repository name: %s", releaseName)
+		logger.Infof("Release name based on repository name: %s", releaseName)
 	}
 
 	repositoryName := strings.ToLower(buildInformation.REPONAME)
@@ -206,7 +247,7 @@ func createPipelineRunFromWebhookData(buildInformation BuildInformation, r Resou
 		{Name: "target-namespace", Value: pipelineNs}}
 
 	if dockerRegistry != "" {
-		params = append(params, v1alpha1.Param{Name: "docker-registry", Value: dockerRegistry})
+		params = append(params, v1alpha1.Param{Name: r.dockerRegistryParam(), Value: dockerRegistry})
 	}
 
 	if helmSecret != "" {
@@ -216,15 +257,21 @@ func createPipelineRunFromWebhookData(buildInformation BuildInformation, r Resou
 	// PipelineRun yml defines the references to the above named resources.
 	pipelineRunData, err := definePipelineRun(generatedPipelineRunName, pipelineNs, saName, buildInformation.REPOURL,
 		pipeline, v1alpha1.PipelineTriggerTypeManual, resources, params)
+	if err != nil {
+		logger.Errorf("error defining the PipelineRun: %s", err.Error())
+		return
+	}
+	// the webhook's runs are listed by this label
+	pipelineRunData.Labels[webhookLabel] = webhook.Name
 
-	logging.Log.Infof("Creating a new PipelineRun named %s in the namespace %s using the service account %s.", generatedPipelineRunName, pipelineNs, saName)
+	logger.Infof("Creating a new PipelineRun named %s in the namespace %s using the service account %s.", generatedPipelineRunName, pipelineNs, saName)
 
 	pipelineRun, err := r.TektonClient.TektonV1alpha1().PipelineRuns(pipelineNs).Create(pipelineRunData)
 	if err != nil {
-		logging.Log.Errorf("error creating the PipelineRun: %s", err.Error())
+		logger.Errorf("error creating the PipelineRun: %s", err.Error())
 		return
 	}
-	logging.Log.Debugf("PipelineRun created: %+v.", pipelineRun)
+	logger.Debugf("PipelineRun created: %+v.", pipelineRun)
 }
 
 /* Get all pipelines in a given namespace: the caller needs to handle any errors,
@@ -333,6 +380,7 @@ func getDateTimeAsString() string {
 func SinkWebService(r Resource) *restful.WebService {
 	ws := new(restful.WebService)
 	ws.Path("/")
+	ws.Filter(requestIDFilter)
 	ws.Route(ws.POST("").To(r.handleWebhook))
 
 	return ws
@@ -0,0 +1,84 @@
+/*
+Copyright 2019 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+		http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package endpoints
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	logging "github.com/tektoncd/experimental/webhooks-extension/pkg/logging"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// objectReference returns the sink as the reference set on a GitHubSource
+func (s sinkReference) objectReference() *corev1.ObjectReference {
+	return &corev1.ObjectReference{
+		APIVersion: s.APIVersion,
+		Kind:       s.Kind,
+		Name:       s.Name,
+	}
+}
+
+// extensionSink returns the extension's sink, at the apiVersion and kind configured for its Knative Serving
+// version, or those of defaultSink if unset
+func (r Resource) extensionSink() sinkReference {
+	sink := defaultSink
+	if r.Defaults.SinkAPIVersion != "" {
+		sink.APIVersion = r.Defaults.SinkAPIVersion
+	}
+	if r.Defaults.SinkKind != "" {
+		sink.Kind = r.Defaults.SinkKind
+	}
+	return sink
+}
+
+// createEventSources creates each of the webhook's event sources with create, so that every sink is sent the
+// webhook's events. If one can't be created the sources already created are deleted again, along with the one that
+// failed unless it already existed, as a create that timed out may still have been applied. kind names the sources
+// in errors, and on error the http status to respond with is returned.
+func (r Resource) createEventSources(ctx context.Context, webhook webhook, installNs string, kind string, create func(source eventSource) error) (int, error) {
+	logger := logging.FromContext(ctx)
+	sources := webhook.eventSources()
+	if len(webhook.Sinks) == 0 {
+		sources[0].Sink = r.extensionSink()
+	}
+	for i := range sources {
+		source := sources[i]
+		err := create(source)
+		if err == nil {
+			continue
+		}
+		logger.Errorf("Error creating %s %s: %s.", kind, source.Name, err.Error())
+		rollback := sources[:i]
+		if !k8serrors.IsAlreadyExists(err) {
+			rollback = sources[:i+1]
+		}
+		for _, created := range rollback {
+			if err := r.deleteEventSource(ctx, webhook, created.Name, installNs); err != nil && !k8serrors.IsNotFound(err) {
+				logger.Errorf("error deleting %s %s: %s.", kind, created.Name, err.Error())
+			}
+		}
+		switch {
+		case apiErrorStatus(err, 0) == http.StatusGatewayTimeout:
+			return http.StatusGatewayTimeout, err
+		case k8serrors.IsAlreadyExists(err):
+			return http.StatusConflict, fmt.Errorf("a %s for webhook %s already exists", kind, webhook.Name)
+		default:
+			return http.StatusBadRequest, err
+		}
+	}
+	return http.StatusCreated, nil
+}
@@ -0,0 +1,88 @@
+/*
+Copyright 2019 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+		http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package endpoints
+
+import (
+	"context"
+	"fmt"
+	logging "github.com/tektoncd/experimental/webhooks-extension/pkg/logging"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// readyConditionType is the type of the condition reporting whether an event source is ready
+const readyConditionType = "Ready"
+
+// withSourceStatus returns the webhook with the Ready condition of its event source. If the source
+// can't be read its status is Unknown, with the error as the message. A webhook with several sinks is
+// only Ready when all of its sources are, and otherwise reports the first source that is not.
+func (r Resource) withSourceStatus(ctx context.Context, hook webhook, installNs string) webhook {
+	// a paused webhook has no event source
+	if hook.Paused {
+		hook.Status = &sourceStatus{Ready: string(corev1.ConditionUnknown), Message: "the webhook is paused"}
+		return hook
+	}
+	sources := hook.eventSources()
+	for _, source := range sources {
+		hook.Status = r.eventSourceStatus(ctx, hook, source.Name, installNs)
+		if hook.Status.Ready == string(corev1.ConditionTrue) {
+			continue
+		}
+		if len(sources) > 1 {
+			hook.Status.Message = fmt.Sprintf("%s: %s", source.Name, hook.Status.Message)
+		}
+		break
+	}
+	return hook
+}
+
+// eventSourceStatus returns the Ready condition of the named event source of a webhook
+func (r Resource) eventSourceStatus(ctx context.Context, hook webhook, name string, installNs string) *sourceStatus {
+	logger := logging.FromContext(ctx)
+	status := &sourceStatus{Ready: string(corev1.ConditionUnknown)}
+	err := r.withAPITimeout(ctx, func() error {
+		if hook.Provider == providerGitLab {
+			source, err := r.DynamicClient.Resource(gitLabSourceResource).Namespace(installNs).Get(name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			conditions, _, _ := unstructured.NestedSlice(source.Object, "status", "conditions")
+			for _, c := range conditions {
+				if condition, ok := c.(map[string]interface{}); ok && condition["type"] == readyConditionType {
+					status.Ready, _ = condition["status"].(string)
+					status.Message, _ = condition["message"].(string)
+				}
+			}
+			return nil
+		}
+		source, err := r.EventSrcClient.SourcesV1alpha1().GitHubSources(installNs).Get(name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		for _, condition := range source.Status.Conditions {
+			if string(condition.Type) == readyConditionType {
+				status.Ready = string(condition.Status)
+				status.Message = condition.Message
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		logger.Errorf("error getting the event source %s for webhook %s: %s.", name, hook.Name, err.Error())
+		status.Message = fmt.Sprintf("could not get the event source: %s", err.Error())
+	}
+	return status
+}
@@ -0,0 +1,178 @@
+/*
+Copyright 2019 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+		http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package endpoints
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	restful "github.com/emicklei/go-restful"
+	logging "github.com/tektoncd/experimental/webhooks-extension/pkg/logging"
+)
+
+// testDeliverySHA is the commit of a test delivery when none is requested. No such commit exists, so the runs
+// it triggers are created but fail to check out their source.
+const testDeliverySHA = "0000000000000000000000000000000000000000"
+
+// sinkClient is the http client test deliveries are sent to the sink with
+var sinkClient = &http.Client{Timeout: 30 * time.Second}
+
+// testDeliveryResult is the response to sending a test delivery for a webhook
+type testDeliveryResult struct {
+	// Status is the http status the sink responded to the delivery with
+	Status int `json:"status"`
+	// PipelineRuns are the names of the PipelineRuns the sink created for the delivery
+	PipelineRuns []string `json:"pipelineruns"`
+	Created      bool     `json:"created"`
+}
+
+// sinkURL returns the URL the extension's sink receives events at, the sink's cluster address in the install
+// namespace if none is configured
+func (r Resource) sinkURL(installNs string) string {
+	if r.Defaults.SinkURL != "" {
+		return r.Defaults.SinkURL
+	}
+	return fmt.Sprintf("http://%s.%s.svc.cluster.local", defaultSink.Name, installNs)
+}
+
+// testDeliveryPayload returns a GitHub push payload for the webhook's repository at sha, with the fields the sink
+// reads from pushes
+func testDeliveryPayload(hook webhook, sha string) ([]byte, error) {
+	repoURL := strings.TrimSuffix(hook.GitRepositoryURL, "/")
+	return json.Marshal(map[string]interface{}{
+		"ref":   "refs/heads/master",
+		"after": sha,
+		"head_commit": map[string]interface{}{
+			"id": sha,
+		},
+		"repository": map[string]interface{}{
+			"name":     path.Base(strings.TrimSuffix(repoURL, ".git")),
+			"url":      hook.GitRepositoryURL,
+			"html_url": hook.GitRepositoryURL,
+		},
+	})
+}
+
+// sendTestDelivery posts a sample push of the named webhook's repository to the extension's sink, as its event
+// source would, so that the wiring from the sink to the webhook's pipelines can be checked without pushing a
+// commit. The commit pushed is the sha query parameter, or testDeliverySHA. The response reports the sink's
+// status and the PipelineRuns it created; HTTP code 502 is returned if the sink can't be reached.
+func (r Resource) sendTestDelivery(request *restful.Request, response *restful.Response) {
+	ctx := request.Request.Context()
+	logger := logging.FromContext(ctx)
+	// Install namespace
+	installNs := r.Defaults.Namespace
+	if installNs == "" {
+		installNs = "default"
+	}
+
+	sha := request.QueryParameter("sha")
+	if sha == "" {
+		sha = testDeliverySHA
+	} else if len(sha) < 7 {
+		// the sink tags images with the first seven characters of the commit
+		err := fmt.Errorf("sha must be at least 7 characters, but was %s", sha)
+		logger.Errorf("error: %s.", err.Error())
+		RespondError(response, err, http.StatusBadRequest)
+		return
+	}
+
+	name := request.PathParameter("name")
+	webhooks, err := r.readGitHubWebhooks(ctx, installNs)
+	if err != nil {
+		logger.Errorf("error getting GitHub webhooks: %s.", err.Error())
+		RespondError(response, err, apiErrorStatus(err, http.StatusInternalServerError))
+		return
+	}
+	hook, ok := webhooks[name]
+	if !ok {
+		err := fmt.Errorf("could not find webhook named %s", name)
+		logger.Errorf("error: %s.", err.Error())
+		RespondError(response, err, http.StatusNotFound)
+		return
+	}
+
+	// runs are told apart from those that existed before the delivery by name
+	before, err := r.listWebhookPipelineRuns(ctx, hook)
+	if err != nil {
+		logger.Errorf("error listing PipelineRuns of webhook %s: %s.", name, err.Error())
+		RespondError(response, err, apiErrorStatus(err, http.StatusInternalServerError))
+		return
+	}
+	existing := map[string]bool{}
+	for _, run := range before {
+		existing[run.Name] = true
+	}
+
+	payload, err := testDeliveryPayload(hook, sha)
+	if err != nil {
+		logger.Errorf("error creating the test delivery of webhook %s: %s.", name, err.Error())
+		RespondError(response, err, http.StatusInternalServerError)
+		return
+	}
+	req, err := http.NewRequest(http.MethodPost, r.sinkURL(installNs), bytes.NewReader(payload))
+	if err != nil {
+		logger.Errorf("error creating the test delivery of webhook %s: %s.", name, err.Error())
+		RespondError(response, err, http.StatusInternalServerError)
+		return
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", restful.MIME_JSON)
+	req.Header.Set(githubEventParameter, "push")
+	// the delivery is signed, as the sink refuses unsigned deliveries for webhooks with a secret token
+	if !hook.NoSecret {
+		secretToken, err := r.readSecretToken(ctx, hook, installNs)
+		if err != nil {
+			logger.Errorf("error reading the secret token of webhook %s: %s.", name, err.Error())
+			RespondError(response, err, apiErrorStatus(err, http.StatusInternalServerError))
+			return
+		}
+		if hook.Provider == providerGitLab {
+			req.Header.Set("X-Gitlab-Token", secretToken)
+		} else {
+			req.Header.Set("X-Hub-Signature-256", signDelivery(payload, secretToken))
+		}
+	}
+	resp, err := sinkClient.Do(req)
+	if err != nil {
+		logger.Errorf("error sending the test delivery of webhook %s: %s.", name, err.Error())
+		RespondError(response, err, http.StatusBadGateway)
+		return
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+
+	after, err := r.listWebhookPipelineRuns(ctx, hook)
+	if err != nil {
+		logger.Errorf("error listing PipelineRuns of webhook %s: %s.", name, err.Error())
+		RespondError(response, err, apiErrorStatus(err, http.StatusInternalServerError))
+		return
+	}
+	result := testDeliveryResult{Status: resp.StatusCode, PipelineRuns: []string{}}
+	for _, run := range after {
+		if !existing[run.Name] {
+			result.PipelineRuns = append(result.PipelineRuns, run.Name)
+		}
+	}
+	result.Created = len(result.PipelineRuns) > 0
+	logger.Infof("Sent a test delivery for webhook %s, the sink responded %d and created %d PipelineRuns.", name, resp.StatusCode, len(result.PipelineRuns))
+	response.WriteEntity(result)
+}
@@ -0,0 +1,71 @@
+/*
+Copyright 2019 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+		http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package endpoints
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+)
+
+// defaultAPITimeout bounds each Kubernetes API call made while handling a request when no timeout is configured
+const defaultAPITimeout = 10 * time.Second
+
+// errAPITimeout is returned when a Kubernetes API call does not complete within the API timeout
+var errAPITimeout = errors.New("timed out waiting for the Kubernetes API server")
+
+// apiTimeout returns the timeout for Kubernetes API calls
+func (r Resource) apiTimeout() time.Duration {
+	if r.Defaults.APITimeout <= 0 {
+		return defaultAPITimeout
+	}
+	return r.Defaults.APITimeout
+}
+
+// withAPITimeout runs call, giving up with errAPITimeout when ctx is done or the API timeout passes first.
+// The clientsets in use have no context-aware methods, so a call that is given up on finishes in the background,
+// bounded by the timeout of the REST config; call must not be relied on to have returned after a timeout. Only
+// reads are run with it: a write given up on may still be applied, so writes are bounded by the REST config alone.
+func (r Resource) withAPITimeout(ctx context.Context, call func() error) error {
+	ctx, cancel := context.WithTimeout(ctx, r.apiTimeout())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- call()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return errAPITimeout
+	}
+}
+
+// apiErrorStatus returns 504 for a timed out Kubernetes API call, 503 when the webhooks ConfigMap is written by
+// another replica, or status for any other error
+func apiErrorStatus(err error, status int) int {
+	switch err {
+	case errAPITimeout:
+		return http.StatusGatewayTimeout
+	case errNotConfigMapWriter:
+		return http.StatusServiceUnavailable
+	}
+	// writes time out through the timeout of the REST config
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		return http.StatusGatewayTimeout
+	}
+	return status
+}
@@ -14,12 +14,19 @@ limitations under the License.
 package endpoints
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
 	eventsrcclientset "github.com/knative/eventing-sources/pkg/client/clientset/versioned"
 	logging "github.com/tektoncd/experimental/webhooks-extension/pkg/logging"
 	tektoncdclientset "github.com/tektoncd/pipeline/pkg/client/clientset/versioned"
+	"k8s.io/client-go/dynamic"
 	k8sclientset "k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // Resource stores all types here that are reused throughout files
@@ -27,6 +34,7 @@ type Resource struct {
 	EventSrcClient eventsrcclientset.Interface
 	TektonClient   tektoncdclientset.Interface
 	K8sClient      k8sclientset.Interface
+	DynamicClient  dynamic.Interface
 	Defaults       EnvDefaults
 }
 
@@ -39,6 +47,50 @@ func NewResource() (Resource, error) {
 		return Resource{}, err
 	}
 
+	apiTimeout := defaultAPITimeout
+	if value := os.Getenv("API_TIMEOUT"); value != "" {
+		apiTimeout, err = time.ParseDuration(value)
+		if err != nil || apiTimeout <= 0 {
+			logging.Log.Errorf("invalid API_TIMEOUT %s, using %s.", value, defaultAPITimeout)
+			apiTimeout = defaultAPITimeout
+		}
+	}
+	// Calls given up on after the API timeout are ended by the client's own timeout
+	config.Timeout = apiTimeout
+
+	configMapMaxRetries := defaultConfigMapMaxRetries
+	if value := os.Getenv("CONFIGMAP_MAX_RETRIES"); value != "" {
+		configMapMaxRetries, err = strconv.Atoi(value)
+		if err != nil || configMapMaxRetries <= 0 {
+			logging.Log.Errorf("invalid CONFIGMAP_MAX_RETRIES %s, using %d.", value, defaultConfigMapMaxRetries)
+			configMapMaxRetries = defaultConfigMapMaxRetries
+		}
+	}
+
+	maxRequestBodyBytes := int64(defaultMaxRequestBodyBytes)
+	if value := os.Getenv("MAX_REQUEST_BODY_BYTES"); value != "" {
+		maxRequestBodyBytes, err = strconv.ParseInt(value, 10, 64)
+		if err != nil || maxRequestBodyBytes <= 0 {
+			logging.Log.Errorf("invalid MAX_REQUEST_BODY_BYTES %s, using %d.", value, defaultMaxRequestBodyBytes)
+			maxRequestBodyBytes = defaultMaxRequestBodyBytes
+		}
+	}
+
+	webhookStorage := os.Getenv("WEBHOOK_STORAGE")
+	if webhookStorage != "" && webhookStorage != webhookStorageCRD {
+		logging.Log.Errorf("invalid WEBHOOK_STORAGE %s, storing webhooks in the configmap.", webhookStorage)
+		webhookStorage = ""
+	}
+
+	defaultEventTypes := splitList(os.Getenv("DEFAULT_EVENT_TYPES"))
+	if err := validateEventTypes(defaultEventTypes); err != nil {
+		logging.Log.Errorf("invalid DEFAULT_EVENT_TYPES: %s, using %s.", err.Error(), strings.Join(allEventTypes, ","))
+		defaultEventTypes = nil
+	}
+	if len(defaultEventTypes) == 0 {
+		defaultEventTypes = allEventTypes
+	}
+
 	// Setup event source client
 	eventSrcClient, err := eventsrcclientset.NewForConfig(config)
 	if err != nil {
@@ -60,15 +112,39 @@ func NewResource() (Resource, error) {
 		return Resource{}, err
 	}
 
+	// Setup dynamic client for event sources without a generated clientset
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		logging.Log.Errorf("error building dynamic client: %s.", err.Error())
+		return Resource{}, err
+	}
+
 	defaults := EnvDefaults{
-		Namespace:      os.Getenv("INSTALLED_NAMESPACE"),
-		DockerRegistry: os.Getenv("DOCKER_REGISTRY_LOCATION"),
+		Namespace:            os.Getenv("INSTALLED_NAMESPACE"),
+		DockerRegistry:       os.Getenv("DOCKER_REGISTRY_LOCATION"),
+		ConfigMapName:        configMapNameForRelease(os.Getenv("RELEASE_NAME")),
+		APITimeout:           apiTimeout,
+		SourceNamePrefix:     os.Getenv("SOURCE_NAME_PREFIX"),
+		DockerRegistryParam:  os.Getenv("DOCKER_REGISTRY_PARAM"),
+		ConfigMapMaxRetries:  configMapMaxRetries,
+		ConfigMapWriter:      os.Getenv("CONFIGMAP_WRITER"),
+		CORSAllowedOrigins:   splitList(os.Getenv("CORS_ALLOWED_ORIGINS")),
+		CORSAllowedMethods:   splitList(os.Getenv("CORS_ALLOWED_METHODS")),
+		CORSAllowedHeaders:   splitList(os.Getenv("CORS_ALLOWED_HEADERS")),
+		DefaultEventTypes:    defaultEventTypes,
+		MaxRequestBodyBytes:  maxRequestBodyBytes,
+		SourceServiceAccount: os.Getenv("SOURCE_SERVICE_ACCOUNT"),
+		SinkAPIVersion:       os.Getenv("SINK_API_VERSION"),
+		SinkKind:             os.Getenv("SINK_KIND"),
+		SinkURL:              os.Getenv("SINK_URL"),
+		WebhookStorage:       webhookStorage,
 	}
 
 	r := Resource{
 		K8sClient:      k8sClient,
 		TektonClient:   tektonClient,
 		EventSrcClient: eventSrcClient,
+		DynamicClient:  dynamicClient,
 		Defaults:       defaults,
 	}
 	return r, nil
@@ -85,13 +161,350 @@ type webhook struct {
 	DockerRegistry   string `json:"dockerregistry,omitempty"`
 	HelmSecret       string `json:"helmsecret,omitempty"`
 	ReleaseName      string `json:"releasename,omitempty"`
+	Provider         string `json:"provider,omitempty"`
+	AuthMode         string `json:"authmode,omitempty"`
+	// AccessTokenKey and SecretTokenKey are the keys of the AccessTokenRef secret holding the tokens,
+	// defaultAccessTokenKey and defaultSecretTokenKey if empty
+	AccessTokenKey string `json:"accesstokenkey,omitempty"`
+	SecretTokenKey string `json:"secrettokenkey,omitempty"`
+	// NoSecret omits the secret token from the webhook's event sources, for public repositories whose deliveries
+	// need not be signed
+	NoSecret bool `json:"nosecret,omitempty"`
+	// Pipelines are triggered in addition to Pipeline, so one webhook can run several pipelines
+	Pipelines []string `json:"pipelines,omitempty"`
+	// Labels and Annotations are set on the event source created for the webhook
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	// GitHub App credentials, used instead of AccessTokenRef with the githubapp auth mode
+	GitHubAppID             int64  `json:"githubappid,omitempty"`
+	GitHubAppInstallationID int64  `json:"githubappinstallationid,omitempty"`
+	GitHubAppKeySecret      string `json:"githubappkeysecret,omitempty"`
+	// PushOnly and PROnly limit the events the webhook's event source is sent to pushes or to pull requests
+	PushOnly bool `json:"pushonly,omitempty"`
+	PROnly   bool `json:"pronly,omitempty"`
+	// SourceName is the name of the webhook's event source when it is not the webhook's name
+	SourceName string `json:"sourcename,omitempty"`
+	// Organization is set to the GitHub organization for a webhook whose GitRepositoryURL is the organization's
+	// root, and which is sent the events of all of its repositories
+	Organization string `json:"organization,omitempty"`
+	// Sinks are sent the webhook's events, each by an event source of its own. When empty the events are sent
+	// to the extension's sink, which creates the webhook's PipelineRuns.
+	Sinks []sinkReference `json:"sinks,omitempty"`
+	// SourceServiceAccount is the service account the webhook's event sources run as, unlike ServiceAccount
+	// which the webhook's PipelineRuns run as
+	SourceServiceAccount string `json:"sourceserviceaccount,omitempty"`
+	// Paused is set while the webhook's event source is deleted, so that no events are sent for it, and the
+	// webhook is kept to create the source again when it is unpaused
+	Paused bool `json:"paused,omitempty"`
+	// Status is the state of the webhook's event source, only returned when requested and never stored
+	Status *sourceStatus `json:"status,omitempty"`
+	// InstallNamespace is the namespace whose ConfigMap the webhook is stored in, only returned when webhooks
+	// are listed by namespace and never stored
+	InstallNamespace string `json:"installnamespace,omitempty"`
+}
+
+// sourceStatus is the Ready condition of a webhook's event source
+type sourceStatus struct {
+	// Ready is True, False or Unknown
+	Ready   string `json:"ready"`
+	Message string `json:"message,omitempty"`
+}
+
+// defaultAccessTokenKey and defaultSecretTokenKey are the keys of a webhook's secret that hold its access token
+// and the secret its events are signed with, when the webhook names no others
+const (
+	defaultAccessTokenKey = "accessToken"
+	defaultSecretTokenKey = "secretToken"
+)
+
+// accessTokenKey returns the key of the webhook's secret holding its access token
+func (w webhook) accessTokenKey() string {
+	if w.AccessTokenKey != "" {
+		return w.AccessTokenKey
+	}
+	return defaultAccessTokenKey
+}
+
+// secretTokenKey returns the key of the webhook's secret holding the secret its events are signed with
+func (w webhook) secretTokenKey() string {
+	if w.SecretTokenKey != "" {
+		return w.SecretTokenKey
+	}
+	return defaultSecretTokenKey
+}
+
+// sourceName returns the name of the webhook's event source
+func (w webhook) sourceName() string {
+	if w.SourceName != "" {
+		return w.SourceName
+	}
+	return w.Name
+}
+
+// releaseLabel is set on the event sources of a webhook with a release name to that name
+const releaseLabel = "webhooks.tekton.dev/release"
+
+// sourceLabels returns the labels set on the webhook's event sources: its Labels and, for a webhook with a
+// release name, releaseLabel, which replaces a label of the same key
+func (w webhook) sourceLabels() map[string]string {
+	if w.ReleaseName == "" {
+		return w.Labels
+	}
+	labels := map[string]string{releaseLabel: w.ReleaseName}
+	for key, value := range w.Labels {
+		if key != releaseLabel {
+			labels[key] = value
+		}
+	}
+	return labels
+}
+
+// runNamePrefix returns the name that the names of the PipelineRuns and PipelineResources the sink creates for
+// the webhook are generated from. It is the webhook's release name, so the runs deploying a Helm release are
+// named after it, or the webhook's name if it has none.
+func (w webhook) runNamePrefix() string {
+	if w.ReleaseName != "" {
+		return w.ReleaseName
+	}
+	return w.Name
+}
+
+// sinkReference refers to an addressable, such as a Knative service, that an event source sends events to
+type sinkReference struct {
+	APIVersion string `json:"apiversion"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+}
+
+// defaultSink is the extension's sink, used for webhooks that list no sinks. Its apiVersion and kind can be
+// configured, see extensionSink.
+var defaultSink = sinkReference{APIVersion: "serving.knative.dev/v1alpha1", Kind: "Service", Name: "webhooks-extension-sink"}
+
+// eventSource is one of a webhook's event sources and the sink it sends events to
+type eventSource struct {
+	Name string
+	Sink sinkReference
+}
+
+// eventSources returns an event source for each of the webhook's sinks. The first is named sourceName(), so a
+// webhook with a single sink keeps its source's name, and the others are suffixed with their position.
+func (w webhook) eventSources() []eventSource {
+	sinks := w.Sinks
+	if len(sinks) == 0 {
+		sinks = []sinkReference{defaultSink}
+	}
+	sources := make([]eventSource, len(sinks))
+	for i, sink := range sinks {
+		sources[i] = eventSource{Name: w.sourceName(), Sink: sink}
+		if i > 0 {
+			sources[i].Name = fmt.Sprintf("%s-%d", w.sourceName(), i)
+		}
+	}
+	return sources
+}
+
+// hasEventSource returns whether name is the name of one of the webhook's event sources
+func (w webhook) hasEventSource(name string) bool {
+	for _, source := range w.eventSources() {
+		if source.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// validateSinks checks that each of the webhook's sinks is a complete reference, listed once
+func (w webhook) validateSinks() error {
+	seen := map[sinkReference]bool{}
+	for _, sink := range w.Sinks {
+		if sink.APIVersion == "" || sink.Kind == "" || sink.Name == "" {
+			return fmt.Errorf("sink %+v must have an apiversion, a kind and a name", sink)
+		}
+		if seen[sink] {
+			return fmt.Errorf("sink %s %s is listed more than once", sink.Kind, sink.Name)
+		}
+		seen[sink] = true
+	}
+	return nil
+}
+
+// allEventTypes are the events a webhook can be sent, named as for GitHub
+var allEventTypes = []string{"push", "pull_request"}
+
+// validateEventTypes checks that event types are among allEventTypes
+func validateEventTypes(eventTypes []string) error {
+	for _, eventType := range eventTypes {
+		found := false
+		for _, known := range allEventTypes {
+			found = found || eventType == known
+		}
+		if !found {
+			return fmt.Errorf("unknown event type %s, expected one of %s", eventType, strings.Join(allEventTypes, ", "))
+		}
+	}
+	return nil
+}
+
+// applyDefaultEventTypes sets pushonly or pronly on a webhook that sets neither when the default event types are
+// only push or only pull_request events, so the webhook keeps its events if the defaults change
+func (w *webhook) applyDefaultEventTypes(defaults []string) {
+	if w.PushOnly || w.PROnly {
+		return
+	}
+	hasPush, hasPR := false, false
+	for _, eventType := range defaults {
+		hasPush = hasPush || eventType == "push"
+		hasPR = hasPR || eventType == "pull_request"
+	}
+	w.PushOnly = hasPush && !hasPR
+	w.PROnly = hasPR && !hasPush
+}
+
+// gitHubEventTypes returns the GitHub events the webhook's event source is sent
+func (w webhook) gitHubEventTypes() []string {
+	switch {
+	case w.PushOnly:
+		return []string{"push"}
+	case w.PROnly:
+		return []string{"pull_request"}
+	}
+	return []string{"push", "pull_request"}
+}
+
+// gitLabEventTypes returns the GitLab events the webhook's event source is sent
+func (w webhook) gitLabEventTypes() []interface{} {
+	switch {
+	case w.PushOnly:
+		return []interface{}{"push_events"}
+	case w.PROnly:
+		return []interface{}{"merge_requests_events"}
+	}
+	return []interface{}{"push_events", "merge_requests_events"}
+}
+
+// sameAs reports whether the webhook has the same configuration as other. They are compared as they are
+// stored, so a missing and an empty list or map are alike.
+func (w webhook) sameAs(other webhook) bool {
+	a, errA := json.Marshal(w)
+	b, errB := json.Marshal(other)
+	return errA == nil && errB == nil && bytes.Equal(a, b)
+}
+
+// pipelineNames returns the names of the pipelines to trigger for the webhook, without duplicates
+func (w webhook) pipelineNames() []string {
+	names := []string{}
+	seen := map[string]bool{}
+	for _, name := range append([]string{w.Pipeline}, w.Pipelines...) {
+		if name != "" && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Git providers a webhook can be created for, github is used when no provider is given
+const (
+	providerGitHub = "github"
+	providerGitLab = "gitlab"
+)
+
+// createResult is returned when creating a webhook, showing how its GitRepositoryURL was interpreted
+type createResult struct {
+	APIURL          string `json:"apiurl"`
+	OwnerRepo       string `json:"ownerrepo"`
+	GitHubAPIURLSet bool   `json:"githubapiurlset"`
+}
+
+// batchResult is returned for each webhook of a batch create, with the http status it would have been
+// created with on its own
+type batchResult struct {
+	Name   string        `json:"name"`
+	Status int           `json:"status"`
+	Error  string        `json:"error,omitempty"`
+	Result *createResult `json:"result,omitempty"`
+	// Errors are the failures of a webhook that is not valid
+	Errors validationErrors `json:"errors,omitempty"`
+}
+
+// deleteResult is returned when deleting the webhooks for a repository
+type deleteResult struct {
+	Deleted int `json:"deleted"`
 }
 
 // ConfigMapName ... the name of the ConfigMap to create
 const ConfigMapName = "githubwebhook"
 
+// configMapNameForRelease returns the name of the ConfigMap storing the webhooks of an install,
+// so that installs with different release names in one namespace keep separate webhook lists
+func configMapNameForRelease(releaseName string) string {
+	if releaseName == "" {
+		return ConfigMapName
+	}
+	return releaseName + "-" + ConfigMapName
+}
+
+// extensionDeploymentName is the name of the extension's Deployment, which owns the event sources it creates
+const extensionDeploymentName = "webhooks-extension"
+
+// requestIDHeader is the header carrying the correlation ID of a request
+const requestIDHeader = "X-Request-ID"
+
+// configMapKey is the key in the ConfigMap under which the webhooks are stored
+const configMapKey = "GitHubSource"
+
+// defaultsConfigMapKey is the key in the ConfigMap under which defaults updated at runtime are stored
+const defaultsConfigMapKey = "Defaults"
+
 //
 type EnvDefaults struct {
 	Namespace      string `json:"namespace"`
 	DockerRegistry string `json:"dockerregistry"`
+	// ConfigMapName is the ConfigMap the webhooks are stored in, ConfigMapName is used if empty
+	ConfigMapName string `json:"-"`
+	// APITimeout bounds each Kubernetes API call made while handling a request, defaultAPITimeout is used if unset
+	APITimeout time.Duration `json:"-"`
+	// SourceNamePrefix is prepended to a webhook's name to name its event source
+	SourceNamePrefix string `json:"-"`
+	// DockerRegistryParam is the PipelineRun param the docker registry is passed in, defaultDockerRegistryParam is
+	// used if empty
+	DockerRegistryParam string `json:"-"`
+	// ConfigMapMaxRetries is how many times a read or write of the webhooks ConfigMap is retried after a
+	// conflict or a transient API error, defaultConfigMapMaxRetries is used if unset
+	ConfigMapMaxRetries int `json:"-"`
+	// ConfigMapWriter identifies this replica, which may only write the webhooks ConfigMap if it is named by the
+	// ConfigMap's writer annotation or the annotation is absent
+	ConfigMapWriter string `json:"-"`
+	// CORSAllowedOrigins are the origins browsers may make cross origin requests to the extension from, with
+	// the CORSAllowedMethods and CORSAllowedHeaders, or defaults for those if empty
+	CORSAllowedOrigins []string `json:"-"`
+	CORSAllowedMethods []string `json:"-"`
+	CORSAllowedHeaders []string `json:"-"`
+	// DefaultEventTypes are the events, push and pull_request, sent for webhooks that set neither pushonly nor
+	// pronly, all of them if empty
+	DefaultEventTypes []string `json:"defaulteventtypes,omitempty"`
+	// MaxRequestBodyBytes is the largest request body that is read, defaultMaxRequestBodyBytes is used if unset
+	MaxRequestBodyBytes int64 `json:"-"`
+	// SourceServiceAccount is the service account event sources run as for webhooks that don't set one
+	SourceServiceAccount string `json:"sourceserviceaccount,omitempty"`
+	// SinkAPIVersion and SinkKind refer to the extension's sink as its Knative Serving version serves it, those
+	// of defaultSink are used if empty
+	SinkAPIVersion string `json:"-"`
+	SinkKind       string `json:"-"`
+	// SinkURL is the URL test deliveries are sent to the extension's sink at, see sinkURL
+	SinkURL string `json:"-"`
+	// WebhookStorage is webhookStorageCRD to store each webhook as a Webhook custom resource, webhooks are stored
+	// in the webhooks ConfigMap if empty
+	WebhookStorage string `json:"-"`
+}
+
+// defaultDockerRegistryParam is the PipelineRun param the docker registry is passed in when none is configured
+const defaultDockerRegistryParam = "docker-registry"
+
+// dockerRegistryParam returns the name of the PipelineRun param the docker registry is passed in
+func (r Resource) dockerRegistryParam() string {
+	if r.Defaults.DockerRegistryParam == "" {
+		return defaultDockerRegistryParam
+	}
+	return r.Defaults.DockerRegistryParam
 }
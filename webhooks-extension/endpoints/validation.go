@@ -0,0 +1,65 @@
+/*
+Copyright 2019 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+		http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package endpoints
+
+import (
+	"net/http"
+	"strings"
+
+	restful "github.com/emicklei/go-restful"
+)
+
+// fieldError is the failure of a webhook field to validate, the field named as in the webhook's JSON
+type fieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// validationErrors are all of the failures found validating a webhook, so that they can be fixed at once
+type validationErrors []fieldError
+
+// Error joins the messages of the failures
+func (e validationErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, failure := range e {
+		messages[i] = failure.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// add records err as a failure of field
+func (e *validationErrors) add(field string, err error) {
+	if err != nil {
+		*e = append(*e, fieldError{Field: field, Message: err.Error()})
+	}
+}
+
+// asError returns the failures as an error, or nil if there are none
+func (e validationErrors) asError() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// respondInvalidWebhook responds with HTTP code 422 to a webhook that failed validation. The failures are
+// written as a JSON list of fields and messages, other errors as text.
+func respondInvalidWebhook(response *restful.Response, err error) {
+	failures, ok := err.(validationErrors)
+	if !ok {
+		RespondError(response, err, http.StatusUnprocessableEntity)
+		return
+	}
+	response.WriteHeaderAndEntity(http.StatusUnprocessableEntity, failures)
+}
@@ -0,0 +1,93 @@
+/*
+Copyright 2019 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+		http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package endpoints
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	eventapi "github.com/knative/eventing-sources/pkg/apis/sources/v1alpha1"
+	informers "github.com/knative/eventing-sources/pkg/client/informers/externalversions"
+	logging "github.com/tektoncd/experimental/webhooks-extension/pkg/logging"
+	"k8s.io/client-go/tools/cache"
+)
+
+// sourceResyncPeriod is how often the watched GitHub sources are relisted
+const sourceResyncPeriod = 10 * time.Minute
+
+// WatchGitHubSources watches the GitHubSources in the install namespace and removes a webhook from the webhooks
+// ConfigMap when its source is deleted, so that webhooks whose source was deleted directly are not reported.
+// It returns once the sources have been listed; the watch runs until stopCh is closed.
+func (r Resource) WatchGitHubSources(stopCh <-chan struct{}) error {
+	installNs := r.Defaults.Namespace
+	if installNs == "" {
+		installNs = "default"
+	}
+	factory := informers.NewSharedInformerFactoryWithOptions(r.EventSrcClient, sourceResyncPeriod, informers.WithNamespace(installNs))
+	informer := factory.Sources().V1alpha1().GitHubSources().Informer()
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		DeleteFunc: func(obj interface{}) {
+			r.removeDeletedSource(installNs, obj)
+		},
+	})
+	factory.Start(stopCh)
+	if !cache.WaitForCacheSync(stopCh, informer.HasSynced) {
+		return errors.New("stopped before the GitHub sources were listed")
+	}
+	return nil
+}
+
+// removeDeletedSource removes the GitHub webhook of a deleted GitHubSource, which may be the source of any of the
+// webhook's sinks, from the webhooks ConfigMap
+func (r Resource) removeDeletedSource(installNs string, obj interface{}) {
+	ctx := context.Background()
+	logger := logging.FromContext(ctx)
+	// a deletion missed while the watch was down is delivered as a tombstone
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	source, ok := obj.(*eventapi.GitHubSource)
+	if !ok {
+		logger.Errorf("error: deleted object %T is not a GitHub source.", obj)
+		return
+	}
+	webhooks, err := r.readGitHubWebhooks(ctx, installNs)
+	if err != nil {
+		logger.Errorf("error getting GitHub webhooks to remove deleted source %s: %s.", source.Name, err.Error())
+		return
+	}
+	for name, hook := range webhooks {
+		if !hook.hasEventSource(source.Name) || (hook.Provider != "" && hook.Provider != providerGitHub) {
+			continue
+		}
+		// the source of a paused webhook is deleted on purpose
+		if hook.Paused {
+			return
+		}
+		// the webhook is only removed if it is still stored unpaused when the ConfigMap is written
+		err := r.updateGitHubWebhooks(ctx, installNs, func(webhooks map[string]webhook) error {
+			if stored, ok := webhooks[name]; ok && !stored.Paused {
+				delete(webhooks, name)
+			}
+			return nil
+		})
+		if err != nil {
+			logger.Errorf("error removing webhook %s of deleted GitHub source: %s.", name, err.Error())
+			return
+		}
+		logger.Infof("Removed webhook %s because its GitHub source %s was deleted.", name, source.Name)
+		return
+	}
+}
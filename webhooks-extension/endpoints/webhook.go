@@ -14,148 +14,890 @@ limitations under the License.
 package endpoints
 
 import (
+	"context"
+	"crypto/rand"
 	"encoding/json"
 	"errors"
 	"fmt"
 	logging "github.com/tektoncd/experimental/webhooks-extension/pkg/logging"
 	"net/http"
+	"net/url"
 	"strings"
+	"time"
 
 	restful "github.com/emicklei/go-restful"
 	eventapi "github.com/knative/eventing-sources/pkg/apis/sources/v1alpha1"
+	v1alpha1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
 )
 
 func (r Resource) createWebhook(request *restful.Request, response *restful.Response) {
-	logging.Log.Infof("Creating webhook with request: %+v.", request)
+	ctx := request.Request.Context()
+	logger := logging.FromContext(ctx)
+	logger.Infof("Creating webhook with request: %+v.", request)
 	// Install namespace
 	installNs := r.Defaults.Namespace
 	if installNs == "" {
 		installNs = "default"
 	}
+	if status, err := r.checkInstallNamespace(ctx, installNs); err != nil {
+		RespondError(response, err, status)
+		return
+	}
 
 	webhook := webhook{}
 	if err := request.ReadEntity(&webhook); err != nil {
-		logging.Log.Errorf("error trying to read request entity as webhook: %s.", err)
+		logger.Errorf("error trying to read request entity as webhook: %s.", err)
 		RespondError(response, err, http.StatusBadRequest)
 		return
 	}
 
-	if webhook.ReleaseName != "" {
-		if len(webhook.ReleaseName) > 63 {
-			tooLongMessage := fmt.Sprintf("requested release name (%s) must be less than 64 characters", webhook.ReleaseName)
-			err := errors.New(tooLongMessage)
-			logging.Log.Errorf("error: %s", err.Error())
-			RespondError(response, err, http.StatusBadRequest)
+	if err := r.prepareWebhook(ctx, &webhook); err != nil {
+		logger.Errorf("error: %s.", err.Error())
+		respondInvalidWebhook(response, err)
+		return
+	}
+
+	webhooks, err := r.readGitHubWebhooks(ctx, installNs)
+	if err != nil {
+		logger.Errorf("error getting GitHub webhooks: %s.", err.Error())
+		RespondError(response, err, apiErrorStatus(err, http.StatusInternalServerError))
+		return
+	}
+	if existing, ok := webhooks[webhook.Name]; ok {
+		// the same webhook is re-posted by declarative clients, so it is returned rather than refused
+		if existing.sameAs(webhook) {
+			logger.Infof("Webhook %s already exists.", webhook.Name)
+			response.WriteHeaderAndEntity(http.StatusOK, existing)
 			return
 		}
+		err := fmt.Errorf("a webhook named %s already exists", webhook.Name)
+		logger.Errorf("error: %s.", err.Error())
+		RespondError(response, err, http.StatusConflict)
+		return
 	}
 
-	dockerRegDefault := r.Defaults.DockerRegistry
-	if webhook.DockerRegistry == "" && dockerRegDefault != "" {
-		webhook.DockerRegistry = dockerRegDefault
+	logger.Infof("Creating webhook: %v.", webhook)
+	result, status, err := r.createSourceUnlessPaused(ctx, webhook, installNs)
+	if err != nil {
+		RespondError(response, err, status)
+		return
+	}
+	// another replica or request may have created a webhook of the same name since the webhooks were read
+	exists := false
+	err = r.updateGitHubWebhooks(ctx, installNs, func(webhooks map[string]webhook) error {
+		if stored, ok := webhooks[webhook.Name]; ok {
+			// a write retried after failing part way through may have stored the webhook already
+			if stored.sameAs(webhook) {
+				return nil
+			}
+			exists = true
+			return fmt.Errorf("a webhook named %s already exists", webhook.Name)
+		}
+		webhooks[webhook.Name] = webhook
+		return nil
+	})
+	if err != nil {
+		status := apiErrorStatus(err, http.StatusInternalServerError)
+		if exists {
+			status = http.StatusConflict
+		}
+		if len(r.deleteUnstoredSources(ctx, installNs, []webhook{webhook})) > 0 {
+			RespondError(response, err, status)
+			return
+		}
+		logger.Infof("Webhook %s was stored although writing it failed: %s.", webhook.Name, err.Error())
+	}
+	audit(request, auditOperationCreate, webhook.Name)
+	response.WriteHeaderAndEntity(http.StatusCreated, result)
+}
+
+// createWebhooks creates a batch of webhooks, writing the ConfigMap once after all of their sources are
+// created. A webhook that fails is reported in its result without aborting the rest of the batch.
+func (r Resource) createWebhooks(request *restful.Request, response *restful.Response) {
+	ctx := request.Request.Context()
+	logger := logging.FromContext(ctx)
+	// Install namespace
+	installNs := r.Defaults.Namespace
+	if installNs == "" {
+		installNs = "default"
+	}
+
+	if status, err := r.checkInstallNamespace(ctx, installNs); err != nil {
+		RespondError(response, err, status)
+		return
+	}
+
+	batch := []webhook{}
+	if err := request.ReadEntity(&batch); err != nil {
+		logger.Errorf("error trying to read request entity as a batch of webhooks: %s.", err)
+		RespondError(response, err, http.StatusBadRequest)
+		return
+	}
+	r.createBatch(request, response, installNs, batch)
+}
+
+// createBatch creates each webhook of the batch that does not exist yet, responding with a result per webhook.
+// Webhooks identical to an existing one succeed without being created again.
+func (r Resource) createBatch(request *restful.Request, response *restful.Response, installNs string, batch []webhook) {
+	ctx := request.Request.Context()
+	logger := logging.FromContext(ctx)
+	webhooks, err := r.readGitHubWebhooks(ctx, installNs)
+	if err != nil {
+		logger.Errorf("error getting GitHub webhooks: %s.", err.Error())
+		RespondError(response, err, apiErrorStatus(err, http.StatusInternalServerError))
+		return
+	}
+
+	results := make([]batchResult, len(batch))
+	var created []string
+	for i := range batch {
+		webhook := batch[i]
+		results[i].Name = webhook.Name
+		if err := r.prepareWebhook(ctx, &webhook); err != nil {
+			logger.Errorf("error: %s.", err.Error())
+			results[i].Status, results[i].Error = http.StatusUnprocessableEntity, err.Error()
+			results[i].Errors, _ = err.(validationErrors)
+			continue
+		}
+		// the map also holds the webhooks created earlier in the batch
+		if existing, ok := webhooks[webhook.Name]; ok {
+			if existing.sameAs(webhook) {
+				results[i].Status = http.StatusOK
+				continue
+			}
+			err := fmt.Errorf("a webhook named %s already exists", webhook.Name)
+			logger.Errorf("error: %s.", err.Error())
+			results[i].Status, results[i].Error = http.StatusConflict, err.Error()
+			continue
+		}
+
+		logger.Infof("Creating webhook: %v.", webhook)
+		result, status, err := r.createSourceUnlessPaused(ctx, webhook, installNs)
+		if err != nil {
+			results[i].Status, results[i].Error = status, err.Error()
+			continue
+		}
+		webhooks[webhook.Name] = webhook
+		created = append(created, webhook.Name)
+		results[i].Status, results[i].Result = http.StatusCreated, &result
+	}
+
+	var conflicts map[string]bool
+	unstored := map[string]bool{}
+	if len(created) > 0 {
+		// webhooks created in between by another replica or request are kept, and the batch's webhooks of the
+		// same name are reported as conflicts
+		err := r.updateGitHubWebhooks(ctx, installNs, func(stored map[string]webhook) error {
+			conflicts = make(map[string]bool)
+			for _, name := range created {
+				if existing, ok := stored[name]; ok {
+					// a write retried after failing part way through may have stored some of the batch already
+					if !existing.sameAs(webhooks[name]) {
+						conflicts[name] = true
+					}
+					continue
+				}
+				stored[name] = webhooks[name]
+			}
+			return nil
+		})
+		if err != nil {
+			hooks := make([]webhook, len(created))
+			for i, name := range created {
+				hooks[i] = webhooks[name]
+			}
+			names := r.deleteUnstoredSources(ctx, installNs, hooks)
+			if len(names) == len(created) {
+				RespondError(response, err, apiErrorStatus(err, http.StatusInternalServerError))
+				return
+			}
+			// the write stored part of the batch before failing, the rest is reported as failed
+			logger.Errorf("error writing GitHub webhooks %v: %s.", names, err.Error())
+			for _, name := range names {
+				unstored[name] = true
+			}
+			for i := range results {
+				if results[i].Status == http.StatusCreated && unstored[results[i].Name] && !conflicts[results[i].Name] {
+					results[i].Status, results[i].Error, results[i].Result = apiErrorStatus(err, http.StatusInternalServerError), err.Error(), nil
+				}
+			}
+		} else {
+			for name := range conflicts {
+				r.deleteUnstoredSource(ctx, webhooks[name], installNs)
+			}
+		}
+		for i := range results {
+			if results[i].Status == http.StatusCreated && conflicts[results[i].Name] {
+				err := fmt.Errorf("a webhook named %s already exists", results[i].Name)
+				logger.Errorf("error: %s.", err.Error())
+				results[i].Status, results[i].Error, results[i].Result = http.StatusConflict, err.Error(), nil
+			}
+		}
+	}
+	for _, name := range created {
+		if !conflicts[name] && !unstored[name] {
+			audit(request, auditOperationCreate, name)
+		}
 	}
-	logging.Log.Debugf("Docker registry location is: %s", webhook.DockerRegistry)
+	response.WriteHeaderAndEntity(http.StatusOK, results)
+}
 
-	namespace := webhook.Namespace
-	if namespace == "" {
-		err := errors.New("namespace is required, but none was given")
-		logging.Log.Errorf("error: %s.", err.Error())
+// validateWebhook runs the checks made when creating a webhook, and checks that its secret exists, without
+// creating its event source or storing it. The response shows how its GitRepositoryURL would be interpreted.
+func (r Resource) validateWebhook(request *restful.Request, response *restful.Response) {
+	ctx := request.Request.Context()
+	logger := logging.FromContext(ctx)
+	// Install namespace
+	installNs := r.Defaults.Namespace
+	if installNs == "" {
+		installNs = "default"
+	}
+	if status, err := r.checkInstallNamespace(ctx, installNs); err != nil {
+		RespondError(response, err, status)
+		return
+	}
+
+	webhook := webhook{}
+	if err := request.ReadEntity(&webhook); err != nil {
+		logger.Errorf("error trying to read request entity as webhook: %s.", err)
 		RespondError(response, err, http.StatusBadRequest)
 		return
 	}
-	logging.Log.Infof("Creating webhook: %v.", webhook)
-	pieces := strings.Split(webhook.GitRepositoryURL, "/")
-	if len(pieces) < 4 {
-		logging.Log.Errorf("error creating webhook: GitRepositoryURL format error (%+v).", webhook.GitRepositoryURL)
-		RespondError(response, errors.New("GitRepositoryURL format error"), http.StatusBadRequest)
+	if err := r.prepareWebhook(ctx, &webhook); err != nil {
+		logger.Errorf("error: %s.", err.Error())
+		respondInvalidWebhook(response, err)
+		return
+	}
+	result, err := interpretRepositoryURL(webhook)
+	if err != nil {
+		logger.Errorf("error: %s.", err.Error())
+		RespondError(response, err, http.StatusUnprocessableEntity)
 		return
 	}
-	apiURL := strings.TrimSuffix(webhook.GitRepositoryURL, pieces[len(pieces)-2]+"/"+pieces[len(pieces)-1]) + "api/v3/"
-	ownerRepo := pieces[len(pieces)-2] + "/" + strings.TrimSuffix(pieces[len(pieces)-1], ".git")
+	if status, err := r.checkWebhookSecret(ctx, webhook, installNs); err != nil {
+		RespondError(response, err, status)
+		return
+	}
+
+	webhooks, err := r.readGitHubWebhooks(ctx, installNs)
+	if err != nil {
+		logger.Errorf("error getting GitHub webhooks: %s.", err.Error())
+		RespondError(response, err, apiErrorStatus(err, http.StatusInternalServerError))
+		return
+	}
+	if _, ok := webhooks[webhook.Name]; ok {
+		err := fmt.Errorf("a webhook named %s already exists", webhook.Name)
+		logger.Errorf("error: %s.", err.Error())
+		RespondError(response, err, http.StatusConflict)
+		return
+	}
+	response.WriteHeaderAndEntity(http.StatusOK, result)
+}
+
+// interpretRepositoryURL returns how a webhook's GitRepositoryURL is interpreted by its provider's event source
+func interpretRepositoryURL(webhook webhook) (createResult, error) {
+	switch webhook.Provider {
+	case "", providerGitHub:
+		apiURL, ownerRepo, err := getGitHubValues(webhook.GitRepositoryURL)
+		if err != nil {
+			return createResult{}, err
+		}
+		result := createResult{APIURL: apiURL, OwnerRepo: ownerRepo, GitHubAPIURLSet: apiURL != ""}
+		if result.APIURL == "" {
+			result.APIURL = defaultGitHubAPIURL
+		}
+		return result, nil
+	case providerGitLab:
+		apiURL, projectPath, err := getGitLabValues(webhook.GitRepositoryURL)
+		if err != nil {
+			return createResult{}, err
+		}
+		return createResult{APIURL: apiURL, OwnerRepo: projectPath}, nil
+	}
+	return createResult{}, fmt.Errorf("unsupported provider '%s'", webhook.Provider)
+}
+
+// checkWebhookSecret checks that the secret the webhook's event source reads its tokens from, or with the
+// githubapp auth mode its GitHub App key, exists in the install namespace
+func (r Resource) checkWebhookSecret(ctx context.Context, webhook webhook, installNs string) (int, error) {
+	logger := logging.FromContext(ctx)
+	name := webhook.AccessTokenRef
+	if webhook.AuthMode == authModeGitHubApp {
+		name = webhook.GitHubAppKeySecret
+	}
+	if name == "" {
+		err := errors.New("a secret is required, but none was given")
+		logger.Errorf("error: %s.", err.Error())
+		return http.StatusUnprocessableEntity, err
+	}
+	err := r.withAPITimeout(ctx, func() error {
+		_, err := r.K8sClient.CoreV1().Secrets(installNs).Get(name, metav1.GetOptions{})
+		return err
+	})
+	if k8serrors.IsNotFound(err) {
+		err = fmt.Errorf("the secret %s does not exist in namespace %s", name, installNs)
+		logger.Errorf("error: %s.", err.Error())
+		return http.StatusUnprocessableEntity, err
+	}
+	if err != nil {
+		logger.Errorf("error getting the secret %s: %s.", name, err.Error())
+		return apiErrorStatus(err, http.StatusInternalServerError), err
+	}
+	return http.StatusOK, nil
+}
+
+// checkInstallNamespace checks that the namespace event sources are created in exists, returning the http
+// status to respond with if it doesn't or can't be read
+func (r Resource) checkInstallNamespace(ctx context.Context, installNs string) (int, error) {
+	logger := logging.FromContext(ctx)
+	err := r.withAPITimeout(ctx, func() error {
+		_, err := r.K8sClient.CoreV1().Namespaces().Get(installNs, metav1.GetOptions{})
+		return err
+	})
+	if k8serrors.IsNotFound(err) {
+		err = fmt.Errorf("the install namespace %s does not exist", installNs)
+		logger.Errorf("error: %s.", err.Error())
+		return http.StatusBadRequest, err
+	}
+	if err != nil {
+		logger.Errorf("error getting the install namespace %s: %s.", installNs, err.Error())
+		return apiErrorStatus(err, http.StatusInternalServerError), err
+	}
+	return http.StatusOK, nil
+}
+
+// prepareWebhook applies the stored defaults to a webhook being created and validates it, returning every failure
+// found as validationErrors. Any error returned is the client's, and is responded to with 422; 400 is kept for
+// bodies that can't be read.
+func (r Resource) prepareWebhook(ctx context.Context, webhook *webhook) error {
+	logger := logging.FromContext(ctx)
+	// the event source status and install namespace are reported by GET, never stored
+	webhook.Status = nil
+	webhook.InstallNamespace = ""
+	webhook.SourceName = ""
+	if r.Defaults.SourceNamePrefix != "" {
+		webhook.SourceName = r.Defaults.SourceNamePrefix + webhook.Name
+	}
+	var errs validationErrors
+	errs.add("sinks", webhook.validateSinks())
+	for _, source := range webhook.eventSources() {
+		if len(source.Name) > 63 {
+			errs.add("name", fmt.Errorf("event source name (%s) must be less than 64 characters", source.Name))
+		}
+	}
+	// the release name is set as a label and used to generate resource names, so it must be a DNS-1123 label
+	if webhook.ReleaseName != "" {
+		if len(webhook.ReleaseName) > 63 {
+			errs.add("releasename", fmt.Errorf("requested release name (%s) must be less than 64 characters", webhook.ReleaseName))
+		} else if dnsErrs := validation.IsDNS1123Label(webhook.ReleaseName); len(dnsErrs) > 0 {
+			errs.add("releasename", fmt.Errorf("requested release name (%s) is not a valid DNS-1123 label: %s", webhook.ReleaseName, strings.Join(dnsErrs, "; ")))
+		}
+	}
+
+	dockerRegDefault := r.getStoredDefaults(ctx).DockerRegistry
+	if webhook.DockerRegistry == "" && dockerRegDefault != "" {
+		webhook.DockerRegistry = dockerRegDefault
+	}
+	logger.Debugf("Docker registry location is: %s", webhook.DockerRegistry)
+	if webhook.SourceServiceAccount == "" {
+		webhook.SourceServiceAccount = r.Defaults.SourceServiceAccount
+	}
+	if webhook.SourceServiceAccount != "" {
+		if dnsErrs := validation.IsDNS1123Subdomain(webhook.SourceServiceAccount); len(dnsErrs) > 0 {
+			errs.add("sourceserviceaccount", fmt.Errorf("source service account (%s) is not a valid DNS-1123 subdomain: %s", webhook.SourceServiceAccount, strings.Join(dnsErrs, "; ")))
+		}
+	}
+
+	if webhook.Namespace == "" {
+		errs.add("namespace", errors.New("namespace is required, but none was given"))
+	}
+	errs.add("gitrepositoryurl", validateGitRepositoryURL(webhook.GitRepositoryURL))
+	validateSourceMetadata(*webhook, &errs)
+	if webhook.PushOnly && webhook.PROnly {
+		errs.add("pronly", errors.New("pushonly and pronly can not both be set"))
+	}
+	webhook.Organization = ""
+	if webhook.Provider == "" || webhook.Provider == providerGitHub {
+		webhook.Organization, _ = gitHubOrganization(webhook.GitRepositoryURL)
+	}
+	webhook.applyDefaultEventTypes(r.Defaults.DefaultEventTypes)
+	if webhook.DockerRegistry != "" {
+		errs.add("dockerregistry", validateDockerRegistry(webhook.DockerRegistry))
+	} else if pipeline := r.pipelineRequiringDockerRegistry(ctx, *webhook); pipeline != "" {
+		errs.add("dockerregistry", fmt.Errorf("a docker registry is required by pipeline %s, but none was given and there is no default", pipeline))
+	}
+	switch webhook.AuthMode {
+	case "", authModePAT:
+		if webhook.AccessTokenRef == "" {
+			errs.add("accesstoken", errors.New("an accesstoken secret is required, but none was given"))
+		}
+		for _, key := range []struct{ field, name string }{{"accesstokenkey", webhook.AccessTokenKey}, {"secrettokenkey", webhook.SecretTokenKey}} {
+			if keyErrs := validation.IsConfigMapKey(key.name); key.name != "" && len(keyErrs) > 0 {
+				errs.add(key.field, fmt.Errorf("requested secret key %s is not valid: %s", key.name, strings.Join(keyErrs, "; ")))
+			}
+		}
+		if webhook.AccessTokenKey != "" && webhook.AccessTokenKey == webhook.SecretTokenKey {
+			errs.add("secrettokenkey", fmt.Errorf("the access token and secret token can not both be held in key %s", webhook.SecretTokenKey))
+		}
+		if webhook.NoSecret && webhook.SecretTokenKey != "" {
+			errs.add("secrettokenkey", errors.New("secrettokenkey can not be set for a webhook without a secret token"))
+		}
+	case authModeGitHubApp:
+		validateGitHubApp(*webhook, &errs)
+		if webhook.Provider != "" && webhook.Provider != providerGitHub {
+			errs.add("authmode", fmt.Errorf("the %s auth mode is only supported for %s webhooks", authModeGitHubApp, providerGitHub))
+		}
+	default:
+		errs.add("authmode", fmt.Errorf("unsupported auth mode '%s'", webhook.AuthMode))
+	}
+	return errs.asError()
+}
+
+// createSource creates the event source for a webhook from its provider, returning how its URL was
+// interpreted and the http status to respond with on error
+func (r Resource) createSource(ctx context.Context, webhook webhook, installNs string) (createResult, int, error) {
+	switch webhook.Provider {
+	case "", providerGitHub:
+		return r.createGitHubSource(ctx, webhook, installNs)
+	case providerGitLab:
+		return r.createGitLabSource(ctx, webhook, installNs)
+	default:
+		err := fmt.Errorf("unsupported provider '%s'", webhook.Provider)
+		logging.FromContext(ctx).Errorf("error creating webhook: %s.", err.Error())
+		return createResult{}, http.StatusUnprocessableEntity, err
+	}
+}
+
+// validateSourceMetadata checks that the webhook's labels and annotations can be set on its event source,
+// recording each that can't in errs
+func validateSourceMetadata(webhook webhook, errs *validationErrors) {
+	for key, value := range webhook.Labels {
+		if labelErrs := append(validation.IsQualifiedName(key), validation.IsValidLabelValue(value)...); len(labelErrs) > 0 {
+			errs.add("labels", fmt.Errorf("requested label %s=%s is not valid: %s", key, value, strings.Join(labelErrs, "; ")))
+		}
+	}
+	for key := range webhook.Annotations {
+		if annotationErrs := validation.IsQualifiedName(strings.ToLower(key)); len(annotationErrs) > 0 {
+			errs.add("annotations", fmt.Errorf("requested annotation %s is not valid: %s", key, strings.Join(annotationErrs, "; ")))
+		}
+	}
+}
+
+// pipelineRequiringDockerRegistry returns the first of the webhook's pipelines that declares the docker registry
+// param without a default, or "" if none do. Pipelines that don't exist yet, or can't be got in time, are not checked.
+func (r Resource) pipelineRequiringDockerRegistry(ctx context.Context, webhook webhook) string {
+	for _, name := range webhook.pipelineNames() {
+		var pipeline *v1alpha1.Pipeline
+		err := r.withAPITimeout(ctx, func() (err error) {
+			pipeline, err = r.TektonClient.TektonV1alpha1().Pipelines(webhook.Namespace).Get(name, metav1.GetOptions{})
+			return err
+		})
+		if err != nil {
+			continue
+		}
+		for _, param := range pipeline.Spec.Params {
+			if param.Name == r.dockerRegistryParam() && param.Default == "" {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+// validateGitRepositoryURL checks that the repository URL is an absolute http or https URL
+func validateGitRepositoryURL(gitRepositoryURL string) error {
+	parsed, err := url.Parse(gitRepositoryURL)
+	if err != nil {
+		return fmt.Errorf("GitRepositoryURL '%s' could not be parsed: %s", gitRepositoryURL, err.Error())
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return fmt.Errorf("GitRepositoryURL '%s' must use the http or https scheme", gitRepositoryURL)
+	}
+	if parsed.Host == "" {
+		return fmt.Errorf("GitRepositoryURL '%s' must include a host", gitRepositoryURL)
+	}
+	return nil
+}
+
+// getGitHubValues returns the GitHub Enterprise API URL and the owner/repo of a GitHub repository URL.
+// The API URL is empty for github.com repositories, whose sources use the public API.
+func getGitHubValues(gitRepositoryURL string) (apiURL, ownerRepo string, err error) {
+	if org, ok := gitHubOrganization(gitRepositoryURL); ok {
+		// the event source registers an organization webhook when given no repository
+		apiURL = strings.TrimSuffix(strings.TrimSuffix(gitRepositoryURL, "/"), org) + "api/v3/"
+		ownerRepo = org
+	} else {
+		pieces := strings.Split(gitRepositoryURL, "/")
+		if len(pieces) < 4 {
+			return "", "", fmt.Errorf("GitRepositoryURL format error (%s)", gitRepositoryURL)
+		}
+		apiURL = strings.TrimSuffix(gitRepositoryURL, pieces[len(pieces)-2]+"/"+pieces[len(pieces)-1]) + "api/v3/"
+		ownerRepo = pieces[len(pieces)-2] + "/" + strings.TrimSuffix(pieces[len(pieces)-1], ".git")
+	}
+	switch strings.Count(apiURL, ".") {
+	case 1:
+		return "", ownerRepo, nil
+	case 2:
+		return apiURL, ownerRepo, nil
+	}
+	return "", "", fmt.Errorf("parsing git api url '%s'", apiURL)
+}
 
-	logging.Log.Debugf("Creating GitHub source with apiURL: %s and Owner-repo: %s.", apiURL, ownerRepo)
+// createGitHubSource creates the GitHubSource for a webhook, returning how its URL was interpreted
+// and the http status to respond with on error
+func (r Resource) createGitHubSource(ctx context.Context, webhook webhook, installNs string) (createResult, int, error) {
+	logger := logging.FromContext(ctx)
+	apiURL, ownerRepo, err := getGitHubValues(webhook.GitRepositoryURL)
+	if err != nil {
+		logger.Errorf("error creating webhook: %s.", err.Error())
+		return createResult{}, http.StatusUnprocessableEntity, err
+	}
+
+	logger.Debugf("Creating GitHub source with apiURL: %s and Owner-repo: %s.", apiURL, ownerRepo)
 
 	entry := eventapi.GitHubSource{
-		ObjectMeta: metav1.ObjectMeta{Name: webhook.Name},
+		ObjectMeta: metav1.ObjectMeta{
+			Labels:      webhook.sourceLabels(),
+			Annotations: webhook.Annotations,
+		},
 		Spec: eventapi.GitHubSourceSpec{
+			ServiceAccountName: webhook.SourceServiceAccount,
 			OwnerAndRepository: ownerRepo,
-			EventTypes:         []string{"push", "pull_request"},
+			EventTypes:         webhook.gitHubEventTypes(),
 			AccessToken: eventapi.SecretValueFromSource{
 				SecretKeyRef: &corev1.SecretKeySelector{
-					Key: "accessToken",
+					Key: webhook.accessTokenKey(),
 					LocalObjectReference: corev1.LocalObjectReference{
 						Name: webhook.AccessTokenRef,
 					},
 				},
 			},
-			SecretToken: eventapi.SecretValueFromSource{
-				SecretKeyRef: &corev1.SecretKeySelector{
-					Key: "secretToken",
-					LocalObjectReference: corev1.LocalObjectReference{
-						Name: webhook.AccessTokenRef,
-					},
+			GitHubAPIURL: apiURL,
+		},
+	}
+	if !webhook.NoSecret {
+		entry.Spec.SecretToken = eventapi.SecretValueFromSource{
+			SecretKeyRef: &corev1.SecretKeySelector{
+				Key: webhook.secretTokenKey(),
+				LocalObjectReference: corev1.LocalObjectReference{
+					Name: webhook.AccessTokenRef,
 				},
 			},
-			Sink: &corev1.ObjectReference{
-				APIVersion: "serving.knative.dev/v1alpha1",
-				Kind:       "Service",
-				Name:       "webhooks-extension-sink",
-			},
-		},
+		}
 	}
-	if c := strings.Count(apiURL, "."); c == 2 {
-		entry.Spec.GitHubAPIURL = apiURL
-	} else if c != 1 {
-		err := fmt.Errorf("parsing git api url '%s'", apiURL)
-		logging.Log.Errorf("Error %s", err.Error())
-		RespondError(response, err, http.StatusBadRequest)
-		return
+	if ownerRef := r.getSourceOwnerReference(ctx, installNs); ownerRef != nil {
+		entry.ObjectMeta.OwnerReferences = []metav1.OwnerReference{*ownerRef}
+	}
+	// github.com webhooks use the public API rather than the derived URL
+	gitHubAPIURL := entry.Spec.GitHubAPIURL
+	if gitHubAPIURL == "" {
+		gitHubAPIURL = defaultGitHubAPIURL
+	}
+	if webhook.AuthMode == authModeGitHubApp {
+		secretName, err := r.createGitHubAppTokenSecret(ctx, webhook, gitHubAPIURL, installNs)
+		if err != nil {
+			return createResult{}, apiErrorStatus(err, http.StatusBadRequest), err
+		}
+		entry.Spec.AccessToken.SecretKeyRef.Name = secretName
+		entry.Spec.SecretToken.SecretKeyRef.Name = secretName
 	}
-	_, err := r.EventSrcClient.SourcesV1alpha1().GitHubSources(installNs).Create(&entry)
+	if webhook.Organization != "" && webhook.AuthMode != authModeGitHubApp {
+		if err := r.checkOrgHookScope(ctx, webhook, gitHubAPIURL, installNs); err != nil {
+			logger.Errorf("error creating webhook: %s.", err.Error())
+			return createResult{}, http.StatusUnprocessableEntity, err
+		}
+	}
+	var created []*eventapi.GitHubSource
+	status, err := r.createEventSources(ctx, webhook, installNs, "GitHub source", func(source eventSource) error {
+		sourceEntry := entry.DeepCopy()
+		sourceEntry.Name = source.Name
+		sourceEntry.Spec.Sink = source.Sink.objectReference()
+		createdEntry, err := r.EventSrcClient.SourcesV1alpha1().GitHubSources(installNs).Create(sourceEntry)
+		if err == nil {
+			created = append(created, createdEntry)
+		}
+		return err
+	})
 	if err != nil {
-		logging.Log.Errorf("Error creating GitHub source: %s.", err.Error())
-		RespondError(response, err, http.StatusBadRequest)
-		return
+		return createResult{}, status, err
+	}
+	if webhook.AuthMode == authModeGitHubApp {
+		if err := r.ownGitHubAppTokenSecret(ctx, webhook, installNs, created); err != nil {
+			logger.Errorf("error making the GitHub sources of webhook %s the owners of its GitHub App token secret: %s.", webhook.Name, err.Error())
+		}
 	}
-	webhooks, err := r.readGitHubWebhooks(installNs)
+	return createResult{
+		APIURL:          gitHubAPIURL,
+		OwnerRepo:       ownerRepo,
+		GitHubAPIURLSet: entry.Spec.GitHubAPIURL != "",
+	}, http.StatusCreated, nil
+}
+
+// getSourceOwnerReference returns an owner reference to the extension's Deployment so that event sources
+// are garbage collected along with the extension. Nil is returned if the Deployment can't be found.
+func (r Resource) getSourceOwnerReference(ctx context.Context, installNs string) *metav1.OwnerReference {
+	logger := logging.FromContext(ctx)
+	var deployment *appsv1.Deployment
+	err := r.withAPITimeout(ctx, func() (err error) {
+		deployment, err = r.K8sClient.AppsV1().Deployments(installNs).Get(extensionDeploymentName, metav1.GetOptions{})
+		return err
+	})
 	if err != nil {
-		logging.Log.Errorf("error getting GitHub webhooks: %s.", err.Error())
-		RespondError(response, err, http.StatusInternalServerError)
-		return
+		logger.Infof("Creating source without owner reference, could not get deployment %s: %s.", extensionDeploymentName, err.Error())
+		return nil
+	}
+	return &metav1.OwnerReference{
+		APIVersion: "apps/v1",
+		Kind:       "Deployment",
+		Name:       deployment.Name,
+		UID:        deployment.UID,
 	}
-	webhooks[webhook.Name] = webhook
-	r.writeGitHubWebhooks(installNs, webhooks)
-	response.WriteHeader(http.StatusCreated)
 }
 
 func (r Resource) getAllWebhooks(request *restful.Request, response *restful.Response) {
+	ctx := request.Request.Context()
+	logger := logging.FromContext(ctx)
 	// Install namespace
 	installNs := r.Defaults.Namespace
 	if installNs == "" {
 		installNs = "default"
 	}
 
-	logging.Log.Debugf("Get all webhooks in namespace: %s.", installNs)
-	sources, err := r.readGitHubWebhooks(installNs)
-	if err != nil {
-		logging.Log.Errorf("error trying to get webhooks: %s.", err.Error())
-		RespondError(response, err, http.StatusInternalServerError)
+	// webhooks may be listed from the ConfigMap of another namespace, or of every namespace
+	listNamespace := request.QueryParameter("namespace")
+	allNamespaces := request.QueryParameter("allnamespaces") == "true"
+	namespaces := []string{installNs}
+	if listNamespace != "" {
+		namespaces = []string{listNamespace}
+	}
+
+	// the event source status is only fetched on request as it costs an API call per webhook
+	withStatus := request.QueryParameter("status") == "true"
+	if repoURL := request.QueryParameter("repository"); repoURL != "" {
+		if allNamespaces {
+			err := errors.New("allnamespaces can not be used with repository")
+			logger.Errorf("error: %s.", err.Error())
+			RespondError(response, err, http.StatusBadRequest)
+			return
+		}
+		r.getWebhookForRepository(ctx, repoURL, namespaces[0], withStatus, response)
 		return
 	}
+
+	if allNamespaces {
+		var err error
+		namespaces, err = r.listNamespaces(ctx)
+		if err != nil {
+			logger.Errorf("error listing namespaces: %s.", err.Error())
+			RespondError(response, err, apiErrorStatus(err, http.StatusInternalServerError))
+			return
+		}
+	}
 	sourcesList := []webhook{}
-	for _, value := range sources {
-		sourcesList = append(sourcesList, value)
+	for _, namespace := range namespaces {
+		logger.Debugf("Get all webhooks in namespace: %s.", namespace)
+		sources, err := r.readGitHubWebhooks(ctx, namespace)
+		if allNamespaces && k8serrors.IsForbidden(err) {
+			logger.Debugf("Skipping webhooks in namespace %s: %s.", namespace, err.Error())
+			continue
+		}
+		if err != nil {
+			logger.Errorf("error trying to get webhooks: %s.", err.Error())
+			RespondError(response, err, apiErrorStatus(err, http.StatusInternalServerError))
+			return
+		}
+		for _, value := range sources {
+			if withStatus {
+				value = r.withSourceStatus(ctx, value, namespace)
+			}
+			if listNamespace != "" || allNamespaces {
+				value.InstallNamespace = namespace
+			}
+			sourcesList = append(sourcesList, value)
+		}
 	}
 	response.WriteEntity(sourcesList)
 }
 
-// retrieve retistry secret, helm secret and pipeline name for the github url
-func (r Resource) getGitHubWebhook(gitrepourl string, namespace string) (webhook, error) {
-	logging.Log.Debugf("Get GitHub webhook in namespace %s with repositoryURL %s.", namespace, gitrepourl)
+// listNamespaces returns the names of the namespaces in the cluster
+func (r Resource) listNamespaces(ctx context.Context) ([]string, error) {
+	var list *corev1.NamespaceList
+	err := r.withAPITimeout(ctx, func() (err error) {
+		list, err = r.K8sClient.CoreV1().Namespaces().List(metav1.ListOptions{})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(list.Items))
+	for _, namespace := range list.Items {
+		names = append(names, namespace.Name)
+	}
+	return names, nil
+}
 
-	sources, err := r.readGitHubWebhooks(namespace)
+// getWebhookForRepository writes the webhook for the repository URL, or 404 if there is none
+func (r Resource) getWebhookForRepository(ctx context.Context, repoURL string, installNs string, withStatus bool, response *restful.Response) {
+	logger := logging.FromContext(ctx)
+	hook, err := r.getGitHubWebhook(ctx, repoURL, installNs)
+	if err != nil {
+		if err == ErrWebhookNotFound {
+			logger.Debugf("No webhook found for repository %s.", repoURL)
+			RespondErrorAndMessage(response, err, fmt.Sprintf("%s: %s", err.Error(), repoURL), http.StatusNotFound)
+			return
+		}
+		logger.Errorf("error trying to get webhook for repository %s: %s.", repoURL, err.Error())
+		RespondError(response, err, apiErrorStatus(err, http.StatusInternalServerError))
+		return
+	}
+	if withStatus {
+		hook = r.withSourceStatus(ctx, hook, installNs)
+	}
+	response.WriteEntity(hook)
+}
+
+func (r Resource) deleteWebhooksForRepository(request *restful.Request, response *restful.Response) {
+	ctx := request.Request.Context()
+	logger := logging.FromContext(ctx)
+	// Install namespace
+	installNs := r.Defaults.Namespace
+	if installNs == "" {
+		installNs = "default"
+	}
+
+	repoURL := request.QueryParameter("url")
+	if repoURL == "" {
+		err := errors.New("url query parameter is required, but none was given")
+		logger.Errorf("error: %s.", err.Error())
+		RespondError(response, err, http.StatusBadRequest)
+		return
+	}
+
+	logger.Infof("Deleting webhooks for repository %s in namespace %s.", repoURL, installNs)
+	webhooks, err := r.readGitHubWebhooks(ctx, installNs)
+	if err != nil {
+		logger.Errorf("error getting GitHub webhooks: %s.", err.Error())
+		RespondError(response, err, apiErrorStatus(err, http.StatusInternalServerError))
+		return
+	}
+
+	deleted := 0
+	var deletedNames []string
+	var deleteErr error
+	for name, hook := range webhooks {
+		if hook.GitRepositoryURL != repoURL {
+			continue
+		}
+		if hook.AuthMode == authModeGitHubApp && !hook.Paused {
+			// the source's finalizer deletes the webhook registered with GitHub using the installation token
+			if _, err := r.refreshGitHubAppToken(ctx, hook, installNs); err != nil {
+				logger.Errorf("error refreshing the GitHub App token of webhook %s: %s.", name, err.Error())
+			}
+		}
+		err := r.deleteSource(ctx, hook, installNs)
+		if err != nil && !k8serrors.IsNotFound(err) {
+			logger.Errorf("error deleting source %s: %s.", name, err.Error())
+			deleteErr = err
+			continue
+		}
+		delete(webhooks, name)
+		deletedNames = append(deletedNames, name)
+		deleted++
+	}
+
+	if deleted > 0 {
+		err := r.updateGitHubWebhooks(ctx, installNs, func(webhooks map[string]webhook) error {
+			for _, name := range deletedNames {
+				delete(webhooks, name)
+			}
+			return nil
+		})
+		if err != nil {
+			logger.Errorf("error writing GitHub webhooks: %s.", err.Error())
+			RespondError(response, err, apiErrorStatus(err, http.StatusInternalServerError))
+			return
+		}
+	}
+	for _, name := range deletedNames {
+		audit(request, auditOperationDelete, name)
+	}
+	if deleteErr != nil {
+		RespondError(response, deleteErr, apiErrorStatus(deleteErr, http.StatusInternalServerError))
+		return
+	}
+	logger.Infof("Deleted %d webhooks for repository %s.", deleted, repoURL)
+	response.WriteEntity(deleteResult{Deleted: deleted})
+}
+
+// deleteUnstoredSources deletes the event sources created for those of hooks that are not stored after writing
+// them failed, returning their names. A write that failed part way through, or after being applied, may have
+// stored some of them, so the webhooks are read again first. If they can't be, no source is deleted, rather than
+// the source of a stored webhook, and all of hooks are returned.
+func (r Resource) deleteUnstoredSources(ctx context.Context, installNs string, hooks []webhook) []string {
+	logger := logging.FromContext(ctx)
+	names := make([]string, 0, len(hooks))
+	stored, err := r.readGitHubWebhooks(ctx, installNs)
+	if err != nil {
+		logger.Errorf("error reading GitHub webhooks, not deleting the sources of unstored webhooks: %s.", err.Error())
+		for _, hook := range hooks {
+			names = append(names, hook.Name)
+		}
+		return names
+	}
+	for _, hook := range hooks {
+		if existing, ok := stored[hook.Name]; ok && existing.sameAs(hook) {
+			continue
+		}
+		r.deleteUnstoredSource(ctx, hook, installNs)
+		names = append(names, hook.Name)
+	}
+	return names
+}
+
+// deleteUnstoredSource deletes the event sources created for a webhook that could not be stored, so they are not
+// left without a webhook. The sources of a paused webhook were not created.
+func (r Resource) deleteUnstoredSource(ctx context.Context, hook webhook, installNs string) {
+	if hook.Paused {
+		return
+	}
+	if err := r.deleteSource(ctx, hook, installNs); err != nil && !k8serrors.IsNotFound(err) {
+		logging.FromContext(ctx).Errorf("error deleting source of unstored webhook %s: %s.", hook.Name, err.Error())
+	}
+}
+
+// deleteSource deletes the event sources created for a webhook. A not found error is only returned if no other
+// error occurred.
+func (r Resource) deleteSource(ctx context.Context, hook webhook, installNs string) error {
+	var deleteErr error
+	for _, source := range hook.eventSources() {
+		err := r.deleteEventSource(ctx, hook, source.Name, installNs)
+		if err != nil && (deleteErr == nil || k8serrors.IsNotFound(deleteErr)) {
+			deleteErr = err
+		}
+	}
+	return deleteErr
+}
+
+// deleteEventSource deletes the named event source of a webhook
+func (r Resource) deleteEventSource(ctx context.Context, hook webhook, name string, installNs string) error {
+	if hook.Provider == providerGitLab {
+		return r.DynamicClient.Resource(gitLabSourceResource).Namespace(installNs).Delete(name, &metav1.DeleteOptions{})
+	}
+	return r.EventSrcClient.SourcesV1alpha1().GitHubSources(installNs).Delete(name, &metav1.DeleteOptions{})
+}
+
+// retrieve retistry secret, helm secret and pipeline name for the github url, returning ErrWebhookNotFound
+// if no webhook is for it
+func (r Resource) getGitHubWebhook(ctx context.Context, gitrepourl string, namespace string) (webhook, error) {
+	logger := logging.FromContext(ctx)
+	logger.Debugf("Get GitHub webhook in namespace %s with repositoryURL %s.", namespace, gitrepourl)
+
+	sources, err := r.readGitHubWebhooks(ctx, namespace)
 	if err != nil {
 		return webhook{}, err
 	}
@@ -164,72 +906,144 @@ func (r Resource) getGitHubWebhook(gitrepourl string, namespace string) (webhook
 			return source, nil
 		}
 	}
-	return webhook{}, fmt.Errorf("could not find webhook with GitRepositoryURL: %s", gitrepourl)
+	return webhook{}, ErrWebhookNotFound
 }
 
-func (r Resource) readGitHubWebhooks(namespace string) (map[string]webhook, error) {
-	logging.Log.Debugf("Reading GitHub webhooks in namespace %s.", namespace)
-	configMapClient := r.K8sClient.CoreV1().ConfigMaps(namespace)
-	configMap, err := configMapClient.Get(ConfigMapName, metav1.GetOptions{})
+// getWebhookForEvent returns the webhook sent the events of a repository, either the repository's own webhook or
+// a webhook for the repository's organization
+func (r Resource) getWebhookForEvent(ctx context.Context, repoURL string, namespace string) (webhook, error) {
+	hook, err := r.getGitHubWebhook(ctx, repoURL, namespace)
+	if err != ErrWebhookNotFound {
+		return hook, err
+	}
+	sources, err := r.readGitHubWebhooks(ctx, namespace)
 	if err != nil {
-		logging.Log.Debugf("Creating empty configmap because error getting configmap: %s.", err.Error())
-		configMap = &corev1.ConfigMap{}
-		configMap.BinaryData = make(map[string][]byte)
+		return webhook{}, err
 	}
-	raw, ok := configMap.BinaryData["GitHubSource"]
-	var result map[string]webhook
-	if ok {
-		err = json.Unmarshal(raw, &result)
+	orgURL := strings.TrimSuffix(repoURL, "/")
+	if i := strings.LastIndex(orgURL, "/"); i >= 0 {
+		orgURL = orgURL[:i]
+	}
+	for _, source := range sources {
+		if source.Organization != "" && strings.TrimSuffix(source.GitRepositoryURL, "/") == orgURL {
+			return source, nil
+		}
+	}
+	return webhook{}, ErrWebhookNotFound
+}
+
+// ErrWebhookNotFound is returned when no webhook exists for a repository URL. Any other error
+// means the webhooks could not be read.
+var ErrWebhookNotFound = errors.New("could not find webhook with GitRepositoryURL")
+
+// configMapName returns the name of the ConfigMap this install stores its webhooks in
+func (r Resource) configMapName() string {
+	if r.Defaults.ConfigMapName == "" {
+		return ConfigMapName
+	}
+	return r.Defaults.ConfigMapName
+}
+
+func (r Resource) readGitHubWebhooks(ctx context.Context, namespace string) (map[string]webhook, error) {
+	logger := logging.FromContext(ctx)
+	logger.Debugf("Reading GitHub webhooks in namespace %s.", namespace)
+	defer observeConfigMap("read", time.Now())
+	if r.storesWebhookCRs() {
+		result, err := r.readWebhookCRs(ctx, namespace)
 		if err != nil {
-			logging.Log.Errorf("error unmarshalling in readGitHubSource: %s", err.Error())
+			logger.Errorf("error listing webhooks: %s.", err.Error())
 			return map[string]webhook{}, err
 		}
-	} else {
+		return result, nil
+	}
+	configMap, err := r.getConfigMap(ctx, namespace)
+	if err != nil && !k8serrors.IsNotFound(err) {
+		logger.Errorf("error getting configmap for GitHub webhooks: %s.", err.Error())
+		return map[string]webhook{}, err
+	}
+	if err != nil {
+		logger.Debugf("Creating empty configmap because error getting configmap: %s.", err.Error())
+		configMap = &corev1.ConfigMap{}
+		configMap.Data = make(map[string]string)
+	}
+	result, err := decodeGitHubWebhooks(configMapValue(configMap, configMapKey))
+	if err != nil {
+		logger.Errorf("error unmarshalling in readGitHubSource: %s", err.Error())
+		return map[string]webhook{}, err
+	}
+	logger.Debugf("Found GitHub sources: %v.", result)
+	return result, nil
+}
+
+// decodeGitHubWebhooks decodes the webhooks stored in the ConfigMap, an empty map if none are stored
+func decodeGitHubWebhooks(raw []byte, found bool) (map[string]webhook, error) {
+	result := make(map[string]webhook)
+	if !found {
+		return result, nil
+	}
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return nil, err
+	}
+	if result == nil {
 		result = make(map[string]webhook)
 	}
-	logging.Log.Debugf("Found GitHub sources: %v.", result)
 	return result, nil
 }
 
-func (r Resource) writeGitHubWebhooks(namespace string, sources map[string]webhook) error {
-	logging.Log.Debugf("In writeGitHubWebhooks, namespace: %s, webhooks found: %+v", namespace, sources)
-	configMapClient := r.K8sClient.CoreV1().ConfigMaps(namespace)
-	configMap, err := configMapClient.Get(ConfigMapName, metav1.GetOptions{})
-	var create = false
-	if err != nil {
-		configMap = &corev1.ConfigMap{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:      ConfigMapName,
-				Namespace: namespace,
-			},
-		}
-		configMap.BinaryData = make(map[string][]byte)
-		create = true
+// writeGitHubWebhooks replaces the webhooks stored in namespace with sources
+func (r Resource) writeGitHubWebhooks(ctx context.Context, namespace string, sources map[string]webhook) error {
+	logger := logging.FromContext(ctx)
+	logger.Debugf("In writeGitHubWebhooks, namespace: %s, webhooks found: %+v", namespace, sources)
+	defer observeConfigMap("write", time.Now())
+	if r.storesWebhookCRs() {
+		return r.updateWebhookCRs(ctx, namespace, func(webhooks map[string]webhook) error {
+			for name := range webhooks {
+				delete(webhooks, name)
+			}
+			for name, hook := range sources {
+				webhooks[name] = hook
+			}
+			return nil
+		})
 	}
 	buf, err := json.Marshal(sources)
 	if err != nil {
-		logging.Log.Errorf("error marshalling GitHub webhooks: %s.", err.Error())
+		logger.Errorf("error marshalling GitHub webhooks: %s.", err.Error())
 		return err
 	}
-	configMap.BinaryData["GitHubSource"] = buf
-	if create {
-		_, err = configMapClient.Create(configMap)
+	return r.writeConfigMapKey(ctx, namespace, configMapKey, func([]byte, bool) ([]byte, error) {
+		return buf, nil
+	})
+}
+
+// updateGitHubWebhooks changes the webhooks stored in namespace with mutate and writes them back. mutate is
+// passed the stored webhooks each time the write is tried, so webhooks written in between by another replica or
+// request are kept. Webhooks stored as Webhooks are written one at a time, so a retry may pass mutate webhooks
+// its earlier try stored already; mutate must accept those. An error returned by mutate is returned without
+// writing.
+func (r Resource) updateGitHubWebhooks(ctx context.Context, namespace string, mutate func(webhooks map[string]webhook) error) error {
+	logger := logging.FromContext(ctx)
+	defer observeConfigMap("write", time.Now())
+	if r.storesWebhookCRs() {
+		return r.updateWebhookCRs(ctx, namespace, mutate)
+	}
+	return r.writeConfigMapKey(ctx, namespace, configMapKey, func(raw []byte, found bool) ([]byte, error) {
+		webhooks, err := decodeGitHubWebhooks(raw, found)
 		if err != nil {
-			logging.Log.Errorf("error creating configmap for GitHub webhooks: %s.", err.Error())
-			return err
+			logger.Errorf("error unmarshalling GitHub webhooks: %s.", err.Error())
+			return nil, err
 		}
-	} else {
-		_, err = configMapClient.Update(configMap)
+		if err := mutate(webhooks); err != nil {
+			return nil, err
+		}
+		logger.Debugf("In updateGitHubWebhooks, namespace: %s, webhooks: %+v", namespace, webhooks)
+		buf, err := json.Marshal(webhooks)
 		if err != nil {
-			logging.Log.Errorf("error updating configmap for GitHub webhooks: %s.", err.Error())
+			logger.Errorf("error marshalling GitHub webhooks: %s.", err.Error())
+			return nil, err
 		}
-	}
-	return nil
-}
-
-func (r Resource) getDefaults(request *restful.Request, response *restful.Response) {
-	logging.Log.Debugf("getDefaults returning: %v", r.Defaults)
-	response.WriteEntity(r.Defaults)
+		return buf, nil
+	})
 }
 
 // RespondError ...
@@ -255,17 +1069,52 @@ func RespondErrorAndMessage(response *restful.Response, err error, message strin
 	response.WriteErrorString(statusCode, message)
 }
 
+// requestIDFilter adds a logger carrying the request's correlation ID to the request context. The ID is taken
+// from the X-Request-ID header, or generated when the header is absent, and is returned on the response.
+func requestIDFilter(request *restful.Request, response *restful.Response, chain *restful.FilterChain) {
+	requestID := request.HeaderParameter(requestIDHeader)
+	if requestID == "" {
+		requestID = generateRequestID()
+	}
+	logger := logging.Log.With("requestID", requestID)
+	request.Request = request.Request.WithContext(logging.WithLogger(request.Request.Context(), logger))
+	response.AddHeader(requestIDHeader, requestID)
+	chain.ProcessFilter(request, response)
+}
+
+// generateRequestID returns a random (version 4) UUID
+func generateRequestID() string {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	buf[6] = (buf[6] & 0x0f) | 0x40
+	buf[8] = (buf[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", buf[0:4], buf[4:6], buf[6:8], buf[8:10], buf[10:])
+}
+
 // ExtensionWebService returns the webhook webservice
 func ExtensionWebService(r Resource) *restful.WebService {
 	ws := new(restful.WebService)
 	ws.
 		Path("/webhooks").
 		Consumes(restful.MIME_JSON, restful.MIME_JSON).
-		Produces(restful.MIME_JSON, restful.MIME_JSON)
+		Produces(restful.MIME_JSON, restful.MIME_JSON).
+		Filter(requestIDFilter).
+		Filter(r.requestBodyLimitFilter)
 
-	ws.Route(ws.POST("/").To(r.createWebhook))
-	ws.Route(ws.GET("/").To(r.getAllWebhooks))
-	ws.Route(ws.GET("/defaults").To(r.getDefaults))
+	ws.Route(ws.POST("/").To(instrument(metricsOperationCreate, r.createWebhook)))
+	ws.Route(ws.POST("/batch").To(instrument(metricsOperationBatchCreate, r.createWebhooks)))
+	ws.Route(ws.POST("/validate").To(instrument(metricsOperationValidate, r.validateWebhook)))
+	ws.Route(ws.GET("/").To(instrument(metricsOperationGet, r.getAllWebhooks)))
+	ws.Route(ws.GET("/export").Produces(exportMIMEType).To(instrument(metricsOperationExport, r.exportWebhooks)))
+	ws.Route(ws.POST("/import").Consumes(exportMIMEType, restful.MIME_JSON).To(instrument(metricsOperationImport, r.importWebhooks)))
+	ws.Route(ws.GET("/defaults").To(instrument(metricsOperationGetDefaults, r.getDefaults)))
+	ws.Route(ws.PUT("/defaults").To(instrument(metricsOperationUpdateDefaults, r.updateDefaults)))
+	ws.Route(ws.POST("/{name}/pause").To(instrument(metricsOperationPause, r.pauseWebhook)))
+	ws.Route(ws.POST("/{name}/unpause").To(instrument(metricsOperationUnpause, r.unpauseWebhook)))
+	ws.Route(ws.GET("/{name}/pipelineruns").To(instrument(metricsOperationPipelineRuns, r.getWebhookPipelineRuns)))
+	ws.Route(ws.POST("/{name}/rotate-secret").To(instrument(metricsOperationRotateSecret, r.rotateWebhookSecret)))
+	ws.Route(ws.POST("/{name}/test").To(instrument(metricsOperationTestDelivery, r.sendTestDelivery)))
+	ws.Route(ws.DELETE("/repository").To(instrument(metricsOperationDelete, r.deleteWebhooksForRepository)))
 
 	return ws
 }
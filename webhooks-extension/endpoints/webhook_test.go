@@ -15,14 +15,59 @@ package endpoints
 
 import (
 	"bytes"
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
 	restful "github.com/emicklei/go-restful"
+	eventapi "github.com/knative/eventing-sources/pkg/apis/sources/v1alpha1"
+	eventsrcclient "github.com/knative/eventing-sources/pkg/client/clientset/versioned/fake"
+	duckv1alpha1 "github.com/knative/pkg/apis/duck/v1alpha1"
+	logging "github.com/tektoncd/experimental/webhooks-extension/pkg/logging"
+	pipelinesv1alpha1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1alpha1"
+	fakeclientset "github.com/tektoncd/pipeline/pkg/client/clientset/versioned/fake"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"hash"
+	"io/ioutil"
+	"math/big"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
 	"reflect"
+	"sort"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	fakedynamic "k8s.io/client-go/dynamic/fake"
+	fakek8sclientset "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
 )
 
 const default_registry = "default.docker.reg:8500/foo"
@@ -178,15 +223,15 @@ func testGetAllWebhooks(expectedWebhooks []webhook, r *Resource, t *testing.T) {
 		return
 	}
 
-	// Now compare the arrays expectedWebhooks and actualWebhooks by turning them into maps
-	expected := map[webhook]bool{}
-	actual := map[webhook]bool{}
+	// Now compare the arrays expectedWebhooks and actualWebhooks by turning them into maps keyed by name
+	expected := map[string]webhook{}
+	actual := map[string]webhook{}
 	for i := range expectedWebhooks {
 		if expectedWebhooks[i].DockerRegistry == "" {
 			expectedWebhooks[i].DockerRegistry = default_registry
 		}
-		expected[expectedWebhooks[i]] = true
-		actual[actualWebhooks[i]] = true
+		expected[expectedWebhooks[i].Name] = expectedWebhooks[i]
+		actual[actualWebhooks[i].Name] = actualWebhooks[i]
 	}
 
 	if !reflect.DeepEqual(expected, actual) {
@@ -210,7 +255,4002 @@ func testGithubSourceReleaseNameTooLong(r *Resource, t *testing.T) {
 	// Create the first entry
 	resp := createWebhook(data, r)
 
-	if resp.StatusCode() != http.StatusBadRequest {
-		t.Error("Expected a bad request when the release name exceeded 63 chars")
+	if resp.StatusCode() != http.StatusUnprocessableEntity {
+		t.Error("Expected an unprocessable entity when the release name exceeded 63 chars")
+	}
+}
+
+func TestReleaseNameValidation(t *testing.T) {
+	tests := []struct {
+		name           string
+		releaseName    string
+		expectedStatus int
+	}{
+		{
+			name:           "valid name",
+			releaseName:    "my-release-1",
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name:           "valid single character",
+			releaseName:    "a",
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name:           "uppercase",
+			releaseName:    "MyRelease",
+			expectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:           "leading dash",
+			releaseName:    "-myrelease",
+			expectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:           "invalid character",
+			releaseName:    "my_release",
+			expectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:           "over length",
+			releaseName:    "1234567891234567891234567891234567891234567891234567891234567890",
+			expectedStatus: http.StatusUnprocessableEntity,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := dummyResource()
+			data := webhook{
+				Name:             "name1",
+				Namespace:        "test",
+				GitRepositoryURL: "https://github.com/owner/repo.git",
+				AccessTokenRef:   "token1",
+				Pipeline:         "pipeline1",
+				ReleaseName:      tt.releaseName,
+			}
+			resp := createWebhook(data, r)
+			if resp.StatusCode() != tt.expectedStatus {
+				t.Errorf("Release name %q: expected status %d, but was %d", tt.releaseName, tt.expectedStatus, resp.StatusCode())
+			}
+		})
+	}
+}
+
+func TestConfigMapMigration(t *testing.T) {
+	r := dummyResource()
+	installNs := "default"
+	oldWebhooks := map[string]webhook{
+		"name1": {
+			Name:             "name1",
+			Namespace:        "test",
+			GitRepositoryURL: "https://github.com/owner/repo",
+			AccessTokenRef:   "token1",
+			Pipeline:         "pipeline1",
+		},
+	}
+	buf, _ := json.Marshal(oldWebhooks)
+	oldConfigMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ConfigMapName,
+			Namespace: installNs,
+		},
+		BinaryData: map[string][]byte{
+			"GitHubSource": buf,
+		},
+	}
+	_, err := r.K8sClient.CoreV1().ConfigMaps(installNs).Create(oldConfigMap)
+	if err != nil {
+		t.Fatalf("Error creating old format configmap: %s", err.Error())
+	}
+
+	// Old format entries are read from BinaryData
+	webhooks, err := r.readGitHubWebhooks(installNs)
+	if err != nil {
+		t.Fatalf("Error reading old format configmap: %s", err.Error())
+	}
+	if !reflect.DeepEqual(oldWebhooks, webhooks) {
+		t.Errorf("Webhook error: expected: \n%v \nbut received \n%v", oldWebhooks, webhooks)
+	}
+
+	// Writing moves the entries to Data
+	err = r.writeGitHubWebhooks(installNs, webhooks)
+	if err != nil {
+		t.Fatalf("Error writing configmap: %s", err.Error())
+	}
+	configMap, err := r.K8sClient.CoreV1().ConfigMaps(installNs).Get(ConfigMapName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Error getting configmap: %s", err.Error())
+	}
+	if _, ok := configMap.BinaryData["GitHubSource"]; ok {
+		t.Error("Expected GitHubSource to be removed from configmap BinaryData")
+	}
+	newWebhooks := map[string]webhook{}
+	err = json.Unmarshal([]byte(configMap.Data["GitHubSource"]), &newWebhooks)
+	if err != nil {
+		t.Fatalf("Error unmarshalling configmap Data: %s", err.Error())
+	}
+	if !reflect.DeepEqual(oldWebhooks, newWebhooks) {
+		t.Errorf("Webhook error: expected: \n%v \nbut received \n%v", oldWebhooks, newWebhooks)
+	}
+}
+
+func deleteWebhooksForRepository(repoURL string, r *Resource) (*httptest.ResponseRecorder, *restful.Response) {
+	httpReq := dummyHTTPRequest("DELETE", "http://wwww.dummy.com:8080/webhooks/repository?url="+url.QueryEscape(repoURL), nil)
+	req := dummyRestfulRequest(httpReq, "", "")
+	httpWriter := httptest.NewRecorder()
+	resp := dummyRestfulResponse(httpWriter)
+	r.deleteWebhooksForRepository(req, resp)
+	return httpWriter, resp
+}
+
+func TestDeleteWebhooksForRepository(t *testing.T) {
+	r := dummyResource()
+	installNs := "default"
+	sources := []webhook{
+		{
+			Name:             "name1",
+			Namespace:        "test",
+			GitRepositoryURL: "https://github.com/owner/repo",
+			AccessTokenRef:   "token1",
+			Pipeline:         "pipeline1",
+			DockerRegistry:   "registry1",
+		},
+		{
+			Name:             "name2",
+			Namespace:        "test",
+			GitRepositoryURL: "https://github.com/owner/repo",
+			AccessTokenRef:   "token2",
+			Pipeline:         "pipeline2",
+			DockerRegistry:   "registry1",
+		},
+		{
+			Name:             "name3",
+			Namespace:        "test",
+			GitRepositoryURL: "https://github.com/owner/other",
+			AccessTokenRef:   "token3",
+			Pipeline:         "pipeline3",
+			DockerRegistry:   "registry1",
+		},
+	}
+	for _, source := range sources {
+		createWebhook(source, r)
+	}
+
+	httpWriter, resp := deleteWebhooksForRepository("https://github.com/owner/repo", r)
+	if resp.StatusCode() != http.StatusOK {
+		t.Fatalf("Expected status %d, but was %d", http.StatusOK, resp.StatusCode())
+	}
+	result := deleteResult{}
+	if err := json.NewDecoder(httpWriter.Body).Decode(&result); err != nil {
+		t.Fatalf("Error decoding result into deleteResult{}: %s", err.Error())
+	}
+	if result.Deleted != 2 {
+		t.Errorf("Expected 2 webhooks to be deleted, but was %d", result.Deleted)
+	}
+
+	for _, name := range []string{"name1", "name2"} {
+		_, err := r.EventSrcClient.SourcesV1alpha1().GitHubSources(installNs).Get(name, metav1.GetOptions{})
+		if err == nil {
+			t.Errorf("Expected GitHubSource %s to be deleted", name)
+		}
+	}
+	testGitHubSource("name3", "owner/other", "", installNs, r, t)
+	testGetAllWebhooks(sources[2:], r, t)
+}
+
+func TestDeleteWebhooksForRepositoryNoMatch(t *testing.T) {
+	r := dummyResource()
+	source := webhook{
+		Name:             "name1",
+		Namespace:        "test",
+		GitRepositoryURL: "https://github.com/owner/repo",
+		AccessTokenRef:   "token1",
+		Pipeline:         "pipeline1",
+		DockerRegistry:   "registry1",
+	}
+	createWebhook(source, r)
+
+	httpWriter, resp := deleteWebhooksForRepository("https://github.com/owner/missing", r)
+	if resp.StatusCode() != http.StatusOK {
+		t.Fatalf("Expected status %d, but was %d", http.StatusOK, resp.StatusCode())
+	}
+	result := deleteResult{}
+	if err := json.NewDecoder(httpWriter.Body).Decode(&result); err != nil {
+		t.Fatalf("Error decoding result into deleteResult{}: %s", err.Error())
+	}
+	if result.Deleted != 0 {
+		t.Errorf("Expected 0 webhooks to be deleted, but was %d", result.Deleted)
+	}
+	testGetAllWebhooks([]webhook{source}, r, t)
+}
+
+func TestGitLabSource(t *testing.T) {
+	r := dummyResource()
+	installNs := "default"
+	source := webhook{
+		Name:             "name1",
+		Namespace:        "test",
+		GitRepositoryURL: "https://gitlab.com/group/subgroup/project.git",
+		AccessTokenRef:   "token1",
+		Pipeline:         "pipeline1",
+		DockerRegistry:   "registry1",
+		Provider:         "gitlab",
+	}
+	resp := createWebhook(source, r)
+	if resp.StatusCode() != http.StatusCreated {
+		t.Fatalf("Expected status %d, but was %d", http.StatusCreated, resp.StatusCode())
+	}
+
+	glSrc, err := r.DynamicClient.Resource(gitLabSourceResource).Namespace(installNs).Get(source.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("GitLabSource %s was not found in namespace %s: %s", source.Name, installNs, err.Error())
+	}
+	projectURL, _, _ := unstructured.NestedString(glSrc.Object, "spec", "projectUrl")
+	if projectURL != "https://gitlab.com/group/subgroup/project" {
+		t.Errorf("Incorrect projectUrl, expected https://gitlab.com/group/subgroup/project but was: %s", projectURL)
+	}
+	secretName, _, _ := unstructured.NestedString(glSrc.Object, "spec", "accessToken", "secretKeyRef", "name")
+	if secretName != "token1" {
+		t.Errorf("Incorrect accessToken secret name, expected token1 but was: %s", secretName)
+	}
+
+	// No GitHubSource should be created for a GitLab webhook
+	_, err = r.EventSrcClient.SourcesV1alpha1().GitHubSources(installNs).Get(source.Name, metav1.GetOptions{})
+	if err == nil {
+		t.Errorf("Expected no GitHubSource to be created for GitLab webhook %s", source.Name)
+	}
+	testGetAllWebhooks([]webhook{source}, r, t)
+}
+
+func TestGetGitLabValues(t *testing.T) {
+	tests := []struct {
+		url                 string
+		expectedAPIURL      string
+		expectedProjectPath string
+		expectError         bool
+	}{
+		{
+			url:                 "https://gitlab.com/owner/repo",
+			expectedAPIURL:      "https://gitlab.com/api/v4/",
+			expectedProjectPath: "owner/repo",
+		},
+		{
+			url:                 "https://gitlab.company.com/group/subgroup/repo.git",
+			expectedAPIURL:      "https://gitlab.company.com/api/v4/",
+			expectedProjectPath: "group/subgroup/repo",
+		},
+		{
+			url:         "https://gitlab.com/repo",
+			expectError: true,
+		},
+	}
+	for _, tt := range tests {
+		apiURL, projectPath, err := getGitLabValues(tt.url)
+		if tt.expectError {
+			if err == nil {
+				t.Errorf("Expected an error parsing %s", tt.url)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unexpected error parsing %s: %s", tt.url, err.Error())
+			continue
+		}
+		if apiURL != tt.expectedAPIURL {
+			t.Errorf("Incorrect API URL for %s, expected %s but was: %s", tt.url, tt.expectedAPIURL, apiURL)
+		}
+		if projectPath != tt.expectedProjectPath {
+			t.Errorf("Incorrect project path for %s, expected %s but was: %s", tt.url, tt.expectedProjectPath, projectPath)
+		}
+	}
+}
+
+func TestGitHubSourceOwnerReference(t *testing.T) {
+	r := dummyResource()
+	installNs := "default"
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "webhooks-extension",
+			Namespace: installNs,
+			UID:       types.UID("deployment-uid"),
+		},
+	}
+	_, err := r.K8sClient.AppsV1().Deployments(installNs).Create(deployment)
+	if err != nil {
+		t.Fatalf("Error creating deployment: %s", err.Error())
+	}
+	source := webhook{
+		Name:             "name1",
+		Namespace:        "test",
+		GitRepositoryURL: "https://github.com/owner/repo",
+		AccessTokenRef:   "token1",
+		Pipeline:         "pipeline1",
+	}
+	createWebhook(source, r)
+
+	ghSrc, err := r.EventSrcClient.SourcesV1alpha1().GitHubSources(installNs).Get(source.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("GitHubSource %s was not found in namespace %s: %s", source.Name, installNs, err.Error())
+	}
+	expected := []metav1.OwnerReference{
+		{
+			APIVersion: "apps/v1",
+			Kind:       "Deployment",
+			Name:       "webhooks-extension",
+			UID:        types.UID("deployment-uid"),
+		},
+	}
+	if !reflect.DeepEqual(expected, ghSrc.OwnerReferences) {
+		t.Errorf("Incorrect owner references, expected %+v but was: %+v", expected, ghSrc.OwnerReferences)
+	}
+}
+
+func TestGitHubSourceNoOwnerReference(t *testing.T) {
+	r := dummyResource()
+	installNs := "default"
+	source := webhook{
+		Name:             "name1",
+		Namespace:        "test",
+		GitRepositoryURL: "https://github.com/owner/repo",
+		AccessTokenRef:   "token1",
+		Pipeline:         "pipeline1",
+	}
+	resp := createWebhook(source, r)
+	if resp.StatusCode() != http.StatusCreated {
+		t.Fatalf("Expected status %d when the extension deployment is missing, but was %d", http.StatusCreated, resp.StatusCode())
+	}
+	ghSrc, err := r.EventSrcClient.SourcesV1alpha1().GitHubSources(installNs).Get(source.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("GitHubSource %s was not found in namespace %s: %s", source.Name, installNs, err.Error())
+	}
+	if len(ghSrc.OwnerReferences) != 0 {
+		t.Errorf("Expected no owner references, but was: %+v", ghSrc.OwnerReferences)
+	}
+}
+
+// rewriteTransport sends all requests to the target server
+type rewriteTransport struct {
+	target *url.URL
+}
+
+func (t rewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req.URL.Scheme = t.target.Scheme
+	req.URL.Host = t.target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestGitHubAppAuthMode(t *testing.T) {
+	r := dummyResource()
+	installNs := "default"
+
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("Error generating private key: %s", err.Error())
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	keySecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "app-key", Namespace: installNs},
+		Data:       map[string][]byte{"privateKey": keyPEM},
+	}
+	if _, err := r.K8sClient.CoreV1().Secrets(installNs).Create(keySecret); err != nil {
+		t.Fatalf("Error creating private key secret: %s", err.Error())
+	}
+
+	minted := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost || req.URL.Path != "/app/installations/42/access_tokens" {
+			t.Errorf("Unexpected request %s %s", req.Method, req.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		jwt := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+		parts := strings.Split(jwt, ".")
+		if len(parts) != 3 {
+			t.Errorf("Expected a bearer JWT, but Authorization header was: %s", req.Header.Get("Authorization"))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		signature, _ := base64.RawURLEncoding.DecodeString(parts[2])
+		hash := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+		if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, hash[:], signature); err != nil {
+			t.Errorf("JWT signature did not verify: %s", err.Error())
+		}
+		rawClaims, _ := base64.RawURLEncoding.DecodeString(parts[1])
+		claims := map[string]interface{}{}
+		json.Unmarshal(rawClaims, &claims)
+		if claims["iss"] != float64(7) {
+			t.Errorf("Expected JWT issuer 7, but was: %v", claims["iss"])
+		}
+		minted++
+		token := "installation-token"
+		if minted > 1 {
+			token = fmt.Sprintf("installation-token-%d", minted)
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"token": "` + token + `"}`))
+	}))
+	defer server.Close()
+	serverURL, _ := url.Parse(server.URL)
+	defaultClient := gitHubAPIClient
+	gitHubAPIClient = &http.Client{Transport: rewriteTransport{target: serverURL}}
+	defer func() { gitHubAPIClient = defaultClient }()
+
+	source := webhook{
+		Name:                    "name1",
+		Namespace:               "test",
+		GitRepositoryURL:        "https://github.com/owner/repo",
+		Pipeline:                "pipeline1",
+		AuthMode:                "githubapp",
+		GitHubAppID:             7,
+		GitHubAppInstallationID: 42,
+		GitHubAppKeySecret:      "app-key",
+	}
+	resp := createWebhook(source, r)
+	if resp.StatusCode() != http.StatusCreated {
+		t.Fatalf("Expected status %d, but was %d", http.StatusCreated, resp.StatusCode())
+	}
+
+	tokenSecret, err := r.K8sClient.CoreV1().Secrets(installNs).Get("name1-github-app-token", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Installation token secret was not created: %s", err.Error())
+	}
+	if tokenSecret.StringData["accessToken"] != "installation-token" {
+		t.Errorf("Incorrect accessToken, expected installation-token but was: %s", tokenSecret.StringData["accessToken"])
+	}
+	if tokenSecret.StringData["secretToken"] == "" {
+		t.Error("Expected a generated secretToken")
+	}
+
+	ghSrc, err := r.EventSrcClient.SourcesV1alpha1().GitHubSources(installNs).Get(source.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("GitHubSource %s was not found in namespace %s: %s", source.Name, installNs, err.Error())
+	}
+	if name := ghSrc.Spec.AccessToken.SecretKeyRef.Name; name != "name1-github-app-token" {
+		t.Errorf("Incorrect accessToken secret, expected name1-github-app-token but was: %s", name)
+	}
+	if name := ghSrc.Spec.SecretToken.SecretKeyRef.Name; name != "name1-github-app-token" {
+		t.Errorf("Incorrect secretToken secret, expected name1-github-app-token but was: %s", name)
+	}
+	// the secret is garbage collected along with the webhook's source
+	tokenSecret, err = r.K8sClient.CoreV1().Secrets(installNs).Get("name1-github-app-token", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Error getting installation token secret: %s", err.Error())
+	}
+	expectedOwners := []metav1.OwnerReference{{APIVersion: "sources.eventing.knative.dev/v1alpha1", Kind: "GitHubSource", Name: ghSrc.Name, UID: ghSrc.UID}}
+	if !reflect.DeepEqual(tokenSecret.OwnerReferences, expectedOwners) {
+		t.Errorf("Expected the installation token secret to be owned by %+v, but was %+v", expectedOwners, tokenSecret.OwnerReferences)
+	}
+
+	// installation tokens expire, so a new one is minted, keeping the secret token
+	secretToken := tokenSecret.StringData["secretToken"]
+	r.refreshGitHubAppTokens(context.Background(), installNs)
+	tokenSecret, err = r.K8sClient.CoreV1().Secrets(installNs).Get("name1-github-app-token", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Error getting installation token secret: %s", err.Error())
+	}
+	if token := tokenSecret.StringData["accessToken"]; token != "installation-token-2" {
+		t.Errorf("Expected the installation token to be refreshed to installation-token-2, but was: %s", token)
+	}
+	if token := tokenSecret.StringData["secretToken"]; token != secretToken {
+		t.Errorf("Expected the secretToken %q to be kept, but was %q", secretToken, token)
+	}
+
+	// a new token is minted for the source's finalizer before the webhook is deleted
+	if httpWriter, _ := deleteWebhooksForRepository(source.GitRepositoryURL, r); httpWriter.Code != http.StatusOK {
+		t.Fatalf("Delete webhook returned %d, expected 200: %s", httpWriter.Code, httpWriter.Body.String())
+	}
+	if minted != 3 {
+		t.Errorf("Expected an installation token to be minted before deleting the webhook, %d were minted", minted)
+	}
+}
+
+func TestGitHubAppAuthModeValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		webhook webhook
+	}{
+		{
+			name: "missing app id",
+			webhook: webhook{
+				AuthMode:                "githubapp",
+				GitHubAppInstallationID: 42,
+				GitHubAppKeySecret:      "app-key",
+			},
+		},
+		{
+			name: "missing installation id",
+			webhook: webhook{
+				AuthMode:           "githubapp",
+				GitHubAppID:        7,
+				GitHubAppKeySecret: "app-key",
+			},
+		},
+		{
+			name: "missing private key secret",
+			webhook: webhook{
+				AuthMode:                "githubapp",
+				GitHubAppID:             7,
+				GitHubAppInstallationID: 42,
+			},
+		},
+		{
+			name: "gitlab provider",
+			webhook: webhook{
+				Provider:                "gitlab",
+				AuthMode:                "githubapp",
+				GitHubAppID:             7,
+				GitHubAppInstallationID: 42,
+				GitHubAppKeySecret:      "app-key",
+			},
+		},
+		{
+			name: "unknown auth mode",
+			webhook: webhook{
+				AuthMode: "oauth",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := dummyResource()
+			tt.webhook.Name = "name1"
+			tt.webhook.Namespace = "test"
+			tt.webhook.GitRepositoryURL = "https://github.com/owner/repo"
+			tt.webhook.Pipeline = "pipeline1"
+			resp := createWebhook(tt.webhook, r)
+			if resp.StatusCode() != http.StatusUnprocessableEntity {
+				t.Errorf("Expected status %d, but was %d", http.StatusUnprocessableEntity, resp.StatusCode())
+			}
+		})
+	}
+}
+
+func TestRequestIDLogging(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	defaultLogger := logging.Log
+	logging.Log = zap.New(core).Sugar()
+	defer func() { logging.Log = defaultLogger }()
+
+	r := dummyResource()
+	container := restful.NewContainer()
+	container.Add(ExtensionWebService(*r))
+
+	source := webhook{
+		Name:             "name1",
+		Namespace:        "test",
+		GitRepositoryURL: "https://github.com/owner/repo",
+		AccessTokenRef:   "token1",
+		Pipeline:         "pipeline1",
+	}
+	b, _ := json.Marshal(source)
+	httpReq := dummyHTTPRequest("POST", "http://wwww.dummy.com:8080/webhooks", bytes.NewBuffer(b))
+	httpReq.Header.Set("X-Request-ID", "test-request-id")
+	httpWriter := httptest.NewRecorder()
+	container.ServeHTTP(httpWriter, httpReq)
+
+	if httpWriter.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, but was %d", http.StatusCreated, httpWriter.Code)
+	}
+	if id := httpWriter.Header().Get("X-Request-ID"); id != "test-request-id" {
+		t.Errorf("Expected X-Request-ID response header test-request-id, but was: %s", id)
+	}
+	if n := logs.FilterField(zap.String("requestID", "test-request-id")).Len(); n < 2 {
+		t.Errorf("Expected the request ID on multiple log lines, but found it on %d", n)
+	}
+
+	// A request ID is generated when the header is absent
+	httpReq = dummyHTTPRequest("GET", "http://wwww.dummy.com:8080/webhooks", nil)
+	httpWriter = httptest.NewRecorder()
+	container.ServeHTTP(httpWriter, httpReq)
+	id := httpWriter.Header().Get("X-Request-ID")
+	if id == "" {
+		t.Fatal("Expected a generated X-Request-ID response header")
+	}
+	if n := logs.FilterField(zap.String("requestID", id)).Len(); n < 2 {
+		t.Errorf("Expected the generated request ID on multiple log lines, but found it on %d", n)
+	}
+}
+
+func getWebhookForRepository(repoURL string, r *Resource) (*httptest.ResponseRecorder, *restful.Response) {
+	httpReq := dummyHTTPRequest("GET", "http://wwww.dummy.com:8080/webhooks/?repository="+url.QueryEscape(repoURL), nil)
+	req := dummyRestfulRequest(httpReq, "", "")
+	httpWriter := httptest.NewRecorder()
+	resp := dummyRestfulResponse(httpWriter)
+	r.getAllWebhooks(req, resp)
+	return httpWriter, resp
+}
+
+func TestGetWebhookForRepository(t *testing.T) {
+	r := dummyResource()
+	sources := []webhook{
+		{
+			Name:             "name1",
+			Namespace:        "test",
+			GitRepositoryURL: "https://github.com/owner/repo",
+			AccessTokenRef:   "token1",
+			Pipeline:         "pipeline1",
+			DockerRegistry:   "registry1",
+		},
+		{
+			Name:             "name2",
+			Namespace:        "test",
+			GitRepositoryURL: "https://github.com/owner/other",
+			AccessTokenRef:   "token2",
+			Pipeline:         "pipeline2",
+			DockerRegistry:   "registry2",
+		},
+	}
+	for _, source := range sources {
+		createWebhook(source, r)
+	}
+
+	httpWriter, resp := getWebhookForRepository("https://github.com/owner/other", r)
+	if resp.StatusCode() != http.StatusOK {
+		t.Fatalf("Expected status %d, but was %d", http.StatusOK, resp.StatusCode())
+	}
+	actual := webhook{}
+	if err := json.NewDecoder(httpWriter.Body).Decode(&actual); err != nil {
+		t.Fatalf("Error decoding result into webhook{}: %s", err.Error())
+	}
+	if !reflect.DeepEqual(actual, sources[1]) {
+		t.Errorf("Webhook error: expected: \n%v \nbut received \n%v", sources[1], actual)
+	}
+
+	// Without the query parameter all webhooks are returned
+	testGetAllWebhooks(sources, r, t)
+}
+
+func TestGetWebhookForRepositoryNotFound(t *testing.T) {
+	r := dummyResource()
+	createWebhook(webhook{
+		Name:             "name1",
+		Namespace:        "test",
+		GitRepositoryURL: "https://github.com/owner/repo",
+		AccessTokenRef:   "token1",
+		Pipeline:         "pipeline1",
+		DockerRegistry:   "registry1",
+	}, r)
+
+	_, resp := getWebhookForRepository("https://github.com/owner/missing", r)
+	if resp.StatusCode() != http.StatusNotFound {
+		t.Errorf("Expected status %d, but was %d", http.StatusNotFound, resp.StatusCode())
+	}
+}
+
+func TestGetGitHubWebhookErrors(t *testing.T) {
+	r := dummyResource()
+	createWebhook(webhook{
+		Name:             "name1",
+		Namespace:        "test",
+		GitRepositoryURL: "https://github.com/owner/repo",
+		AccessTokenRef:   "token1",
+		Pipeline:         "pipeline1",
+		DockerRegistry:   "registry1",
+	}, r)
+
+	if _, err := r.getGitHubWebhook(context.Background(), "https://github.com/owner/missing", "default"); err != ErrWebhookNotFound {
+		t.Errorf("Expected ErrWebhookNotFound for a missing repository, got %v", err)
+	}
+
+	readErr := errors.New("configmaps is forbidden")
+	r.K8sClient.(*fakek8sclientset.Clientset).PrependReactor("get", "configmaps", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, readErr
+	})
+	if _, err := r.getGitHubWebhook(context.Background(), "https://github.com/owner/repo", "default"); err != readErr {
+		t.Errorf("Expected the configmap read error, got %v", err)
+	}
+	httpWriter, _ := getWebhookForRepository("https://github.com/owner/repo", r)
+	if httpWriter.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status %d when the webhooks can't be read, but was %d", http.StatusInternalServerError, httpWriter.Code)
+	}
+}
+
+func TestCreateWebhookWriteErrorDeletesSource(t *testing.T) {
+	r := dummyResource()
+	writeErr := k8serrors.NewForbidden(schema.GroupResource{Resource: "configmaps"}, ConfigMapName, errors.New("not allowed"))
+	failWrite := func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, writeErr
+	}
+	r.K8sClient.(*fakek8sclientset.Clientset).PrependReactor("create", "configmaps", failWrite)
+	r.K8sClient.(*fakek8sclientset.Clientset).PrependReactor("update", "configmaps", failWrite)
+
+	httpWriter := createWebhookRecorder(webhook{
+		Name:             "name1",
+		Namespace:        "test",
+		GitRepositoryURL: "https://github.com/owner/repo",
+		AccessTokenRef:   "token1",
+		Pipeline:         "pipeline1",
+	}, r)
+	if httpWriter.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status %d when the webhook can't be stored, got %d", http.StatusInternalServerError, httpWriter.Code)
+	}
+	if _, err := r.EventSrcClient.SourcesV1alpha1().GitHubSources("default").Get("name1", metav1.GetOptions{}); !k8serrors.IsNotFound(err) {
+		t.Errorf("Expected the GitHubSource of the unstored webhook to be deleted, got %v", err)
+	}
+
+	httpWriter = createWebhooksRecorder([]webhook{{
+		Name:             "name2",
+		Namespace:        "test",
+		GitRepositoryURL: "https://github.com/owner/other",
+		AccessTokenRef:   "token1",
+		Pipeline:         "pipeline1",
+	}}, r)
+	if httpWriter.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status %d when the batch can't be stored, got %d", http.StatusInternalServerError, httpWriter.Code)
+	}
+	if _, err := r.EventSrcClient.SourcesV1alpha1().GitHubSources("default").Get("name2", metav1.GetOptions{}); !k8serrors.IsNotFound(err) {
+		t.Errorf("Expected the GitHubSource of the unstored batch webhook to be deleted, got %v", err)
+	}
+}
+
+func TestWatchGitHubSourcesDeletion(t *testing.T) {
+	r := dummyResource()
+	hooks := []webhook{
+		{
+			Name:             "name1",
+			Namespace:        "foo",
+			GitRepositoryURL: "https://github.com/owner/repo",
+			AccessTokenRef:   "token1",
+			Pipeline:         "pipeline1",
+			DockerRegistry:   "registry1",
+		},
+		{
+			Name:             "name2",
+			Namespace:        "foo",
+			GitRepositoryURL: "https://github.com/owner/other",
+			AccessTokenRef:   "token1",
+			Pipeline:         "pipeline1",
+			DockerRegistry:   "registry1",
+		},
+		{
+			Name:             "name3",
+			Namespace:        "foo",
+			GitRepositoryURL: "https://github.com/owner/third",
+			AccessTokenRef:   "token1",
+			Pipeline:         "pipeline1",
+			DockerRegistry:   "registry1",
+			Sinks: []sinkReference{
+				defaultSink,
+				{APIVersion: "serving.knative.dev/v1alpha1", Kind: "Service", Name: "security-scan"},
+			},
+		},
+	}
+	for _, hook := range hooks {
+		if resp := createWebhook(hook, r); resp.StatusCode() != http.StatusCreated {
+			t.Fatalf("Create webhook returned %d, expected 201", resp.StatusCode())
+		}
+	}
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	if err := r.WatchGitHubSources(stopCh); err != nil {
+		t.Fatalf("Error watching GitHub sources: %s", err.Error())
+	}
+	// the source of the second sink of name3 is named name3-1
+	for _, sourceName := range []string{"name1", "name3-1"} {
+		if err := r.EventSrcClient.SourcesV1alpha1().GitHubSources("default").Delete(sourceName, &metav1.DeleteOptions{}); err != nil {
+			t.Fatalf("Error deleting GitHub source %s: %s", sourceName, err.Error())
+		}
+	}
+
+	var stored map[string]webhook
+	for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(10 * time.Millisecond) {
+		var err error
+		stored, err = r.readGitHubWebhooks(context.Background(), "default")
+		if err != nil {
+			t.Fatalf("Error reading webhooks: %s", err.Error())
+		}
+		_, ok1 := stored["name1"]
+		_, ok3 := stored["name3"]
+		if !ok1 && !ok3 {
+			break
+		}
+	}
+	if _, ok := stored["name1"]; ok {
+		t.Error("Expected the webhook of the deleted GitHub source to be removed")
+	}
+	if _, ok := stored["name3"]; ok {
+		t.Error("Expected the webhook of the deleted GitHub source of its second sink to be removed")
+	}
+	if _, ok := stored["name2"]; !ok {
+		t.Error("Expected the webhook of the remaining GitHub source to be kept")
+	}
+}
+
+func TestSourceNamePrefix(t *testing.T) {
+	r := updateResourceDefaults(dummyResource(), EnvDefaults{Namespace: "default", SourceNamePrefix: "team-"})
+	hook := webhook{
+		Name:             "name1",
+		Namespace:        "foo",
+		GitRepositoryURL: "https://github.com/owner/repo",
+		AccessTokenRef:   "token1",
+		Pipeline:         "pipeline1",
+		DockerRegistry:   "registry1",
+		SourceName:       "ignored",
+	}
+	if resp := createWebhook(hook, r); resp.StatusCode() != http.StatusCreated {
+		t.Fatalf("Create webhook returned %d, expected 201", resp.StatusCode())
+	}
+	testGitHubSource("team-name1", "owner/repo", "", "default", r, t)
+	stored, err := r.readGitHubWebhooks(context.Background(), "default")
+	if err != nil {
+		t.Fatalf("Error reading webhooks: %s", err.Error())
+	}
+	if name := stored["name1"].SourceName; name != "team-name1" {
+		t.Errorf("Expected the source name team-name1 to be stored, got %q", name)
+	}
+
+	// the stored source name is used to delete the source
+	httpReq := dummyHTTPRequest("DELETE", "http://wwww.dummy.com:8080/webhooks/repository?url="+url.QueryEscape(hook.GitRepositoryURL), nil)
+	httpWriter := httptest.NewRecorder()
+	r.deleteWebhooksForRepository(dummyRestfulRequest(httpReq, "", ""), dummyRestfulResponse(httpWriter))
+	if httpWriter.Code != http.StatusOK {
+		t.Fatalf("Delete webhooks returned %d, expected 200: %s", httpWriter.Code, httpWriter.Body.String())
+	}
+	if _, err := r.EventSrcClient.SourcesV1alpha1().GitHubSources("default").Get("team-name1", metav1.GetOptions{}); err == nil {
+		t.Error("Expected the prefixed GitHub source to be deleted")
+	}
+
+	long := hook
+	long.Name = strings.Repeat("a", 60)
+	if resp := createWebhook(long, r); resp.StatusCode() != http.StatusUnprocessableEntity {
+		t.Errorf("Create webhook with a source name over 63 characters returned %d, expected 422", resp.StatusCode())
+	}
+}
+
+func TestConfigMapNamePerRelease(t *testing.T) {
+	shared := dummyResource()
+	first := updateResourceDefaults(shared, EnvDefaults{Namespace: "default", ConfigMapName: configMapNameForRelease("first")})
+	second := updateResourceDefaults(shared, EnvDefaults{Namespace: "default", ConfigMapName: configMapNameForRelease("second")})
+	if first.configMapName() == second.configMapName() {
+		t.Fatalf("Expected different configmap names, both were %s", first.configMapName())
+	}
+
+	firstHook := webhook{
+		Name:             "name1",
+		Namespace:        "test",
+		GitRepositoryURL: "https://github.com/owner/repo",
+		AccessTokenRef:   "token1",
+		Pipeline:         "pipeline1",
+		DockerRegistry:   "registry1",
+	}
+	secondHook := webhook{
+		Name:             "name2",
+		Namespace:        "test",
+		GitRepositoryURL: "https://github.com/owner/other",
+		AccessTokenRef:   "token2",
+		Pipeline:         "pipeline2",
+		DockerRegistry:   "registry2",
+	}
+	createWebhook(firstHook, first)
+	createWebhook(secondHook, second)
+
+	testGetAllWebhooks([]webhook{firstHook}, first, t)
+	testGetAllWebhooks([]webhook{secondHook}, second, t)
+	for _, name := range []string{"first-githubwebhook", "second-githubwebhook"} {
+		if _, err := shared.K8sClient.CoreV1().ConfigMaps("default").Get(name, metav1.GetOptions{}); err != nil {
+			t.Errorf("Expected configmap %s to exist: %s", name, err.Error())
+		}
+	}
+}
+
+func TestConfigMapNameForRelease(t *testing.T) {
+	if name := configMapNameForRelease(""); name != ConfigMapName {
+		t.Errorf("Expected %s with no release name, but was %s", ConfigMapName, name)
+	}
+	if name := configMapNameForRelease("myrelease"); name != "myrelease-githubwebhook" {
+		t.Errorf("Expected myrelease-githubwebhook, but was %s", name)
+	}
+	r := Resource{}
+	if name := r.configMapName(); name != ConfigMapName {
+		t.Errorf("Expected %s when no name is configured, but was %s", ConfigMapName, name)
+	}
+}
+
+func TestGitRepositoryURLValidation(t *testing.T) {
+	tests := []struct {
+		name             string
+		gitRepositoryURL string
+		expectedStatus   int
+	}{
+		{
+			name:             "valid url",
+			gitRepositoryURL: "https://github.com/owner/repo.git",
+			expectedStatus:   http.StatusCreated,
+		},
+		{
+			name:             "missing scheme",
+			gitRepositoryURL: "github.com/owner/repo",
+			expectedStatus:   http.StatusUnprocessableEntity,
+		},
+		{
+			name:             "unsupported scheme",
+			gitRepositoryURL: "git://github.com/owner/repo",
+			expectedStatus:   http.StatusUnprocessableEntity,
+		},
+		{
+			name:             "missing host",
+			gitRepositoryURL: "https:///owner/repo",
+			expectedStatus:   http.StatusUnprocessableEntity,
+		},
+		{
+			name:             "not a url",
+			gitRepositoryURL: "not a url",
+			expectedStatus:   http.StatusUnprocessableEntity,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := dummyResource()
+			data := webhook{
+				Name:             "name1",
+				Namespace:        "test",
+				GitRepositoryURL: tt.gitRepositoryURL,
+				AccessTokenRef:   "token1",
+				Pipeline:         "pipeline1",
+			}
+			resp := createWebhook(data, r)
+			if resp.StatusCode() != tt.expectedStatus {
+				t.Errorf("GitRepositoryURL %q: expected status %d, but was %d", tt.gitRepositoryURL, tt.expectedStatus, resp.StatusCode())
+			}
+			if tt.expectedStatus == http.StatusUnprocessableEntity {
+				_, err := r.EventSrcClient.SourcesV1alpha1().GitHubSources("default").Get("name1", metav1.GetOptions{})
+				if err == nil {
+					t.Errorf("Expected no GitHubSource to be created for GitRepositoryURL %q", tt.gitRepositoryURL)
+				}
+			}
+		})
+	}
+}
+
+func TestCreateWebhookResponse(t *testing.T) {
+	tests := []struct {
+		name             string
+		provider         string
+		gitRepositoryURL string
+		expected         createResult
+	}{
+		{
+			name:             "enterprise",
+			gitRepositoryURL: "https://github.company.com/owner2/repo2",
+			expected: createResult{
+				APIURL:          "https://github.company.com/api/v3/",
+				OwnerRepo:       "owner2/repo2",
+				GitHubAPIURLSet: true,
+			},
+		},
+		{
+			name:             "github.com",
+			gitRepositoryURL: "https://github.com/owner/repo.git",
+			expected: createResult{
+				APIURL:    defaultGitHubAPIURL,
+				OwnerRepo: "owner/repo",
+			},
+		},
+		{
+			name:             "gitlab",
+			provider:         providerGitLab,
+			gitRepositoryURL: "https://gitlab.company.com/group/project.git",
+			expected: createResult{
+				APIURL:    "https://gitlab.company.com/api/v4/",
+				OwnerRepo: "group/project",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := dummyResource()
+			b, _ := json.Marshal(webhook{
+				Name:             "name1",
+				Namespace:        "test",
+				GitRepositoryURL: tt.gitRepositoryURL,
+				AccessTokenRef:   "token1",
+				Pipeline:         "pipeline1",
+				Provider:         tt.provider,
+			})
+			httpReq := dummyHTTPRequest("POST", "http://wwww.dummy.com:8080/webhook/", bytes.NewBuffer(b))
+			req := dummyRestfulRequest(httpReq, "", "")
+			httpWriter := httptest.NewRecorder()
+			resp := dummyRestfulResponse(httpWriter)
+			r.createWebhook(req, resp)
+			if resp.StatusCode() != http.StatusCreated {
+				t.Fatalf("Expected status %d, but was %d", http.StatusCreated, resp.StatusCode())
+			}
+
+			actual := createResult{}
+			if err := json.NewDecoder(httpWriter.Body).Decode(&actual); err != nil {
+				t.Fatalf("Error decoding result into createResult{}: %s", err.Error())
+			}
+			if actual != tt.expected {
+				t.Errorf("Create result error: expected %+v, but received %+v", tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestMultiplePipelineWebhooks(t *testing.T) {
+	r := dummyResource()
+	sources := []webhook{
+		{
+			Name:             "single",
+			Namespace:        "test",
+			GitRepositoryURL: "https://github.com/owner/single",
+			AccessTokenRef:   "token1",
+			Pipeline:         "build",
+			DockerRegistry:   "registry1",
+		},
+		{
+			Name:             "multi",
+			Namespace:        "test",
+			GitRepositoryURL: "https://github.com/owner/multi",
+			AccessTokenRef:   "token2",
+			Pipelines:        []string{"build", "image-scan"},
+			DockerRegistry:   "registry1",
+		},
+	}
+	for _, source := range sources {
+		if resp := createWebhook(source, r); resp.StatusCode() != http.StatusCreated {
+			t.Fatalf("Expected status %d creating %s, but was %d", http.StatusCreated, source.Name, resp.StatusCode())
+		}
+	}
+
+	// One event source is created per webhook, the sink runs each of its pipelines
+	testGitHubSource("single", "owner/single", "", "default", r, t)
+	testGitHubSource("multi", "owner/multi", "", "default", r, t)
+	testGetAllWebhooks(sources, r, t)
+
+	for _, name := range []string{"build", "image-scan"} {
+		pipeline := &pipelinesv1alpha1.Pipeline{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "test"}}
+		if _, err := r.TektonClient.TektonV1alpha1().Pipelines("test").Create(pipeline); err != nil {
+			t.Fatalf("Error creating pipeline %s: %s", name, err.Error())
+		}
+	}
+	tests := []struct {
+		repoURL           string
+		expectedPipelines []string
+	}{
+		{repoURL: "https://github.com/owner/single", expectedPipelines: []string{"build"}},
+		{repoURL: "https://github.com/owner/multi", expectedPipelines: []string{"build", "image-scan"}},
+	}
+	for _, tt := range tests {
+		before, _ := r.TektonClient.TektonV1alpha1().PipelineRuns("test").List(metav1.ListOptions{})
+		existing := map[string]bool{}
+		for _, run := range before.Items {
+			existing[run.Name] = true
+		}
+
+		buildInformation := BuildInformation{
+			REPOURL:   tt.repoURL,
+			SHORTID:   "abc1234",
+			COMMITID:  "abc1234def5678",
+			REPONAME:  "repo",
+			TIMESTAMP: getDateTimeAsString(),
+		}
+		createPipelineRunFromWebhookData(context.Background(), buildInformation, *r)
+
+		after, err := r.TektonClient.TektonV1alpha1().PipelineRuns("test").List(metav1.ListOptions{})
+		if err != nil {
+			t.Fatalf("Error listing pipelineruns: %s", err.Error())
+		}
+		triggered := []string{}
+		for _, run := range after.Items {
+			if !existing[run.Name] {
+				triggered = append(triggered, run.Spec.PipelineRef.Name)
+			}
+		}
+		sort.Strings(triggered)
+		if !reflect.DeepEqual(triggered, tt.expectedPipelines) {
+			t.Errorf("Repository %s: expected pipelines %v to be run, but ran %v", tt.repoURL, tt.expectedPipelines, triggered)
+		}
+	}
+}
+
+func TestDockerRegistryParam(t *testing.T) {
+	tests := []struct {
+		name          string
+		param         string
+		expectedParam string
+	}{
+		{name: "default", expectedParam: "docker-registry"},
+		{name: "configured", param: "registry", expectedParam: "registry"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := updateResourceDefaults(dummyResource(), EnvDefaults{Namespace: "default", DockerRegistry: default_registry, DockerRegistryParam: tt.param})
+			pipeline := &pipelinesv1alpha1.Pipeline{ObjectMeta: metav1.ObjectMeta{Name: "build", Namespace: "test"}}
+			if _, err := r.TektonClient.TektonV1alpha1().Pipelines("test").Create(pipeline); err != nil {
+				t.Fatalf("Error creating pipeline: %s", err.Error())
+			}
+			// the registry is taken from the defaults and stored with the webhook
+			hook := webhook{
+				Name:             "name1",
+				Namespace:        "test",
+				GitRepositoryURL: "https://github.com/owner/repo",
+				AccessTokenRef:   "token1",
+				Pipeline:         "build",
+			}
+			if resp := createWebhook(hook, r); resp.StatusCode() != http.StatusCreated {
+				t.Fatalf("Create webhook returned %d, expected 201", resp.StatusCode())
+			}
+
+			buildInformation := BuildInformation{
+				REPOURL:   hook.GitRepositoryURL,
+				SHORTID:   "abc1234",
+				COMMITID:  "abc1234def5678",
+				REPONAME:  "repo",
+				TIMESTAMP: getDateTimeAsString(),
+			}
+			createPipelineRunFromWebhookData(context.Background(), buildInformation, *r)
+
+			runs, err := r.TektonClient.TektonV1alpha1().PipelineRuns("test").List(metav1.ListOptions{})
+			if err != nil {
+				t.Fatalf("Error listing pipelineruns: %s", err.Error())
+			}
+			if len(runs.Items) != 1 {
+				t.Fatalf("Expected one pipelinerun, got %d", len(runs.Items))
+			}
+			found := false
+			for _, param := range runs.Items[0].Spec.Params {
+				if param.Name == tt.expectedParam {
+					found = true
+					if param.Value != default_registry {
+						t.Errorf("Expected param %s to be %s, but was %s", tt.expectedParam, default_registry, param.Value)
+					}
+				}
+			}
+			if !found {
+				t.Errorf("Expected param %s on the pipelinerun, params were %v", tt.expectedParam, runs.Items[0].Spec.Params)
+			}
+		})
+	}
+}
+
+func TestPipelineNames(t *testing.T) {
+	tests := []struct {
+		name     string
+		webhook  webhook
+		expected []string
+	}{
+		{name: "single", webhook: webhook{Pipeline: "build"}, expected: []string{"build"}},
+		{name: "list", webhook: webhook{Pipelines: []string{"build", "scan"}}, expected: []string{"build", "scan"}},
+		{name: "both", webhook: webhook{Pipeline: "build", Pipelines: []string{"scan", "build"}}, expected: []string{"build", "scan"}},
+		{name: "none", webhook: webhook{}, expected: []string{}},
+	}
+	for _, tt := range tests {
+		if names := tt.webhook.pipelineNames(); !reflect.DeepEqual(names, tt.expected) {
+			t.Errorf("%s: expected %v, but was %v", tt.name, tt.expected, names)
+		}
+	}
+}
+
+func updateDefaults(defaults EnvDefaults, r *Resource) *httptest.ResponseRecorder {
+	b, _ := json.Marshal(defaults)
+	httpReq := dummyHTTPRequest("PUT", "http://wwww.dummy.com:8080/webhooks/defaults", bytes.NewBuffer(b))
+	req := dummyRestfulRequest(httpReq, "", "")
+	httpWriter := httptest.NewRecorder()
+	resp := dummyRestfulResponse(httpWriter)
+	r.updateDefaults(req, resp)
+	return httpWriter
+}
+
+func TestUpdateDefaults(t *testing.T) {
+	r := dummyResource()
+
+	httpWriter := updateDefaults(EnvDefaults{DockerRegistry: "registry.example.com:5000/team"}, r)
+	if httpWriter.Code != http.StatusOK {
+		t.Fatalf("Update defaults returned %d, expected 200: %s", httpWriter.Code, httpWriter.Body.String())
+	}
+	updated := EnvDefaults{}
+	if err := json.NewDecoder(httpWriter.Body).Decode(&updated); err != nil {
+		t.Fatalf("Error decoding result into defaults{}: %s", err.Error())
+	}
+	if updated.DockerRegistry != "registry.example.com:5000/team" || updated.Namespace != "default" {
+		t.Errorf("Update defaults returned unexpected defaults: %+v", updated)
+	}
+
+	// The new defaults are returned by GET, survive a restart and are used for new webhooks
+	restarted := updateResourceDefaults(r, dummyDefaults())
+	defaults := getEnvDefaults(restarted, t)
+	if defaults.DockerRegistry != "registry.example.com:5000/team" {
+		t.Errorf("Expected the updated docker registry after restart, got: %s", defaults.DockerRegistry)
+	}
+	hook := webhook{
+		Name:             "name1",
+		Namespace:        "foo",
+		GitRepositoryURL: "https://github.com/owner/repo",
+		AccessTokenRef:   "token1",
+		Pipeline:         "pipeline1",
+	}
+	createWebhook(hook, restarted)
+	hooks, err := restarted.readGitHubWebhooks(context.Background(), "default")
+	if err != nil {
+		t.Fatalf("Error reading webhooks: %s", err.Error())
+	}
+	if hooks["name1"].DockerRegistry != "registry.example.com:5000/team" {
+		t.Errorf("Expected the webhook to use the updated docker registry, got: %s", hooks["name1"].DockerRegistry)
+	}
+}
+
+func TestUpdateDefaultsInvalid(t *testing.T) {
+	r := dummyResource()
+	invalid := []EnvDefaults{
+		{DockerRegistry: ""},
+		{DockerRegistry: "https://registry.example.com"},
+		{DockerRegistry: "registry.example.com/Team"},
+		{DockerRegistry: "registry example.com"},
+		{DockerRegistry: "docker.io/myorg", Namespace: "other"},
+	}
+	for _, defaults := range invalid {
+		httpWriter := updateDefaults(defaults, r)
+		if httpWriter.Code != http.StatusBadRequest {
+			t.Errorf("Update defaults with %+v returned %d, expected 400", defaults, httpWriter.Code)
+		}
+	}
+	defaults := getEnvDefaults(r, t)
+	if defaults.DockerRegistry != "" {
+		t.Errorf("Expected the docker registry to be unchanged, got: %s", defaults.DockerRegistry)
+	}
+}
+
+func TestDockerRegistryValidation(t *testing.T) {
+	pipeline := &pipelinesv1alpha1.Pipeline{
+		ObjectMeta: metav1.ObjectMeta{Name: "needs-registry", Namespace: "foo"},
+		Spec: pipelinesv1alpha1.PipelineSpec{
+			Params: []pipelinesv1alpha1.PipelineParam{{Name: "docker-registry"}},
+		},
+	}
+	tests := []struct {
+		name           string
+		dockerRegistry string
+		pipeline       string
+		defaults       EnvDefaults
+		expectedStatus int
+		expectedReg    string
+	}{
+		{name: "valid", dockerRegistry: "registry.example.com:5000/team", pipeline: "pipeline1", defaults: dummyDefaults(), expectedStatus: http.StatusCreated, expectedReg: "registry.example.com:5000/team"},
+		{name: "malformed", dockerRegistry: "my registry", pipeline: "pipeline1", defaults: dummyDefaults(), expectedStatus: http.StatusUnprocessableEntity},
+		{name: "scheme", dockerRegistry: "https://registry.example.com", pipeline: "pipeline1", defaults: dummyDefaults(), expectedStatus: http.StatusUnprocessableEntity},
+		{name: "empty with default", pipeline: "needs-registry", defaults: EnvDefaults{Namespace: "default", DockerRegistry: default_registry}, expectedStatus: http.StatusCreated, expectedReg: default_registry},
+		{name: "empty without default", pipeline: "needs-registry", defaults: dummyDefaults(), expectedStatus: http.StatusUnprocessableEntity},
+		{name: "empty not required", pipeline: "pipeline1", defaults: dummyDefaults(), expectedStatus: http.StatusCreated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := updateResourceDefaults(dummyResource(), tt.defaults)
+			if _, err := r.TektonClient.TektonV1alpha1().Pipelines("foo").Create(pipeline); err != nil {
+				t.Fatalf("Error creating pipeline: %s", err.Error())
+			}
+			hook := webhook{
+				Name:             "name1",
+				Namespace:        "foo",
+				GitRepositoryURL: "https://github.com/owner/repo",
+				AccessTokenRef:   "token1",
+				Pipeline:         tt.pipeline,
+				DockerRegistry:   tt.dockerRegistry,
+			}
+			resp := createWebhook(hook, r)
+			if resp.StatusCode() != tt.expectedStatus {
+				t.Fatalf("Create webhook returned %d, expected %d", resp.StatusCode(), tt.expectedStatus)
+			}
+			if tt.expectedStatus != http.StatusCreated {
+				return
+			}
+			hooks, err := r.readGitHubWebhooks(context.Background(), "default")
+			if err != nil {
+				t.Fatalf("Error reading webhooks: %s", err.Error())
+			}
+			if hooks["name1"].DockerRegistry != tt.expectedReg {
+				t.Errorf("Expected docker registry %q, got %q", tt.expectedReg, hooks["name1"].DockerRegistry)
+			}
+		})
+	}
+}
+
+func TestWebhookSourceLabels(t *testing.T) {
+	r := dummyResource()
+	hook := webhook{
+		Name:             "name1",
+		Namespace:        "foo",
+		GitRepositoryURL: "https://github.com/owner/repo",
+		AccessTokenRef:   "token1",
+		Pipeline:         "pipeline1",
+		DockerRegistry:   "registry1",
+		Labels:           map[string]string{"cost-center": "1234", "team": "web"},
+		Annotations:      map[string]string{"example.com/owner": "web-team"},
+	}
+	resp := createWebhook(hook, r)
+	if resp.StatusCode() != http.StatusCreated {
+		t.Fatalf("Create webhook returned %d, expected 201", resp.StatusCode())
+	}
+
+	source, err := r.EventSrcClient.SourcesV1alpha1().GitHubSources("default").Get("name1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Error getting GitHub source: %s", err.Error())
+	}
+	if !reflect.DeepEqual(source.Labels, hook.Labels) {
+		t.Errorf("Expected source labels %v, got %v", hook.Labels, source.Labels)
+	}
+	if !reflect.DeepEqual(source.Annotations, hook.Annotations) {
+		t.Errorf("Expected source annotations %v, got %v", hook.Annotations, source.Annotations)
+	}
+
+	// GET reflects the labels and annotations
+	testGetAllWebhooks([]webhook{hook}, r, t)
+
+	invalid := hook
+	invalid.Name = "name2"
+	invalid.GitRepositoryURL = "https://github.com/owner/repo2"
+	invalid.Labels = map[string]string{"team": "not a valid value"}
+	if resp := createWebhook(invalid, r); resp.StatusCode() != http.StatusUnprocessableEntity {
+		t.Errorf("Create webhook with an invalid label returned %d, expected 422", resp.StatusCode())
+	}
+}
+
+func TestCreateWebhookConflict(t *testing.T) {
+	hook := webhook{
+		Name:             "name1",
+		Namespace:        "foo",
+		GitRepositoryURL: "https://github.com/owner/repo",
+		AccessTokenRef:   "token1",
+		Pipeline:         "pipeline1",
+		DockerRegistry:   "registry1",
+	}
+
+	// A webhook with the same name is already in the configmap
+	r := dummyResource()
+	if resp := createWebhook(hook, r); resp.StatusCode() != http.StatusCreated {
+		t.Fatalf("Create webhook returned %d, expected 201", resp.StatusCode())
+	}
+	duplicate := hook
+	duplicate.GitRepositoryURL = "https://github.com/owner/other"
+	httpWriter := createWebhookRecorder(duplicate, r)
+	if httpWriter.Code != http.StatusConflict {
+		t.Errorf("Create duplicate webhook returned %d, expected 409", httpWriter.Code)
+	}
+	if !strings.Contains(httpWriter.Body.String(), "name1") {
+		t.Errorf("Expected the conflict message to name the webhook, got: %s", httpWriter.Body.String())
+	}
+	testGetAllWebhooks([]webhook{hook}, r, t)
+
+	// A GitHub source with the same name exists but is not in the configmap
+	r = dummyResource()
+	existing := &eventapi.GitHubSource{ObjectMeta: metav1.ObjectMeta{Name: "name1", Namespace: "default"}}
+	if _, err := r.EventSrcClient.SourcesV1alpha1().GitHubSources("default").Create(existing); err != nil {
+		t.Fatalf("Error creating GitHub source: %s", err.Error())
+	}
+	httpWriter = createWebhookRecorder(hook, r)
+	if httpWriter.Code != http.StatusConflict {
+		t.Errorf("Create webhook with an existing source returned %d, expected 409", httpWriter.Code)
+	}
+	if !strings.Contains(httpWriter.Body.String(), "name1") {
+		t.Errorf("Expected the conflict message to name the webhook, got: %s", httpWriter.Body.String())
+	}
+	testGetAllWebhooks([]webhook{}, r, t)
+}
+
+func TestCreateWebhookIdempotent(t *testing.T) {
+	hook := webhook{
+		Name:             "name1",
+		Namespace:        "foo",
+		GitRepositoryURL: "https://github.com/owner/repo",
+		AccessTokenRef:   "token1",
+		Pipeline:         "pipeline1",
+		PushOnly:         true,
+		Labels:           map[string]string{},
+	}
+	r := dummyResource()
+	if resp := createWebhook(hook, r); resp.StatusCode() != http.StatusCreated {
+		t.Fatalf("Create webhook returned %d, expected 201", resp.StatusCode())
+	}
+	stored, err := r.readGitHubWebhooks(context.Background(), "default")
+	if err != nil {
+		t.Fatalf("Error reading webhooks: %s", err.Error())
+	}
+
+	// The same webhook posted again is returned as it is stored, the empty labels are not stored
+	httpWriter := createWebhookRecorder(hook, r)
+	if httpWriter.Code != http.StatusOK {
+		t.Fatalf("Create identical webhook returned %d, expected 200: %s", httpWriter.Code, httpWriter.Body.String())
+	}
+	returned := webhook{}
+	if err := json.NewDecoder(httpWriter.Body).Decode(&returned); err != nil {
+		t.Fatalf("Error decoding response into webhook{}: %s", err.Error())
+	}
+	if !reflect.DeepEqual(returned, stored["name1"]) {
+		t.Errorf("Expected the existing webhook %+v, got %+v", stored["name1"], returned)
+	}
+	sources, err := r.EventSrcClient.SourcesV1alpha1().GitHubSources("default").List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("Error listing GitHub sources: %s", err.Error())
+	}
+	if len(sources.Items) != 1 {
+		t.Errorf("Expected one GitHub source, got %d", len(sources.Items))
+	}
+
+	// Any difference is a conflict
+	for _, change := range []func(*webhook){
+		func(w *webhook) { w.DockerRegistry = "registry.example.com/other" },
+		func(w *webhook) { w.PushOnly, w.PROnly = false, true },
+		func(w *webhook) { w.GitRepositoryURL = "https://github.com/owner/other" },
+	} {
+		conflicting := hook
+		change(&conflicting)
+		if httpWriter := createWebhookRecorder(conflicting, r); httpWriter.Code != http.StatusConflict {
+			t.Errorf("Create conflicting webhook %+v returned %d, expected 409", conflicting, httpWriter.Code)
+		}
+	}
+}
+
+func createWebhookRecorder(webhook webhook, r *Resource) *httptest.ResponseRecorder {
+	b, _ := json.Marshal(webhook)
+	httpReq := dummyHTTPRequest("POST", "http://wwww.dummy.com:8080/webhook/", bytes.NewBuffer(b))
+	req := dummyRestfulRequest(httpReq, "", "")
+	httpWriter := httptest.NewRecorder()
+	resp := dummyRestfulResponse(httpWriter)
+	r.createWebhook(req, resp)
+	return httpWriter
+}
+
+func TestAPITimeout(t *testing.T) {
+	r := dummyResource()
+	r.Defaults.APITimeout = 50 * time.Millisecond
+	release := make(chan struct{})
+	defer close(release)
+	// Simulate an API server that does not answer configmap requests
+	r.K8sClient.(*fakek8sclientset.Clientset).PrependReactor("get", "configmaps", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		<-release
+		return false, nil, nil
+	})
+
+	hook := webhook{
+		Name:             "name1",
+		Namespace:        "foo",
+		GitRepositoryURL: "https://github.com/owner/repo",
+		AccessTokenRef:   "token1",
+		Pipeline:         "pipeline1",
+		DockerRegistry:   "registry1",
+	}
+	start := time.Now()
+	if code := createWebhookRecorder(hook, r).Code; code != http.StatusGatewayTimeout {
+		t.Errorf("Create webhook returned %d, expected 504", code)
+	}
+
+	httpReq := dummyHTTPRequest("GET", "http://wwww.dummy.com:8080/webhook/", nil)
+	httpWriter := httptest.NewRecorder()
+	r.getAllWebhooks(dummyRestfulRequest(httpReq, "", ""), dummyRestfulResponse(httpWriter))
+	if httpWriter.Code != http.StatusGatewayTimeout {
+		t.Errorf("Get all webhooks returned %d, expected 504", httpWriter.Code)
+	}
+
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected the requests to time out after %s, took %s", r.Defaults.APITimeout, elapsed)
+	}
+	if _, err := r.EventSrcClient.SourcesV1alpha1().GitHubSources("default").Get("name1", metav1.GetOptions{}); err == nil {
+		t.Error("Expected no GitHub source to be created when the webhooks could not be read")
+	}
+}
+
+func TestAPITimeoutSourceLookups(t *testing.T) {
+	r := dummyResource()
+	r.Defaults.APITimeout = 50 * time.Millisecond
+	release := make(chan struct{})
+	defer close(release)
+	// Simulate an API server that does not answer deployment, pipeline or GitHub App key secret requests
+	hang := func(action k8stesting.Action) (bool, runtime.Object, error) {
+		<-release
+		return false, nil, nil
+	}
+	r.K8sClient.(*fakek8sclientset.Clientset).PrependReactor("get", "deployments", hang)
+	r.TektonClient.(*fakeclientset.Clientset).PrependReactor("get", "pipelines", hang)
+	r.K8sClient.(*fakek8sclientset.Clientset).PrependReactor("get", "secrets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.(k8stesting.GetAction).GetName() != "app-key" {
+			return false, nil, nil
+		}
+		return hang(action)
+	})
+
+	start := time.Now()
+	// the owner reference and the docker registry check are skipped when they can't be looked up in time
+	hook := webhook{
+		Name:             "name1",
+		Namespace:        "foo",
+		GitRepositoryURL: "https://github.com/owner/repo",
+		AccessTokenRef:   "token1",
+		Pipeline:         "pipeline1",
+	}
+	if code := createWebhookRecorder(hook, r).Code; code != http.StatusCreated {
+		t.Fatalf("Create webhook returned %d, expected 201", code)
+	}
+	ghSrc, err := r.EventSrcClient.SourcesV1alpha1().GitHubSources("default").Get("name1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("GitHubSource name1 was not found: %s", err.Error())
+	}
+	if len(ghSrc.OwnerReferences) != 0 {
+		t.Errorf("Expected no owner reference, but was: %+v", ghSrc.OwnerReferences)
+	}
+
+	appHook := webhook{
+		Name:                    "name2",
+		Namespace:               "foo",
+		GitRepositoryURL:        "https://github.com/owner/repo2",
+		Pipeline:                "pipeline1",
+		DockerRegistry:          "registry1",
+		AuthMode:                "githubapp",
+		GitHubAppID:             7,
+		GitHubAppInstallationID: 42,
+		GitHubAppKeySecret:      "app-key",
+	}
+	if code := createWebhookRecorder(appHook, r).Code; code != http.StatusGatewayTimeout {
+		t.Errorf("Create GitHub App webhook returned %d, expected 504", code)
+	}
+
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected the lookups to time out after %s, took %s", r.Defaults.APITimeout, elapsed)
+	}
+}
+
+// timeoutError is the error of a network call that timed out
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestTimedOutSourceCreate(t *testing.T) {
+	r := dummyResource()
+	// Simulate a GitHubSource create that is applied, but whose response times out
+	eventSrcClient := r.EventSrcClient.(*eventsrcclient.Clientset)
+	eventSrcClient.PrependReactor("create", "githubsources", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if err := eventSrcClient.Tracker().Add(action.(k8stesting.CreateAction).GetObject()); err != nil {
+			return true, nil, err
+		}
+		return true, nil, &url.Error{Op: "Post", URL: "https://kubernetes.default.svc", Err: timeoutError{}}
+	})
+
+	hook := webhook{
+		Name:             "name1",
+		Namespace:        "foo",
+		GitRepositoryURL: "https://github.com/owner/repo",
+		AccessTokenRef:   "token1",
+		Pipeline:         "pipeline1",
+	}
+	if code := createWebhookRecorder(hook, r).Code; code != http.StatusGatewayTimeout {
+		t.Errorf("Create webhook returned %d, expected 504", code)
+	}
+	if _, err := r.EventSrcClient.SourcesV1alpha1().GitHubSources("default").Get("name1", metav1.GetOptions{}); !k8serrors.IsNotFound(err) {
+		t.Errorf("Expected the GitHubSource created before timing out to be deleted, got %v", err)
+	}
+	if hooks, err := r.readGitHubWebhooks(context.Background(), "default"); err != nil || len(hooks) != 0 {
+		t.Errorf("Expected no webhook to be stored, got %+v, %v", hooks, err)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	r := dummyResource()
+	container := restful.NewContainer()
+	container.Add(ExtensionWebService(*r))
+	container.Add(MetricsWebService())
+	scrape := func(metric string) float64 {
+		httpWriter := httptest.NewRecorder()
+		container.ServeHTTP(httpWriter, dummyHTTPRequest("GET", "http://wwww.dummy.com:8080/metrics", nil))
+		if httpWriter.Code != http.StatusOK {
+			t.Fatalf("Scraping metrics returned %d, expected 200", httpWriter.Code)
+		}
+		for _, line := range strings.Split(httpWriter.Body.String(), "\n") {
+			if strings.HasPrefix(line, metric+" ") {
+				value, err := strconv.ParseFloat(strings.TrimPrefix(line, metric+" "), 64)
+				if err != nil {
+					t.Fatalf("Error parsing metric %s: %s", line, err.Error())
+				}
+				return value
+			}
+		}
+		return 0
+	}
+	created := `webhooks_extension_operations_total{code="201",operation="create"}`
+	conflicts := `webhooks_extension_operations_total{code="409",operation="create"}`
+	reads := `webhooks_extension_configmap_duration_seconds_count{operation="read"}`
+	createdBefore, conflictsBefore, readsBefore := scrape(created), scrape(conflicts), scrape(reads)
+
+	hook := webhook{
+		Name:             "name1",
+		Namespace:        "foo",
+		GitRepositoryURL: "https://github.com/owner/repo",
+		AccessTokenRef:   "token1",
+		Pipeline:         "pipeline1",
+		DockerRegistry:   "registry1",
+	}
+	b, _ := json.Marshal(hook)
+	for i := 0; i < 2; i++ {
+		container.ServeHTTP(httptest.NewRecorder(), dummyHTTPRequest("POST", "http://wwww.dummy.com:8080/webhooks/", bytes.NewBuffer(b)))
+	}
+
+	if n := scrape(created) - createdBefore; n != 1 {
+		t.Errorf("Expected 1 successful create to be counted, got %v", n)
+	}
+	if n := scrape(conflicts) - conflictsBefore; n != 1 {
+		t.Errorf("Expected 1 conflicting create to be counted, got %v", n)
+	}
+	if n := scrape(reads) - readsBefore; n < 2 {
+		t.Errorf("Expected the configmap reads of each create to be observed, got %v", n)
+	}
+}
+
+func TestAuditLog(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	defaultLogger := logging.Log
+	logging.Log = zap.New(core).Sugar()
+	defer func() { logging.Log = defaultLogger }()
+
+	r := dummyResource()
+	container := restful.NewContainer()
+	container.Add(ExtensionWebService(*r))
+	serve := func(method, target string, body []byte) int {
+		httpReq := dummyHTTPRequest(method, "http://wwww.dummy.com:8080"+target, bytes.NewBuffer(body))
+		httpReq.Header.Set(actorHeader, "alice")
+		httpWriter := httptest.NewRecorder()
+		container.ServeHTTP(httpWriter, httpReq)
+		return httpWriter.Code
+	}
+
+	source := webhook{
+		Name:             "name1",
+		Namespace:        "test",
+		GitRepositoryURL: "https://github.com/owner/repo",
+		AccessTokenRef:   "token1",
+		Pipeline:         "pipeline1",
+		DockerRegistry:   "registry1",
+	}
+	b, _ := json.Marshal(source)
+	if code := serve("POST", "/webhooks", b); code != http.StatusCreated {
+		t.Fatalf("Create webhook returned %d, expected 201", code)
+	}
+	b, _ = json.Marshal(EnvDefaults{DockerRegistry: "registry.example.com/team"})
+	if code := serve("PUT", "/webhooks/defaults", b); code != http.StatusOK {
+		t.Fatalf("Update defaults returned %d, expected 200", code)
+	}
+	if code := serve("DELETE", "/webhooks/repository?url="+url.QueryEscape(source.GitRepositoryURL), nil); code != http.StatusOK {
+		t.Fatalf("Delete webhooks returned %d, expected 200", code)
+	}
+	// A failed operation is not audited
+	if code := serve("DELETE", "/webhooks/repository", nil); code != http.StatusBadRequest {
+		t.Fatalf("Delete webhooks without a url returned %d, expected 400", code)
+	}
+
+	expected := []struct {
+		operation string
+		webhook   string
+	}{
+		{auditOperationCreate, "name1"},
+		{auditOperationUpdateDefaults, ""},
+		{auditOperationDelete, "name1"},
+	}
+	entries := logs.FilterMessage(auditMessage).All()
+	if len(entries) != len(expected) {
+		t.Fatalf("Expected %d audit entries, but found %d: %+v", len(expected), len(entries), entries)
+	}
+	for i, entry := range entries {
+		fields := entry.ContextMap()
+		if fields["actor"] != "alice" || fields["operation"] != expected[i].operation || fields["webhook"] != expected[i].webhook {
+			t.Errorf("Audit entry %d: expected actor alice, operation %s and webhook %q, but was %v", i, expected[i].operation, expected[i].webhook, fields)
+		}
+		if _, err := time.Parse(time.RFC3339, fmt.Sprint(fields["timestamp"])); err != nil {
+			t.Errorf("Audit entry %d: expected an RFC3339 timestamp, but was %v", i, fields["timestamp"])
+		}
+		if fields["requestID"] == nil {
+			t.Errorf("Audit entry %d: expected a request ID, but was %v", i, fields)
+		}
+	}
+}
+
+func createWebhooksRecorder(webhooks []webhook, r *Resource) *httptest.ResponseRecorder {
+	b, _ := json.Marshal(webhooks)
+	httpReq := dummyHTTPRequest("POST", "http://wwww.dummy.com:8080/webhook/batch", bytes.NewBuffer(b))
+	req := dummyRestfulRequest(httpReq, "", "")
+	httpWriter := httptest.NewRecorder()
+	resp := dummyRestfulResponse(httpWriter)
+	r.createWebhooks(req, resp)
+	return httpWriter
+}
+
+func batchResults(httpWriter *httptest.ResponseRecorder, t *testing.T) []batchResult {
+	if httpWriter.Code != http.StatusOK {
+		t.Fatalf("Batch create returned %d, expected 200: %s", httpWriter.Code, httpWriter.Body.String())
+	}
+	results := []batchResult{}
+	if err := json.Unmarshal(httpWriter.Body.Bytes(), &results); err != nil {
+		t.Fatalf("Error unmarshalling batch results: %s", err.Error())
+	}
+	return results
+}
+
+func TestCreateWebhooksBatch(t *testing.T) {
+	hooks := []webhook{
+		{
+			Name:             "name1",
+			Namespace:        "foo",
+			GitRepositoryURL: "https://github.com/owner/repo1",
+			AccessTokenRef:   "token1",
+			Pipeline:         "pipeline1",
+			DockerRegistry:   "registry1",
+		},
+		{
+			Name:             "name2",
+			Namespace:        "foo",
+			GitRepositoryURL: "https://github.com/owner/repo2",
+			AccessTokenRef:   "token2",
+			Pipeline:         "pipeline2",
+			DockerRegistry:   "registry2",
+		},
+	}
+
+	r := dummyResource()
+	results := batchResults(createWebhooksRecorder(hooks, r), t)
+	if len(results) != len(hooks) {
+		t.Fatalf("Expected %d results, got %d", len(hooks), len(results))
+	}
+	for i, result := range results {
+		if result.Name != hooks[i].Name || result.Status != http.StatusCreated || result.Error != "" {
+			t.Errorf("Expected webhook %s to be created, got %+v", hooks[i].Name, result)
+		}
+		if result.Result == nil || result.Result.OwnerRepo != "owner/repo"+fmt.Sprint(i+1) {
+			t.Errorf("Expected the create result for webhook %s, got %+v", hooks[i].Name, result.Result)
+		}
+		testGitHubSource(hooks[i].Name, "owner/repo"+fmt.Sprint(i+1), "", "default", r, t)
+	}
+	testGetAllWebhooks(hooks, r, t)
+}
+
+func TestCreateWebhooksBatchPartialFailure(t *testing.T) {
+	existing := webhook{
+		Name:             "existing",
+		Namespace:        "foo",
+		GitRepositoryURL: "https://github.com/owner/existing",
+		AccessTokenRef:   "token",
+		Pipeline:         "pipeline",
+		DockerRegistry:   "registry",
+	}
+	valid := existing
+	valid.Name, valid.GitRepositoryURL = "valid", "https://github.com/owner/valid"
+	invalid := existing
+	invalid.Name, invalid.GitRepositoryURL = "invalid", "not a url"
+	duplicate := valid
+	duplicate.GitRepositoryURL = "https://github.com/owner/duplicate"
+	conflicting := existing
+	conflicting.Pipeline = "other-pipeline"
+
+	r := dummyResource()
+	if resp := createWebhook(existing, r); resp.StatusCode() != http.StatusCreated {
+		t.Fatalf("Create webhook returned %d, expected 201", resp.StatusCode())
+	}
+	results := batchResults(createWebhooksRecorder([]webhook{invalid, conflicting, valid, duplicate, existing}, r), t)
+	expected := []struct {
+		name   string
+		status int
+	}{
+		{name: "invalid", status: http.StatusUnprocessableEntity},
+		{name: "existing", status: http.StatusConflict},
+		{name: "valid", status: http.StatusCreated},
+		{name: "valid", status: http.StatusConflict},
+		{name: "existing", status: http.StatusOK},
+	}
+	if len(results) != len(expected) {
+		t.Fatalf("Expected %d results, got %d", len(expected), len(results))
+	}
+	for i, result := range results {
+		if result.Name != expected[i].name || result.Status != expected[i].status {
+			t.Errorf("Expected result %d to be %s with status %d, got %+v", i, expected[i].name, expected[i].status, result)
+		}
+		if (result.Status == http.StatusCreated || result.Status == http.StatusOK) != (result.Error == "") {
+			t.Errorf("Expected only failed results to carry an error, got %+v", result)
+		}
+	}
+	testGetAllWebhooks([]webhook{existing, valid}, r, t)
+}
+
+func exportWebhooksRecorder(r *Resource) *httptest.ResponseRecorder {
+	httpReq := dummyHTTPRequest("GET", "http://wwww.dummy.com:8080/webhooks/export", nil)
+	req := dummyRestfulRequest(httpReq, "", "")
+	httpWriter := httptest.NewRecorder()
+	r.exportWebhooks(req, dummyRestfulResponse(httpWriter))
+	return httpWriter
+}
+
+func importWebhooksRecorder(body []byte, r *Resource) *httptest.ResponseRecorder {
+	httpReq := dummyHTTPRequest("POST", "http://wwww.dummy.com:8080/webhooks/import", bytes.NewBuffer(body))
+	req := dummyRestfulRequest(httpReq, "", "")
+	httpWriter := httptest.NewRecorder()
+	r.importWebhooks(req, dummyRestfulResponse(httpWriter))
+	return httpWriter
+}
+
+func TestExportImportWebhooks(t *testing.T) {
+	hooks := []webhook{
+		{
+			Name:             "name1",
+			Namespace:        "foo",
+			GitRepositoryURL: "https://github.com/owner/repo1",
+			AccessTokenRef:   "token1",
+			Pipeline:         "pipeline1",
+			DockerRegistry:   "registry1",
+		},
+		{
+			Name:             "name2",
+			Namespace:        "bar",
+			GitRepositoryURL: "https://github.com/owner/repo2",
+			AccessTokenRef:   "token2",
+			Pipeline:         "pipeline2",
+			PushOnly:         true,
+		},
+	}
+	exported := dummyResource()
+	for _, hook := range hooks {
+		if resp := createWebhook(hook, exported); resp.StatusCode() != http.StatusCreated {
+			t.Fatalf("Create webhook returned %d, expected 201", resp.StatusCode())
+		}
+	}
+
+	httpWriter := exportWebhooksRecorder(exported)
+	if httpWriter.Code != http.StatusOK {
+		t.Fatalf("Export returned %d, expected 200: %s", httpWriter.Code, httpWriter.Body.String())
+	}
+	if contentType := httpWriter.Header().Get("Content-Type"); contentType != exportMIMEType {
+		t.Errorf("Expected the export content type %s, got %s", exportMIMEType, contentType)
+	}
+	backup := httpWriter.Body.Bytes()
+
+	// The webhooks and their sources are recreated from the export
+	imported := dummyResource()
+	results := batchResults(importWebhooksRecorder(backup, imported), t)
+	if len(results) != len(hooks) {
+		t.Fatalf("Expected %d results, got %d", len(hooks), len(results))
+	}
+	for i, result := range results {
+		if result.Name != hooks[i].Name || result.Status != http.StatusCreated {
+			t.Errorf("Expected webhook %s to be imported, got %+v", hooks[i].Name, result)
+		}
+		testGitHubSource(hooks[i].Name, "owner/repo"+fmt.Sprint(i+1), "", "default", imported, t)
+	}
+	testGetAllWebhooks(hooks, imported, t)
+	if reexported := exportWebhooksRecorder(imported); reexported.Body.String() != string(backup) {
+		t.Errorf("Expected the imported webhooks to export as\n%s\ngot\n%s", backup, reexported.Body.String())
+	}
+
+	// Importing the same webhooks again changes nothing
+	for _, result := range batchResults(importWebhooksRecorder(backup, imported), t) {
+		if result.Status != http.StatusOK || result.Error != "" {
+			t.Errorf("Expected webhook %s to be left as it is, got %+v", result.Name, result)
+		}
+	}
+	sources, err := imported.EventSrcClient.SourcesV1alpha1().GitHubSources("default").List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("Error listing GitHub sources: %s", err.Error())
+	}
+	if len(sources.Items) != len(hooks) {
+		t.Errorf("Expected %d GitHub sources, got %d", len(hooks), len(sources.Items))
+	}
+}
+
+func TestImportWebhooksInvalid(t *testing.T) {
+	r := dummyResource()
+	if httpWriter := importWebhooksRecorder([]byte("name: [unterminated"), r); httpWriter.Code != http.StatusBadRequest {
+		t.Errorf("Import of invalid YAML returned %d, expected 400", httpWriter.Code)
+	}
+}
+
+func TestGetAllWebhooksStatus(t *testing.T) {
+	ready := webhook{
+		Name:             "ready",
+		Namespace:        "foo",
+		GitRepositoryURL: "https://github.com/owner/ready",
+		AccessTokenRef:   "token",
+		Pipeline:         "pipeline",
+		DockerRegistry:   "registry",
+	}
+	failing := ready
+	failing.Name, failing.GitRepositoryURL = "failing", "https://github.com/owner/failing"
+
+	r := dummyResource()
+	for _, hook := range []webhook{ready, failing} {
+		if resp := createWebhook(hook, r); resp.StatusCode() != http.StatusCreated {
+			t.Fatalf("Create webhook returned %d, expected 201", resp.StatusCode())
+		}
+	}
+	source, err := r.EventSrcClient.SourcesV1alpha1().GitHubSources("default").Get("failing", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Error getting GitHub source: %s", err.Error())
+	}
+	source.Status.Conditions = append(source.Status.Conditions, duckv1alpha1.Condition{
+		Type:    duckv1alpha1.ConditionReady,
+		Status:  corev1.ConditionFalse,
+		Message: "secret token not found",
+	})
+	if _, err := r.EventSrcClient.SourcesV1alpha1().GitHubSources("default").Update(source); err != nil {
+		t.Fatalf("Error updating GitHub source: %s", err.Error())
+	}
+
+	// The status is only returned on request
+	testGetAllWebhooks([]webhook{ready, failing}, r, t)
+
+	httpReq := dummyHTTPRequest("GET", "http://wwww.dummy.com:8080/webhooks/?status=true", nil)
+	req := dummyRestfulRequest(httpReq, "", "")
+	httpWriter := httptest.NewRecorder()
+	r.getAllWebhooks(req, dummyRestfulResponse(httpWriter))
+	actual := []webhook{}
+	if err := json.NewDecoder(httpWriter.Body).Decode(&actual); err != nil {
+		t.Fatalf("Error decoding result into []webhook{}: %s", err.Error())
+	}
+	expected := map[string]sourceStatus{
+		"ready":   {Ready: string(corev1.ConditionUnknown)},
+		"failing": {Ready: string(corev1.ConditionFalse), Message: "secret token not found"},
+	}
+	if len(actual) != len(expected) {
+		t.Fatalf("Incorrect length of result, expected %d, but was %d", len(expected), len(actual))
+	}
+	for _, hook := range actual {
+		if hook.Status == nil || *hook.Status != expected[hook.Name] {
+			t.Errorf("Expected webhook %s to have status %+v, got %+v", hook.Name, expected[hook.Name], hook.Status)
+		}
+	}
+
+	// The status is reported but never stored
+	webhooks, err := r.readGitHubWebhooks(context.Background(), "default")
+	if err != nil {
+		t.Fatalf("Error reading webhooks: %s", err.Error())
+	}
+	for name, hook := range webhooks {
+		if hook.Status != nil {
+			t.Errorf("Expected no status to be stored for webhook %s, got %+v", name, hook.Status)
+		}
+	}
+}
+
+func TestCreateWebhookInstallNamespace(t *testing.T) {
+	hook := webhook{
+		Name:             "name1",
+		Namespace:        "foo",
+		GitRepositoryURL: "https://github.com/owner/repo",
+		AccessTokenRef:   "token1",
+		Pipeline:         "pipeline1",
+		DockerRegistry:   "registry1",
+	}
+
+	// The fallback install namespace exists
+	r := updateResourceDefaults(dummyResource(), EnvDefaults{})
+	if code := createWebhookRecorder(hook, r).Code; code != http.StatusCreated {
+		t.Errorf("Create webhook in an existing install namespace returned %d, expected 201", code)
+	}
+	testGitHubSource("name1", "owner/repo", "", "default", r, t)
+
+	r = updateResourceDefaults(dummyResource(), EnvDefaults{Namespace: "missing"})
+	httpWriter := createWebhookRecorder(hook, r)
+	if httpWriter.Code != http.StatusBadRequest {
+		t.Errorf("Create webhook in a missing install namespace returned %d, expected 400", httpWriter.Code)
+	}
+	if !strings.Contains(httpWriter.Body.String(), "missing") {
+		t.Errorf("Expected the error to name the missing namespace, got: %s", httpWriter.Body.String())
+	}
+	if _, err := r.EventSrcClient.SourcesV1alpha1().GitHubSources("missing").Get("name1", metav1.GetOptions{}); err == nil {
+		t.Error("Expected no GitHub source to be created in the missing namespace")
+	}
+	if code := createWebhooksRecorder([]webhook{hook}, r).Code; code != http.StatusBadRequest {
+		t.Errorf("Batch create in a missing install namespace returned %d, expected 400", code)
+	}
+}
+
+func TestCreateWebhookErrorStatus(t *testing.T) {
+	valid := webhook{
+		Name:             "name1",
+		Namespace:        "foo",
+		GitRepositoryURL: "https://github.com/owner/repo",
+		AccessTokenRef:   "token1",
+		Pipeline:         "pipeline1",
+		DockerRegistry:   "registry1",
+	}
+	noNamespace := valid
+	noNamespace.Namespace = ""
+	badURL := valid
+	badURL.GitRepositoryURL = "https://github.com/repo"
+	encode := func(hook webhook) string {
+		b, _ := json.Marshal(hook)
+		return string(b)
+	}
+
+	tests := []struct {
+		name           string
+		body           string
+		expectedStatus int
+	}{
+		{name: "malformed JSON", body: `{"name": "name1",`, expectedStatus: http.StatusBadRequest},
+		{name: "wrong JSON type", body: `["name1"]`, expectedStatus: http.StatusBadRequest},
+		{name: "missing namespace", body: encode(noNamespace), expectedStatus: http.StatusUnprocessableEntity},
+		{name: "malformed URL", body: encode(badURL), expectedStatus: http.StatusUnprocessableEntity},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := dummyResource()
+			for _, handler := range []restful.RouteFunction{r.createWebhook, r.validateWebhook} {
+				httpReq := dummyHTTPRequest("POST", "http://wwww.dummy.com:8080/webhook/", strings.NewReader(tt.body))
+				httpWriter := httptest.NewRecorder()
+				handler(dummyRestfulRequest(httpReq, "", ""), dummyRestfulResponse(httpWriter))
+				if httpWriter.Code != tt.expectedStatus {
+					t.Errorf("Expected status %d, but was %d: %s", tt.expectedStatus, httpWriter.Code, httpWriter.Body.String())
+				}
+			}
+		})
+	}
+
+	// in a batch a body that can't be read fails the request, an invalid webhook only its own result
+	r := dummyResource()
+	httpReq := dummyHTTPRequest("POST", "http://wwww.dummy.com:8080/webhook/batch", strings.NewReader(`[{"name": "name1",`))
+	httpWriter := httptest.NewRecorder()
+	r.createWebhooks(dummyRestfulRequest(httpReq, "", ""), dummyRestfulResponse(httpWriter))
+	if httpWriter.Code != http.StatusBadRequest {
+		t.Errorf("Batch create with malformed JSON returned %d, expected 400", httpWriter.Code)
+	}
+	results := batchResults(createWebhooksRecorder([]webhook{noNamespace, badURL}, r), t)
+	for _, result := range results {
+		if result.Status != http.StatusUnprocessableEntity {
+			t.Errorf("Expected an invalid webhook in a batch to have status 422, got %+v", result)
+		}
+	}
+}
+
+func validateWebhookRecorder(webhook webhook, r *Resource) *httptest.ResponseRecorder {
+	b, _ := json.Marshal(webhook)
+	httpReq := dummyHTTPRequest("POST", "http://wwww.dummy.com:8080/webhook/validate", bytes.NewBuffer(b))
+	req := dummyRestfulRequest(httpReq, "", "")
+	httpWriter := httptest.NewRecorder()
+	resp := dummyRestfulResponse(httpWriter)
+	r.validateWebhook(req, resp)
+	return httpWriter
+}
+
+func TestValidateWebhook(t *testing.T) {
+	valid := webhook{
+		Name:             "name1",
+		Namespace:        "foo",
+		GitRepositoryURL: "https://github.example.com/owner/repo",
+		AccessTokenRef:   "token1",
+		Pipeline:         "pipeline1",
+		DockerRegistry:   "registry1",
+	}
+	existing := valid
+	existing.Name = "existing"
+
+	tests := []struct {
+		name           string
+		change         func(hook *webhook, r **Resource)
+		expectedStatus int
+		expectedError  string
+	}{
+		{name: "valid", change: func(hook *webhook, r **Resource) {}, expectedStatus: http.StatusOK},
+		{name: "unparseable URL", change: func(hook *webhook, r **Resource) { hook.GitRepositoryURL = "https://github.com/%zz" }, expectedStatus: http.StatusUnprocessableEntity, expectedError: "could not be parsed"},
+		{name: "URL without owner and repo", change: func(hook *webhook, r **Resource) { hook.GitRepositoryURL = "https://github.com/repo" }, expectedStatus: http.StatusUnprocessableEntity, expectedError: "parsing git api url"},
+		{name: "release name too long", change: func(hook *webhook, r **Resource) { hook.ReleaseName = strings.Repeat("a", 64) }, expectedStatus: http.StatusUnprocessableEntity, expectedError: "less than 64 characters"},
+		{name: "invalid release name", change: func(hook *webhook, r **Resource) { hook.ReleaseName = "Not_Valid" }, expectedStatus: http.StatusUnprocessableEntity, expectedError: "DNS-1123"},
+		{name: "missing secret", change: func(hook *webhook, r **Resource) { hook.AccessTokenRef = "missing-token" }, expectedStatus: http.StatusUnprocessableEntity, expectedError: "missing-token"},
+		{name: "no secret", change: func(hook *webhook, r **Resource) { hook.AccessTokenRef = "" }, expectedStatus: http.StatusUnprocessableEntity, expectedError: "secret is required"},
+		{name: "no namespace", change: func(hook *webhook, r **Resource) { hook.Namespace = "" }, expectedStatus: http.StatusUnprocessableEntity, expectedError: "namespace is required"},
+		{name: "missing install namespace", change: func(hook *webhook, r **Resource) {
+			*r = updateResourceDefaults(*r, EnvDefaults{Namespace: "missing"})
+		}, expectedStatus: http.StatusBadRequest, expectedError: "install namespace missing"},
+		{name: "existing webhook", change: func(hook *webhook, r **Resource) { hook.Name = "existing" }, expectedStatus: http.StatusConflict, expectedError: "already exists"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base := dummyResource()
+			secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "token1", Namespace: "default"}}
+			if _, err := base.K8sClient.CoreV1().Secrets("default").Create(secret); err != nil {
+				t.Fatalf("Error creating secret: %s", err.Error())
+			}
+			if resp := createWebhook(existing, base); resp.StatusCode() != http.StatusCreated {
+				t.Fatalf("Create webhook returned %d, expected 201", resp.StatusCode())
+			}
+			r := base
+			hook := valid
+			tt.change(&hook, &r)
+
+			httpWriter := validateWebhookRecorder(hook, r)
+			if httpWriter.Code != tt.expectedStatus {
+				t.Fatalf("Validate webhook returned %d, expected %d: %s", httpWriter.Code, tt.expectedStatus, httpWriter.Body.String())
+			}
+			if tt.expectedError != "" && !strings.Contains(httpWriter.Body.String(), tt.expectedError) {
+				t.Errorf("Expected the error to contain %q, got: %s", tt.expectedError, httpWriter.Body.String())
+			}
+			if tt.expectedStatus == http.StatusOK {
+				result := createResult{}
+				if err := json.Unmarshal(httpWriter.Body.Bytes(), &result); err != nil {
+					t.Fatalf("Error unmarshalling response: %s", err.Error())
+				}
+				expected := createResult{APIURL: "https://github.example.com/api/v3/", OwnerRepo: "owner/repo", GitHubAPIURLSet: true}
+				if result != expected {
+					t.Errorf("Expected the interpretation %+v, got %+v", expected, result)
+				}
+			}
+
+			// Nothing is created whatever the outcome
+			if _, err := r.EventSrcClient.SourcesV1alpha1().GitHubSources("default").Get(valid.Name, metav1.GetOptions{}); err == nil {
+				t.Error("Expected no GitHub source to be created")
+			}
+			testGetAllWebhooks([]webhook{existing}, base, t)
+		})
+	}
+}
+
+func rotateWebhookSecret(name string, r *Resource) *httptest.ResponseRecorder {
+	httpReq := dummyHTTPRequest("POST", "http://wwww.dummy.com:8080/webhooks/"+name+"/rotate-secret", nil)
+	req := dummyRestfulRequest(httpReq, "", name)
+	httpWriter := httptest.NewRecorder()
+	resp := dummyRestfulResponse(httpWriter)
+	r.rotateWebhookSecret(req, resp)
+	return httpWriter
+}
+
+func TestRotateWebhookSecret(t *testing.T) {
+	r := dummyResource()
+	installNs := "default"
+
+	tokenSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "github-secret", Namespace: installNs},
+		Data: map[string][]byte{
+			"accessToken": []byte("access-token"),
+			"secretToken": []byte("old-token"),
+		},
+	}
+	if _, err := r.K8sClient.CoreV1().Secrets(installNs).Create(tokenSecret); err != nil {
+		t.Fatalf("Error creating token secret: %s", err.Error())
+	}
+	source := webhook{
+		Name:             "name1",
+		Namespace:        "test",
+		GitRepositoryURL: "https://github.com/owner/repo",
+		AccessTokenRef:   "github-secret",
+		Pipeline:         "pipeline1",
+	}
+	if resp := createWebhook(source, r); resp.StatusCode() != http.StatusCreated {
+		t.Fatalf("Expected status %d, but was %d", http.StatusCreated, resp.StatusCode())
+	}
+	ghSrc, err := r.EventSrcClient.SourcesV1alpha1().GitHubSources(installNs).Get(source.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("GitHubSource %s was not found: %s", source.Name, err.Error())
+	}
+	ghSrc.Status.WebhookIDKey = "1234"
+	if _, err := r.EventSrcClient.SourcesV1alpha1().GitHubSources(installNs).Update(ghSrc); err != nil {
+		t.Fatalf("Error updating GitHubSource status: %s", err.Error())
+	}
+
+	remoteSecret := ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPatch || req.URL.Path != "/repos/owner/repo/hooks/1234/config" {
+			t.Errorf("Unexpected request %s %s", req.Method, req.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if auth := req.Header.Get("Authorization"); auth != "token access-token" {
+			t.Errorf("Expected the access token to be used, but Authorization header was: %s", auth)
+		}
+		config := map[string]string{}
+		json.NewDecoder(req.Body).Decode(&config)
+		remoteSecret = config["secret"]
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+	serverURL, _ := url.Parse(server.URL)
+	defaultClient := gitHubAPIClient
+	gitHubAPIClient = &http.Client{Transport: rewriteTransport{target: serverURL}}
+	defer func() { gitHubAPIClient = defaultClient }()
+
+	httpWriter := rotateWebhookSecret(source.Name, r)
+	if httpWriter.Code != http.StatusOK {
+		t.Fatalf("Rotate secret returned %d, expected 200: %s", httpWriter.Code, httpWriter.Body.String())
+	}
+	result := rotateSecretResult{}
+	if err := json.NewDecoder(httpWriter.Body).Decode(&result); err != nil {
+		t.Fatalf("Error decoding result into rotateSecretResult{}: %s", err.Error())
+	}
+	if result.SecretToken == "" || result.SecretToken == "old-token" {
+		t.Errorf("Expected a new secret token, but was: %q", result.SecretToken)
+	}
+	if !result.RemoteUpdated || remoteSecret != result.SecretToken {
+		t.Errorf("Expected the GitHub webhook secret to be updated to %q, but was %q: %+v", result.SecretToken, remoteSecret, result)
+	}
+
+	updated, err := r.K8sClient.CoreV1().Secrets(installNs).Get("github-secret", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Error getting token secret: %s", err.Error())
+	}
+	if token := string(updated.Data["secretToken"]); token != result.SecretToken {
+		t.Errorf("Expected secretToken to be overwritten with %q, but was %q", result.SecretToken, token)
+	}
+	if token := string(updated.Data["accessToken"]); token != "access-token" {
+		t.Errorf("Expected accessToken to be unchanged, but was %q", token)
+	}
+}
+
+func TestRotateWebhookSecretNotFound(t *testing.T) {
+	r := dummyResource()
+	if httpWriter := rotateWebhookSecret("missing", r); httpWriter.Code != http.StatusNotFound {
+		t.Errorf("Rotate secret returned %d, expected 404: %s", httpWriter.Code, httpWriter.Body.String())
+	}
+}
+
+func setWebhookPaused(name string, paused bool, r *Resource) *httptest.ResponseRecorder {
+	action := "unpause"
+	if paused {
+		action = "pause"
+	}
+	httpReq := dummyHTTPRequest("POST", "http://wwww.dummy.com:8080/webhooks/"+name+"/"+action, nil)
+	req := dummyRestfulRequest(httpReq, "", name)
+	httpWriter := httptest.NewRecorder()
+	resp := dummyRestfulResponse(httpWriter)
+	if paused {
+		r.pauseWebhook(req, resp)
+	} else {
+		r.unpauseWebhook(req, resp)
+	}
+	return httpWriter
+}
+
+func TestPauseUnpauseWebhook(t *testing.T) {
+	r := dummyResource()
+	installNs := "default"
+	source := webhook{
+		Name:             "name1",
+		Namespace:        "foo",
+		GitRepositoryURL: "https://github.com/owner/repo",
+		AccessTokenRef:   "token1",
+		Pipeline:         "pipeline1",
+		DockerRegistry:   "registry1",
+	}
+	if resp := createWebhook(source, r); resp.StatusCode() != http.StatusCreated {
+		t.Fatalf("Create webhook returned %d, expected 201", resp.StatusCode())
+	}
+
+	for i := 0; i < 2; i++ {
+		if httpWriter := setWebhookPaused(source.Name, true, r); httpWriter.Code != http.StatusOK {
+			t.Fatalf("Pause webhook returned %d, expected 200: %s", httpWriter.Code, httpWriter.Body.String())
+		}
+		if _, err := r.EventSrcClient.SourcesV1alpha1().GitHubSources(installNs).Get(source.Name, metav1.GetOptions{}); !k8serrors.IsNotFound(err) {
+			t.Errorf("Expected the GitHubSource of the paused webhook to be deleted, but got: %v", err)
+		}
+		stored, err := r.readGitHubWebhooks(context.Background(), installNs)
+		if err != nil {
+			t.Fatalf("Error reading webhooks: %s", err.Error())
+		}
+		if hook, ok := stored[source.Name]; !ok || !hook.Paused {
+			t.Errorf("Expected the webhook to be kept and paused, but was: %+v", stored)
+		}
+	}
+	paused := source
+	paused.Paused = true
+	testGetAllWebhooks([]webhook{paused}, r, t)
+
+	for i := 0; i < 2; i++ {
+		if httpWriter := setWebhookPaused(source.Name, false, r); httpWriter.Code != http.StatusOK {
+			t.Fatalf("Unpause webhook returned %d, expected 200: %s", httpWriter.Code, httpWriter.Body.String())
+		}
+		testGitHubSource(source.Name, "owner/repo", "", "default", r, t)
+	}
+	testGetAllWebhooks([]webhook{source}, r, t)
+}
+
+func TestPauseWebhookNotFound(t *testing.T) {
+	r := dummyResource()
+	for _, paused := range []bool{true, false} {
+		if httpWriter := setWebhookPaused("missing", paused, r); httpWriter.Code != http.StatusNotFound {
+			t.Errorf("Setting paused to %t returned %d, expected 404: %s", paused, httpWriter.Code, httpWriter.Body.String())
+		}
+	}
+}
+
+func TestCreatePausedWebhook(t *testing.T) {
+	r := dummyResource()
+	source := webhook{
+		Name:             "name1",
+		Namespace:        "foo",
+		GitRepositoryURL: "https://github.com/owner/repo",
+		AccessTokenRef:   "token1",
+		Pipeline:         "pipeline1",
+		DockerRegistry:   "registry1",
+		Paused:           true,
+	}
+	if resp := createWebhook(source, r); resp.StatusCode() != http.StatusCreated {
+		t.Fatalf("Create webhook returned %d, expected 201", resp.StatusCode())
+	}
+	if _, err := r.EventSrcClient.SourcesV1alpha1().GitHubSources("default").Get(source.Name, metav1.GetOptions{}); !k8serrors.IsNotFound(err) {
+		t.Errorf("Expected no GitHubSource for the paused webhook, but got: %v", err)
+	}
+	testGetAllWebhooks([]webhook{source}, r, t)
+
+	if httpWriter := setWebhookPaused(source.Name, false, r); httpWriter.Code != http.StatusOK {
+		t.Fatalf("Unpause webhook returned %d, expected 200: %s", httpWriter.Code, httpWriter.Body.String())
+	}
+	testGitHubSource(source.Name, "owner/repo", "", "default", r, t)
+}
+
+func signPayload(newHash func() hash.Hash, prefix string, payload []byte, token string) string {
+	mac := hmac.New(newHash, []byte(token))
+	mac.Write(payload)
+	return prefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	r := dummyResource()
+	installNs := "default"
+	for _, secret := range []*corev1.Secret{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "github-secret", Namespace: installNs},
+			Data:       map[string][]byte{"accessToken": []byte("access-token"), "secretToken": []byte("stored-token")},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "gitlab-secret", Namespace: installNs},
+			Data:       map[string][]byte{"accessToken": []byte("access-token"), "secretToken": []byte("gitlab-token")},
+		},
+	} {
+		if _, err := r.K8sClient.CoreV1().Secrets(installNs).Create(secret); err != nil {
+			t.Fatalf("Error creating token secret: %s", err.Error())
+		}
+	}
+	for _, hook := range []webhook{
+		{
+			Name:             "github",
+			Namespace:        "test",
+			GitRepositoryURL: "https://github.com/owner/repo",
+			AccessTokenRef:   "github-secret",
+			Pipeline:         "pipeline1",
+		},
+		{
+			Name:             "gitlab",
+			Namespace:        "test",
+			GitRepositoryURL: "https://gitlab.com/owner/repo",
+			AccessTokenRef:   "gitlab-secret",
+			Pipeline:         "pipeline1",
+			Provider:         providerGitLab,
+		},
+	} {
+		if resp := createWebhook(hook, r); resp.StatusCode() != http.StatusCreated {
+			t.Fatalf("Create webhook %s returned %d, expected 201", hook.Name, resp.StatusCode())
+		}
+	}
+
+	payload := []byte(`{"ref": "refs/heads/master"}`)
+	tests := []struct {
+		name      string
+		repoURL   string
+		signature string
+		expected  error
+	}{
+		{name: "sha1", repoURL: "https://github.com/owner/repo", signature: signPayload(sha1.New, "sha1=", payload, "stored-token")},
+		{name: "sha256", repoURL: "https://github.com/owner/repo", signature: signPayload(sha256.New, "sha256=", payload, "stored-token")},
+		{name: "wrong token", repoURL: "https://github.com/owner/repo", signature: signPayload(sha1.New, "sha1=", payload, "other-token"), expected: ErrInvalidSignature},
+		{name: "other payload", repoURL: "https://github.com/owner/repo", signature: signPayload(sha1.New, "sha1=", []byte("{}"), "stored-token"), expected: ErrInvalidSignature},
+		{name: "unsigned", repoURL: "https://github.com/owner/repo", expected: ErrInvalidSignature},
+		{name: "not hex", repoURL: "https://github.com/owner/repo", signature: "sha1=not-hex", expected: ErrInvalidSignature},
+		{name: "gitlab token", repoURL: "https://gitlab.com/owner/repo", signature: "gitlab-token"},
+		{name: "wrong gitlab token", repoURL: "https://gitlab.com/owner/repo", signature: "stored-token", expected: ErrInvalidSignature},
+		{name: "unknown repository", repoURL: "https://github.com/owner/other", signature: signPayload(sha1.New, "sha1=", payload, "stored-token"), expected: ErrWebhookNotFound},
+	}
+	for _, tt := range tests {
+		if err := r.VerifyWebhookSignature(context.Background(), tt.repoURL, payload, tt.signature); err != tt.expected {
+			t.Errorf("%s: expected %v, but was %v", tt.name, tt.expected, err)
+		}
+	}
+}
+
+func TestWebhookEventTypes(t *testing.T) {
+	tests := []struct {
+		name     string
+		pushOnly bool
+		prOnly   bool
+		expected []string
+	}{
+		{name: "pushonly", pushOnly: true, expected: []string{"push"}},
+		{name: "pronly", prOnly: true, expected: []string{"pull_request"}},
+		{name: "all", expected: []string{"push", "pull_request"}},
+	}
+	for _, tt := range tests {
+		r := dummyResource()
+		source := webhook{
+			Name:             "name1",
+			Namespace:        "test",
+			GitRepositoryURL: "https://github.com/owner/repo",
+			AccessTokenRef:   "token1",
+			Pipeline:         "pipeline1",
+			PushOnly:         tt.pushOnly,
+			PROnly:           tt.prOnly,
+		}
+		if resp := createWebhook(source, r); resp.StatusCode() != http.StatusCreated {
+			t.Fatalf("%s: expected status %d, but was %d", tt.name, http.StatusCreated, resp.StatusCode())
+		}
+		ghSrc, err := r.EventSrcClient.SourcesV1alpha1().GitHubSources("default").Get(source.Name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("%s: GitHubSource %s was not found: %s", tt.name, source.Name, err.Error())
+		}
+		if !reflect.DeepEqual(ghSrc.Spec.EventTypes, tt.expected) {
+			t.Errorf("%s: expected event types %v, but was %v", tt.name, tt.expected, ghSrc.Spec.EventTypes)
+		}
+
+		// the choice is stored with the webhook
+		hooks, err := r.readGitHubWebhooks(context.Background(), "default")
+		if err != nil {
+			t.Fatalf("%s: error reading webhooks: %s", tt.name, err.Error())
+		}
+		if stored := hooks[source.Name]; stored.PushOnly != tt.pushOnly || stored.PROnly != tt.prOnly {
+			t.Errorf("%s: expected pushonly %t and pronly %t to be stored, but was %+v", tt.name, tt.pushOnly, tt.prOnly, stored)
+		}
+	}
+}
+
+func TestWebhookEventTypesGitLab(t *testing.T) {
+	r := dummyResource()
+	source := webhook{
+		Name:             "name1",
+		Namespace:        "test",
+		GitRepositoryURL: "https://gitlab.com/group/project",
+		AccessTokenRef:   "token1",
+		Pipeline:         "pipeline1",
+		Provider:         "gitlab",
+		PROnly:           true,
+	}
+	if resp := createWebhook(source, r); resp.StatusCode() != http.StatusCreated {
+		t.Fatalf("Expected status %d, but was %d", http.StatusCreated, resp.StatusCode())
+	}
+	glSrc, err := r.DynamicClient.Resource(gitLabSourceResource).Namespace("default").Get(source.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("GitLabSource %s was not found: %s", source.Name, err.Error())
+	}
+	eventTypes, _, _ := unstructured.NestedStringSlice(glSrc.Object, "spec", "eventTypes")
+	if !reflect.DeepEqual(eventTypes, []string{"merge_requests_events"}) {
+		t.Errorf("Expected event types [merge_requests_events], but was %v", eventTypes)
+	}
+}
+
+func TestWebhookEventTypesConflict(t *testing.T) {
+	r := dummyResource()
+	source := webhook{
+		Name:             "name1",
+		Namespace:        "test",
+		GitRepositoryURL: "https://github.com/owner/repo",
+		AccessTokenRef:   "token1",
+		Pipeline:         "pipeline1",
+		PushOnly:         true,
+		PROnly:           true,
+	}
+	if resp := createWebhook(source, r); resp.StatusCode() != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status %d, but was %d", http.StatusUnprocessableEntity, resp.StatusCode())
+	}
+}
+
+func TestCreateOrganizationWebhook(t *testing.T) {
+	scopes := ""
+	userRequests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet || req.URL.Path != "/user" {
+			t.Errorf("Unexpected request %s %s", req.Method, req.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if auth := req.Header.Get("Authorization"); auth != "token access-token" {
+			t.Errorf("Expected the access token to be used, but Authorization header was: %s", auth)
+		}
+		userRequests++
+		w.Header().Set("X-OAuth-Scopes", scopes)
+		w.Write([]byte(`{"login": "admin"}`))
+	}))
+	defer server.Close()
+	serverURL, _ := url.Parse(server.URL)
+	defaultClient := gitHubAPIClient
+	gitHubAPIClient = &http.Client{Transport: rewriteTransport{target: serverURL}}
+	defer func() { gitHubAPIClient = defaultClient }()
+
+	tests := []struct {
+		name             string
+		repoURL          string
+		scopes           string
+		status           int
+		ownerRepo        string
+		organization     string
+		wantUserRequests int
+	}{
+		{name: "organization", repoURL: "https://github.com/myorg", scopes: "repo, admin:org_hook", status: http.StatusCreated, ownerRepo: "myorg", organization: "myorg", wantUserRequests: 1},
+		{name: "organization with trailing slash", repoURL: "https://github.com/myorg/", scopes: "admin:org", status: http.StatusCreated, ownerRepo: "myorg", organization: "myorg", wantUserRequests: 1},
+		{name: "organization without scope", repoURL: "https://github.com/myorg", scopes: "repo", status: http.StatusUnprocessableEntity, wantUserRequests: 1},
+		{name: "repository", repoURL: "https://github.com/myorg/repo", scopes: "repo", status: http.StatusCreated, ownerRepo: "myorg/repo"},
+	}
+	for _, tt := range tests {
+		r := dummyResource()
+		installNs := "default"
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "github-secret", Namespace: installNs},
+			Data:       map[string][]byte{"accessToken": []byte("access-token"), "secretToken": []byte("secret-token")},
+		}
+		if _, err := r.K8sClient.CoreV1().Secrets(installNs).Create(secret); err != nil {
+			t.Fatalf("%s: error creating token secret: %s", tt.name, err.Error())
+		}
+		scopes, userRequests = tt.scopes, 0
+
+		source := webhook{
+			Name:             "name1",
+			Namespace:        "test",
+			GitRepositoryURL: tt.repoURL,
+			AccessTokenRef:   "github-secret",
+			Pipeline:         "pipeline1",
+		}
+		if resp := createWebhook(source, r); resp.StatusCode() != tt.status {
+			t.Errorf("%s: expected status %d, but was %d", tt.name, tt.status, resp.StatusCode())
+			continue
+		}
+		if userRequests != tt.wantUserRequests {
+			t.Errorf("%s: expected %d token scope requests, but was %d", tt.name, tt.wantUserRequests, userRequests)
+		}
+		if tt.status != http.StatusCreated {
+			continue
+		}
+		ghSrc, err := r.EventSrcClient.SourcesV1alpha1().GitHubSources(installNs).Get(source.Name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("%s: GitHubSource %s was not found: %s", tt.name, source.Name, err.Error())
+		}
+		if ghSrc.Spec.OwnerAndRepository != tt.ownerRepo {
+			t.Errorf("%s: expected owner and repository %s, but was %s", tt.name, tt.ownerRepo, ghSrc.Spec.OwnerAndRepository)
+		}
+		stored, err := r.readGitHubWebhooks(context.Background(), installNs)
+		if err != nil {
+			t.Fatalf("%s: error reading webhooks: %s", tt.name, err.Error())
+		}
+		if stored["name1"].Organization != tt.organization {
+			t.Errorf("%s: expected the webhook to be stored with organization %q, but was %q", tt.name, tt.organization, stored["name1"].Organization)
+		}
+	}
+}
+
+func TestGetWebhookForEventOrganization(t *testing.T) {
+	r := dummyResource()
+	for _, hook := range []webhook{
+		{Name: "org", Namespace: "test", GitRepositoryURL: "https://github.com/myorg", AccessTokenRef: "token1", Pipeline: "pipeline1"},
+		{Name: "repo", Namespace: "test", GitRepositoryURL: "https://github.com/myorg/special", AccessTokenRef: "token1", Pipeline: "pipeline2"},
+	} {
+		if resp := createWebhook(hook, r); resp.StatusCode() != http.StatusCreated {
+			t.Fatalf("Create webhook %s returned %d, expected 201", hook.Name, resp.StatusCode())
+		}
+	}
+	for repoURL, expected := range map[string]string{
+		"https://github.com/myorg/special": "repo",
+		"https://github.com/myorg/other":   "org",
+	} {
+		hook, err := r.getWebhookForEvent(context.Background(), repoURL, "default")
+		if err != nil || hook.Name != expected {
+			t.Errorf("Expected the events of %s to be for webhook %s, but was %s (%v)", repoURL, expected, hook.Name, err)
+		}
+	}
+	// an organization webhook is not found for its repositories when managing webhooks by repository
+	if _, err := r.getGitHubWebhook(context.Background(), "https://github.com/myorg/other", "default"); err != ErrWebhookNotFound {
+		t.Errorf("Expected no webhook for the repository, but was %v", err)
+	}
+	if _, err := r.getWebhookForEvent(context.Background(), "https://github.com/otherorg/repo", "default"); err != ErrWebhookNotFound {
+		t.Errorf("Expected no webhook for another organization, but was %v", err)
+	}
+}
+
+func TestWebhookDefaultEventTypes(t *testing.T) {
+	tests := []struct {
+		name     string
+		defaults []string
+		pronly   bool
+		expected []string
+	}{
+		{name: "push default", defaults: []string{"push"}, expected: []string{"push"}},
+		{name: "pull_request default", defaults: []string{"pull_request"}, expected: []string{"pull_request"}},
+		{name: "all by default", defaults: []string{"push", "pull_request"}, expected: []string{"push", "pull_request"}},
+		{name: "request overrides default", defaults: []string{"push"}, pronly: true, expected: []string{"pull_request"}},
+	}
+	for _, tt := range tests {
+		r := dummyResource()
+		r.Defaults.DefaultEventTypes = tt.defaults
+		source := webhook{
+			Name:             "name1",
+			Namespace:        "test",
+			GitRepositoryURL: "https://github.com/owner/repo",
+			AccessTokenRef:   "token1",
+			Pipeline:         "pipeline1",
+			PROnly:           tt.pronly,
+		}
+		if resp := createWebhook(source, r); resp.StatusCode() != http.StatusCreated {
+			t.Fatalf("%s: expected status %d, but was %d", tt.name, http.StatusCreated, resp.StatusCode())
+		}
+		ghSrc, err := r.EventSrcClient.SourcesV1alpha1().GitHubSources("default").Get(source.Name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("%s: GitHubSource %s was not found: %s", tt.name, source.Name, err.Error())
+		}
+		if !reflect.DeepEqual(ghSrc.Spec.EventTypes, tt.expected) {
+			t.Errorf("%s: expected event types %v, but was %v", tt.name, tt.expected, ghSrc.Spec.EventTypes)
+		}
+		if defaults := getEnvDefaults(r, t); !reflect.DeepEqual(defaults.DefaultEventTypes, tt.defaults) {
+			t.Errorf("%s: expected the default event types %v to be returned, but was %v", tt.name, tt.defaults, defaults.DefaultEventTypes)
+		}
+	}
+}
+
+func TestWriteGitHubWebhooksConflict(t *testing.T) {
+	r := dummyResource()
+	hooks := map[string]webhook{"name1": {Name: "name1", GitRepositoryURL: "https://github.com/owner/repo"}}
+	if err := r.writeGitHubWebhooks(context.Background(), "default", map[string]webhook{}); err != nil {
+		t.Fatalf("Error writing webhooks: %s", err.Error())
+	}
+
+	// Another writer updates the ConfigMap after it is read, so the first update conflicts
+	client := r.K8sClient.(*fakek8sclientset.Clientset)
+	updates := 0
+	client.PrependReactor("update", "configmaps", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		updates++
+		if updates != 1 {
+			return false, nil, nil
+		}
+		// the tracker is used directly as the clientset is locked while its reactors run
+		gvr := corev1.SchemeGroupVersion.WithResource("configmaps")
+		obj, err := client.Tracker().Get(gvr, "default", ConfigMapName)
+		if err != nil {
+			t.Errorf("Error getting configmap: %s", err.Error())
+			return false, nil, nil
+		}
+		other := obj.(*corev1.ConfigMap).DeepCopy()
+		other.Data[defaultsConfigMapKey] = `{"dockerregistry":"other.registry"}`
+		if err := client.Tracker().Update(gvr, other, "default"); err != nil {
+			t.Errorf("Error updating configmap as the other writer: %s", err.Error())
+		}
+		return true, nil, k8serrors.NewConflict(schema.GroupResource{Resource: "configmaps"}, ConfigMapName, errors.New("the object has been modified"))
+	})
+
+	if err := r.writeGitHubWebhooks(context.Background(), "default", hooks); err != nil {
+		t.Fatalf("Expected the write to be retried after the conflict, got: %s", err.Error())
+	}
+	if updates != 2 {
+		t.Errorf("Expected a conflicting update and a retried update, got %d updates", updates)
+	}
+	configMap, err := client.CoreV1().ConfigMaps("default").Get(ConfigMapName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Error getting configmap: %s", err.Error())
+	}
+	if configMap.Data[defaultsConfigMapKey] != `{"dockerregistry":"other.registry"}` {
+		t.Errorf("Expected the other writer's defaults to be kept, got %q", configMap.Data[defaultsConfigMapKey])
+	}
+	stored, err := r.readGitHubWebhooks(context.Background(), "default")
+	if err != nil {
+		t.Fatalf("Error reading webhooks: %s", err.Error())
+	}
+	if !reflect.DeepEqual(stored, hooks) {
+		t.Errorf("Expected webhooks %+v, got %+v", hooks, stored)
+	}
+}
+
+func TestUpdateGitHubWebhooksConcurrentWriters(t *testing.T) {
+	r := dummyResource()
+	hook1 := webhook{Name: "name1", GitRepositoryURL: "https://github.com/owner/repo1"}
+	hook2 := webhook{Name: "name2", GitRepositoryURL: "https://github.com/owner/repo2"}
+	if err := r.writeGitHubWebhooks(context.Background(), "default", map[string]webhook{}); err != nil {
+		t.Fatalf("Error writing webhooks: %s", err.Error())
+	}
+
+	// Another writer adds its webhook after the first has read the webhooks, so the first writer's update conflicts
+	client := r.K8sClient.(*fakek8sclientset.Clientset)
+	updates := 0
+	client.PrependReactor("update", "configmaps", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		updates++
+		if updates != 1 {
+			return false, nil, nil
+		}
+		gvr := corev1.SchemeGroupVersion.WithResource("configmaps")
+		obj, err := client.Tracker().Get(gvr, "default", ConfigMapName)
+		if err != nil {
+			t.Errorf("Error getting configmap: %s", err.Error())
+			return false, nil, nil
+		}
+		other := obj.(*corev1.ConfigMap).DeepCopy()
+		buf, _ := json.Marshal(map[string]webhook{hook2.Name: hook2})
+		other.Data[configMapKey] = string(buf)
+		if err := client.Tracker().Update(gvr, other, "default"); err != nil {
+			t.Errorf("Error updating configmap as the other writer: %s", err.Error())
+		}
+		return true, nil, k8serrors.NewConflict(schema.GroupResource{Resource: "configmaps"}, ConfigMapName, errors.New("the object has been modified"))
+	})
+
+	mutations := 0
+	err := r.updateGitHubWebhooks(context.Background(), "default", func(webhooks map[string]webhook) error {
+		mutations++
+		webhooks[hook1.Name] = hook1
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Expected the update to be retried after the conflict, got: %s", err.Error())
+	}
+	if mutations != 2 {
+		t.Errorf("Expected the webhooks to be changed again after the conflict, got %d changes", mutations)
+	}
+	stored, err := r.readGitHubWebhooks(context.Background(), "default")
+	if err != nil {
+		t.Fatalf("Error reading webhooks: %s", err.Error())
+	}
+	expected := map[string]webhook{hook1.Name: hook1, hook2.Name: hook2}
+	if !reflect.DeepEqual(stored, expected) {
+		t.Errorf("Expected both writers' webhooks %+v, got %+v", expected, stored)
+	}
+}
+
+func TestWriteGitHubWebhooksConflictRetriesExhausted(t *testing.T) {
+	r := dummyResource()
+	r.Defaults.ConfigMapMaxRetries = 2
+	if err := r.writeGitHubWebhooks(context.Background(), "default", map[string]webhook{}); err != nil {
+		t.Fatalf("Error writing webhooks: %s", err.Error())
+	}
+	updates := 0
+	r.K8sClient.(*fakek8sclientset.Clientset).PrependReactor("update", "configmaps", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		updates++
+		return true, nil, k8serrors.NewConflict(schema.GroupResource{Resource: "configmaps"}, ConfigMapName, errors.New("the object has been modified"))
+	})
+	if err := r.writeGitHubWebhooks(context.Background(), "default", map[string]webhook{}); !k8serrors.IsConflict(err) {
+		t.Errorf("Expected a conflict error once the retries are used up, got %v", err)
+	}
+	if updates != 3 {
+		t.Errorf("Expected the update and 2 retries, got %d updates", updates)
+	}
+}
+
+func TestConfigMapTransientErrorsRetried(t *testing.T) {
+	r := dummyResource()
+	hooks := map[string]webhook{"name1": {Name: "name1", Namespace: "foo", GitRepositoryURL: "https://github.com/owner/repo"}}
+	client := r.K8sClient.(*fakek8sclientset.Clientset)
+	// The API server times out on the first read and the first update, then recovers
+	failed := map[string]bool{}
+	failOnce := func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if failed[action.GetVerb()] {
+			return false, nil, nil
+		}
+		failed[action.GetVerb()] = true
+		return true, nil, k8serrors.NewServerTimeout(schema.GroupResource{Resource: "configmaps"}, action.GetVerb(), 1)
+	}
+	client.PrependReactor("get", "configmaps", failOnce)
+	client.PrependReactor("update", "configmaps", failOnce)
+
+	if err := r.writeGitHubWebhooks(context.Background(), "default", map[string]webhook{}); err != nil {
+		t.Fatalf("Error creating the configmap: %s", err.Error())
+	}
+	if err := r.writeGitHubWebhooks(context.Background(), "default", hooks); err != nil {
+		t.Fatalf("Expected the update to be retried, got: %s", err.Error())
+	}
+	delete(failed, "get")
+	stored, err := r.readGitHubWebhooks(context.Background(), "default")
+	if err != nil {
+		t.Fatalf("Expected the read to be retried, got: %s", err.Error())
+	}
+	if !failed["get"] || !failed["update"] {
+		t.Errorf("Expected a read and an update to fail, got %v", failed)
+	}
+	if !reflect.DeepEqual(stored, hooks) {
+		t.Errorf("Expected webhooks %+v, got %+v", hooks, stored)
+	}
+}
+
+func TestWriteGitHubWebhooksWriterAnnotation(t *testing.T) {
+	r := dummyResource()
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        ConfigMapName,
+			Namespace:   "default",
+			Annotations: map[string]string{configMapWriterAnnotation: "replica-a"},
+		},
+	}
+	if _, err := r.K8sClient.CoreV1().ConfigMaps("default").Create(configMap); err != nil {
+		t.Fatalf("Error creating configmap: %s", err.Error())
+	}
+	hooks := map[string]webhook{"name1": {Name: "name1", GitRepositoryURL: "https://github.com/owner/repo"}}
+
+	r.Defaults.ConfigMapWriter = "replica-b"
+	if err := r.writeGitHubWebhooks(context.Background(), "default", hooks); err != errNotConfigMapWriter {
+		t.Errorf("Expected errNotConfigMapWriter for another replica, got %v", err)
+	}
+	if status := apiErrorStatus(errNotConfigMapWriter, http.StatusInternalServerError); status != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %d when not the writer, got %d", http.StatusServiceUnavailable, status)
+	}
+
+	r.Defaults.ConfigMapWriter = "replica-a"
+	if err := r.writeGitHubWebhooks(context.Background(), "default", hooks); err != nil {
+		t.Fatalf("Expected the named writer to write the webhooks, got %v", err)
+	}
+	stored, err := r.readGitHubWebhooks(context.Background(), "default")
+	if err != nil {
+		t.Fatalf("Error reading webhooks: %s", err.Error())
+	}
+	if !reflect.DeepEqual(stored, hooks) {
+		t.Errorf("Expected webhooks %+v, got %+v", hooks, stored)
+	}
+}
+
+func listWebhooks(query string, r *Resource, t *testing.T) []webhook {
+	httpReq := dummyHTTPRequest("GET", "http://wwww.dummy.com:8080/webhooks/?"+query, nil)
+	req := dummyRestfulRequest(httpReq, "", "")
+	httpWriter := httptest.NewRecorder()
+	resp := dummyRestfulResponse(httpWriter)
+	r.getAllWebhooks(req, resp)
+	if httpWriter.Code != http.StatusOK {
+		t.Fatalf("Get webhooks with %s returned %d, expected 200: %s", query, httpWriter.Code, httpWriter.Body.String())
+	}
+	hooks := []webhook{}
+	if err := json.NewDecoder(httpWriter.Body).Decode(&hooks); err != nil {
+		t.Fatalf("Error decoding result into []webhook{}: %s", err.Error())
+	}
+	sort.Slice(hooks, func(i, j int) bool { return hooks[i].Name < hooks[j].Name })
+	return hooks
+}
+
+func TestGetAllWebhooksNamespaces(t *testing.T) {
+	r := dummyResource()
+	stored := map[string]string{"default": "hook-default", "team-a": "hook-a", "team-b": "hook-b"}
+	for namespace, name := range stored {
+		if namespace != "default" {
+			if _, err := r.K8sClient.CoreV1().Namespaces().Create(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}); err != nil {
+				t.Fatalf("Error creating namespace %s: %s", namespace, err.Error())
+			}
+		}
+		hooks := map[string]webhook{name: {Name: name, Namespace: "pipelines", GitRepositoryURL: "https://github.com/owner/" + name}}
+		if err := r.writeGitHubWebhooks(context.Background(), namespace, hooks); err != nil {
+			t.Fatalf("Error writing webhooks to %s: %s", namespace, err.Error())
+		}
+	}
+	// a namespace without webhooks
+	if _, err := r.K8sClient.CoreV1().Namespaces().Create(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "empty"}}); err != nil {
+		t.Fatalf("Error creating namespace: %s", err.Error())
+	}
+
+	// The install namespace by default, without the namespace in the result
+	hooks := listWebhooks("", r, t)
+	if len(hooks) != 1 || hooks[0].Name != "hook-default" || hooks[0].InstallNamespace != "" {
+		t.Errorf("Expected only the install namespace's webhook, got %+v", hooks)
+	}
+
+	// A single namespace
+	hooks = listWebhooks("namespace=team-a", r, t)
+	if len(hooks) != 1 || hooks[0].Name != "hook-a" || hooks[0].InstallNamespace != "team-a" {
+		t.Errorf("Expected only the webhook in team-a, got %+v", hooks)
+	}
+
+	// All namespaces, skipping those whose ConfigMap can't be read
+	r.K8sClient.(*fakek8sclientset.Clientset).PrependReactor("get", "configmaps", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetNamespace() != "team-b" {
+			return false, nil, nil
+		}
+		return true, nil, k8serrors.NewForbidden(schema.GroupResource{Resource: "configmaps"}, ConfigMapName, errors.New("access denied"))
+	})
+	hooks = listWebhooks("allnamespaces=true", r, t)
+	expected := []struct{ name, namespace string }{{"hook-a", "team-a"}, {"hook-default", "default"}}
+	if len(hooks) != len(expected) {
+		t.Fatalf("Expected %d webhooks, got %+v", len(expected), hooks)
+	}
+	for i, hook := range hooks {
+		if hook.Name != expected[i].name || hook.InstallNamespace != expected[i].namespace {
+			t.Errorf("Expected webhook %s in %s, got %+v", expected[i].name, expected[i].namespace, hook)
+		}
+	}
+}
+
+// writeTestCertificate writes a self-signed certificate for 127.0.0.1 and its key to dir
+func writeTestCertificate(dir string, t *testing.T) (string, string, *x509.Certificate) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Error generating key: %s", err.Error())
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "webhooks-extension"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Error creating certificate: %s", err.Error())
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Error parsing certificate: %s", err.Error())
+	}
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	if err := ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatalf("Error writing certificate: %s", err.Error())
+	}
+	if err := ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}), 0600); err != nil {
+		t.Fatalf("Error writing key: %s", err.Error())
+	}
+	return certFile, keyFile, cert
+}
+
+func TestServerTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "webhooks-extension-tls")
+	if err != nil {
+		t.Fatalf("Error creating temp dir: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+	certFile, keyFile, cert := writeTestCertificate(dir, t)
+
+	tlsConfig, err := ServerTLSConfig(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("Error configuring TLS: %s", err.Error())
+	}
+	container := restful.NewContainer()
+	container.Add(LivenessWebService())
+	server := httptest.NewUnstartedServer(container)
+	server.TLS = tlsConfig
+	server.StartTLS()
+	defer server.Close()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+	resp, err := client.Get(server.URL + "/liveness")
+	if err != nil {
+		t.Fatalf("Error making HTTPS request: %s", err.Error())
+	}
+	defer resp.Body.Close()
+	if resp.TLS == nil || !resp.TLS.HandshakeComplete {
+		t.Fatal("Expected the request to be served over TLS")
+	}
+	if len(resp.TLS.PeerCertificates) == 0 || !resp.TLS.PeerCertificates[0].Equal(cert) {
+		t.Error("Expected the configured certificate to be served")
+	}
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("Expected status %d, got %d", http.StatusNoContent, resp.StatusCode)
+	}
+}
+
+func TestServerTLSConfig(t *testing.T) {
+	if tlsConfig, err := ServerTLSConfig("", ""); tlsConfig != nil || err != nil {
+		t.Errorf("Expected plain HTTP when no certificate is configured, got %v, %v", tlsConfig, err)
+	}
+	if _, err := ServerTLSConfig("tls.crt", ""); err == nil {
+		t.Error("Expected an error when only a certificate is configured")
+	}
+	if _, err := ServerTLSConfig("missing.crt", "missing.key"); err == nil {
+		t.Error("Expected an error when the certificate can't be loaded")
+	}
+}
+
+func TestCORSFilter(t *testing.T) {
+	r := dummyResource()
+	r.Defaults.CORSAllowedOrigins = []string{"https://dashboard.example.com"}
+	container := restful.NewContainer()
+	container.Filter(CORSFilter(r.Defaults))
+	container.Add(ExtensionWebService(*r))
+
+	tests := []struct {
+		name      string
+		method    string
+		origin    string
+		preflight bool
+		allowed   bool
+	}{
+		{name: "allowed origin", method: http.MethodGet, origin: "https://dashboard.example.com", allowed: true},
+		{name: "disallowed origin", method: http.MethodGet, origin: "https://other.example.com"},
+		{name: "same origin", method: http.MethodGet},
+		{name: "allowed preflight", method: http.MethodOptions, origin: "https://dashboard.example.com", preflight: true, allowed: true},
+		{name: "disallowed preflight", method: http.MethodOptions, origin: "https://other.example.com", preflight: true},
+	}
+	for _, tt := range tests {
+		httpReq := dummyHTTPRequest(tt.method, "http://wwww.dummy.com:8080/webhooks", nil)
+		if tt.origin != "" {
+			httpReq.Header.Set("Origin", tt.origin)
+		}
+		if tt.preflight {
+			httpReq.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		}
+		httpWriter := httptest.NewRecorder()
+		container.ServeHTTP(httpWriter, httpReq)
+
+		allowOrigin := httpWriter.Header().Get("Access-Control-Allow-Origin")
+		if tt.allowed && allowOrigin != tt.origin {
+			t.Errorf("%s: expected Access-Control-Allow-Origin %s, got %q", tt.name, tt.origin, allowOrigin)
+		}
+		if !tt.allowed && allowOrigin != "" {
+			t.Errorf("%s: expected no Access-Control-Allow-Origin header, got %q", tt.name, allowOrigin)
+		}
+		switch {
+		case tt.preflight && tt.allowed:
+			if httpWriter.Code != http.StatusNoContent {
+				t.Errorf("%s: expected status %d, got %d", tt.name, http.StatusNoContent, httpWriter.Code)
+			}
+			if methods := httpWriter.Header().Get("Access-Control-Allow-Methods"); !strings.Contains(methods, http.MethodPost) {
+				t.Errorf("%s: expected POST to be allowed, got %q", tt.name, methods)
+			}
+			if headers := httpWriter.Header().Get("Access-Control-Allow-Headers"); !strings.Contains(headers, "Content-Type") {
+				t.Errorf("%s: expected Content-Type to be allowed, got %q", tt.name, headers)
+			}
+		case tt.preflight:
+			if methods := httpWriter.Header().Get("Access-Control-Allow-Methods"); methods != "" {
+				t.Errorf("%s: expected no Access-Control-Allow-Methods header, got %q", tt.name, methods)
+			}
+		default:
+			if httpWriter.Code != http.StatusOK {
+				t.Errorf("%s: expected status %d, got %d", tt.name, http.StatusOK, httpWriter.Code)
+			}
+		}
+	}
+}
+
+func TestRequestBodyLimit(t *testing.T) {
+	r := dummyResource()
+	r.Defaults.MaxRequestBodyBytes = 1024
+	container := restful.NewContainer()
+	container.Add(ExtensionWebService(*r))
+
+	hook := webhook{
+		Name:             "name1",
+		Namespace:        "foo",
+		GitRepositoryURL: "https://github.com/owner/repo",
+		AccessTokenRef:   "token1",
+		Pipeline:         "pipeline1",
+		DockerRegistry:   "registry1",
+	}
+	oversized := hook
+	oversized.Annotations = map[string]string{"padding": strings.Repeat("a", 2048)}
+	b, _ := json.Marshal(oversized)
+	httpWriter := httptest.NewRecorder()
+	container.ServeHTTP(httpWriter, dummyHTTPRequest("POST", "http://wwww.dummy.com:8080/webhooks/", bytes.NewBuffer(b)))
+	if httpWriter.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Create webhook with an oversized body returned %d, expected 413: %s", httpWriter.Code, httpWriter.Body.String())
+	}
+	if _, err := r.EventSrcClient.SourcesV1alpha1().GitHubSources("default").Get(hook.Name, metav1.GetOptions{}); !k8serrors.IsNotFound(err) {
+		t.Errorf("Expected no GitHubSource for the rejected webhook, but got: %v", err)
+	}
+
+	b, _ = json.Marshal(hook)
+	httpWriter = httptest.NewRecorder()
+	container.ServeHTTP(httpWriter, dummyHTTPRequest("POST", "http://wwww.dummy.com:8080/webhooks/", bytes.NewBuffer(b)))
+	if httpWriter.Code != http.StatusCreated {
+		t.Errorf("Create webhook within the body limit returned %d, expected 201: %s", httpWriter.Code, httpWriter.Body.String())
+	}
+}
+
+func TestWebhookSinks(t *testing.T) {
+	r := dummyResource()
+	installNs := "default"
+	single := webhook{
+		Name:             "name1",
+		Namespace:        "foo",
+		GitRepositoryURL: "https://github.com/owner/repo",
+		AccessTokenRef:   "token1",
+		Pipeline:         "pipeline1",
+		DockerRegistry:   "registry1",
+	}
+	scanSink := sinkReference{APIVersion: "serving.knative.dev/v1alpha1", Kind: "Service", Name: "security-scan"}
+	multi := webhook{
+		Name:             "name2",
+		Namespace:        "foo",
+		GitRepositoryURL: "https://github.com/owner/other",
+		AccessTokenRef:   "token1",
+		Pipeline:         "pipeline1",
+		DockerRegistry:   "registry1",
+		Sinks:            []sinkReference{defaultSink, scanSink},
+	}
+	for _, hook := range []webhook{single, multi} {
+		if resp := createWebhook(hook, r); resp.StatusCode() != http.StatusCreated {
+			t.Fatalf("Create webhook %s returned %d, expected 201", hook.Name, resp.StatusCode())
+		}
+	}
+
+	wantSinks := map[string]string{"name1": "webhooks-extension-sink", "name2": "webhooks-extension-sink", "name2-1": "security-scan"}
+	for name, sink := range wantSinks {
+		ghSrc, err := r.EventSrcClient.SourcesV1alpha1().GitHubSources(installNs).Get(name, metav1.GetOptions{})
+		if err != nil {
+			t.Errorf("GitHubSource %s was not found: %s", name, err.Error())
+			continue
+		}
+		if ghSrc.Spec.Sink == nil || ghSrc.Spec.Sink.Name != sink || ghSrc.Spec.Sink.Kind != "Service" {
+			t.Errorf("Expected GitHubSource %s to send events to %s, but its sink was %+v", name, sink, ghSrc.Spec.Sink)
+		}
+	}
+	list, err := r.EventSrcClient.SourcesV1alpha1().GitHubSources(installNs).List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("Error listing GitHubSources: %s", err.Error())
+	}
+	if len(list.Items) != len(wantSinks) {
+		t.Errorf("Expected %d GitHubSources, but there were %d", len(wantSinks), len(list.Items))
+	}
+	testGetAllWebhooks([]webhook{single, multi}, r, t)
+
+	if _, resp := deleteWebhooksForRepository(multi.GitRepositoryURL, r); resp.StatusCode() != http.StatusOK {
+		t.Fatalf("Expected status %d, but was %d", http.StatusOK, resp.StatusCode())
+	}
+	for _, name := range []string{"name2", "name2-1"} {
+		if _, err := r.EventSrcClient.SourcesV1alpha1().GitHubSources(installNs).Get(name, metav1.GetOptions{}); !k8serrors.IsNotFound(err) {
+			t.Errorf("Expected GitHubSource %s to be deleted, but got: %v", name, err)
+		}
+	}
+	testGitHubSource("name1", "owner/repo", "", installNs, r, t)
+}
+
+func TestWebhookSinkAPIVersion(t *testing.T) {
+	r := dummyResource()
+	r.Defaults.SinkAPIVersion = "serving.knative.dev/v1"
+	installNs := "default"
+	hook := webhook{
+		Name:             "name1",
+		Namespace:        "foo",
+		GitRepositoryURL: "https://github.com/owner/repo",
+		AccessTokenRef:   "token1",
+		Pipeline:         "pipeline1",
+		DockerRegistry:   "registry1",
+	}
+	if resp := createWebhook(hook, r); resp.StatusCode() != http.StatusCreated {
+		t.Fatalf("Create webhook returned %d, expected 201", resp.StatusCode())
+	}
+	ghSrc, err := r.EventSrcClient.SourcesV1alpha1().GitHubSources(installNs).Get("name1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("GitHubSource name1 was not found: %s", err.Error())
+	}
+	want := corev1.ObjectReference{APIVersion: "serving.knative.dev/v1", Kind: "Service", Name: "webhooks-extension-sink"}
+	if ghSrc.Spec.Sink == nil || *ghSrc.Spec.Sink != want {
+		t.Errorf("Expected the GitHubSource's sink to be %+v, but it was %+v", want, ghSrc.Spec.Sink)
+	}
+	// the version is not stored with the webhook, so it follows the configuration
+	testGetAllWebhooks([]webhook{hook}, r, t)
+}
+
+func TestWebhookSinksConflict(t *testing.T) {
+	r := dummyResource()
+	installNs := "default"
+	existing := &eventapi.GitHubSource{ObjectMeta: metav1.ObjectMeta{Name: "name1-1", Namespace: installNs}}
+	if _, err := r.EventSrcClient.SourcesV1alpha1().GitHubSources(installNs).Create(existing); err != nil {
+		t.Fatalf("Error creating GitHubSource: %s", err.Error())
+	}
+	hook := webhook{
+		Name:             "name1",
+		Namespace:        "foo",
+		GitRepositoryURL: "https://github.com/owner/repo",
+		AccessTokenRef:   "token1",
+		Pipeline:         "pipeline1",
+		DockerRegistry:   "registry1",
+		Sinks: []sinkReference{
+			defaultSink,
+			{APIVersion: "serving.knative.dev/v1alpha1", Kind: "Service", Name: "security-scan"},
+		},
+	}
+	if resp := createWebhook(hook, r); resp.StatusCode() != http.StatusConflict {
+		t.Fatalf("Create webhook returned %d, expected 409", resp.StatusCode())
+	}
+	if _, err := r.EventSrcClient.SourcesV1alpha1().GitHubSources(installNs).Get("name1", metav1.GetOptions{}); !k8serrors.IsNotFound(err) {
+		t.Errorf("Expected the GitHubSource created before the conflict to be deleted, but got: %v", err)
+	}
+	testGetAllWebhooks([]webhook{}, r, t)
+}
+
+func TestWebhookSinksValidation(t *testing.T) {
+	r := dummyResource()
+	sink := sinkReference{APIVersion: "serving.knative.dev/v1alpha1", Kind: "Service", Name: "security-scan"}
+	for _, sinks := range [][]sinkReference{
+		{{Kind: "Service", Name: "security-scan"}},
+		{sink, sink},
+	} {
+		hook := webhook{
+			Name:             "name1",
+			Namespace:        "foo",
+			GitRepositoryURL: "https://github.com/owner/repo",
+			AccessTokenRef:   "token1",
+			Pipeline:         "pipeline1",
+			DockerRegistry:   "registry1",
+			Sinks:            sinks,
+		}
+		if resp := createWebhook(hook, r); resp.StatusCode() != http.StatusUnprocessableEntity {
+			t.Errorf("Create webhook with sinks %+v returned %d, expected 422", sinks, resp.StatusCode())
+		}
+	}
+}
+
+func TestCreateWebhookValidationErrors(t *testing.T) {
+	r := dummyResource()
+	invalid := webhook{
+		Name:             "name1",
+		GitRepositoryURL: "ftp://github.com/owner/repo",
+		Pipeline:         "pipeline1",
+		DockerRegistry:   "my registry",
+		ReleaseName:      "Not_Valid",
+	}
+	httpWriter := createWebhookRecorder(invalid, r)
+	if httpWriter.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("Create webhook returned %d, expected 422: %s", httpWriter.Code, httpWriter.Body.String())
+	}
+	failures := []fieldError{}
+	if err := json.NewDecoder(httpWriter.Body).Decode(&failures); err != nil {
+		t.Fatalf("Error decoding response into []fieldError{}: %s", err.Error())
+	}
+	reported := map[string]bool{}
+	for _, failure := range failures {
+		if failure.Message == "" {
+			t.Errorf("Expected a message for field %s", failure.Field)
+		}
+		reported[failure.Field] = true
+	}
+	for _, field := range []string{"namespace", "gitrepositoryurl", "releasename", "dockerregistry", "accesstoken"} {
+		if !reported[field] {
+			t.Errorf("Expected a failure of field %s to be reported, got %+v", field, failures)
+		}
+	}
+	if len(failures) != 5 {
+		t.Errorf("Expected 5 failures, got %+v", failures)
+	}
+	testGetAllWebhooks([]webhook{}, r, t)
+}
+
+func TestSourceServiceAccount(t *testing.T) {
+	r := dummyResource()
+	r.Defaults.SourceServiceAccount = "sources"
+	installNs := "default"
+	defaulted := webhook{
+		Name:             "name1",
+		Namespace:        "foo",
+		GitRepositoryURL: "https://github.com/owner/repo",
+		AccessTokenRef:   "token1",
+		Pipeline:         "pipeline1",
+		DockerRegistry:   "registry1",
+	}
+	custom := webhook{
+		Name:                 "name2",
+		Namespace:            "foo",
+		GitRepositoryURL:     "https://github.com/owner/other",
+		AccessTokenRef:       "token1",
+		Pipeline:             "pipeline1",
+		DockerRegistry:       "registry1",
+		SourceServiceAccount: "custom-sources",
+	}
+	for _, hook := range []webhook{defaulted, custom} {
+		if resp := createWebhook(hook, r); resp.StatusCode() != http.StatusCreated {
+			t.Fatalf("Create webhook %s returned %d, expected 201", hook.Name, resp.StatusCode())
+		}
+	}
+
+	wantServiceAccounts := map[string]string{"name1": "sources", "name2": "custom-sources"}
+	for name, serviceAccount := range wantServiceAccounts {
+		ghSrc, err := r.EventSrcClient.SourcesV1alpha1().GitHubSources(installNs).Get(name, metav1.GetOptions{})
+		if err != nil {
+			t.Errorf("GitHubSource %s was not found: %s", name, err.Error())
+			continue
+		}
+		if ghSrc.Spec.ServiceAccountName != serviceAccount {
+			t.Errorf("Expected GitHubSource %s to run as %s, but it runs as %q", name, serviceAccount, ghSrc.Spec.ServiceAccountName)
+		}
+	}
+	defaulted.SourceServiceAccount = "sources"
+	testGetAllWebhooks([]webhook{defaulted, custom}, r, t)
+
+	invalid := custom
+	invalid.Name = "name3"
+	invalid.SourceServiceAccount = "Not_Valid"
+	if resp := createWebhook(invalid, r); resp.StatusCode() != http.StatusUnprocessableEntity {
+		t.Errorf("Create webhook with an invalid source service account returned %d, expected 422", resp.StatusCode())
+	}
+}
+
+func getWebhookPipelineRuns(name, query string, r *Resource) *httptest.ResponseRecorder {
+	httpReq := dummyHTTPRequest("GET", "http://wwww.dummy.com:8080/webhooks/"+name+"/pipelineruns"+query, nil)
+	req := dummyRestfulRequest(httpReq, "", name)
+	httpWriter := httptest.NewRecorder()
+	resp := dummyRestfulResponse(httpWriter)
+	r.getWebhookPipelineRuns(req, resp)
+	return httpWriter
+}
+
+func TestGetWebhookPipelineRuns(t *testing.T) {
+	r := dummyResource()
+	hook := webhook{
+		Name:             "name1",
+		Namespace:        "foo",
+		GitRepositoryURL: "https://github.com/owner/repo",
+		AccessTokenRef:   "token1",
+		Pipeline:         "pipeline1",
+		DockerRegistry:   "registry1",
+	}
+	if resp := createWebhook(hook, r); resp.StatusCode() != http.StatusCreated {
+		t.Fatalf("Create webhook returned %d, expected 201", resp.StatusCode())
+	}
+
+	start := time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC)
+	runs := []struct {
+		name    string
+		webhook string
+		started time.Duration
+		status  corev1.ConditionStatus
+	}{
+		{name: "run-oldest", webhook: "name1", started: 0, status: corev1.ConditionTrue},
+		{name: "run-newest", webhook: "name1", started: 2 * time.Hour, status: corev1.ConditionUnknown},
+		{name: "run-middle", webhook: "name1", started: time.Hour, status: corev1.ConditionFalse},
+		{name: "run-other", webhook: "name2", started: 3 * time.Hour, status: corev1.ConditionTrue},
+		{name: "run-unlabeled", started: 3 * time.Hour, status: corev1.ConditionTrue},
+	}
+	for _, run := range runs {
+		pipelineRun := &pipelinesv1alpha1.PipelineRun{
+			ObjectMeta: metav1.ObjectMeta{Name: run.name, Namespace: "foo", Labels: map[string]string{}},
+		}
+		if run.webhook != "" {
+			pipelineRun.Labels[webhookLabel] = run.webhook
+		}
+		pipelineRun.Status.StartTime = &metav1.Time{Time: start.Add(run.started)}
+		pipelineRun.Status.Conditions = duckv1alpha1.Conditions{{Type: duckv1alpha1.ConditionSucceeded, Status: run.status}}
+		if _, err := r.TektonClient.TektonV1alpha1().PipelineRuns("foo").Create(pipelineRun); err != nil {
+			t.Fatalf("Error creating PipelineRun %s: %s", run.name, err.Error())
+		}
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{query: "", want: []string{"run-newest", "run-middle", "run-oldest"}},
+		{query: "?limit=2", want: []string{"run-newest", "run-middle"}},
+		{query: "?limit=10", want: []string{"run-newest", "run-middle", "run-oldest"}},
+	}
+	for _, tt := range tests {
+		httpWriter := getWebhookPipelineRuns("name1", tt.query, r)
+		if httpWriter.Code != http.StatusOK {
+			t.Fatalf("Get PipelineRuns%s returned %d, expected 200: %s", tt.query, httpWriter.Code, httpWriter.Body.String())
+		}
+		summaries := []pipelineRunSummary{}
+		if err := json.NewDecoder(httpWriter.Body).Decode(&summaries); err != nil {
+			t.Fatalf("Error decoding result into []pipelineRunSummary{}: %s", err.Error())
+		}
+		names := []string{}
+		for _, summary := range summaries {
+			names = append(names, summary.Name)
+		}
+		if !reflect.DeepEqual(names, tt.want) {
+			t.Errorf("Get PipelineRuns%s returned %v, expected %v", tt.query, names, tt.want)
+		}
+		if len(summaries) > 0 && (summaries[0].Status != string(corev1.ConditionUnknown) || summaries[0].StartTime == nil || !summaries[0].StartTime.Time.Equal(start.Add(2*time.Hour))) {
+			t.Errorf("Expected the newest run to be running since %s, got %+v", start.Add(2*time.Hour), summaries[0])
+		}
+	}
+
+	if httpWriter := getWebhookPipelineRuns("name1", "?limit=none", r); httpWriter.Code != http.StatusBadRequest {
+		t.Errorf("Get PipelineRuns with an invalid limit returned %d, expected 400", httpWriter.Code)
+	}
+	if httpWriter := getWebhookPipelineRuns("missing", "", r); httpWriter.Code != http.StatusNotFound {
+		t.Errorf("Get PipelineRuns of a missing webhook returned %d, expected 404", httpWriter.Code)
+	}
+}
+
+func TestWebhookSecretKeys(t *testing.T) {
+	r := dummyResource()
+	installNs := "default"
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "managed-secret", Namespace: installNs},
+		Data:       map[string][]byte{"token": []byte("access-token"), "hook-secret": []byte("stored-token")},
+	}
+	if _, err := r.K8sClient.CoreV1().Secrets(installNs).Create(secret); err != nil {
+		t.Fatalf("Error creating token secret: %s", err.Error())
+	}
+	custom := webhook{
+		Name:             "name1",
+		Namespace:        "foo",
+		GitRepositoryURL: "https://github.com/owner/repo",
+		AccessTokenRef:   "managed-secret",
+		AccessTokenKey:   "token",
+		SecretTokenKey:   "hook-secret",
+		Pipeline:         "pipeline1",
+		DockerRegistry:   "registry1",
+	}
+	defaulted := webhook{
+		Name:             "name2",
+		Namespace:        "foo",
+		GitRepositoryURL: "https://github.com/owner/other",
+		AccessTokenRef:   "token1",
+		Pipeline:         "pipeline1",
+		DockerRegistry:   "registry1",
+	}
+	for _, hook := range []webhook{custom, defaulted} {
+		if resp := createWebhook(hook, r); resp.StatusCode() != http.StatusCreated {
+			t.Fatalf("Create webhook %s returned %d, expected 201", hook.Name, resp.StatusCode())
+		}
+	}
+
+	wantKeys := map[string][2]string{"name1": {"token", "hook-secret"}, "name2": {"accessToken", "secretToken"}}
+	for name, keys := range wantKeys {
+		ghSrc, err := r.EventSrcClient.SourcesV1alpha1().GitHubSources(installNs).Get(name, metav1.GetOptions{})
+		if err != nil {
+			t.Errorf("GitHubSource %s was not found: %s", name, err.Error())
+			continue
+		}
+		if key := ghSrc.Spec.AccessToken.SecretKeyRef.Key; key != keys[0] {
+			t.Errorf("Expected GitHubSource %s to read its access token from key %s, but it was %s", name, keys[0], key)
+		}
+		if key := ghSrc.Spec.SecretToken.SecretKeyRef.Key; key != keys[1] {
+			t.Errorf("Expected GitHubSource %s to read its secret token from key %s, but it was %s", name, keys[1], key)
+		}
+	}
+	testGetAllWebhooks([]webhook{custom, defaulted}, r, t)
+	if token, err := r.readSecretToken(context.Background(), custom, installNs); err != nil || token != "stored-token" {
+		t.Errorf("Expected the secret token to be read from key hook-secret, got %q: %v", token, err)
+	}
+
+	invalid := custom
+	invalid.Name = "name3"
+	invalid.GitRepositoryURL = "https://github.com/owner/third"
+	invalid.AccessTokenKey = "not a key"
+	invalid.SecretTokenKey = "not a key"
+	httpWriter := createWebhookRecorder(invalid, r)
+	if httpWriter.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("Create webhook returned %d, expected 422: %s", httpWriter.Code, httpWriter.Body.String())
+	}
+	failures := []fieldError{}
+	if err := json.NewDecoder(httpWriter.Body).Decode(&failures); err != nil {
+		t.Fatalf("Error decoding response into []fieldError{}: %s", err.Error())
+	}
+	if len(failures) != 3 {
+		t.Errorf("Expected both keys to be invalid and the same, got %+v", failures)
+	}
+}
+
+func TestReleaseNameNamesResources(t *testing.T) {
+	r := dummyResource()
+	released := webhook{
+		Name:             "name1",
+		Namespace:        "test",
+		GitRepositoryURL: "https://github.com/owner/repo",
+		AccessTokenRef:   "token1",
+		Pipeline:         "build",
+		ReleaseName:      "my-release",
+		Labels:           map[string]string{"team": "a", releaseLabel: "other"},
+	}
+	unreleased := webhook{
+		Name:             "name2",
+		Namespace:        "test",
+		GitRepositoryURL: "https://github.com/owner/other",
+		AccessTokenRef:   "token1",
+		Pipeline:         "build",
+	}
+	for _, hook := range []webhook{released, unreleased} {
+		if resp := createWebhook(hook, r); resp.StatusCode() != http.StatusCreated {
+			t.Fatalf("Create webhook %s returned %d, expected 201", hook.Name, resp.StatusCode())
+		}
+	}
+
+	// the release name labels the event source, which keeps its webhook's name
+	ghSrc, err := r.EventSrcClient.SourcesV1alpha1().GitHubSources("default").Get("name1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("GitHubSource name1 was not found: %s", err.Error())
+	}
+	expectedLabels := map[string]string{"team": "a", releaseLabel: "my-release"}
+	if !reflect.DeepEqual(ghSrc.Labels, expectedLabels) {
+		t.Errorf("Expected the GitHubSource labels %v, got %v", expectedLabels, ghSrc.Labels)
+	}
+
+	pipeline := &pipelinesv1alpha1.Pipeline{ObjectMeta: metav1.ObjectMeta{Name: "build", Namespace: "test"}}
+	if _, err := r.TektonClient.TektonV1alpha1().Pipelines("test").Create(pipeline); err != nil {
+		t.Fatalf("Error creating pipeline: %s", err.Error())
+	}
+	expectedPrefixes := map[string]string{"name1": "my-release-", "name2": "name2-"}
+	for _, hook := range []webhook{released, unreleased} {
+		buildInformation := BuildInformation{
+			REPOURL:   hook.GitRepositoryURL,
+			SHORTID:   "abc1234",
+			COMMITID:  "abc1234def5678",
+			REPONAME:  "repo",
+			TIMESTAMP: getDateTimeAsString(),
+		}
+		createPipelineRunFromWebhookData(context.Background(), buildInformation, *r)
+	}
+	runs, err := r.TektonClient.TektonV1alpha1().PipelineRuns("test").List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("Error listing pipelineruns: %s", err.Error())
+	}
+	if len(runs.Items) != 2 {
+		t.Fatalf("Expected a pipelinerun per webhook, got %d", len(runs.Items))
+	}
+	for _, run := range runs.Items {
+		prefix := expectedPrefixes[run.Labels[webhookLabel]]
+		if prefix == "" || !strings.HasPrefix(run.Name, prefix) {
+			t.Errorf("Expected the pipelinerun %s of webhook %s to be named with the prefix %q", run.Name, run.Labels[webhookLabel], prefix)
+		}
+	}
+	resources, err := r.TektonClient.TektonV1alpha1().PipelineResources("test").List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("Error listing pipelineresources: %s", err.Error())
+	}
+	for _, resource := range resources.Items {
+		if !strings.HasPrefix(resource.Name, "my-release-") && !strings.HasPrefix(resource.Name, "name2-") {
+			t.Errorf("Expected the pipelineresource %s to be named after its release or webhook", resource.Name)
+		}
+	}
+}
+
+func TestWebhookNoSecret(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider string
+		noSecret bool
+	}{
+		{name: "github with secret token", provider: providerGitHub},
+		{name: "github without secret token", provider: providerGitHub, noSecret: true},
+		{name: "gitlab with secret token", provider: providerGitLab},
+		{name: "gitlab without secret token", provider: providerGitLab, noSecret: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := dummyResource()
+			hook := webhook{
+				Name:             "name1",
+				Namespace:        "test",
+				GitRepositoryURL: "https://" + tt.provider + ".com/owner/repo",
+				AccessTokenRef:   "token1",
+				Pipeline:         "pipeline1",
+				DockerRegistry:   "registry1",
+				Provider:         tt.provider,
+				NoSecret:         tt.noSecret,
+			}
+			if resp := createWebhook(hook, r); resp.StatusCode() != http.StatusCreated {
+				t.Fatalf("Create webhook returned %d, expected 201", resp.StatusCode())
+			}
+
+			var hasSecretToken bool
+			if tt.provider == providerGitLab {
+				glSrc, err := r.DynamicClient.Resource(gitLabSourceResource).Namespace("default").Get(hook.Name, metav1.GetOptions{})
+				if err != nil {
+					t.Fatalf("GitLabSource %s was not found: %s", hook.Name, err.Error())
+				}
+				key, found, _ := unstructured.NestedString(glSrc.Object, "spec", "secretToken", "secretKeyRef", "key")
+				hasSecretToken = found
+				if found && key != defaultSecretTokenKey {
+					t.Errorf("Expected the secret token to be read from key %s, but it was %s", defaultSecretTokenKey, key)
+				}
+			} else {
+				ghSrc, err := r.EventSrcClient.SourcesV1alpha1().GitHubSources("default").Get(hook.Name, metav1.GetOptions{})
+				if err != nil {
+					t.Fatalf("GitHubSource %s was not found: %s", hook.Name, err.Error())
+				}
+				keyRef := ghSrc.Spec.SecretToken.SecretKeyRef
+				hasSecretToken = keyRef != nil
+				if keyRef != nil && (keyRef.Name != "token1" || keyRef.Key != defaultSecretTokenKey) {
+					t.Errorf("Expected the secret token to be read from token1 %s, but it was %s %s", defaultSecretTokenKey, keyRef.Name, keyRef.Key)
+				}
+			}
+			if hasSecretToken == tt.noSecret {
+				t.Errorf("Expected the source to reference a secret token: %t, but it was %t", !tt.noSecret, hasSecretToken)
+			}
+			testGetAllWebhooks([]webhook{hook}, r, t)
+
+			if tt.noSecret {
+				if httpWriter := rotateWebhookSecret(hook.Name, r); httpWriter.Code != http.StatusBadRequest {
+					t.Errorf("Rotating the secret of a webhook without one returned %d, expected 400", httpWriter.Code)
+				}
+			}
+		})
+	}
+
+	invalid := webhook{
+		Name:             "name1",
+		Namespace:        "test",
+		GitRepositoryURL: "https://github.com/owner/repo",
+		AccessTokenRef:   "token1",
+		Pipeline:         "pipeline1",
+		SecretTokenKey:   "hook-secret",
+		NoSecret:         true,
+	}
+	if resp := createWebhook(invalid, dummyResource()); resp.StatusCode() != http.StatusUnprocessableEntity {
+		t.Errorf("Create webhook with a secrettokenkey and no secret returned %d, expected 422", resp.StatusCode())
+	}
+}
+
+func sendTestDelivery(name, query string, r *Resource) *httptest.ResponseRecorder {
+	httpReq := dummyHTTPRequest("POST", "http://wwww.dummy.com:8080/webhooks/"+name+"/test"+query, nil)
+	req := dummyRestfulRequest(httpReq, "", name)
+	httpWriter := httptest.NewRecorder()
+	resp := dummyRestfulResponse(httpWriter)
+	r.sendTestDelivery(req, resp)
+	return httpWriter
+}
+
+func TestSendTestDelivery(t *testing.T) {
+	r := dummyResource()
+	tokenSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "token1", Namespace: "default"},
+		Data:       map[string][]byte{"accessToken": []byte("access-token"), "secretToken": []byte("stored-token")},
+	}
+	if _, err := r.K8sClient.CoreV1().Secrets("default").Create(tokenSecret); err != nil {
+		t.Fatalf("Error creating token secret: %s", err.Error())
+	}
+	hook := webhook{
+		Name:             "name1",
+		Namespace:        "test",
+		GitRepositoryURL: "https://github.com/owner/repo",
+		AccessTokenRef:   "token1",
+		Pipeline:         "build",
+		DockerRegistry:   "registry1",
+	}
+	if resp := createWebhook(hook, r); resp.StatusCode() != http.StatusCreated {
+		t.Fatalf("Create webhook returned %d, expected 201", resp.StatusCode())
+	}
+	pipeline := &pipelinesv1alpha1.Pipeline{ObjectMeta: metav1.ObjectMeta{Name: "build", Namespace: "test"}}
+	if _, err := r.TektonClient.TektonV1alpha1().Pipelines("test").Create(pipeline); err != nil {
+		t.Fatalf("Error creating pipeline: %s", err.Error())
+	}
+
+	// the test server stands in for the sink, handing the delivery to the sink's handler
+	var eventType, repoURL string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		eventType = req.Header.Get(githubEventParameter)
+		payload := struct {
+			Repository struct {
+				URL string `json:"url"`
+			} `json:"repository"`
+		}{}
+		json.Unmarshal(body, &payload)
+		repoURL = payload.Repository.URL
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+		r.handleWebhook(dummyRestfulRequest(req, "", ""), dummyRestfulResponse(w))
+	}))
+	defer server.Close()
+	r = updateResourceDefaults(r, EnvDefaults{Namespace: "default", SinkURL: server.URL})
+
+	httpWriter := sendTestDelivery("name1", "?sha=abc1234def5678", r)
+	if httpWriter.Code != http.StatusOK {
+		t.Fatalf("Test delivery returned %d, expected 200: %s", httpWriter.Code, httpWriter.Body.String())
+	}
+	if eventType != "push" || repoURL != hook.GitRepositoryURL {
+		t.Errorf("Expected the sink to be sent a push of %s, but it was sent a %q event of %q", hook.GitRepositoryURL, eventType, repoURL)
+	}
+	result := testDeliveryResult{}
+	if err := json.NewDecoder(httpWriter.Body).Decode(&result); err != nil {
+		t.Fatalf("Error decoding result into testDeliveryResult{}: %s", err.Error())
+	}
+	runs, err := r.TektonClient.TektonV1alpha1().PipelineRuns("test").List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("Error listing pipelineruns: %s", err.Error())
+	}
+	if len(runs.Items) != 1 {
+		t.Fatalf("Expected the test delivery to create one pipelinerun, got %d", len(runs.Items))
+	}
+	expected := testDeliveryResult{Status: http.StatusOK, PipelineRuns: []string{runs.Items[0].Name}, Created: true}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Expected the result %+v, got %+v", expected, result)
+	}
+
+	if httpWriter := sendTestDelivery("name1", "?sha=abc", r); httpWriter.Code != http.StatusBadRequest {
+		t.Errorf("Test delivery with a short sha returned %d, expected 400", httpWriter.Code)
+	}
+	if httpWriter := sendTestDelivery("missing", "", r); httpWriter.Code != http.StatusNotFound {
+		t.Errorf("Test delivery for a missing webhook returned %d, expected 404", httpWriter.Code)
+	}
+	server.Close()
+	if httpWriter := sendTestDelivery("name1", "", r); httpWriter.Code != http.StatusBadGateway {
+		t.Errorf("Test delivery to an unreachable sink returned %d, expected 502", httpWriter.Code)
+	}
+}
+
+func TestWebhookCRStorage(t *testing.T) {
+	r := dummyResource()
+	r.Defaults.WebhookStorage = webhookStorageCRD
+	hooks := []webhook{
+		{
+			Name:             "name1",
+			Namespace:        "test",
+			GitRepositoryURL: "https://github.com/owner/repo",
+			AccessTokenRef:   "token1",
+			Pipeline:         "pipeline1",
+			DockerRegistry:   "registry1",
+			Labels:           map[string]string{"team": "a"},
+		},
+		{
+			Name:             "name2",
+			Namespace:        "test",
+			GitRepositoryURL: "https://github.com/owner/other",
+			AccessTokenRef:   "token1",
+			Pipeline:         "pipeline1",
+			DockerRegistry:   "registry1",
+		},
+	}
+
+	// Create
+	for _, hook := range hooks {
+		if httpWriter := createWebhookRecorder(hook, r); httpWriter.Code != http.StatusCreated {
+			t.Fatalf("Create webhook %s returned %d, expected 201: %s", hook.Name, httpWriter.Code, httpWriter.Body.String())
+		}
+	}
+	entry, err := r.DynamicClient.Resource(webhookResource).Namespace("default").Get("name1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Webhook name1 was not found: %s", err.Error())
+	}
+	if url, _, _ := unstructured.NestedString(entry.Object, "spec", "gitrepositoryurl"); url != "https://github.com/owner/repo" {
+		t.Errorf("Expected the Webhook to store the gitrepositoryurl, got %q", url)
+	}
+	if store := entry.GetLabels()[webhookStoreLabel]; store != ConfigMapName {
+		t.Errorf("Expected the Webhook to be labelled with store %s, got %q", ConfigMapName, store)
+	}
+	if _, err := r.K8sClient.CoreV1().ConfigMaps("default").Get(ConfigMapName, metav1.GetOptions{}); !k8serrors.IsNotFound(err) {
+		t.Errorf("Expected no configmap to be written, got %v", err)
+	}
+
+	// List
+	listed := listWebhooks("", r, t)
+	if len(listed) != 2 || listed[0].Name != "name1" || listed[1].Name != "name2" {
+		t.Fatalf("Expected webhooks name1 and name2 to be listed, got %+v", listed)
+	}
+	if !reflect.DeepEqual(listed[0].Labels, hooks[0].Labels) {
+		t.Errorf("Expected the labels %v to be stored, got %v", hooks[0].Labels, listed[0].Labels)
+	}
+
+	// Get
+	hook, err := r.getGitHubWebhook(context.Background(), "https://github.com/owner/other", "default")
+	if err != nil {
+		t.Fatalf("Error getting webhook: %s", err.Error())
+	}
+	if hook.Name != "name2" {
+		t.Errorf("Expected webhook name2 for its repository, got %s", hook.Name)
+	}
+
+	// Delete
+	if httpWriter, _ := deleteWebhooksForRepository("https://github.com/owner/repo", r); httpWriter.Code != http.StatusOK {
+		t.Fatalf("Delete webhooks returned %d, expected 200: %s", httpWriter.Code, httpWriter.Body.String())
+	}
+	if _, err := r.DynamicClient.Resource(webhookResource).Namespace("default").Get("name1", metav1.GetOptions{}); !k8serrors.IsNotFound(err) {
+		t.Errorf("Expected Webhook name1 to be deleted, got %v", err)
+	}
+	stored, err := r.readGitHubWebhooks(context.Background(), "default")
+	if err != nil {
+		t.Fatalf("Error reading webhooks: %s", err.Error())
+	}
+	if _, ok := stored["name2"]; len(stored) != 1 || !ok {
+		t.Errorf("Expected only webhook name2 to be kept, got %+v", stored)
+	}
+}
+
+func TestWebhookCRStorageReleaseName(t *testing.T) {
+	r := dummyResource()
+	r.Defaults.WebhookStorage = webhookStorageCRD
+	other := dummyResource()
+	other.DynamicClient = r.DynamicClient
+	other.Defaults.WebhookStorage = webhookStorageCRD
+	other.Defaults.ConfigMapName = configMapNameForRelease("other")
+
+	hook := webhook{Name: "name1", Namespace: "test", GitRepositoryURL: "https://github.com/owner/repo"}
+	if err := r.writeGitHubWebhooks(context.Background(), "default", map[string]webhook{hook.Name: hook}); err != nil {
+		t.Fatalf("Error writing webhooks: %s", err.Error())
+	}
+	if err := other.writeGitHubWebhooks(context.Background(), "default", map[string]webhook{hook.Name: hook}); err != nil {
+		t.Fatalf("Error writing the other release's webhooks: %s", err.Error())
+	}
+	if _, err := r.DynamicClient.Resource(webhookResource).Namespace("default").Get("other-githubwebhook.name1", metav1.GetOptions{}); err != nil {
+		t.Errorf("Expected the other release's Webhook to be prefixed with its configmap name: %s", err.Error())
+	}
+
+	// Each release only sees and deletes its own webhooks
+	if err := other.writeGitHubWebhooks(context.Background(), "default", map[string]webhook{}); err != nil {
+		t.Fatalf("Error writing the other release's webhooks: %s", err.Error())
+	}
+	stored, err := r.readGitHubWebhooks(context.Background(), "default")
+	if err != nil {
+		t.Fatalf("Error reading webhooks: %s", err.Error())
+	}
+	if !reflect.DeepEqual(stored, map[string]webhook{hook.Name: hook}) {
+		t.Errorf("Expected the webhook to be kept when the other release's are deleted, got %+v", stored)
+	}
+}
+
+func TestWebhookCRStorageBatchRetry(t *testing.T) {
+	r := dummyResource()
+	r.Defaults.WebhookStorage = webhookStorageCRD
+	// The second Webhook written conflicts the first time, after the first one is stored
+	creates := 0
+	r.DynamicClient.(*fakedynamic.FakeDynamicClient).PrependReactor("create", "webhooks", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		creates++
+		if creates != 2 {
+			return false, nil, nil
+		}
+		name := action.(k8stesting.CreateAction).GetObject().(*unstructured.Unstructured).GetName()
+		return true, nil, k8serrors.NewConflict(webhookResource.GroupResource(), name, errors.New("written in between"))
+	})
+	hooks := []webhook{
+		{Name: "name1", Namespace: "test", GitRepositoryURL: "https://github.com/owner/repo", AccessTokenRef: "token1", Pipeline: "pipeline1", DockerRegistry: "registry1"},
+		{Name: "name2", Namespace: "test", GitRepositoryURL: "https://github.com/owner/other", AccessTokenRef: "token1", Pipeline: "pipeline1", DockerRegistry: "registry1"},
+	}
+	httpWriter := createWebhooksRecorder(hooks, r)
+	if httpWriter.Code != http.StatusOK {
+		t.Fatalf("Create webhooks returned %d, expected 200: %s", httpWriter.Code, httpWriter.Body.String())
+	}
+	// the Webhook stored before the conflict is the batch's own, not a conflicting one
+	for _, result := range batchResults(httpWriter, t) {
+		if result.Status != http.StatusCreated {
+			t.Errorf("Expected webhook %s to be created, got %d: %s", result.Name, result.Status, result.Error)
+		}
+	}
+	for _, hook := range hooks {
+		if _, err := r.EventSrcClient.SourcesV1alpha1().GitHubSources("default").Get(hook.Name, metav1.GetOptions{}); err != nil {
+			t.Errorf("Expected the GitHubSource of stored webhook %s to be kept, got %v", hook.Name, err)
+		}
+	}
+	stored, err := r.readGitHubWebhooks(context.Background(), "default")
+	if err != nil {
+		t.Fatalf("Error reading webhooks: %s", err.Error())
+	}
+	if len(stored) != 2 {
+		t.Errorf("Expected both webhooks to be stored, got %+v", stored)
+	}
+}
+
+func TestMigrateWebhooksToCRD(t *testing.T) {
+	r := dummyResource()
+	hooks := map[string]webhook{
+		"name1": {Name: "name1", Namespace: "test", GitRepositoryURL: "https://github.com/owner/repo", Pipeline: "pipeline1"},
+		"name2": {Name: "name2", Namespace: "test", GitRepositoryURL: "https://github.com/owner/other", Pipeline: "pipeline1"},
+	}
+	if err := r.writeGitHubWebhooks(context.Background(), "default", hooks); err != nil {
+		t.Fatalf("Error writing webhooks: %s", err.Error())
+	}
+
+	// Without the CRD storage nothing is imported
+	if err := r.MigrateWebhooksToCRD(context.Background()); err != nil {
+		t.Fatalf("Error migrating webhooks: %s", err.Error())
+	}
+	if _, err := r.DynamicClient.Resource(webhookResource).Namespace("default").Get("name1", metav1.GetOptions{}); !k8serrors.IsNotFound(err) {
+		t.Errorf("Expected no Webhook to be imported without the CRD storage, got %v", err)
+	}
+
+	// A Webhook that already exists is not replaced
+	r.Defaults.WebhookStorage = webhookStorageCRD
+	imported := webhook{Name: "name2", Namespace: "test", GitRepositoryURL: "https://github.com/owner/other", Pipeline: "pipeline2"}
+	entry, err := r.webhookCR("default", imported)
+	if err != nil {
+		t.Fatalf("Error building Webhook: %s", err.Error())
+	}
+	if _, err := r.DynamicClient.Resource(webhookResource).Namespace("default").Create(entry, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("Error creating Webhook: %s", err.Error())
+	}
+	if err := r.MigrateWebhooksToCRD(context.Background()); err != nil {
+		t.Fatalf("Error migrating webhooks: %s", err.Error())
+	}
+	stored, err := r.readGitHubWebhooks(context.Background(), "default")
+	if err != nil {
+		t.Fatalf("Error reading webhooks: %s", err.Error())
+	}
+	expected := map[string]webhook{"name1": hooks["name1"], "name2": imported}
+	if !reflect.DeepEqual(stored, expected) {
+		t.Errorf("Expected webhooks %+v, got %+v", expected, stored)
+	}
+	configMap, err := r.K8sClient.CoreV1().ConfigMaps("default").Get(ConfigMapName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Error getting configmap: %s", err.Error())
+	}
+	if _, ok := configMap.Annotations[configMapMigratedAnnotation]; !ok {
+		t.Errorf("Expected the configmap to be annotated as migrated, got %v", configMap.Annotations)
+	}
+	if _, ok := configMap.Data[configMapKey]; !ok {
+		t.Errorf("Expected the configmap's webhooks to be kept")
+	}
+
+	// The webhooks are only imported once
+	if err := r.DynamicClient.Resource(webhookResource).Namespace("default").Delete("name1", &metav1.DeleteOptions{}); err != nil {
+		t.Fatalf("Error deleting Webhook: %s", err.Error())
+	}
+	if err := r.MigrateWebhooksToCRD(context.Background()); err != nil {
+		t.Fatalf("Error migrating webhooks: %s", err.Error())
+	}
+	if _, err := r.DynamicClient.Resource(webhookResource).Namespace("default").Get("name1", metav1.GetOptions{}); !k8serrors.IsNotFound(err) {
+		t.Errorf("Expected a deleted Webhook not to be imported again, got %v", err)
+	}
+}
+
+func TestMigrateWebhooksToRelease(t *testing.T) {
+	r := dummyResource()
+	hooks := map[string]webhook{
+		"name1": {Name: "name1", Namespace: "test", GitRepositoryURL: "https://github.com/owner/repo", Pipeline: "pipeline1"},
+	}
+	if err := r.writeGitHubWebhooks(context.Background(), "default", hooks); err != nil {
+		t.Fatalf("Error writing webhooks: %s", err.Error())
+	}
+
+	// Setting a release name on the install keeps its webhooks
+	released := updateResourceDefaults(r, EnvDefaults{Namespace: "default", ConfigMapName: configMapNameForRelease("blue")})
+	if err := released.MigrateWebhooksToRelease(context.Background()); err != nil {
+		t.Fatalf("Error migrating webhooks: %s", err.Error())
+	}
+	stored, err := released.readGitHubWebhooks(context.Background(), "default")
+	if err != nil {
+		t.Fatalf("Error reading webhooks: %s", err.Error())
+	}
+	if !reflect.DeepEqual(stored, hooks) {
+		t.Errorf("Expected webhooks %+v, got %+v", hooks, stored)
+	}
+	configMap, err := r.K8sClient.CoreV1().ConfigMaps("default").Get(ConfigMapName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Error getting configmap: %s", err.Error())
+	}
+	if movedTo := configMap.Annotations[configMapMovedAnnotation]; movedTo != "blue-githubwebhook" {
+		t.Errorf("Expected the configmap to be annotated as copied to blue-githubwebhook, got %v", configMap.Annotations)
+	}
+	if _, ok := configMap.Data[configMapKey]; !ok {
+		t.Errorf("Expected the configmap's webhooks to be kept")
+	}
+
+	// Webhooks created since are not overwritten
+	created := webhook{Name: "name2", Namespace: "test", GitRepositoryURL: "https://github.com/owner/other", Pipeline: "pipeline1"}
+	if err := released.updateGitHubWebhooks(context.Background(), "default", func(webhooks map[string]webhook) error {
+		webhooks[created.Name] = created
+		return nil
+	}); err != nil {
+		t.Fatalf("Error writing webhooks: %s", err.Error())
+	}
+	if err := released.MigrateWebhooksToRelease(context.Background()); err != nil {
+		t.Fatalf("Error migrating webhooks: %s", err.Error())
+	}
+	if stored, _ := released.readGitHubWebhooks(context.Background(), "default"); len(stored) != 2 {
+		t.Errorf("Expected the webhooks written since the copy to be kept, got %+v", stored)
+	}
+
+	// Another install given a release name later does not copy the webhooks again
+	other := updateResourceDefaults(r, EnvDefaults{Namespace: "default", ConfigMapName: configMapNameForRelease("green")})
+	if err := other.MigrateWebhooksToRelease(context.Background()); err != nil {
+		t.Fatalf("Error migrating webhooks: %s", err.Error())
+	}
+	if _, err := r.K8sClient.CoreV1().ConfigMaps("default").Get("green-githubwebhook", metav1.GetOptions{}); !k8serrors.IsNotFound(err) {
+		t.Errorf("Expected no configmap to be copied for a second release, got %v", err)
+	}
+}
+
+func TestHandleWebhookSignature(t *testing.T) {
+	r := dummyResource()
+	tokenSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "token1", Namespace: "default"},
+		Data:       map[string][]byte{"accessToken": []byte("access-token"), "secretToken": []byte("stored-token")},
+	}
+	if _, err := r.K8sClient.CoreV1().Secrets("default").Create(tokenSecret); err != nil {
+		t.Fatalf("Error creating token secret: %s", err.Error())
+	}
+	for _, hook := range []webhook{
+		{Name: "signed", Namespace: "test", GitRepositoryURL: "https://github.com/owner/repo", AccessTokenRef: "token1", Pipeline: "build"},
+		{Name: "unsigned", Namespace: "test", GitRepositoryURL: "https://github.com/owner/public", AccessTokenRef: "token1", Pipeline: "build", NoSecret: true},
+	} {
+		if resp := createWebhook(hook, r); resp.StatusCode() != http.StatusCreated {
+			t.Fatalf("Create webhook %s returned %d, expected 201", hook.Name, resp.StatusCode())
+		}
+	}
+
+	push := func(repoURL string) []byte {
+		return []byte(`{"head_commit": {"id": "abc1234def5678"}, "repository": {"name": "repo", "url": "` + repoURL + `"}}`)
+	}
+	tests := []struct {
+		name     string
+		payload  []byte
+		header   string
+		value    string
+		expected int
+		runs     int
+	}{
+		{name: "sha256", payload: push("https://github.com/owner/repo"), header: "X-Hub-Signature-256", value: signPayload(sha256.New, "sha256=", push("https://github.com/owner/repo"), "stored-token"), expected: http.StatusOK, runs: 1},
+		{name: "sha1", payload: push("https://github.com/owner/repo"), header: "X-Hub-Signature", value: signPayload(sha1.New, "sha1=", push("https://github.com/owner/repo"), "stored-token"), expected: http.StatusOK, runs: 1},
+		{name: "wrong token", payload: push("https://github.com/owner/repo"), header: "X-Hub-Signature-256", value: signPayload(sha256.New, "sha256=", push("https://github.com/owner/repo"), "other-token"), expected: http.StatusUnauthorized},
+		{name: "not signed", payload: push("https://github.com/owner/repo"), expected: http.StatusUnauthorized},
+		{name: "webhook without a secret", payload: push("https://github.com/owner/public"), expected: http.StatusOK, runs: 1},
+	}
+	for _, tt := range tests {
+		// runs are named by the second they are created in, so each delivery gets a clientset of its own
+		r.TektonClient = dummyClientset()
+		pipeline := &pipelinesv1alpha1.Pipeline{ObjectMeta: metav1.ObjectMeta{Name: "build", Namespace: "test"}}
+		if _, err := r.TektonClient.TektonV1alpha1().Pipelines("test").Create(pipeline); err != nil {
+			t.Fatalf("Error creating pipeline: %s", err.Error())
+		}
+		httpReq := dummyHTTPRequest("POST", "http://wwww.dummy.com:8080/", bytes.NewReader(tt.payload))
+		httpReq.Header.Set(githubEventParameter, "push")
+		if tt.header != "" {
+			httpReq.Header.Set(tt.header, tt.value)
+		}
+		httpWriter := httptest.NewRecorder()
+		r.handleWebhook(dummyRestfulRequest(httpReq, "", ""), dummyRestfulResponse(httpWriter))
+		if httpWriter.Code != tt.expected {
+			t.Errorf("%s: expected status %d, but was %d", tt.name, tt.expected, httpWriter.Code)
+		}
+		runs, err := r.TektonClient.TektonV1alpha1().PipelineRuns("test").List(metav1.ListOptions{})
+		if err != nil {
+			t.Fatalf("Error listing pipelineruns: %s", err.Error())
+		}
+		if len(runs.Items) != tt.runs {
+			t.Errorf("%s: expected %d pipelineruns to be created, but was %d", tt.name, tt.runs, len(runs.Items))
+		}
 	}
 }
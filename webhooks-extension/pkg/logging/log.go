@@ -14,6 +14,7 @@ limitations under the License.
 package logging
 
 import (
+	"context"
 	"fmt"
 	"go.uber.org/zap"
 )
@@ -21,6 +22,8 @@ import (
 // Log is our logger for use elsewhere
 var Log = loggerInit()
 
+type loggerKey struct{}
+
 func loggerInit() *zap.SugaredLogger {
 	Logger := zap.NewExample().Sugar()
 	defer Logger.Sync()
@@ -30,3 +33,16 @@ func loggerInit() *zap.SugaredLogger {
 	Logger.Info("constructed a logger")
 	return Logger
 }
+
+// WithLogger returns a copy of the context carrying the given logger
+func WithLogger(ctx context.Context, logger *zap.SugaredLogger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// FromContext returns the logger carried by the context, or Log if there is none
+func FromContext(ctx context.Context) *zap.SugaredLogger {
+	if logger, ok := ctx.Value(loggerKey{}).(*zap.SugaredLogger); ok {
+		return logger
+	}
+	return Log
+}